@@ -0,0 +1,898 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/daemon"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/manifest"
+	"neobelt/internal/service"
+)
+
+// Exit codes of the server management commands
+const (
+	exitError             = 1 // the command failed
+	exitUsage             = 2 // invalid arguments, as for flag parsing errors
+	exitNotFound          = 3 // the server or registry entry doesn't exist
+	exitDockerUnavailable = 4 // Docker isn't running
+	exitNotRunning        = 5 // a server or the daemon isn't running, or a server failed its health check
+)
+
+// configPasswordEnv holds the password for export and import, so it can be scripted
+const configPasswordEnv = "NEOBELT_CONFIG_PASSWORD"
+
+// registrySecretEnv holds the password or header of `registry add`, so it can be scripted
+const registrySecretEnv = "NEOBELT_REGISTRY_SECRET"
+
+// mappingFlag collects KEY=VALUE style options given multiple times
+type mappingFlag struct {
+	values    map[string]string
+	separator string
+	splitLast bool // split at the last separator, so Windows paths like C:\data work as keys
+}
+
+func (m *mappingFlag) String() string {
+	var parts []string
+	for key, value := range m.values {
+		parts = append(parts, key+m.separator+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *mappingFlag) Set(value string) error {
+	index := strings.Index(value, m.separator)
+	if m.splitLast {
+		index = strings.LastIndex(value, m.separator)
+	}
+	if index <= 0 {
+		return fmt.Errorf("expected KEY%sVALUE, got %q", m.separator, value)
+	}
+	m.values[strings.TrimSpace(value[:index])] = strings.TrimSpace(value[index+len(m.separator):])
+	return nil
+}
+
+// runServerCommand runs a server management command, reporting false if there is none with the name
+func runServerCommand(name string, args []string) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch name {
+	case "list", "ls":
+		runListCommand(ctx, args)
+	case "status":
+		runStatusCommand(ctx, args)
+	case "install":
+		runInstallCommand(ctx, args)
+	case "create":
+		runCreateCommand(ctx, args)
+	case "start", "stop", "restart":
+		runContainerActionCommand(ctx, name, args)
+	case "rm":
+		runRemoveCommand(ctx, args)
+	case "logs":
+		runLogsCommand(ctx, args)
+	case "registry":
+		runRegistryCommand(ctx, args)
+	case "export":
+		runExportCommand(ctx, args)
+	case "import":
+		runImportCommand(ctx, args)
+	case "plan", "apply":
+		runManifestCommand(ctx, name, args)
+	case "daemon":
+		runDaemonCommand(ctx, args)
+	default:
+		return false
+	}
+	return true
+}
+
+// exitWithError prints an error and exits with the given code
+func exitWithError(code int, err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(code)
+}
+
+// newCommandFlags creates the flag set of a command with its --json flag
+func newCommandFlags(name string) (*flag.FlagSet, *bool) {
+	flags := flag.NewFlagSet("neobelt "+name, flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "Print the result as JSON")
+	return flags, jsonOutput
+}
+
+// parseCommandArgs parses flags given before, between or after the positional arguments and
+// returns the positional arguments
+func parseCommandArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newService connects to the running daemon, or loads the configuration and connects to Docker
+// itself if there is none. It exits if Docker is needed but isn't running.
+func newService(ctx context.Context, needsDocker bool) service.API {
+	// Log messages still go to the log file, the console only shows the command's output
+	logging.SetConsoleOutput(io.Discard)
+
+	var svc service.API
+	if client, err := daemon.Connect(ctx); err == nil {
+		svc = client
+	} else if svc, err = service.NewInitialized(nil); err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if needsDocker {
+		status, err := svc.CheckDockerStatus(ctx)
+		if err != nil || !status.IsRunning {
+			exitWithError(exitDockerUnavailable, fmt.Errorf("Docker is not running"))
+		}
+	}
+	return svc
+}
+
+// printJSON prints a command's result as indented JSON
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		exitWithError(exitError, fmt.Errorf("failed to encode JSON: %w", err))
+	}
+}
+
+// findServerContainer returns the managed container of a server given by container name,
+// configured server ID or (a prefix of) the container ID
+func findServerContainer(ctx context.Context, svc service.API, name string) docker.ContainerInfo {
+	containers, err := svc.GetManagedContainers(ctx)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	containerID := name
+	if servers, err := svc.GetConfiguredServers(); err == nil {
+		for _, server := range servers {
+			if server.ID == name && server.ContainerID != "" {
+				containerID = server.ContainerID
+			}
+		}
+	}
+
+	var matches []docker.ContainerInfo
+	for _, container := range containers {
+		if container.Name == name {
+			return container
+		}
+		if containerID != "" && (strings.HasPrefix(container.ID, containerID) || strings.HasPrefix(containerID, container.ID)) {
+			matches = append(matches, container)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		exitWithError(exitNotFound, fmt.Errorf("server %s not found", name))
+	case 1:
+		return matches[0]
+	}
+	exitWithError(exitUsage, fmt.Errorf("%s matches %d servers, use the container name", name, len(matches)))
+	return docker.ContainerInfo{}
+}
+
+// findConfiguredServerOf returns the configured server of a container, nil if it has none
+func findConfiguredServerOf(svc service.API, container docker.ContainerInfo) *config.ConfiguredServer {
+	servers, err := svc.GetConfiguredServers()
+	if err != nil {
+		return nil
+	}
+	for _, server := range servers {
+		if server.ContainerID != "" && (strings.HasPrefix(server.ContainerID, container.ID) || strings.HasPrefix(container.ID, server.ContainerID)) {
+			return &server
+		}
+	}
+	return nil
+}
+
+// printContainers prints containers as a table
+func printContainers(containers []docker.ContainerInfo) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSERVER\tSTATE\tPORT\tVERSION\tHEALTH\tID")
+	for _, container := range containers {
+		health := container.Health
+		if health == "" {
+			health = "-"
+		}
+		port := "-" // stdio servers have none
+		if container.Port > 0 {
+			port = strconv.Itoa(container.Port)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", container.Name, container.DisplayName, container.State, port, container.Version, health, container.ID)
+	}
+	writer.Flush()
+}
+
+// runListCommand lists the managed servers
+func runListCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("list")
+	if len(parseCommandArgs(flags, args)) > 0 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt list [--json]"))
+	}
+
+	svc := newService(ctx, true)
+	containers, err := svc.GetManagedContainers(ctx)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+	if containers == nil {
+		containers = []docker.ContainerInfo{}
+	}
+
+	if *jsonOutput {
+		printJSON(containers)
+		return
+	}
+	printContainers(containers)
+}
+
+// runStatusCommand shows whether Docker runs and how many servers are up, or checks the given
+// servers. It fails if Docker or one of the servers isn't running or isn't healthy.
+func runStatusCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("status")
+	names := parseCommandArgs(flags, args)
+
+	svc := newService(ctx, false)
+	dockerStatus, err := svc.CheckDockerStatus(ctx)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+	if !dockerStatus.IsRunning {
+		if *jsonOutput {
+			printJSON(map[string]interface{}{"docker": dockerStatus})
+		} else {
+			fmt.Println("Docker: not running")
+		}
+		os.Exit(exitDockerUnavailable)
+	}
+
+	if len(names) == 0 {
+		containers, err := svc.GetManagedContainers(ctx)
+		if err != nil {
+			exitWithError(exitError, err)
+		}
+		running := 0
+		for _, container := range containers {
+			if container.State == "running" {
+				running++
+			}
+		}
+
+		if *jsonOutput {
+			printJSON(map[string]interface{}{
+				"docker":  dockerStatus,
+				"servers": len(containers),
+				"running": running,
+			})
+			return
+		}
+		fmt.Println("Docker: running")
+		fmt.Printf("Servers: %d (%d running)\n", len(containers), running)
+		return
+	}
+
+	exitCode := 0
+	containers := make([]docker.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		container := findServerContainer(ctx, svc, name)
+		if container.State != "running" {
+			exitCode = exitNotRunning
+		} else if server := findConfiguredServerOf(svc, container); server != nil {
+			container.Health, container.HealthError, err = svc.CheckServerHealth(ctx, server.ID)
+			if err != nil {
+				container.HealthError = err.Error()
+			}
+			if container.Health == docker.HealthUnhealthy {
+				exitCode = exitNotRunning
+			}
+		}
+		containers = append(containers, container)
+	}
+
+	if *jsonOutput {
+		printJSON(containers)
+	} else {
+		printContainers(containers)
+		for _, container := range containers {
+			if container.HealthError != "" {
+				fmt.Printf("%s: %s\n", container.Name, container.HealthError)
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// runInstallCommand installs a server from the registries by name or Docker image
+func runInstallCommand(ctx context.Context, args []string) {
+	var registryName string
+	flags, jsonOutput := newCommandFlags("install")
+	flags.StringVar(&registryName, "registry", "", "Only install from the registry with this name")
+	names := parseCommandArgs(flags, args)
+	if len(names) != 1 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt install [--registry NAME] [--json] <registry-server>"))
+	}
+
+	svc := newService(ctx, true)
+	servers, err := svc.FetchAllRegistries(ctx)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	var matches []config.RegistryServer
+	for _, server := range servers {
+		if registryName != "" && !strings.EqualFold(server.SourceRegistryName, registryName) {
+			continue
+		}
+		if strings.EqualFold(server.Name, names[0]) || server.DockerImage == names[0] {
+			matches = append(matches, server)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		exitWithError(exitNotFound, fmt.Errorf("server %s not found in the registries", names[0]))
+	case len(matches) > 1:
+		var registries []string
+		for _, match := range matches {
+			registries = append(registries, match.SourceRegistryName)
+		}
+		exitWithError(exitUsage, fmt.Errorf("%s is offered by several registries (%s), choose one with --registry", names[0], strings.Join(registries, ", ")))
+	}
+	server := matches[0]
+
+	installed := findInstalledServer(svc, server.DockerImage)
+	if installed == nil || installed.Version != server.Version {
+		if !*jsonOutput {
+			fmt.Fprintf(os.Stderr, "Pulling %s\n", server.DockerImage)
+		}
+		if err := svc.InstallServer(ctx, server); err != nil {
+			exitWithError(exitError, err)
+		}
+		installed = findInstalledServer(svc, server.DockerImage)
+	}
+
+	if *jsonOutput {
+		printJSON(installed)
+		return
+	}
+	fmt.Printf("Installed %s %s as %s\n", installed.Name, installed.Version, installed.ID)
+}
+
+// findInstalledServer returns the most recently installed server with an ID, name or Docker image
+func findInstalledServer(svc service.API, name string) *config.InstalledServer {
+	servers, err := svc.GetInstalledServers()
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	var found *config.InstalledServer
+	for i := range servers {
+		server := &servers[i]
+		if server.ID == name || server.DockerImage == name || strings.EqualFold(server.Name, name) {
+			if found == nil || server.InstallDate >= found.InstallDate {
+				found = server
+			}
+		}
+	}
+	return found
+}
+
+// runCreateCommand creates and configures a container for an installed server
+func runCreateCommand(ctx context.Context, args []string) {
+	var containerName string
+	var addToClaude, sessionContainers bool
+	environment := &mappingFlag{values: make(map[string]string), separator: "="}
+	volumes := &mappingFlag{values: make(map[string]string), separator: ":", splitLast: true}
+
+	flags, jsonOutput := newCommandFlags("create")
+	flags.StringVar(&containerName, "name", "", "Container name (default: server name and timestamp)")
+	flags.Var(environment, "env", "Set an environment variable as KEY=VALUE (can be used multiple times)")
+	flags.Var(volumes, "volume", "Mount a host path as HOST_PATH:CONTAINER_PATH (can be used multiple times)")
+	flags.BoolVar(&addToClaude, "claude", false, "Add the server to the Claude Desktop configuration")
+	flags.BoolVar(&sessionContainers, "session-containers", false, "Give every client session of a stdio server its own container")
+	names := parseCommandArgs(flags, args)
+	if len(names) != 1 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt create [--name NAME] [--env KEY=VALUE] [--volume HOST:CONTAINER] [--session-containers] [--claude] [--json] <installed-server>"))
+	}
+
+	svc := newService(ctx, true)
+	installed := findInstalledServer(svc, names[0])
+	if installed == nil {
+		exitWithError(exitNotFound, fmt.Errorf("installed server %s not found, install it first", names[0]))
+	}
+
+	server, err := svc.CreateServer(ctx, installed.ID, containerName, environment.values, volumes.values, sessionContainers)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if addToClaude {
+		if err := svc.AddMCPServerToClaude(server.ContainerName, server.Port); err != nil {
+			exitWithError(exitError, err)
+		}
+	}
+
+	if *jsonOutput {
+		printJSON(server)
+		return
+	}
+	if server.Transport == config.TransportStdio {
+		fmt.Printf("Created %s, connect to it with neobelt --mcp-attach %s\n", server.ContainerName, server.ContainerName)
+		return
+	}
+	fmt.Printf("Created %s on port %d\n", server.ContainerName, server.Port)
+}
+
+// runContainerActionCommand starts, stops or restarts servers
+func runContainerActionCommand(ctx context.Context, action string, args []string) {
+	flags, jsonOutput := newCommandFlags(action)
+	names := parseCommandArgs(flags, args)
+	if len(names) == 0 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt %s [--json] <server>...", action))
+	}
+
+	svc := newService(ctx, true)
+	pastTense := map[string]string{"start": "Started", "stop": "Stopped", "restart": "Restarted"}
+	actions := map[string]func(context.Context, string) error{
+		"start":   svc.StartContainer,
+		"stop":    svc.StopContainer,
+		"restart": svc.RestartContainer,
+	}
+
+	results := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		container := findServerContainer(ctx, svc, name)
+		if err := actions[action](ctx, container.ID); err != nil {
+			exitWithError(exitError, fmt.Errorf("failed to %s %s: %w", action, container.Name, err))
+		}
+
+		results = append(results, map[string]string{"id": container.ID, "name": container.Name, "action": action})
+		if !*jsonOutput {
+			fmt.Printf("%s %s\n", pastTense[action], container.Name)
+		}
+	}
+
+	if *jsonOutput {
+		printJSON(results)
+	}
+}
+
+// runRemoveCommand removes servers with their configuration and Claude Desktop entries
+func runRemoveCommand(ctx context.Context, args []string) {
+	var force bool
+	flags, jsonOutput := newCommandFlags("rm")
+	flags.BoolVar(&force, "force", false, "Remove running servers")
+	flags.BoolVar(&force, "f", false, "Remove running servers")
+	names := parseCommandArgs(flags, args)
+	if len(names) == 0 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt rm [--force] [--json] <server>..."))
+	}
+
+	svc := newService(ctx, true)
+	results := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		container := findServerContainer(ctx, svc, name)
+		if err := svc.RemoveContainer(ctx, container.ID, force); err != nil {
+			exitWithError(exitError, fmt.Errorf("failed to remove %s: %w", container.Name, err))
+		}
+
+		results = append(results, map[string]string{"id": container.ID, "name": container.Name, "action": "rm"})
+		if !*jsonOutput {
+			fmt.Printf("Removed %s\n", container.Name)
+		}
+	}
+
+	if *jsonOutput {
+		printJSON(results)
+	}
+}
+
+// runLogsCommand prints the last log lines of a server
+func runLogsCommand(ctx context.Context, args []string) {
+	var lines int
+	flags, jsonOutput := newCommandFlags("logs")
+	flags.IntVar(&lines, "lines", 100, "Number of lines from the end of the log")
+	flags.IntVar(&lines, "n", 100, "Number of lines from the end of the log")
+	names := parseCommandArgs(flags, args)
+	if len(names) != 1 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt logs [--lines N] [--json] <server>"))
+	}
+
+	svc := newService(ctx, true)
+	container := findServerContainer(ctx, svc, names[0])
+	logs, err := svc.GetContainerLogs(ctx, container.ID, lines)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if *jsonOutput {
+		printJSON(map[string]string{"id": container.ID, "name": container.Name, "logs": logs})
+		return
+	}
+	fmt.Print(logs)
+}
+
+// runRegistryCommand manages the registries and searches their servers
+func runRegistryCommand(ctx context.Context, args []string) {
+	usage := fmt.Errorf("usage: neobelt registry add|list|search [options]")
+	if len(args) == 0 {
+		exitWithError(exitUsage, usage)
+	}
+
+	switch args[0] {
+	case "add":
+		var registry config.Registry
+		flags, jsonOutput := newCommandFlags("registry add")
+		flags.StringVar(&registry.Description, "description", "", "Description of the registry")
+		flags.StringVar(&registry.AuthType, "auth", "none", "Authentication: none, basic or header")
+		flags.StringVar(&registry.AuthUsername, "username", "", "Username for basic authentication")
+		positional := parseCommandArgs(flags, args[1:])
+		if len(positional) != 2 {
+			exitWithError(exitUsage, fmt.Errorf("usage: neobelt registry add [--description TEXT] [--auth none|basic|header] [--username USER] [--json] <name> <https-url> (password or \"Name: Value\" header from %s or stdin)", registrySecretEnv))
+		}
+		switch registry.AuthType {
+		case "none":
+		case "basic":
+			if registry.AuthUsername == "" {
+				exitWithError(exitUsage, fmt.Errorf("basic authentication needs --username"))
+			}
+			registry.AuthPassword = readSecret(registrySecretEnv, "password", true)
+		case "header":
+			registry.AuthHeader = readSecret(registrySecretEnv, "header", true)
+			if name, value, found := strings.Cut(registry.AuthHeader, ":"); !found || strings.TrimSpace(name) == "" || strings.TrimSpace(value) == "" {
+				exitWithError(exitUsage, fmt.Errorf("expected the header as \"Name: Value\""))
+			}
+		default:
+			exitWithError(exitUsage, fmt.Errorf("unknown authentication %q (expected none, basic or header)", registry.AuthType))
+		}
+		registry.Name, registry.URL = positional[0], positional[1]
+
+		svc := newService(ctx, false)
+		if err := svc.AddCustomRegistry(ctx, registry.Name, registry.URL, registry.Description, registry.AuthType, registry.AuthUsername, registry.AuthPassword, registry.AuthHeader); err != nil {
+			exitWithError(exitError, err)
+		}
+
+		if *jsonOutput {
+			printJSON(redactRegistry(registry))
+			return
+		}
+		fmt.Printf("Added registry %s\n", registry.Name)
+
+	case "list":
+		flags, jsonOutput := newCommandFlags("registry list")
+		parseCommandArgs(flags, args[1:])
+
+		svc := newService(ctx, false)
+		registries := svc.GetRegistries()
+		if *jsonOutput {
+			for i := range registries {
+				registries[i] = redactRegistry(registries[i])
+			}
+			printJSON(registries)
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tURL\tAUTH")
+		for _, registry := range registries {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", registry.Name, registry.URL, registry.AuthType)
+		}
+		writer.Flush()
+
+	case "search":
+		var registryName string
+		flags, jsonOutput := newCommandFlags("registry search")
+		flags.StringVar(&registryName, "registry", "", "Only search the registry with this name")
+		terms := parseCommandArgs(flags, args[1:])
+		query := strings.ToLower(strings.Join(terms, " "))
+
+		svc := newService(ctx, false)
+		servers, err := svc.FetchAllRegistries(ctx)
+		if err != nil {
+			exitWithError(exitError, err)
+		}
+
+		matches := []config.RegistryServer{}
+		for _, server := range servers {
+			if registryName != "" && !strings.EqualFold(server.SourceRegistryName, registryName) {
+				continue
+			}
+			text := strings.ToLower(strings.Join(append([]string{server.Name, server.Description, server.DockerImage}, server.Tags...), " "))
+			if strings.Contains(text, query) {
+				matches = append(matches, server)
+			}
+		}
+
+		if *jsonOutput {
+			printJSON(matches)
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tVERSION\tREGISTRY\tIMAGE")
+		for _, server := range matches {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", server.Name, server.Version, server.SourceRegistryName, server.DockerImage)
+		}
+		writer.Flush()
+
+	default:
+		exitWithError(exitUsage, usage)
+	}
+}
+
+// redactRegistry hides the credentials of a registry for printing
+func redactRegistry(registry config.Registry) config.Registry {
+	if registry.AuthPassword != "" {
+		registry.AuthPassword = "[REDACTED]"
+	}
+	if registry.AuthHeader != "" {
+		name, _, _ := strings.Cut(registry.AuthHeader, ":")
+		registry.AuthHeader = name + ": [REDACTED]"
+	}
+	return registry
+}
+
+// readConfigPassword returns the password for export and import from NEOBELT_CONFIG_PASSWORD or
+// the first line of stdin
+func readConfigPassword(stdinAvailable bool) string {
+	return readSecret(configPasswordEnv, "password", stdinAvailable)
+}
+
+// readSecret returns a secret from an environment variable or the first line of stdin, so it
+// doesn't show up in the shell history or process list
+func readSecret(envName, what string, stdinAvailable bool) string {
+	if secret := os.Getenv(envName); secret != "" {
+		return secret
+	}
+	if !stdinAvailable {
+		exitWithError(exitUsage, fmt.Errorf("set %s when the configuration is read from stdin", envName))
+	}
+
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		exitWithError(exitError, fmt.Errorf("failed to read %s: %w", what, err))
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		exitWithError(exitUsage, fmt.Errorf("%s cannot be empty", what))
+	}
+	return secret
+}
+
+// runExportCommand writes the encrypted configuration to a file or stdout
+func runExportCommand(ctx context.Context, args []string) {
+	var output string
+	flags, jsonOutput := newCommandFlags("export")
+	flags.StringVar(&output, "output", "", "Write the configuration to this file instead of stdout")
+	flags.StringVar(&output, "o", "", "Write the configuration to this file instead of stdout")
+	if len(parseCommandArgs(flags, args)) > 0 || (*jsonOutput && output == "") {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt export [--output FILE [--json]] (password from %s or stdin)", configPasswordEnv))
+	}
+
+	svc := newService(ctx, false)
+	encryptedData, err := svc.EncryptConfiguration(readConfigPassword(true))
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if output == "" {
+		os.Stdout.Write(encryptedData)
+		return
+	}
+	if err := os.WriteFile(output, encryptedData, 0600); err != nil {
+		exitWithError(exitError, fmt.Errorf("failed to write export file: %w", err))
+	}
+
+	if *jsonOutput {
+		printJSON(map[string]string{"path": output})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Exported configuration to %s\n", output)
+}
+
+// runImportCommand replaces the configuration with an exported one, keeping a backup
+func runImportCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("import")
+	files := parseCommandArgs(flags, args)
+	if len(files) != 1 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt import [--json] <file|-> (password from %s or stdin)", configPasswordEnv))
+	}
+
+	var encryptedData []byte
+	var err error
+	if files[0] == "-" {
+		encryptedData, err = io.ReadAll(os.Stdin)
+	} else {
+		encryptedData, err = os.ReadFile(files[0])
+	}
+	if err != nil {
+		exitWithError(exitError, fmt.Errorf("failed to read import file: %w", err))
+	}
+	password := readConfigPassword(files[0] != "-")
+
+	svc := newService(ctx, false)
+	if err := svc.ImportConfiguration(string(encryptedData), password); err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if *jsonOutput {
+		printJSON(map[string]string{"config_path": svc.GetConfigPath()})
+		return
+	}
+	fmt.Printf("Imported configuration into %s\n", svc.GetConfigPath())
+}
+
+// runManifestCommand shows the changes needed to converge on a manifest or applies them
+func runManifestCommand(ctx context.Context, command string, args []string) {
+	var file string
+	flags, jsonOutput := newCommandFlags(command)
+	flags.StringVar(&file, "file", manifest.DefaultFile, "Manifest to read, - for stdin")
+	flags.StringVar(&file, "f", manifest.DefaultFile, "Manifest to read, - for stdin")
+	if len(parseCommandArgs(flags, args)) > 0 {
+		exitWithError(exitUsage, fmt.Errorf("usage: neobelt %s [--file neobelt.yaml] [--json]", command))
+	}
+
+	m, err := manifest.Load(file)
+	if err != nil {
+		exitWithError(exitUsage, err)
+	}
+
+	svc := newService(ctx, true)
+	var plan *manifest.Plan
+	if command == "plan" {
+		plan, err = svc.PlanManifest(ctx, *m)
+	} else {
+		plan, err = svc.ApplyManifest(ctx, *m)
+	}
+	if err != nil && plan == nil {
+		exitWithError(exitError, err)
+	}
+
+	if *jsonOutput {
+		printJSON(plan)
+	} else {
+		printPlan(command, plan)
+	}
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+}
+
+// printPlan prints the changes of a plan, or what was applied
+func printPlan(command string, plan *manifest.Plan) {
+	if !plan.HasChanges() {
+		fmt.Println("No changes, the servers match the manifest")
+		return
+	}
+
+	for _, action := range plan.Actions {
+		line := fmt.Sprintf("%s %s %s", action.Symbol(), strings.ReplaceAll(action.Kind, "-", " "), action.Target)
+		if len(action.Details) > 0 {
+			line += " (" + strings.Join(action.Details, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+
+	changes := fmt.Sprintf("%d changes", len(plan.Actions))
+	if len(plan.Actions) == 1 {
+		changes = "1 change"
+	}
+	if command == "plan" {
+		fmt.Printf("%s, run neobelt apply to make them\n", changes)
+	} else {
+		fmt.Printf("Applied %s\n", changes)
+	}
+}
+
+// runDaemonCommand runs the daemon in the foreground, starts it in the background, shows
+// whether it is running or stops it
+func runDaemonCommand(ctx context.Context, args []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "start":
+			runDaemonStartCommand(ctx, args[1:])
+		case "status":
+			runDaemonStatusCommand(ctx, args[1:])
+		case "stop":
+			runDaemonStopCommand(ctx, args[1:])
+		default:
+			exitWithError(exitUsage, fmt.Errorf("unknown daemon command %q (expected start, status or stop)", args[0]))
+		}
+		return
+	}
+
+	flags := flag.NewFlagSet("neobelt daemon", flag.ExitOnError)
+	listen := flags.String("listen", "", "Serve the control API on this localhost address instead of the Unix socket")
+	if positional := parseCommandArgs(flags, args); len(positional) > 0 {
+		exitWithError(exitUsage, fmt.Errorf("unexpected argument %q", positional[0]))
+	}
+
+	if err := daemon.Run(ctx, daemon.Options{Listen: *listen}); err != nil {
+		exitWithError(exitError, err)
+	}
+}
+
+// runDaemonStartCommand starts the daemon in the background unless it is running already
+func runDaemonStartCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("daemon start")
+	parseCommandArgs(flags, args)
+
+	client, err := daemon.Connect(ctx)
+	if err != nil {
+		if client, err = daemon.Start(ctx); err != nil {
+			exitWithError(exitError, err)
+		}
+	}
+	printDaemonStatus(ctx, client, *jsonOutput)
+}
+
+// runDaemonStatusCommand shows the running daemon, exiting with exitNotRunning if there is none
+func runDaemonStatusCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("daemon status")
+	parseCommandArgs(flags, args)
+
+	client, err := daemon.Connect(ctx)
+	if err != nil {
+		exitWithError(exitNotRunning, err)
+	}
+	printDaemonStatus(ctx, client, *jsonOutput)
+}
+
+// printDaemonStatus prints where the daemon listens and since when
+func printDaemonStatus(ctx context.Context, client *daemon.Client, jsonOutput bool) {
+	status, err := client.Status(ctx)
+	if err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if jsonOutput {
+		printJSON(status)
+		return
+	}
+	fmt.Printf("Daemon %s running (pid %d) on %s %s since %s\n", status.Version, status.PID, status.Network, status.Address, status.StartedAt.Format(time.RFC3339))
+}
+
+// runDaemonStopCommand asks the running daemon to stop
+func runDaemonStopCommand(ctx context.Context, args []string) {
+	flags, jsonOutput := newCommandFlags("daemon stop")
+	parseCommandArgs(flags, args)
+
+	client, err := daemon.Connect(ctx)
+	if err != nil {
+		exitWithError(exitNotRunning, err)
+	}
+	if err := client.Shutdown(ctx); err != nil {
+		exitWithError(exitError, err)
+	}
+
+	if *jsonOutput {
+		printJSON(map[string]bool{"stopped": true})
+		return
+	}
+	fmt.Println("Daemon stopped")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// cliTestArgsEnv makes the test binary run a server command instead of the tests, so the exit
+// code can be checked from the outside
+const cliTestArgsEnv = "NEOBELT_CLI_TEST_ARGS"
+
+// newStubDockerAPI serves just enough of the Docker API for a daemon without containers
+func newStubDockerAPI(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.45")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServerCommandExitCodes(t *testing.T) {
+	if args := os.Getenv(cliTestArgsEnv); args != "" {
+		var command []string
+		json.Unmarshal([]byte(args), &command)
+		if !runServerCommand(command[0], command[1:]) {
+			os.Exit(exitUsage)
+		}
+		os.Exit(0)
+	}
+
+	dockerAPI := newStubDockerAPI(t)
+	dockerDown := "tcp://127.0.0.1:1"
+	dockerUp := "tcp://" + strings.TrimPrefix(dockerAPI.URL, "http://")
+
+	tests := []struct {
+		name       string
+		args       []string
+		dockerHost string
+		stdin      string
+		want       int
+	}{
+		{"extra list argument", []string{"list", "extra"}, dockerUp, "", exitUsage},
+		{"logs without server", []string{"logs"}, dockerUp, "", exitUsage},
+		{"registry without subcommand", []string{"registry"}, dockerUp, "", exitUsage},
+		{"registry add with unknown auth", []string{"registry", "add", "--auth", "token", "custom", "https://example.com/registry.json"}, dockerUp, "", exitUsage},
+		{"registry add with empty password", []string{"registry", "add", "--auth", "basic", "--username", "me", "custom", "https://example.com/registry.json"}, dockerUp, "\n", exitUsage},
+		{"registry add with invalid header", []string{"registry", "add", "--auth", "header", "custom", "https://example.com/registry.json"}, dockerUp, "token\n", exitUsage},
+		{"registry add with password on stdin", []string{"registry", "add", "--auth", "basic", "--username", "me", "custom", "http://example.com/registry.json"}, dockerUp, "secret\n", exitError}, // past the password, the URL is refused
+		{"logs of unknown server", []string{"logs", "missing"}, dockerUp, "", exitNotFound},
+		{"create of unknown installed server", []string{"create", "missing"}, dockerUp, "", exitNotFound},
+		{"list without Docker", []string{"list"}, dockerDown, "", exitDockerUnavailable},
+		{"start without Docker", []string{"start", "missing"}, dockerDown, "", exitDockerUnavailable},
+		{"registry list without Docker", []string{"registry", "list", "--json"}, dockerDown, "", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configHome := t.TempDir()
+			args, _ := json.Marshal(test.args)
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestServerCommandExitCodes$")
+			cmd.Env = append(os.Environ(),
+				cliTestArgsEnv+"="+string(args),
+				"DOCKER_HOST="+test.dockerHost,
+				"XDG_CONFIG_HOME="+configHome,
+				"HOME="+configHome,
+				registrySecretEnv+"=",
+			)
+			cmd.Stdin = strings.NewReader(test.stdin)
+			output, err := cmd.CombinedOutput()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("failed to run neobelt %v: %v", test.args, err)
+			}
+			if code != test.want {
+				t.Errorf("neobelt %s: expected exit code %d, got %d\n%s", strings.Join(test.args, " "), test.want, code, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+import Modal from './Modal.js';
+import { RespondToolApproval } from '../../wailsjs/go/app/App.js';
+import { logger } from '../utils/logger.js';
+
+export class ToolApprovalModal {
+    constructor() {
+        this.modal = Modal;
+        this.queue = [];
+        this.current = null;
+    }
+
+    request(approvalRequest) {
+        this.queue.push(approvalRequest);
+        if (!this.current) {
+            this.showNext();
+        }
+    }
+
+    cancel(requestId) {
+        // The request timed out or the MCP client stopped waiting
+        this.queue = this.queue.filter(request => request.id !== requestId);
+        if (this.current && this.current.id === requestId) {
+            this.current = null;
+            this.modal.onClose = null;
+            this.modal.hide();
+            this.showNext();
+        }
+    }
+
+    showNext() {
+        this.current = this.queue.shift() || null;
+        if (!this.current) {
+            return;
+        }
+
+        const request = this.current;
+        let args = '';
+        if (request.arguments) {
+            try {
+                args = JSON.stringify(request.arguments, null, 2);
+            } catch (error) {
+                args = String(request.arguments);
+            }
+        }
+
+        const content = `
+            <div class="space-y-4">
+                <p class="text-sm text-gray-600">
+                    ${request.client ? `<span class="font-medium">${this.escapeHtml(request.client)}</span>` : 'An MCP client'}
+                    wants to run a tool that requires your approval.
+                </p>
+                <dl class="grid grid-cols-3 gap-2 text-sm">
+                    <dt class="text-gray-500">Server</dt>
+                    <dd class="col-span-2 text-gray-900 break-all">${this.escapeHtml(request.server)}</dd>
+                    <dt class="text-gray-500">Tool</dt>
+                    <dd class="col-span-2 text-gray-900 font-mono break-all">${this.escapeHtml(request.tool)}</dd>
+                    <dt class="text-gray-500">Expires</dt>
+                    <dd class="col-span-2 text-gray-900">${new Date(request.expires_at).toLocaleTimeString()}</dd>
+                </dl>
+                ${args ? `
+                    <div>
+                        <p class="text-sm text-gray-500 mb-1">Arguments</p>
+                        <pre class="text-xs bg-gray-100 rounded-md p-3 overflow-auto max-h-40">${this.escapeHtml(args)}</pre>
+                    </div>
+                ` : ''}
+                <div class="flex justify-end space-x-3">
+                    <button id="deny-tool-call" class="px-4 py-2 bg-gray-100 text-gray-700 text-sm font-medium rounded-md hover:bg-gray-200 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-gray-500">
+                        Deny
+                    </button>
+                    <button id="approve-tool-call" class="px-4 py-2 bg-blue-600 text-white text-sm font-medium rounded-md hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500">
+                        Allow
+                    </button>
+                </div>
+            </div>
+        `;
+
+        this.modal.show(content, {
+            title: 'Tool Call Needs Approval',
+            size: 'md',
+            closeButton: true,
+            backdrop: true,
+            closable: false
+        });
+
+        // Closing the modal without answering denies the call
+        this.modal.onClose = () => {
+            if (this.current === request) {
+                this.respond(false);
+            }
+        };
+
+        document.getElementById('approve-tool-call')?.addEventListener('click', () => this.respond(true));
+        document.getElementById('deny-tool-call')?.addEventListener('click', () => this.respond(false));
+    }
+
+    async respond(approved) {
+        const request = this.current;
+        if (!request) {
+            return;
+        }
+
+        this.current = null;
+        this.modal.onClose = null;
+        this.modal.hide();
+
+        try {
+            await RespondToolApproval(request.id, approved);
+        } catch (error) {
+            logger.warning('Failed to answer tool approval request:', error);
+        }
+
+        this.showNext();
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text || '';
+        return div.innerHTML;
+    }
+}
+
+// Export singleton instance
+export default new ToolApprovalModal();
@@ -6,6 +6,7 @@ import { Servers } from './pages/Servers.js';
 import { Registry } from './pages/Registry.js';
 import { Settings } from './pages/Settings.js';
 import DockerStatusModal from './components/DockerStatusModal.js';
+import ToolApprovalModal from './components/ToolApprovalModal.js';
 import { EventsOn } from '../wailsjs/runtime/runtime.js';
 import { GetAppConfig } from '../wailsjs/go/app/App.js';
 import { initLogger, logger } from './utils/logger.js';
@@ -30,6 +31,8 @@ class App {
         initLogger(AppModule);
         this.setupRoutes();
         this.setupDockerStatusListener();
+        this.setupToolApprovalListener();
+        this.setupContainerStateListener();
         await this.loadStartupPage();
         await this.render();
         // Initialize router after routes are set up
@@ -53,6 +56,28 @@ class App {
         });
     }
 
+    setupToolApprovalListener() {
+        // MCP proxies ask before running tools that require approval
+        EventsOn('tool_approval_requested', (request) => {
+            logger.debug('Tool approval requested:', JSON.stringify(request));
+            ToolApprovalModal.request(request);
+        });
+        EventsOn('tool_approval_cancelled', (requestId) => {
+            ToolApprovalModal.cancel(requestId);
+        });
+    }
+
+    setupContainerStateListener() {
+        // Docker reports container changes, so pages don't have to wait for their next refresh
+        EventsOn('container_state_changed', (data) => {
+            logger.debug('Container state changed:', JSON.stringify(data.event));
+            const page = this.pages[this.currentPage];
+            if (page && page.onContainerStateChanged) {
+                page.onContainerStateChanged(data);
+            }
+        });
+    }
+
     async loadStartupPage() {
         try {
             const appConfig = await GetAppConfig();
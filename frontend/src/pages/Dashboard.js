@@ -433,6 +433,10 @@ export class Dashboard {
         }
     }
 
+    onContainerStateChanged() {
+        this.refreshData();
+    }
+
     startAutoRefresh() {
         // Refresh every 5 seconds
         this.refreshInterval = setInterval(() => {
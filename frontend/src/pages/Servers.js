@@ -86,6 +86,14 @@ export class Servers {
         this.stopResourceUsageRefresh();
     }
 
+    onContainerStateChanged() {
+        // Don't re-render underneath an open modal
+        if (document.getElementById('modal-overlay')) {
+            return;
+        }
+        this.loadServers();
+    }
+
     attachEventListenersAfterRender() {
         attachMainEventListeners.call(this);
     }
@@ -241,7 +249,9 @@ export class Servers {
                 volumes: configuredServer.volumes || {},
                 docker_command: configuredServer.docker_command || '',
                 docker_image: configuredServer.docker_image || (installedServer?.docker_image), // Use stored image as primary source
-                server_id: configuredServer.server_id
+                server_id: configuredServer.server_id,
+                transport: configuredServer.transport || '',
+                session_containers: server.labels?.['neobelt.session-containers'] === 'true'
             };
             
             // Show the configuration form with current values
@@ -347,6 +357,9 @@ export class Servers {
         const displayDockerImage = containerConfig?.docker_image || server?.docker_image || 'Unknown';
         
         const memoryRequirement = server?.resource_requirements?.memory || '';
+
+        // Stdio servers have no port, clients attach to them through Docker
+        const isStdio = (containerConfig?.transport || server?.transport) === 'stdio';
         
         // Pre-populate environment variables
         const envVarsConfig = this.extractEnvironmentVariablesConfig(server, currentEnvironment);
@@ -489,6 +502,18 @@ export class Servers {
 /host/config:/app/config">${volumesText}</textarea>
                         <p class="text-xs text-gray-500 mt-1">One mount per line in host_path:container_path format</p>
                     </div>
+
+                    ${isStdio ? `
+                        <div>
+                            <div class="flex items-center">
+                                <input type="checkbox" id="session-containers" class="h-4 w-4 text-primary-600 focus:ring-primary-500 border-gray-300 rounded" ${containerConfig?.session_containers ? 'checked' : ''}>
+                                <label for="session-containers" class="ml-2 block text-sm text-gray-700">
+                                    New container for every client session
+                                </label>
+                            </div>
+                            <p class="text-xs text-gray-500 mt-1">This server only speaks stdio. Clients connect with <code>neobelt --mcp-attach</code> and run in this server's container, unless every session gets a container of its own.</p>
+                        </div>
+                    ` : ''}
                     
                     <div class="bg-blue-50 border border-blue-200 rounded-lg p-4" id="claude-integration-section">
                         <h4 class="font-medium text-gray-900 mb-3">Claude Desktop Integration</h4>
@@ -1399,8 +1424,12 @@ export class Servers {
                 labels: {
                     "neobelt.server-id": (installedServer?.id) || containerConfig.server_id,
                     "neobelt.server-name": (installedServer?.name) || 'Unknown Server'
-                }
+                },
+                open_stdin: (containerConfig.transport || installedServer?.transport) === 'stdio'
             };
+            if (newConfig.open_stdin && document.getElementById('session-containers')?.checked) {
+                newConfig.labels['neobelt.session-containers'] = 'true';
+            }
 
             logger.debug('Updating container configuration:', newConfig);
             
@@ -1728,8 +1757,9 @@ export class Servers {
             };
         }
         
-        // Handle port allocation starting from default port range
-        const port = await this.findAvailablePort(serverDefaults.default_port || 8000);
+        // Handle port allocation starting from default port range, stdio servers have no port
+        const isStdio = server.transport === 'stdio';
+        const port = isStdio ? 0 : await this.findAvailablePort(serverDefaults.default_port || 8000);
         const volumesText = document.getElementById('volume-mounts').value.trim();
 
         if (!containerName) {
@@ -1785,8 +1815,12 @@ export class Servers {
                 labels: {
                     "neobelt.server-id": server.id,
                     "neobelt.server-name": server.name
-                }
+                },
+                open_stdin: isStdio
             };
+            if (isStdio && document.getElementById('session-containers')?.checked) {
+                config.labels['neobelt.session-containers'] = 'true';
+            }
 
             logger.debug('Creating container with config:', config);
             const containerId = await window.go.app.App.CreateContainer(config);
@@ -6,6 +6,7 @@ export class Settings {
         this.settings = {
             general: {
                 autoStart: true,
+                backgroundDaemon: false,
                 startupPage: 'dashboard'
             },
             claude: {
@@ -39,6 +40,7 @@ export class Settings {
             const appConfig = await window.go.app.App.GetAppConfig();
             this.settings.general = {
                 autoStart: appConfig.auto_start || false,
+                backgroundDaemon: appConfig.background_daemon || false,
                 startupPage: appConfig.startup_page || 'dashboard'
             };
 
@@ -83,9 +85,11 @@ export class Settings {
     updateUI() {
         // Update general settings fields
         const generalAutoStartField = document.getElementById('generalAutoStart');
+        const generalBackgroundDaemonField = document.getElementById('generalBackgroundDaemon');
         const generalStartupPageField = document.getElementById('generalStartupPage');
 
         if (generalAutoStartField) generalAutoStartField.checked = this.settings.general.autoStart;
+        if (generalBackgroundDaemonField) generalBackgroundDaemonField.checked = this.settings.general.backgroundDaemon;
         if (generalStartupPageField) generalStartupPageField.value = this.settings.general.startupPage;
 
         // Update server settings fields
@@ -224,6 +228,17 @@ export class Settings {
                                             </label>
                                         </div>
 
+                                        <div class="flex items-center justify-between">
+                                            <div>
+                                                <label class="text-sm font-medium text-gray-700">Keep running in the background</label>
+                                                <p class="text-sm text-gray-500">Monitor servers and answer tool approvals after the window is closed. Takes effect on the next start.</p>
+                                            </div>
+                                            <label class="relative inline-flex items-center cursor-pointer">
+                                                <input id="generalBackgroundDaemon" type="checkbox" class="sr-only peer" ${this.settings.general.backgroundDaemon ? 'checked' : ''}>
+                                                <div class="w-11 h-6 bg-gray-200 peer-focus:outline-none peer-focus:ring-4 peer-focus:ring-primary-300 rounded-full peer peer-checked:after:translate-x-full peer-checked:after:border-white after:content-[''] after:absolute after:top-[2px] after:left-[2px] after:bg-white after:border-gray-300 after:border after:rounded-full after:h-5 after:w-5 after:transition-all peer-checked:bg-primary-600"></div>
+                                            </label>
+                                        </div>
+
                                         <div>
                                             <label class="block text-sm font-medium text-gray-700 mb-2">Default startup page</label>
                                             <div class="relative">
@@ -569,6 +584,7 @@ export class Settings {
         try {
             // Collect General app values from the form
             const generalAutoStart = document.getElementById('generalAutoStart')?.checked || false;
+            const generalBackgroundDaemon = document.getElementById('generalBackgroundDaemon')?.checked || false;
             const startupPage = document.getElementById('generalStartupPage')?.value || 'dashboard';
 
             // Collect Advanced settings values from the form
@@ -584,7 +600,8 @@ export class Settings {
                 startup_page: startupPage,
                 auto_start: generalAutoStart,
                 debug_mode: debugMode,
-                log_retention: logRetention
+                log_retention: logRetention,
+                background_daemon: generalBackgroundDaemon
             };
 
             // Collect Server Defaults values from the form
@@ -630,6 +647,7 @@ export class Settings {
             // Update local settings
             this.settings.general = {
                 autoStart: generalAutoStart,
+                backgroundDaemon: generalBackgroundDaemon,
                 startupPage: startupPage
             };
 
@@ -770,7 +788,8 @@ export class Settings {
                         startup_page: "dashboard",
                         auto_start: false,
                         debug_mode: false,
-                        log_retention: 30
+                        log_retention: 30,
+                        background_daemon: false
                     };
 
                     const defaultServerDefaults = {
@@ -800,6 +819,7 @@ export class Settings {
                     
                     // Update the UI - General settings
                     document.getElementById('generalAutoStart').checked = false;
+                    document.getElementById('generalBackgroundDaemon').checked = false;
                     document.getElementById('generalStartupPage').value = 'dashboard';
                     
                     // Update the UI - Advanced settings
@@ -827,6 +847,7 @@ export class Settings {
                     // Update local settings
                     this.settings.general = {
                         autoStart: false,
+                        backgroundDaemon: false,
                         startupPage: 'dashboard'
                     };
 
@@ -0,0 +1,201 @@
+package approval
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/unixsocket"
+)
+
+// DefaultTimeout is how long a tool call waits for a decision before it is denied
+const DefaultTimeout = 2 * time.Minute
+
+// ErrNoApprover is returned when neither the Neobelt app nor `neobelt approve` is running
+var ErrNoApprover = errors.New("no approver is running")
+
+// Request asks the user whether an MCP tool call may run
+type Request struct {
+	ID          string          `json:"id"`
+	Server      string          `json:"server"`
+	Tool        string          `json:"tool"`
+	Arguments   json.RawMessage `json:"arguments,omitempty"`
+	Client      string          `json:"client,omitempty"`
+	RequestedAt time.Time       `json:"requested_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+}
+
+// Decision is the user's answer to a Request
+type Decision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Handler decides on a request. The context is cancelled when the requester gives up waiting.
+type Handler func(ctx context.Context, request Request) Decision
+
+// SocketPath returns the path of the local socket approvers listen on
+func SocketPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "approval.sock"), nil
+}
+
+// Listen serves approval requests on the local socket until the context is cancelled
+func Listen(ctx context.Context, handler Handler) error {
+	socketPath, err := SocketPath()
+	if err != nil {
+		return err
+	}
+
+	// A socket file nobody answers on is left over from a crashed approver
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("another approver is already listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	// Only the current user may ask for (or fake) approvals
+	listener, err := unixsocket.Listen(socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on approval socket: %w", err)
+	}
+	defer os.Remove(socketPath)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept approval request: %w", err)
+		}
+		go serveConn(ctx, conn, handler)
+	}
+}
+
+// serveConn answers the single request sent on a connection
+func serveConn(ctx context.Context, conn net.Conn, handler Handler) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+
+	var request Request
+	if err := json.Unmarshal(line, &request); err != nil {
+		return
+	}
+
+	// The requester closing the connection means it stopped waiting
+	ctx, cancel := context.WithDeadline(ctx, request.ExpiresAt)
+	defer cancel()
+	go func() {
+		reader.ReadByte()
+		cancel()
+	}()
+
+	decision := handler(ctx, request)
+	if ctx.Err() != nil {
+		return
+	}
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return
+	}
+	conn.Write(append(data, '\n'))
+}
+
+// Ask sends a request to the running approver and waits for the decision. Without an approver
+// it falls back to prompting on the terminal, if there is one.
+func Ask(ctx context.Context, request Request, timeout time.Duration) (Decision, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if request.ID == "" {
+		request.ID = newRequestID()
+	}
+	request.RequestedAt = time.Now()
+	request.ExpiresAt = request.RequestedAt.Add(timeout)
+
+	decision, err := askApprover(ctx, request)
+	if errors.Is(err, ErrNoApprover) {
+		decision, err = PromptTerminal(ctx, request)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return Decision{}, fmt.Errorf("no decision within %v", timeout)
+	}
+	return decision, err
+}
+
+// askApprover sends a request over the local socket
+func askApprover(ctx context.Context, request Request) (Decision, error) {
+	socketPath, err := SocketPath()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return Decision{}, ErrNoApprover
+	}
+	defer conn.Close()
+
+	// Closing the connection tells the approver to stop asking
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to marshal approval request: %w", err)
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return Decision{}, fmt.Errorf("failed to send approval request: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		if ctx.Err() != nil {
+			return Decision{}, ctx.Err()
+		}
+		return Decision{}, fmt.Errorf("approver closed the connection without a decision")
+	}
+
+	var decision Decision
+	if err := json.Unmarshal(line, &decision); err != nil {
+		return Decision{}, fmt.Errorf("failed to parse approval decision: %w", err)
+	}
+	return decision, nil
+}
+
+// newRequestID returns a random ID for a request
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,178 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempConfigDir points the socket to a fresh config directory and makes sure no test ever
+// prompts on the terminal of whoever runs it
+func useTempConfigDir(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	previous := openTerminal
+	openTerminal = func() (io.ReadCloser, io.WriteCloser, error) {
+		return nil, nil, errors.New("no terminal")
+	}
+	t.Cleanup(func() { openTerminal = previous })
+}
+
+// startApprover runs Listen with the handler until the test ends
+func startApprover(t *testing.T, handler Handler) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Listen(ctx, handler)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("approver failed: %v", err)
+		}
+	})
+
+	socketPath, err := SocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("approver did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAskGetsDecisionFromApprover(t *testing.T) {
+	useTempConfigDir(t)
+
+	requests := make(chan Request, 2)
+	startApprover(t, func(ctx context.Context, request Request) Decision {
+		requests <- request
+		if request.Tool == "issue_search" {
+			return Decision{Approved: true}
+		}
+		return Decision{Approved: false, Reason: "too dangerous"}
+	})
+
+	decision, err := Ask(context.Background(), Request{
+		Server:    "jira",
+		Tool:      "issue_search",
+		Arguments: json.RawMessage(`{"query":"bug"}`),
+		Client:    "claude-ai",
+	}, time.Minute)
+	if err != nil || !decision.Approved {
+		t.Fatalf("expected the call to be approved, got %+v (%v)", decision, err)
+	}
+
+	request := <-requests
+	if request.ID == "" || request.Server != "jira" || request.Client != "claude-ai" || string(request.Arguments) != `{"query":"bug"}` {
+		t.Errorf("expected the request to reach the approver unchanged, got %+v", request)
+	}
+	if expires := request.ExpiresAt.Sub(request.RequestedAt); expires != time.Minute {
+		t.Errorf("expected the request to expire after the timeout, got %v", expires)
+	}
+
+	decision, err = Ask(context.Background(), Request{Server: "jira", Tool: "issue_delete"}, time.Minute)
+	if err != nil || decision.Approved || decision.Reason != "too dangerous" {
+		t.Fatalf("expected the call to be denied with the approver's reason, got %+v (%v)", decision, err)
+	}
+}
+
+func TestListenRefusesSecondApprover(t *testing.T) {
+	useTempConfigDir(t)
+	startApprover(t, func(ctx context.Context, request Request) Decision {
+		return Decision{Approved: true}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Listen(ctx, nil); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Fatalf("expected a second approver to be refused, got %v", err)
+	}
+}
+
+func TestAskTimesOutAndCancelsApprover(t *testing.T) {
+	useTempConfigDir(t)
+
+	cancelled := make(chan error, 1)
+	startApprover(t, func(ctx context.Context, request Request) Decision {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return Decision{Approved: true}
+	})
+
+	decision, err := Ask(context.Background(), Request{Server: "jira", Tool: "issue_delete"}, 200*time.Millisecond)
+	if err == nil || decision.Approved || !strings.Contains(err.Error(), "no decision within") {
+		t.Fatalf("expected the request to time out, got %+v (%v)", decision, err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the approver to stop waiting once the request expired")
+	}
+}
+
+func TestRequesterDisconnectCancelsApprover(t *testing.T) {
+	useTempConfigDir(t)
+
+	asked := make(chan struct{})
+	cancelled := make(chan error, 1)
+	startApprover(t, func(ctx context.Context, request Request) Decision {
+		close(asked)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return Decision{Approved: true}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan error, 1)
+	go func() {
+		_, err := Ask(ctx, Request{Server: "jira", Tool: "issue_delete"}, time.Hour)
+		results <- err
+	}()
+
+	<-asked
+	cancel()
+	if err := <-results; err == nil {
+		t.Fatal("expected a cancelled request to fail")
+	}
+
+	// Long before the request expires, the approver learns the requester is gone
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("expected the approver's context to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the approver to stop waiting once the requester disconnected")
+	}
+}
+
+func TestAskFailsClosedWithoutApproverOrTerminal(t *testing.T) {
+	useTempConfigDir(t)
+
+	decision, err := Ask(context.Background(), Request{Server: "jira", Tool: "issue_delete"}, time.Second)
+	if err == nil || decision.Approved {
+		t.Fatalf("expected the call to be denied without an approver, got %+v (%v)", decision, err)
+	}
+	if !strings.Contains(err.Error(), "no terminal") {
+		t.Errorf("expected the error to explain that nobody can approve, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package approval
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// terminalMutex keeps prompts of concurrent requests from interleaving
+var terminalMutex sync.Mutex
+
+// PromptTerminal asks on the controlling terminal. stdin and stdout can't be used since they
+// carry the MCP protocol when running as a proxy.
+func PromptTerminal(ctx context.Context, request Request) (Decision, error) {
+	input, output, err := openTerminal()
+	if err != nil {
+		return Decision{}, fmt.Errorf("approval required, but neither the Neobelt app nor `neobelt approve` is running and there is no terminal to ask on")
+	}
+	defer input.Close()
+	defer output.Close()
+
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+
+	return Prompt(ctx, request, input, output)
+}
+
+// Prompt shows a request and reads a yes/no answer. The reader is closed to give up
+// waiting when the context is done.
+func Prompt(ctx context.Context, request Request, input io.ReadCloser, output io.Writer) (Decision, error) {
+	fmt.Fprintf(output, "\nTool call needs approval\n")
+	fmt.Fprintf(output, "  Server:    %s\n", request.Server)
+	fmt.Fprintf(output, "  Tool:      %s\n", request.Tool)
+	if request.Client != "" {
+		fmt.Fprintf(output, "  Client:    %s\n", request.Client)
+	}
+	if len(request.Arguments) > 0 {
+		fmt.Fprintf(output, "  Arguments: %s\n", string(request.Arguments))
+	}
+	fmt.Fprintf(output, "  Expires:   %s\n", request.ExpiresAt.Format("15:04:05"))
+	fmt.Fprintf(output, "Allow? [y/N] ")
+
+	answers := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(input).ReadString('\n')
+		answers <- strings.ToLower(strings.TrimSpace(line))
+	}()
+
+	select {
+	case answer := <-answers:
+		if answer == "y" || answer == "yes" {
+			return Decision{Approved: true}, nil
+		}
+		return Decision{Approved: false, Reason: "denied by the user"}, nil
+	case <-ctx.Done():
+		input.Close()
+		fmt.Fprintf(output, "\nRequest expired or was cancelled\n")
+		return Decision{}, ctx.Err()
+	}
+}
+
+// openTerminal opens the terminal to prompt on, tests replace it to run without one
+var openTerminal = openControllingTerminal
+
+// openControllingTerminal opens the controlling terminal for reading and writing
+func openControllingTerminal() (io.ReadCloser, io.WriteCloser, error) {
+	inputPath, outputPath := "/dev/tty", "/dev/tty"
+	if runtime.GOOS == "windows" {
+		inputPath, outputPath = "CONIN$", "CONOUT$"
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := os.OpenFile(outputPath, os.O_WRONLY, 0)
+	if err != nil {
+		input.Close()
+		return nil, nil, err
+	}
+	return input, output, nil
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the audit log inside the log directory. It doesn't end in .log, so
+// log cleanup and "Clear logs" leave it alone.
+const FileName = "audit.jsonl"
+
+// Statuses of audited calls
+const (
+	StatusSuccess   = "success"    // the tool ran and returned a result
+	StatusToolError = "tool_error" // the tool ran but reported an error (isError in the result)
+	StatusError     = "error"      // the call failed with a JSON-RPC error
+	StatusRejected  = "rejected"   // the tool policy does not allow the tool
+	StatusDenied    = "denied"     // the user did not approve the call
+	StatusCancelled = "cancelled"  // the client cancelled the call
+)
+
+// redactedValue replaces the values of arguments that look like credentials
+const redactedValue = "[REDACTED]"
+
+// Limits of the arguments stored in a record, so one call with e.g. base64 file content can't
+// bloat the log. The hash always covers the complete arguments.
+const (
+	maxArgumentStringSize = 1024      // longer string values are cut
+	maxArgumentsSize      = 64 * 1024 // larger arguments are left out of the record
+)
+
+// sensitiveKeyPattern matches argument names whose values are never written to the audit log
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key|authorization|credential|private[_-]?key|cookie)`)
+
+// Record is a single audited MCP tool call
+type Record struct {
+	Time          time.Time       `json:"time"`
+	Server        string          `json:"server"`
+	Client        string          `json:"client,omitempty"`
+	Method        string          `json:"method"`
+	Tool          string          `json:"tool,omitempty"`
+	ArgumentsHash string          `json:"arguments_hash,omitempty"` // SHA-256 of the arguments as sent
+	Arguments     json.RawMessage `json:"arguments,omitempty"`      // arguments with credentials redacted
+	Status        string          `json:"status"`
+	Error         string          `json:"error,omitempty"`
+	DurationMs    int64           `json:"duration_ms"`
+}
+
+// Log appends records to the audit log. Several proxies may write to the same file, every
+// record is written with a single append.
+type Log struct {
+	path  string
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// Path returns the path of the audit log in a log directory
+func Path(logDir string) string {
+	return filepath.Join(logDir, FileName)
+}
+
+// Open opens the audit log in a log directory for appending
+func Open(logDir string) (*Log, error) {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	path := Path(logDir)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &Log{path: path, file: file}, nil
+}
+
+// Path returns the path of the audit log file
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append writes a record to the audit log
+func (l *Log) Append(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Time = record.Time.UTC()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit log
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
+
+// HashArguments returns the hex SHA-256 of the raw arguments, empty if there are none
+func HashArguments(arguments json.RawMessage) string {
+	if len(arguments) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(arguments)
+	return hex.EncodeToString(sum[:])
+}
+
+// RedactArguments replaces the values of credential-like arguments, at any depth, and cuts long
+// string values. Arguments that are still too large are left out.
+func RedactArguments(arguments json.RawMessage) json.RawMessage {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(arguments, &parsed); err != nil {
+		return nil
+	}
+
+	redacted, err := json.Marshal(redact(parsed))
+	if err != nil || len(redacted) > maxArgumentsSize {
+		return nil
+	}
+	return redacted
+}
+
+// redact walks a parsed JSON value, redacts sensitive object members and cuts long strings
+func redact(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case string:
+		if len(typed) > maxArgumentStringSize {
+			return fmt.Sprintf("%s... [%d bytes]", strings.ToValidUTF8(typed[:maxArgumentStringSize], ""), len(typed))
+		}
+	case map[string]interface{}:
+		for key, member := range typed {
+			if sensitiveKeyPattern.MatchString(key) {
+				typed[key] = redactedValue
+			} else {
+				typed[key] = redact(member)
+			}
+		}
+	case []interface{}:
+		for i, element := range typed {
+			typed[i] = redact(element)
+		}
+	}
+	return value
+}
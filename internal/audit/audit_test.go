@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactArguments(t *testing.T) {
+	long := strings.Repeat("a", 10*maxArgumentStringSize)
+	tests := []struct {
+		name      string
+		arguments string
+		want      string
+	}{
+		{name: "none", arguments: "", want: ""},
+		{name: "plain", arguments: `{"path":"/tmp","count":2}`, want: `{"count":2,"path":"/tmp"}`},
+		{name: "credentials at any depth", arguments: `{"auth":{"api_key":"k","user":"u"},"items":[{"password":"p"}]}`, want: `{"auth":{"api_key":"[REDACTED]","user":"u"},"items":[{"password":"[REDACTED]"}]}`},
+		{name: "long strings are cut", arguments: `{"path":"/tmp/file","content":"` + long + `"}`, want: `{"content":"` + long[:maxArgumentStringSize] + `... [10240 bytes]","path":"/tmp/file"}`},
+		{name: "too large after cutting", arguments: `[` + strings.Repeat(`"`+long+`",`, 100) + `"x"]`, want: ""},
+		{name: "invalid", arguments: `{`, want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(RedactArguments(json.RawMessage(test.arguments))); got != test.want {
+				t.Errorf("expected %.200q, got %.200q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestReadSkipsOversizedAndBrokenRecords(t *testing.T) {
+	dir := t.TempDir()
+	log, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := log.Append(Record{Time: started, Server: "first", Method: "tools/call", Status: StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line larger than any scanner buffer, and one that is not JSON at all
+	file, err := os.OpenFile(log.Path(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oversized, _ := json.Marshal(Record{Time: started, Server: "oversized", Method: "tools/call", Arguments: json.RawMessage(`"` + strings.Repeat("a", 20*1024*1024) + `"`)})
+	file.Write(append(oversized, '\n'))
+	file.WriteString("{broken\n")
+	file.Close()
+
+	if err := log.Append(Record{Time: started.Add(time.Second), Server: "last", Method: "tools/call", Status: StatusError}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Read(log.Path(), Query{})
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	var servers []string
+	for _, record := range records {
+		servers = append(servers, record.Server)
+	}
+	if strings.Join(servers, ",") != "first,oversized,last" {
+		t.Errorf("expected every valid record, got %v", servers)
+	}
+
+	records, err = Read(log.Path(), Query{Status: StatusError, Limit: 1})
+	if err != nil || len(records) != 1 || records[0].Server != "last" {
+		t.Errorf("expected only the matching record, got %+v (%v)", records, err)
+	}
+}
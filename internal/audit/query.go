@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Export formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// Query selects audit records. Empty fields match every record.
+type Query struct {
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Server string    `json:"server"`
+	Tool   string    `json:"tool"`
+	Client string    `json:"client"`
+	Status string    `json:"status"`
+	Limit  int       `json:"limit"` // only the newest records, 0 for all
+}
+
+// Matches reports whether a record is selected by the query
+func (q Query) Matches(record Record) bool {
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+	if q.Server != "" && record.Server != q.Server {
+		return false
+	}
+	if q.Tool != "" && record.Tool != q.Tool {
+		return false
+	}
+	if q.Client != "" && record.Client != q.Client {
+		return false
+	}
+	if q.Status != "" && record.Status != q.Status {
+		return false
+	}
+	return true
+}
+
+// Read returns the records of an audit log selected by the query, oldest first. A missing
+// log has no records, lines that can't be parsed are skipped.
+func Read(path string, query Query) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	// Lines are read without a size limit, one oversized or broken record must not hide the rest
+	records := []Record{}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		var record Record
+		if len(line) > 0 && json.Unmarshal(line, &record) == nil && query.Matches(record) {
+			records = append(records, record)
+			if query.Limit > 0 && len(records) > query.Limit {
+				records = records[1:]
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// Export writes records as JSON lines or CSV
+func Export(w io.Writer, records []Record, format string) error {
+	switch format {
+	case FormatJSONL, "":
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("failed to write audit record: %w", err)
+			}
+		}
+		return nil
+
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"time", "server", "client", "method", "tool", "arguments_hash", "arguments", "status", "error", "duration_ms"})
+		for _, record := range records {
+			writer.Write([]string{
+				record.Time.Format(time.RFC3339Nano),
+				record.Server,
+				record.Client,
+				record.Method,
+				record.Tool,
+				record.ArgumentsHash,
+				string(record.Arguments),
+				record.Status,
+				record.Error,
+				strconv.FormatInt(record.DurationMs, 10),
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write audit records: %w", err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown export format %q (expected jsonl or csv)", format)
+	}
+}
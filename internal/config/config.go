@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/spf13/viper"
@@ -26,12 +28,20 @@ type RegistryServer struct {
 	EnvironmentVariables map[string]any `json:"environment_variables"`
 	Ports                map[string]any `json:"ports"`
 	Volumes              []any          `json:"volumes"`
+	Transport            string         `json:"transport"` // TransportStdio for servers that only speak stdio
 	// Added fields to track source registry
 	SourceRegistryName string `json:"source_registry_name"`
 	SourceRegistryURL  string `json:"source_registry_url"`
 	IsOfficial         bool   `json:"is_official"`
 }
 
+// MCP transports of registry servers. Servers listen on their MCP port unless the registry
+// entry sets the stdio transport.
+const (
+	TransportHTTP  = "http"
+	TransportStdio = "stdio"
+)
+
 // Registry structure
 type Registry struct {
 	Name         string `json:"name"`
@@ -65,6 +75,9 @@ type AppConfig struct {
 	AutoStart       bool   `json:"auto_start" mapstructure:"auto_start"`
 	DebugMode       bool   `json:"debug_mode" mapstructure:"debug_mode"`
 	LogRetention    int    `json:"log_retention" mapstructure:"log_retention"` // days
+
+	// Run the service in a background daemon, so monitoring goes on when the window is closed
+	BackgroundDaemon bool `json:"background_daemon" mapstructure:"background_daemon"`
 }
 
 // ServerDefaultsConfig contains default settings for new servers
@@ -73,6 +86,9 @@ type ServerDefaultsConfig struct {
 	DefaultPort      int  `json:"default_port" mapstructure:"default_port"`
 	MaxMemoryMB      int  `json:"max_memory_mb" mapstructure:"max_memory_mb"`
 	RestartOnFailure bool `json:"restart_on_failure" mapstructure:"restart_on_failure"`
+
+	// Failed health checks in a row before a server is unhealthy and restarted, 0 for the default
+	HealthCheckFailures int `json:"health_check_failures" mapstructure:"health_check_failures"`
 }
 
 // RemoteAccessConfig contains remote access settings
@@ -109,6 +125,7 @@ type InstalledServer struct {
 	EnvironmentVariables map[string]any `json:"environment_variables" mapstructure:"environment_variables"`
 	Ports                map[string]any `json:"ports" mapstructure:"ports"`
 	Volumes              []any          `json:"volumes" mapstructure:"volumes"`
+	Transport            string         `json:"transport" mapstructure:"transport"`
 	InstallDate          string         `json:"install_date" mapstructure:"install_date"`
 	LastUpdated          string         `json:"last_updated" mapstructure:"last_updated"`
 	SourceRegistry       string         `json:"source_registry" mapstructure:"source_registry"`
@@ -127,11 +144,62 @@ type ConfiguredServer struct {
 	DockerCommand     string            `json:"docker_command" mapstructure:"docker_command"`
 	Port              int               `json:"port" mapstructure:"port"`
 	ContainerPort     int               `json:"container_port" mapstructure:"container_port"` // MCP port from registry
+	Transport         string            `json:"transport" mapstructure:"transport"`           // TransportStdio for servers without a port
 	Environment       map[string]string `json:"environment" mapstructure:"environment"`
 	Volumes           map[string]string `json:"volumes" mapstructure:"volumes"`
 	CreatedDate       string            `json:"created_date" mapstructure:"created_date"`
 	LastStarted       string            `json:"last_started" mapstructure:"last_started"`
 	AutoStart         bool              `json:"auto_start" mapstructure:"auto_start"`
+	ToolPolicy        ToolPolicy        `json:"tool_policy" mapstructure:"tool_policy"`
+}
+
+// ToolPolicy restricts which tools of a configured server MCP clients can list and call.
+// Patterns are globs as understood by path.Match, e.g. "jira_*" or "*_delete".
+type ToolPolicy struct {
+	Allow []string `json:"allow" mapstructure:"allow" yaml:"allow"` // if not empty, only matching tools are allowed
+	Deny  []string `json:"deny" mapstructure:"deny" yaml:"deny"`    // matching tools are never allowed, even if allowed above
+
+	// Calls of matching tools wait for the user to approve them
+	RequireApproval []string `json:"require_approval" mapstructure:"require_approval" yaml:"require_approval"`
+}
+
+// IsEmpty reports whether the policy allows every tool without approval
+func (tp ToolPolicy) IsEmpty() bool {
+	return len(tp.Allow) == 0 && len(tp.Deny) == 0 && len(tp.RequireApproval) == 0
+}
+
+// Allows reports whether a tool may be listed and called
+func (tp ToolPolicy) Allows(tool string) bool {
+	if len(tp.Allow) > 0 && !matchesAny(tp.Allow, tool) {
+		return false
+	}
+	return !matchesAny(tp.Deny, tool)
+}
+
+// NeedsApproval reports whether calls of a tool have to be approved by the user
+func (tp ToolPolicy) NeedsApproval(tool string) bool {
+	return matchesAny(tp.RequireApproval, tool)
+}
+
+// Validate checks that all patterns are valid globs
+func (tp ToolPolicy) Validate() error {
+	patterns := append(append(append([]string{}, tp.Allow...), tp.Deny...), tp.RequireApproval...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesAny reports whether a name matches one of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
 }
 
 // ConfigManager handles configuration persistence
@@ -141,18 +209,28 @@ type ConfigManager struct {
 	config     *Configuration
 }
 
-// NewConfigManager creates a new configuration manager
-func NewConfigManager() (*ConfigManager, error) {
+// GetConfigDir returns the Neobelt config directory, creating it if needed
+func GetConfigDir() (string, error) {
 	// Get user config directory
 	configDir, err := os.UserConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user config directory: %w", err)
+		return "", fmt.Errorf("failed to get user config directory: %w", err)
 	}
 
 	// Create neobelt config directory
 	neobeltConfigDir := filepath.Join(configDir, "com.dnnspaul.neobelt")
 	if err := os.MkdirAll(neobeltConfigDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return neobeltConfigDir, nil
+}
+
+// NewConfigManager creates a new configuration manager
+func NewConfigManager() (*ConfigManager, error) {
+	neobeltConfigDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
 	}
 
 	// Create logs directory
@@ -179,10 +257,12 @@ func NewConfigManager() (*ConfigManager, error) {
 	v.SetDefault("app.auto_start", false)
 	v.SetDefault("app.debug_mode", false)
 	v.SetDefault("app.log_retention", 30)
+	v.SetDefault("app.background_daemon", false)
 	v.SetDefault("server_defaults.auto_start", false)
 	v.SetDefault("server_defaults.default_port", 8000)
 	v.SetDefault("server_defaults.max_memory_mb", 512)
 	v.SetDefault("server_defaults.restart_on_failure", true)
+	v.SetDefault("server_defaults.health_check_failures", 3)
 	v.SetDefault("remote_access.remote_server", "remote.neobelt.io")
 	v.SetDefault("remote_access.username", "")
 	v.SetDefault("remote_access.private_key", "")
@@ -213,7 +293,7 @@ func (cm *ConfigManager) Load() error {
 	if err := cm.viper.ReadInConfig(); err != nil {
 		// If config file doesn't exist, create it with defaults
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			fmt.Printf("Config file not found, creating default configuration at: %s\n", cm.configPath) // Keep as fmt since logger isn't initialized yet
+			fmt.Fprintf(os.Stderr, "Config file not found, creating default configuration at: %s\n", cm.configPath) // Keep as fmt since logger isn't initialized yet, stdout may carry MCP messages
 			if err := cm.Save(); err != nil {
 				return fmt.Errorf("failed to create default config: %w", err)
 			}
@@ -228,9 +308,40 @@ func (cm *ConfigManager) Load() error {
 		return fmt.Errorf("failed to unmarshal configuration: %w", err)
 	}
 
+	cm.restoreMapKeys()
+
 	return nil
 }
 
+// restoreMapKeys takes the environment variables and volumes of configured servers from the file
+// as written. Viper lowercases map keys, which would turn API_KEY into api_key and break volume
+// paths with capitals.
+func (cm *ConfigManager) restoreMapKeys() {
+	data, err := os.ReadFile(cm.configPath)
+	if err != nil {
+		return
+	}
+
+	var file struct {
+		ConfiguredServers []ConfiguredServer `json:"configured_servers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read environment variables and volumes as written: %v\n", err)
+		return
+	}
+
+	written := make(map[string]ConfiguredServer, len(file.ConfiguredServers))
+	for _, server := range file.ConfiguredServers {
+		written[server.ID] = server
+	}
+	for i, server := range cm.config.ConfiguredServers {
+		if original, ok := written[server.ID]; ok {
+			cm.config.ConfiguredServers[i].Environment = original.Environment
+			cm.config.ConfiguredServers[i].Volumes = original.Volumes
+		}
+	}
+}
+
 // Save saves the current configuration to file
 func (cm *ConfigManager) Save() error {
 	// Update viper with current config
@@ -341,6 +452,16 @@ func (cm *ConfigManager) GetConfiguredServers() []ConfiguredServer {
 	return cm.config.ConfiguredServers
 }
 
+// FindConfiguredServer returns the configured server with the given ID or container name
+func (cm *ConfigManager) FindConfiguredServer(idOrContainerName string) *ConfiguredServer {
+	for _, server := range cm.GetConfiguredServers() {
+		if server.ID == idOrContainerName || server.ContainerName == idOrContainerName {
+			return &server
+		}
+	}
+	return nil
+}
+
 // AddOrUpdateInstalledServer adds or updates an installed server
 func (cm *ConfigManager) AddOrUpdateInstalledServer(server InstalledServer) error {
 	if cm.config == nil {
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestToolPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   ToolPolicy
+		tool     string
+		allowed  bool
+		approval bool
+	}{
+		{name: "empty policy allows everything", policy: ToolPolicy{}, tool: "anything", allowed: true},
+		{name: "empty allow list allows everything not denied", policy: ToolPolicy{Deny: []string{"*_delete"}}, tool: "issue_create", allowed: true},
+		{name: "deny pattern", policy: ToolPolicy{Deny: []string{"*_delete"}}, tool: "issue_delete", allowed: false},
+		{name: "allow list", policy: ToolPolicy{Allow: []string{"jira_*"}}, tool: "jira_search", allowed: true},
+		{name: "not in allow list", policy: ToolPolicy{Allow: []string{"jira_*"}}, tool: "github_search", allowed: false},
+		{name: "deny wins over allow", policy: ToolPolicy{Allow: []string{"jira_*"}, Deny: []string{"jira_delete*"}}, tool: "jira_delete_issue", allowed: false},
+		{name: "exact name", policy: ToolPolicy{Allow: []string{"search"}}, tool: "search_all", allowed: false},
+		{name: "approval", policy: ToolPolicy{RequireApproval: []string{"*_write"}}, tool: "file_write", allowed: true, approval: true},
+		{name: "approval of other tools", policy: ToolPolicy{RequireApproval: []string{"*_write"}}, tool: "file_read", allowed: true},
+		{name: "invalid pattern never matches", policy: ToolPolicy{Deny: []string{"["}}, tool: "[", allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if allowed := test.policy.Allows(test.tool); allowed != test.allowed {
+				t.Errorf("expected Allows(%q) to be %v", test.tool, test.allowed)
+			}
+			if approval := test.policy.NeedsApproval(test.tool); approval != test.approval {
+				t.Errorf("expected NeedsApproval(%q) to be %v", test.tool, test.approval)
+			}
+		})
+	}
+}
+
+func TestToolPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ToolPolicy
+		valid  bool
+	}{
+		{name: "empty", policy: ToolPolicy{}, valid: true},
+		{name: "valid globs", policy: ToolPolicy{Allow: []string{"jira_*"}, Deny: []string{"*_[dD]elete"}, RequireApproval: []string{"?rite"}}, valid: true},
+		{name: "invalid allow", policy: ToolPolicy{Allow: []string{"jira_["}}, valid: false},
+		{name: "invalid deny", policy: ToolPolicy{Deny: []string{"\\"}}, valid: false},
+		{name: "invalid approval", policy: ToolPolicy{RequireApproval: []string{"[a-"}}, valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.policy.Validate(); (err == nil) != test.valid {
+				t.Errorf("expected valid to be %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsCaseOfEnvironmentAndVolumes(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	cm, err := NewConfigManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := ConfiguredServer{
+		ID:            "server-1",
+		ContainerName: "github",
+		Environment:   map[string]string{"GITHUB_TOKEN": "token", "LogLevel": "debug"},
+		Volumes:       map[string]string{"/Users/Jane/Data": "/data"},
+	}
+	if err := cm.AddOrUpdateConfiguredServer(server); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewConfigManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := reloaded.FindConfiguredServer("server-1")
+	if loaded == nil {
+		t.Fatal("configured server was not saved")
+	}
+	if !maps.Equal(loaded.Environment, server.Environment) {
+		t.Errorf("expected environment %v, got %v", server.Environment, loaded.Environment)
+	}
+	if !maps.Equal(loaded.Volumes, server.Volumes) {
+		t.Errorf("expected volumes %v, got %v", server.Volumes, loaded.Volumes)
+	}
+}
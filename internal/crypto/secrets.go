@@ -0,0 +1,224 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"neobelt/internal/config"
+)
+
+// SecretStore keeps named secrets (e.g. API tokens for MCP proxy headers) encrypted on disk, so
+// they never have to appear in configuration files like claude_desktop_config.json. The key lives
+// in its own file next to the secrets, readable by the current user only. That is obfuscation at
+// rest, e.g. against secrets showing up in backups of single files or over someone's shoulder,
+// not protection: anyone who can read the config directory can decrypt them.
+type SecretStore struct {
+	mutex      sync.Mutex
+	keyPath    string
+	secretPath string
+}
+
+// NewSecretStore creates a secret store in the Neobelt config directory
+func NewSecretStore() (*SecretStore, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretStore{
+		keyPath:    filepath.Join(configDir, "secrets.key"),
+		secretPath: filepath.Join(configDir, "secrets.json"),
+	}, nil
+}
+
+// Get returns the value of a secret
+func (s *SecretStore) Get(name string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	value, exists := secrets[name]
+	if !exists {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	return value, nil
+}
+
+// Set stores a secret, replacing any existing value
+func (s *SecretStore) Set(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("secret name cannot be empty")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// Delete removes a secret
+func (s *SecretStore) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := secrets[name]; !exists {
+		return fmt.Errorf("secret %q not found", name)
+	}
+	delete(secrets, name)
+	return s.save(secrets)
+}
+
+// List returns the names of all stored secrets, sorted
+func (s *SecretStore) List() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load decrypts all secrets, an absent store is empty
+func (s *SecretStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+
+	data, err := os.ReadFile(s.secretPath)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret store: %w", err)
+	}
+
+	var encryptedData EncryptedData
+	if err := json.Unmarshal(data, &encryptedData); err != nil {
+		return nil, fmt.Errorf("failed to parse secret store: %w", err)
+	}
+
+	gcm, err := s.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(encryptedData.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedData.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cipher text: %w", err)
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret store: %w", err)
+	}
+
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts all secrets and writes them to disk
+func (s *SecretStore) save(secrets map[string]string) error {
+	gcm, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	encryptedData := EncryptedData{
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		CipherText: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+		Version:    "1.0",
+	}
+	data, err := json.MarshalIndent(encryptedData, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal encrypted data: %w", err)
+	}
+
+	// Write to a temp file first so a crash never loses all secrets
+	tmpPath := s.secretPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.secretPath); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	return nil
+}
+
+// cipher returns the AES-GCM cipher for the store, generating the key on first write
+func (s *SecretStore) cipher(create bool) (cipher.AEAD, error) {
+	encodedKey, err := os.ReadFile(s.keyPath)
+	if os.IsNotExist(err) && create {
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		encodedKey = []byte(base64.StdEncoding.EncodeToString(key))
+		if err := os.WriteFile(s.keyPath, encodedKey, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write secret key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read secret key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(string(encodedKey))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid secret key in %s", s.keyPath)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
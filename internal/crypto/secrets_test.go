@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestSecretStore(t *testing.T) *SecretStore {
+	t.Helper()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	store, err := NewSecretStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSecretStore(t *testing.T) {
+	store := newTestSecretStore(t)
+
+	if _, err := store.Get("github"); err == nil {
+		t.Fatal("expected an error for a secret in an empty store")
+	}
+	if err := store.Set("", "value"); err == nil {
+		t.Error("expected an error for an empty secret name")
+	}
+
+	if err := store.Set("github", "token-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("gitlab", "token-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("github", "token-3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := store.Get("github"); err != nil || value != "token-3" {
+		t.Errorf("expected the replaced value, got %q (%v)", value, err)
+	}
+	if names, err := store.List(); err != nil || strings.Join(names, ",") != "github,gitlab" {
+		t.Errorf("expected the sorted secret names, got %v (%v)", names, err)
+	}
+
+	// Values never appear in the file on disk
+	data, err := os.ReadFile(store.secretPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token-") {
+		t.Errorf("secret store contains a plain text value: %s", data)
+	}
+	for _, path := range []string{store.secretPath, store.keyPath} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("expected %s to be readable by the user only, got %v (%v)", path, info.Mode().Perm(), err)
+		}
+	}
+
+	if err := store.Delete("github"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("github"); err == nil {
+		t.Error("expected an error deleting a missing secret")
+	}
+	if _, err := store.Get("github"); err == nil {
+		t.Error("expected the deleted secret to be gone")
+	}
+}
+
+func TestSecretStoreRejectsForeignKey(t *testing.T) {
+	store := newTestSecretStore(t)
+	if err := store.Set("github", "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A store encrypted with another key cannot be read
+	if err := os.WriteFile(store.keyPath, []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("github"); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("expected a decryption error, got %v", err)
+	}
+}
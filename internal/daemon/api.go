@@ -0,0 +1,412 @@
+package daemon
+
+import (
+	"context"
+	"io"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/crypto"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/manifest"
+	"neobelt/internal/mcp"
+	"neobelt/internal/service"
+)
+
+var _ service.API = (*Client)(nil)
+
+// FetchOfficialRegistry calls FetchOfficialRegistry of the daemon
+func (c *Client) FetchOfficialRegistry(ctx context.Context) ([]config.RegistryServer, error) {
+	var result []config.RegistryServer
+	err := c.call(ctx, "FetchOfficialRegistry", []interface{}{&result})
+	return result, err
+}
+
+// FetchCustomRegistry calls FetchCustomRegistry of the daemon
+func (c *Client) FetchCustomRegistry(ctx context.Context, url string) ([]config.RegistryServer, error) {
+	var result []config.RegistryServer
+	err := c.call(ctx, "FetchCustomRegistry", []interface{}{&result}, url)
+	return result, err
+}
+
+// GetRegistries calls GetRegistries of the daemon
+func (c *Client) GetRegistries() []config.Registry {
+	var result []config.Registry
+	c.call(context.Background(), "GetRegistries", []interface{}{&result})
+	return result
+}
+
+// AddCustomRegistry calls AddCustomRegistry of the daemon
+func (c *Client) AddCustomRegistry(ctx context.Context, name, url, description, authType, authUsername, authPassword, authHeader string) error {
+	return c.call(ctx, "AddCustomRegistry", nil, name, url, description, authType, authUsername, authPassword, authHeader)
+}
+
+// FetchAllRegistries calls FetchAllRegistries of the daemon
+func (c *Client) FetchAllRegistries(ctx context.Context) ([]config.RegistryServer, error) {
+	var result []config.RegistryServer
+	err := c.call(ctx, "FetchAllRegistries", []interface{}{&result})
+	return result, err
+}
+
+// RemoveCustomRegistry calls RemoveCustomRegistry of the daemon
+func (c *Client) RemoveCustomRegistry(url string) error {
+	return c.call(context.Background(), "RemoveCustomRegistry", nil, url)
+}
+
+// UpdateCustomRegistry calls UpdateCustomRegistry of the daemon
+func (c *Client) UpdateCustomRegistry(ctx context.Context, oldURL, name, newURL, description, authType, authUsername, authPassword, authHeader string) error {
+	return c.call(ctx, "UpdateCustomRegistry", nil, oldURL, name, newURL, description, authType, authUsername, authPassword, authHeader)
+}
+
+// GetConfiguration calls GetConfiguration of the daemon
+func (c *Client) GetConfiguration() (*config.Configuration, error) {
+	var result *config.Configuration
+	err := c.call(context.Background(), "GetConfiguration", []interface{}{&result})
+	return result, err
+}
+
+// GetConfigPath calls GetConfigPath of the daemon
+func (c *Client) GetConfigPath() string {
+	var result string
+	c.call(context.Background(), "GetConfigPath", []interface{}{&result})
+	return result
+}
+
+// GetLogDir calls GetLogDir of the daemon
+func (c *Client) GetLogDir() string {
+	var result string
+	c.call(context.Background(), "GetLogDir", []interface{}{&result})
+	return result
+}
+
+// GetServerDefaults calls GetServerDefaults of the daemon
+func (c *Client) GetServerDefaults() (*config.ServerDefaultsConfig, error) {
+	var result *config.ServerDefaultsConfig
+	err := c.call(context.Background(), "GetServerDefaults", []interface{}{&result})
+	return result, err
+}
+
+// UpdateServerDefaults calls UpdateServerDefaults of the daemon
+func (c *Client) UpdateServerDefaults(ctx context.Context, serverDefaults config.ServerDefaultsConfig) (bool, error) {
+	var result bool
+	err := c.call(ctx, "UpdateServerDefaults", []interface{}{&result}, serverDefaults)
+	return result, err
+}
+
+// GetAppConfig calls GetAppConfig of the daemon
+func (c *Client) GetAppConfig() (*config.AppConfig, error) {
+	var result *config.AppConfig
+	err := c.call(context.Background(), "GetAppConfig", []interface{}{&result})
+	return result, err
+}
+
+// UpdateAppConfig calls UpdateAppConfig of the daemon
+func (c *Client) UpdateAppConfig(appConfig config.AppConfig) error {
+	return c.call(context.Background(), "UpdateAppConfig", nil, appConfig)
+}
+
+// GetRemoteAccess calls GetRemoteAccess of the daemon
+func (c *Client) GetRemoteAccess() (*config.RemoteAccessConfig, error) {
+	var result *config.RemoteAccessConfig
+	err := c.call(context.Background(), "GetRemoteAccess", []interface{}{&result})
+	return result, err
+}
+
+// UpdateRemoteAccess calls UpdateRemoteAccess of the daemon
+func (c *Client) UpdateRemoteAccess(remoteAccess config.RemoteAccessConfig) error {
+	return c.call(context.Background(), "UpdateRemoteAccess", nil, remoteAccess)
+}
+
+// GetClaudeIntegration calls GetClaudeIntegration of the daemon
+func (c *Client) GetClaudeIntegration() (*config.ClaudeIntegrationConfig, error) {
+	var result *config.ClaudeIntegrationConfig
+	err := c.call(context.Background(), "GetClaudeIntegration", []interface{}{&result})
+	return result, err
+}
+
+// UpdateClaudeIntegration calls UpdateClaudeIntegration of the daemon
+func (c *Client) UpdateClaudeIntegration(claudeIntegration config.ClaudeIntegrationConfig) error {
+	return c.call(context.Background(), "UpdateClaudeIntegration", nil, claudeIntegration)
+}
+
+// GenerateSSHKeys calls GenerateSSHKeys of the daemon
+func (c *Client) GenerateSSHKeys() (*crypto.SSHKeyPair, error) {
+	var result *crypto.SSHKeyPair
+	err := c.call(context.Background(), "GenerateSSHKeys", []interface{}{&result})
+	return result, err
+}
+
+// GetSSHPublicKey calls GetSSHPublicKey of the daemon
+func (c *Client) GetSSHPublicKey() (string, error) {
+	var result string
+	err := c.call(context.Background(), "GetSSHPublicKey", []interface{}{&result})
+	return result, err
+}
+
+// ListSecrets calls ListSecrets of the daemon
+func (c *Client) ListSecrets() ([]string, error) {
+	var result []string
+	err := c.call(context.Background(), "ListSecrets", []interface{}{&result})
+	return result, err
+}
+
+// SetSecret calls SetSecret of the daemon
+func (c *Client) SetSecret(name, value string) error {
+	return c.call(context.Background(), "SetSecret", nil, name, value)
+}
+
+// DeleteSecret calls DeleteSecret of the daemon
+func (c *Client) DeleteSecret(name string) error {
+	return c.call(context.Background(), "DeleteSecret", nil, name)
+}
+
+// ClearAllLogs calls ClearAllLogs of the daemon
+func (c *Client) ClearAllLogs() error {
+	return c.call(context.Background(), "ClearAllLogs", nil)
+}
+
+// EncryptConfiguration calls EncryptConfiguration of the daemon
+func (c *Client) EncryptConfiguration(password string) ([]byte, error) {
+	var result []byte
+	err := c.call(context.Background(), "EncryptConfiguration", []interface{}{&result}, password)
+	return result, err
+}
+
+// ImportConfiguration calls ImportConfiguration of the daemon
+func (c *Client) ImportConfiguration(encryptedData, password string) error {
+	return c.call(context.Background(), "ImportConfiguration", nil, encryptedData, password)
+}
+
+// GetRecentLogMessages calls GetRecentLogMessages of the daemon
+func (c *Client) GetRecentLogMessages(count int) ([]logging.LogMessage, error) {
+	var result []logging.LogMessage
+	err := c.call(context.Background(), "GetRecentLogMessages", []interface{}{&result}, count)
+	return result, err
+}
+
+// GetAuditRecords calls GetAuditRecords of the daemon
+func (c *Client) GetAuditRecords(query audit.Query) ([]audit.Record, error) {
+	var result []audit.Record
+	err := c.call(context.Background(), "GetAuditRecords", []interface{}{&result}, query)
+	return result, err
+}
+
+// GetManagedContainers calls GetManagedContainers of the daemon
+func (c *Client) GetManagedContainers(ctx context.Context) ([]docker.ContainerInfo, error) {
+	var result []docker.ContainerInfo
+	err := c.call(ctx, "GetManagedContainers", []interface{}{&result})
+	return result, err
+}
+
+// StartContainer calls StartContainer of the daemon
+func (c *Client) StartContainer(ctx context.Context, containerID string) error {
+	return c.call(ctx, "StartContainer", nil, containerID)
+}
+
+// StopContainer calls StopContainer of the daemon
+func (c *Client) StopContainer(ctx context.Context, containerID string) error {
+	return c.call(ctx, "StopContainer", nil, containerID)
+}
+
+// RestartContainer calls RestartContainer of the daemon
+func (c *Client) RestartContainer(ctx context.Context, containerID string) error {
+	return c.call(ctx, "RestartContainer", nil, containerID)
+}
+
+// GetContainerLogs calls GetContainerLogs of the daemon
+func (c *Client) GetContainerLogs(ctx context.Context, containerID string, lines int) (string, error) {
+	var result string
+	err := c.call(ctx, "GetContainerLogs", []interface{}{&result}, containerID, lines)
+	return result, err
+}
+
+// RemoveContainer calls RemoveContainer of the daemon
+func (c *Client) RemoveContainer(ctx context.Context, containerID string, force bool) error {
+	return c.call(ctx, "RemoveContainer", nil, containerID, force)
+}
+
+// GetInstalledServersWithVersionCheck calls GetInstalledServersWithVersionCheck of the daemon
+func (c *Client) GetInstalledServersWithVersionCheck(ctx context.Context) ([]map[string]any, error) {
+	var result []map[string]any
+	err := c.call(ctx, "GetInstalledServersWithVersionCheck", []interface{}{&result})
+	return result, err
+}
+
+// PullImage calls PullImage of the daemon
+func (c *Client) PullImage(ctx context.Context, imageName string) error {
+	return c.call(ctx, "PullImage", nil, imageName)
+}
+
+// CreateContainer calls CreateContainer of the daemon
+func (c *Client) CreateContainer(ctx context.Context, config docker.ContainerCreateConfig) (string, error) {
+	var result string
+	err := c.call(ctx, "CreateContainer", []interface{}{&result}, config)
+	return result, err
+}
+
+// InstallServer calls InstallServer of the daemon
+func (c *Client) InstallServer(ctx context.Context, server config.RegistryServer) error {
+	return c.call(ctx, "InstallServer", nil, server)
+}
+
+// InstallManualServer calls InstallManualServer of the daemon
+func (c *Client) InstallManualServer(dockerImage, name, description string) (string, error) {
+	var result string
+	err := c.call(context.Background(), "InstallManualServer", []interface{}{&result}, dockerImage, name, description)
+	return result, err
+}
+
+// GetInstalledServers calls GetInstalledServers of the daemon
+func (c *Client) GetInstalledServers() ([]config.InstalledServer, error) {
+	var result []config.InstalledServer
+	err := c.call(context.Background(), "GetInstalledServers", []interface{}{&result})
+	return result, err
+}
+
+// GetConfiguredServers calls GetConfiguredServers of the daemon
+func (c *Client) GetConfiguredServers() ([]config.ConfiguredServer, error) {
+	var result []config.ConfiguredServer
+	err := c.call(context.Background(), "GetConfiguredServers", []interface{}{&result})
+	return result, err
+}
+
+// CreateConfiguredServer calls CreateConfiguredServer of the daemon
+func (c *Client) CreateConfiguredServer(installedServerID, containerName, containerID string, port int, environment, volumes map[string]string) error {
+	return c.call(context.Background(), "CreateConfiguredServer", nil, installedServerID, containerName, containerID, port, environment, volumes)
+}
+
+// CreateServer calls CreateServer of the daemon
+func (c *Client) CreateServer(ctx context.Context, installedServerID, containerName string, environment, volumes map[string]string, sessionContainers bool) (*config.ConfiguredServer, error) {
+	var result *config.ConfiguredServer
+	err := c.call(ctx, "CreateServer", []interface{}{&result}, installedServerID, containerName, environment, volumes, sessionContainers)
+	return result, err
+}
+
+// UpdateToolPolicy calls UpdateToolPolicy of the daemon
+func (c *Client) UpdateToolPolicy(serverID string, policy config.ToolPolicy) error {
+	return c.call(context.Background(), "UpdateToolPolicy", nil, serverID, policy)
+}
+
+// InspectMCPServer calls InspectMCPServer of the daemon
+func (c *Client) InspectMCPServer(ctx context.Context, serverID string) (*mcp.ServerInspection, error) {
+	var result *mcp.ServerInspection
+	err := c.call(ctx, "InspectMCPServer", []interface{}{&result}, serverID)
+	return result, err
+}
+
+// CallMCPServerTool calls CallMCPServerTool of the daemon
+func (c *Client) CallMCPServerTool(ctx context.Context, serverID, toolName, argumentsJSON string) (*mcp.ToolCallResult, error) {
+	var result *mcp.ToolCallResult
+	err := c.call(ctx, "CallMCPServerTool", []interface{}{&result}, serverID, toolName, argumentsJSON)
+	return result, err
+}
+
+// RemoveInstalledServer calls RemoveInstalledServer of the daemon
+func (c *Client) RemoveInstalledServer(ctx context.Context, serverID string, removeImage bool) error {
+	return c.call(ctx, "RemoveInstalledServer", nil, serverID, removeImage)
+}
+
+// GetOrphanedContainers calls GetOrphanedContainers of the daemon
+func (c *Client) GetOrphanedContainers(ctx context.Context) ([]docker.ContainerInfo, error) {
+	var result []docker.ContainerInfo
+	err := c.call(ctx, "GetOrphanedContainers", []interface{}{&result})
+	return result, err
+}
+
+// CleanupOrphanedContainers calls CleanupOrphanedContainers of the daemon
+func (c *Client) CleanupOrphanedContainers(ctx context.Context) error {
+	return c.call(ctx, "CleanupOrphanedContainers", nil)
+}
+
+// CheckServerHealth calls CheckServerHealth of the daemon
+func (c *Client) CheckServerHealth(ctx context.Context, serverID string) (string, string, error) {
+	var status string
+	var message string
+	err := c.call(ctx, "CheckServerHealth", []interface{}{&status, &message}, serverID)
+	return status, message, err
+}
+
+// CheckDockerStatus calls CheckDockerStatus of the daemon
+func (c *Client) CheckDockerStatus(ctx context.Context) (*docker.DockerStatus, error) {
+	var result *docker.DockerStatus
+	err := c.call(ctx, "CheckDockerStatus", []interface{}{&result})
+	return result, err
+}
+
+// StartDockerDesktop calls StartDockerDesktop of the daemon
+func (c *Client) StartDockerDesktop() error {
+	return c.call(context.Background(), "StartDockerDesktop", nil)
+}
+
+// PlanManifest calls PlanManifest of the daemon
+func (c *Client) PlanManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error) {
+	var result *manifest.Plan
+	err := c.call(ctx, "PlanManifest", []interface{}{&result}, m)
+	return result, err
+}
+
+// ApplyManifest calls ApplyManifest of the daemon
+func (c *Client) ApplyManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error) {
+	var result *manifest.Plan
+	err := c.call(ctx, "ApplyManifest", []interface{}{&result}, m)
+	return result, err
+}
+
+// DetectClaudeConfig calls DetectClaudeConfig of the daemon
+func (c *Client) DetectClaudeConfig() (string, error) {
+	var result string
+	err := c.call(context.Background(), "DetectClaudeConfig", []interface{}{&result})
+	return result, err
+}
+
+// TestClaudeConfig calls TestClaudeConfig of the daemon
+func (c *Client) TestClaudeConfig(configPath string) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := c.call(context.Background(), "TestClaudeConfig", []interface{}{&result}, configPath)
+	return result, err
+}
+
+// AddMCPServerToClaude calls AddMCPServerToClaude of the daemon
+func (c *Client) AddMCPServerToClaude(containerName string, port int) error {
+	return c.call(context.Background(), "AddMCPServerToClaude", nil, containerName, port)
+}
+
+// RemoveMCPServerFromClaude calls RemoveMCPServerFromClaude of the daemon
+func (c *Client) RemoveMCPServerFromClaude(containerName string) error {
+	return c.call(context.Background(), "RemoveMCPServerFromClaude", nil, containerName)
+}
+
+// RemoveAllNeobeltMCPServersFromClaude calls RemoveAllNeobeltMCPServersFromClaude of the daemon
+func (c *Client) RemoveAllNeobeltMCPServersFromClaude() error {
+	return c.call(context.Background(), "RemoveAllNeobeltMCPServersFromClaude", nil)
+}
+
+// CleanupClaudeConfiguration calls CleanupClaudeConfiguration of the daemon
+func (c *Client) CleanupClaudeConfiguration() error {
+	return c.call(context.Background(), "CleanupClaudeConfiguration", nil)
+}
+
+// RespondToolApproval calls RespondToolApproval of the daemon
+func (c *Client) RespondToolApproval(requestID string, approved bool) error {
+	return c.call(context.Background(), "RespondToolApproval", nil, requestID, approved)
+}
+
+// ExportAuditRecords writes the audit records the daemon returns for the query as JSON lines or
+// CSV and returns how many were written
+func (c *Client) ExportAuditRecords(w io.Writer, query audit.Query, format string) (int, error) {
+	records, err := c.GetAuditRecords(query)
+	if err != nil {
+		return 0, err
+	}
+
+	// GetAuditRecords returns the newest first, exports are in the order of the log
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	if err := audit.Export(w, records, format); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
@@ -0,0 +1,268 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotRunning is returned when no daemon answers
+var ErrNotRunning = errors.New("the neobelt daemon is not running")
+
+// Client calls the control API of the running daemon. It implements service.API, so the GUI
+// and the command line can use it in place of an in-process service.
+type Client struct {
+	mutex sync.Mutex
+	info  *Info
+	http  *http.Client
+}
+
+// Connect connects to the running daemon
+func Connect(ctx context.Context) (*Client, error) {
+	info, err := readInfo()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+
+	client := &Client{info: info}
+	client.http = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				info := client.currentInfo()
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, info.Network, info.Address)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := client.Status(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	return client, nil
+}
+
+// Start runs the daemon in the background, detached from this process and its terminal, and
+// connects to it. The daemon's output goes to OutputPath.
+func Start(ctx context.Context) (*Client, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find neobelt executable: %w", err)
+	}
+
+	outputPath, err := OutputPath()
+	if err != nil {
+		return nil, err
+	}
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon output file: %w", err)
+	}
+	defer output.Close() // the daemon has its own copy
+
+	cmd := exec.Command(executable, "daemon")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start daemon: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err := <-exited:
+			return nil, fmt.Errorf("daemon exited: %v, see %s", err, outputPath)
+		case <-timeout:
+			return nil, fmt.Errorf("daemon didn't answer in time")
+		case <-ticker.C:
+			if client, err := Connect(ctx); err == nil {
+				return client, nil
+			}
+		}
+	}
+}
+
+// currentInfo returns where the daemon listens and its token
+func (c *Client) currentInfo() *Info {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.info
+}
+
+// request sends a request with the daemon's token. If the daemon was restarted since, with a
+// new token and maybe address, the request is sent again to the new daemon.
+func (c *Client) request(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	info := c.currentInfo()
+	response, err := c.send(ctx, info, method, path, body)
+	if err == nil && response.StatusCode != http.StatusUnauthorized {
+		return response, nil
+	}
+
+	current, infoErr := readInfo()
+	if infoErr != nil || current.Token == info.Token {
+		return response, err
+	}
+	if response != nil {
+		response.Body.Close()
+	}
+
+	// Keep-alive connections still lead to the old daemon
+	c.http.CloseIdleConnections()
+	c.mutex.Lock()
+	c.info = current
+	c.mutex.Unlock()
+	return c.send(ctx, current, method, path, body)
+}
+
+// send sends a single request
+func (c *Client) send(ctx context.Context, info *Info, method, path string, body []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, "http://neobelt"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+info.Token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach daemon: %w", err)
+	}
+	return response, nil
+}
+
+// Status describes the running daemon
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	response, err := c.request(ctx, http.MethodGet, "/v1/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %s", response.Status)
+	}
+
+	var status Status
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to parse daemon status: %w", err)
+	}
+	return &status, nil
+}
+
+// Shutdown asks the daemon to stop
+func (c *Client) Shutdown(ctx context.Context) error {
+	response, err := c.request(ctx, http.MethodPost, "/v1/shutdown", nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon returned %s", response.Status)
+	}
+	return nil
+}
+
+// call runs a method of the service in the daemon and decodes its results into the pointers
+func (c *Client) call(ctx context.Context, method string, results []interface{}, arguments ...interface{}) error {
+	if arguments == nil {
+		arguments = []interface{}{}
+	}
+	body, err := json.Marshal(arguments)
+	if err != nil {
+		return fmt.Errorf("failed to marshal arguments of %s: %w", method, err)
+	}
+
+	response, err := c.request(ctx, http.MethodPost, "/v1/call/"+method, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var decoded struct {
+		Results []json.RawMessage `json:"results"`
+		Error   string            `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("failed to parse response of %s: %w", method, err)
+	}
+
+	// Results are decoded even with an error, some methods report what they did before failing
+	for i, result := range results {
+		if i >= len(decoded.Results) {
+			break
+		}
+		if err := json.Unmarshal(decoded.Results[i], result); err != nil {
+			return fmt.Errorf("failed to parse result of %s: %w", method, err)
+		}
+	}
+
+	if decoded.Error != "" {
+		return errors.New(decoded.Error)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon returned %s", response.Status)
+	}
+	return nil
+}
+
+// Events streams the events of the daemon to the handler until the context ends or the daemon
+// stops
+func (c *Client) Events(ctx context.Context, handler func(Event)) error {
+	response, err := c.request(ctx, http.MethodGet, "/v1/events", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon returned %s", response.Status)
+	}
+
+	var event Event
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.Name != "" {
+				handler(event)
+			}
+			event = Event{}
+		case strings.HasPrefix(line, "event: "):
+			event.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = json.RawMessage(strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+	return nil
+}
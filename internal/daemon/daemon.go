@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"neobelt/internal/config"
+)
+
+// Info tells clients where the running daemon listens and how to authenticate. The daemon
+// writes it to the config directory, readable only by the current user.
+type Info struct {
+	Network   string    `json:"network"` // unix or tcp
+	Address   string    `json:"address"`
+	Token     string    `json:"token"`
+	PID       int       `json:"pid"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Status describes the running daemon, as reported by its control API
+type Status struct {
+	Network   string    `json:"network"`
+	Address   string    `json:"address"`
+	PID       int       `json:"pid"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// InfoPath returns the path of the file describing the running daemon
+func InfoPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "daemon.json"), nil
+}
+
+// SocketPath returns the path of the Unix socket the daemon listens on by default
+func SocketPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "daemon.sock"), nil
+}
+
+// OutputPath returns the file that output of a daemon started in the background goes to
+func OutputPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	logsDir := filepath.Join(configDir, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	return filepath.Join(logsDir, "daemon.out"), nil
+}
+
+// readInfo reads the file describing the running daemon
+func readInfo() (*Info, error) {
+	path, err := InfoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse daemon info: %w", err)
+	}
+	return &info, nil
+}
+
+// writeInfo writes the file describing the running daemon, which holds its token
+func writeInfo(info *Info) error {
+	path, err := InfoPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal daemon info: %w", err)
+	}
+
+	// Write to a temporary file first, so clients never read half of it
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write daemon info: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write daemon info: %w", err)
+	}
+	return nil
+}
+
+// newToken generates the token clients authenticate with
+func newToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate daemon token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the command in a new session, so it has no controlling terminal and doesn't
+// get SIGHUP when the terminal it was started from closes
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
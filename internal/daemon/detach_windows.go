@@ -0,0 +1,18 @@
+package daemon
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess is DETACHED_PROCESS from the Windows API, the process gets no console
+const detachedProcess = 0x00000008
+
+// detach starts the command without a console and in its own process group, so it survives
+// the console it was started from
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
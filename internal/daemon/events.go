@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"encoding/json"
+	"sync"
+
+	"neobelt/internal/logging"
+)
+
+// Event is an event of the service, e.g. container_state_changed, with its data as JSON
+type Event struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// broadcaster passes the events of the service on to every client streaming them
+type broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// newBroadcaster creates a broadcaster without subscribers
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: make(map[chan Event]struct{})}
+}
+
+// Emit sends an event to all subscribers. A subscriber that doesn't keep up misses events
+// rather than holding up the service.
+func (b *broadcaster) Emit(name string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		logging.LogWarning("Failed to marshal %s event: %v", name, err)
+		return
+	}
+	event := Event{Name: name, Data: raw}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events from now on
+func (b *broadcaster) subscribe() chan Event {
+	subscriber := make(chan Event, 64)
+	b.mutex.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mutex.Unlock()
+	return subscriber
+}
+
+// unsubscribe stops sending events to the channel
+func (b *broadcaster) unsubscribe(subscriber chan Event) {
+	b.mutex.Lock()
+	delete(b.subscribers, subscriber)
+	b.mutex.Unlock()
+}
@@ -0,0 +1,299 @@
+package daemon
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"neobelt/internal/logging"
+	"neobelt/internal/service"
+	"neobelt/internal/unixsocket"
+	"neobelt/internal/version"
+)
+
+// Options configure the daemon
+type Options struct {
+	// Listen is a localhost address like 127.0.0.1:7420 to serve the control API on, empty
+	// for the Unix socket in the config directory
+	Listen string
+}
+
+// localMethods are API methods that can't be called over the control API. Clients export
+// audit records to their own files from GetAuditRecords.
+var localMethods = map[string]bool{
+	"ExportAuditRecords": true,
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Run starts the service, with its Docker, container and health monitors, and serves the
+// control API until the context ends or a client asks the daemon to stop
+func Run(ctx context.Context, options Options) error {
+	if client, err := Connect(ctx); err == nil {
+		return fmt.Errorf("the daemon is already running (pid %d)", client.currentInfo().PID)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	listener, info, err := listen(options.Listen)
+	if err != nil {
+		return err
+	}
+
+	events := newBroadcaster()
+	svc := service.New(events)
+	if err := svc.Initialize(); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to initialize configuration manager: %w", err)
+	}
+	info.Token = token
+	info.PID = os.Getpid()
+	info.Version = version.Version
+	info.StartedAt = time.Now()
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	svc.StartMonitors(ctx)
+
+	server := &http.Server{
+		Handler:           newHandler(svc, events, info, stop),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := writeInfo(info); err != nil {
+		listener.Close()
+		return err
+	}
+	defer removeInfo(info)
+
+	logging.LogInfo("Daemon listening on %s %s", info.Network, info.Address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve control API: %w", err)
+	}
+	logging.LogInfo("Daemon stopped")
+	return nil
+}
+
+// listen opens the Unix socket, or the TCP address if one is given. TCP is only served on
+// localhost, the token alone shouldn't guard Docker against the network.
+func listen(address string) (net.Listener, *Info, error) {
+	if address == "" {
+		socketPath, err := SocketPath()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Nobody answers on the socket, or Connect would have found the daemon
+		os.Remove(socketPath)
+		listener, err := unixsocket.Listen(socketPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to listen on daemon socket: %w", err)
+		}
+		return listener, &Info{Network: "unix", Address: socketPath}, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, nil, fmt.Errorf("the daemon only listens on localhost, not %q", host)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return listener, &Info{Network: "tcp", Address: listener.Addr().String()}, nil
+}
+
+// removeInfo removes the daemon info and socket, unless another daemon took them over
+func removeInfo(info *Info) {
+	if current, err := readInfo(); err == nil && current.PID == info.PID {
+		if path, err := InfoPath(); err == nil {
+			os.Remove(path)
+		}
+	}
+	if info.Network == "unix" {
+		os.Remove(info.Address)
+	}
+}
+
+// handler serves the control API
+type handler struct {
+	methods map[string]reflect.Value
+	events  *broadcaster
+	info    *Info
+	stop    func()
+}
+
+// newHandler exposes the methods of the API, except the local ones, as POST /v1/call/<method>
+func newHandler(api service.API, events *broadcaster, info *Info, stop func()) http.Handler {
+	h := &handler{
+		methods: make(map[string]reflect.Value),
+		events:  events,
+		info:    info,
+		stop:    stop,
+	}
+
+	apiType := reflect.TypeOf((*service.API)(nil)).Elem()
+	apiValue := reflect.ValueOf(api)
+	for i := 0; i < apiType.NumMethod(); i++ {
+		name := apiType.Method(i).Name
+		if !localMethods[name] {
+			h.methods[name] = apiValue.MethodByName(name)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", h.status)
+	mux.HandleFunc("POST /v1/call/{method}", h.call)
+	mux.HandleFunc("GET /v1/events", h.streamEvents)
+	mux.HandleFunc("POST /v1/shutdown", h.shutdown)
+	return h.authenticate(mux)
+}
+
+// authenticate rejects requests without the daemon's token
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + h.info.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeJSON(w, http.StatusUnauthorized, callResponse{Error: "invalid or missing token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// status describes the daemon
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Status{
+		Network:   h.info.Network,
+		Address:   h.info.Address,
+		PID:       h.info.PID,
+		Version:   h.info.Version,
+		StartedAt: h.info.StartedAt,
+	})
+}
+
+// callResponse holds the results of a method, without the error, which is reported separately
+type callResponse struct {
+	Results []interface{} `json:"results"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// call runs an API method with the arguments given as a JSON array. Methods taking a context
+// get the one of the request, so a client giving up cancels them.
+func (h *handler) call(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("method")
+	method, ok := h.methods[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, callResponse{Error: fmt.Sprintf("unknown method %q", name)})
+		return
+	}
+
+	var arguments []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&arguments); err != nil && err != io.EOF {
+		writeJSON(w, http.StatusBadRequest, callResponse{Error: fmt.Sprintf("arguments must be a JSON array: %v", err)})
+		return
+	}
+
+	methodType := method.Type()
+	var values []reflect.Value
+	if methodType.NumIn() > 0 && methodType.In(0) == contextType {
+		values = append(values, reflect.ValueOf(r.Context()))
+	}
+	if len(values)+len(arguments) != methodType.NumIn() {
+		writeJSON(w, http.StatusBadRequest, callResponse{Error: fmt.Sprintf("%s takes %d arguments, got %d", name, methodType.NumIn()-len(values), len(arguments))})
+		return
+	}
+	for i, argument := range arguments {
+		value := reflect.New(methodType.In(len(values)))
+		if err := json.Unmarshal(argument, value.Interface()); err != nil {
+			writeJSON(w, http.StatusBadRequest, callResponse{Error: fmt.Sprintf("invalid argument %d of %s: %v", i+1, name, err)})
+			return
+		}
+		values = append(values, value.Elem())
+	}
+
+	response := callResponse{Results: []interface{}{}}
+	status := http.StatusOK
+	for _, result := range method.Call(values) {
+		if result.Type() == errorType {
+			if !result.IsNil() {
+				response.Error = result.Interface().(error).Error()
+				status = http.StatusInternalServerError
+			}
+			continue
+		}
+		response.Results = append(response.Results, result.Interface())
+	}
+	writeJSON(w, status, response)
+}
+
+// streamEvents sends the events of the service as server-sent events until the client goes away
+func (h *handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, callResponse{Error: "streaming not supported"})
+		return
+	}
+
+	events := h.events.subscribe()
+	defer h.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			// Event names are identifiers and the data is compact JSON, neither has line breaks
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", strings.TrimSpace(event.Name), event.Data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// shutdown stops the daemon once the response is sent
+func (h *handler) shutdown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, callResponse{Results: []interface{}{}})
+	logging.LogInfo("Daemon stop requested")
+	go h.stop()
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
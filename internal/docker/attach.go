@@ -0,0 +1,204 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"neobelt/internal/logging"
+)
+
+// LabelSessionContainers marks stdio server containers whose client sessions each get a new
+// container instead of a process in the server's container
+const LabelSessionContainers = "neobelt.session-containers"
+
+// labelSessionOf names the server container a session container was created from
+const labelSessionOf = "neobelt.session-of"
+
+// AttachStdio runs a session of a stdio MCP server and connects it to stdin, stdout and stderr
+// until the server exits or stdin is closed. Sessions of a shared server run as processes in
+// its container, which is started if needed. Servers with LabelSessionContainers get a new
+// container for the session that is removed afterwards.
+func (ds *DockerService) AttachStdio(ctx context.Context, containerName string, stdin io.Reader, stdout, stderr io.Writer) error {
+	inspect, err := ds.client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if !inspect.Config.OpenStdin {
+		return fmt.Errorf("container %s does not run a stdio MCP server", containerName)
+	}
+
+	if inspect.Config.Labels[LabelSessionContainers] == "true" {
+		return ds.attachSessionContainer(ctx, inspect, stdin, stdout, stderr)
+	}
+	return ds.attachSharedContainer(ctx, inspect, stdin, stdout, stderr)
+}
+
+// attachSharedContainer runs the server command once more in the running server container.
+// The container's main process stays a server of its own, waiting on its open stdin: stdio
+// servers often come in images without a shell or sleep (distroless, scratch), so there is no
+// idle command to run instead that works with every image. Servers where that idle instance
+// costs too much can use session containers.
+func (ds *DockerService) attachSharedContainer(ctx context.Context, inspect container.InspectResponse, stdin io.Reader, stdout, stderr io.Writer) error {
+	name := strings.TrimPrefix(inspect.Name, "/")
+	command := serverCommand(inspect)
+	if len(command) == 0 {
+		return fmt.Errorf("container %s has no command to run", name)
+	}
+
+	if !inspect.State.Running {
+		logging.LogInfo("Starting container %s for a stdio session", name)
+		if err := ds.client.ContainerStart(ctx, inspect.ID, container.StartOptions{}); err != nil {
+			return fmt.Errorf("failed to start container: %w", err)
+		}
+	}
+
+	process, err := ds.client.ContainerExecCreate(ctx, inspect.ID, container.ExecOptions{
+		User:         inspect.Config.User,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		WorkingDir:   inspect.Config.WorkingDir,
+		Cmd:          command,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create server process: %w", err)
+	}
+
+	stream, err := ds.client.ContainerExecAttach(ctx, process.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to server process: %w", err)
+	}
+	defer stream.Close()
+
+	if err := bridgeStdio(ctx, stream, stdin, stdout, stderr); err != nil {
+		return err
+	}
+
+	result, err := ds.client.ContainerExecInspect(ctx, process.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect server process: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("server exited with status %d", result.ExitCode)
+	}
+	return nil
+}
+
+// serverCommand returns the command the server container runs its server with
+func serverCommand(inspect container.InspectResponse) []string {
+	return append(append([]string{}, inspect.Config.Entrypoint...), inspect.Config.Cmd...)
+}
+
+// sessionContainerConfig returns the configuration of a session container for a server
+// container. It isn't managed by neobelt itself, it only carries the name of its server.
+func sessionContainerConfig(inspect container.InspectResponse) (*container.Config, *container.HostConfig) {
+	name := strings.TrimPrefix(inspect.Name, "/")
+	containerConfig := &container.Config{
+		Image:        inspect.Config.Image,
+		Env:          inspect.Config.Env,
+		Cmd:          inspect.Config.Cmd,
+		Entrypoint:   inspect.Config.Entrypoint,
+		WorkingDir:   inspect.Config.WorkingDir,
+		User:         inspect.Config.User,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		OpenStdin:    true,
+		StdinOnce:    true, // the server gets EOF when the client goes away
+		Labels:       map[string]string{labelSessionOf: name},
+	}
+	hostConfig := &container.HostConfig{
+		Mounts:      inspect.HostConfig.Mounts,
+		NetworkMode: inspect.HostConfig.NetworkMode,
+		AutoRemove:  true,
+		Resources:   container.Resources{Memory: inspect.HostConfig.Memory},
+	}
+	return containerConfig, hostConfig
+}
+
+// attachSessionContainer creates a container like the server container for one session and
+// removes it when the session ends
+func (ds *DockerService) attachSessionContainer(ctx context.Context, inspect container.InspectResponse, stdin io.Reader, stdout, stderr io.Writer) error {
+	name := strings.TrimPrefix(inspect.Name, "/")
+	containerConfig, hostConfig := sessionContainerConfig(inspect)
+	created, err := ds.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create session container: %w", err)
+	}
+	logging.LogDebug("Created session container %s for %s", created.ID[:12], name)
+
+	// Make sure the container goes away when the session is cancelled
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		ds.client.ContainerRemove(removeCtx, created.ID, container.RemoveOptions{Force: true})
+	}()
+
+	stream, err := ds.client.ContainerAttach(ctx, created.ID, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach to session container: %w", err)
+	}
+	defer stream.Close()
+
+	// Wait before starting, an automatically removed container could be gone already
+	waitResult, waitErr := ds.client.ContainerWait(ctx, created.ID, container.WaitConditionRemoved)
+
+	if err := ds.client.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start session container: %w", err)
+	}
+
+	if err := bridgeStdio(ctx, stream, stdin, stdout, stderr); err != nil {
+		return err
+	}
+
+	select {
+	case result := <-waitResult:
+		if result.StatusCode != 0 {
+			return fmt.Errorf("server exited with status %d", result.StatusCode)
+		}
+		return nil
+	case err := <-waitErr:
+		return fmt.Errorf("failed to wait for session container: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bridgeStdio copies stdin to an attached server and its multiplexed output to stdout and
+// stderr, until the output ends
+func bridgeStdio(ctx context.Context, stream types.HijackedResponse, stdin io.Reader, stdout, stderr io.Writer) error {
+	go func() {
+		if _, err := io.Copy(stream.Conn, stdin); err != nil {
+			logging.LogDebug("Stopped forwarding stdin: %v", err)
+		}
+		// Closing stdin ends the session, the server exits and its output ends
+		stream.CloseWrite()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, stream.Reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to read server output: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
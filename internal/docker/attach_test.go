@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// newTestDockerService returns a service talking to a stub Docker API served by handler
+func newTestDockerService(t *testing.T, handler http.HandlerFunc) *DockerService {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.45")
+		if strings.HasSuffix(r.URL.Path, "/_ping") {
+			w.Write([]byte("OK"))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("DOCKER_HOST", "tcp://"+strings.TrimPrefix(server.URL, "http://"))
+	ds, err := NewDockerService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func TestSessionContainerConfig(t *testing.T) {
+	inspect := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			Name: "/github",
+			HostConfig: &container.HostConfig{
+				Mounts:      []mount.Mount{{Type: mount.TypeBind, Source: "/data", Target: "/data"}},
+				NetworkMode: "bridge",
+				Resources:   container.Resources{Memory: 512 * 1024 * 1024},
+			},
+		},
+		Config: &container.Config{
+			Image:      "example/github-mcp:1.0",
+			Env:        []string{"TOKEN=secret"},
+			Entrypoint: []string{"/server"},
+			Cmd:        []string{"stdio"},
+			OpenStdin:  true,
+			Labels:     map[string]string{"neobelt.managed-by": "true", LabelSessionContainers: "true"},
+		},
+	}
+
+	containerConfig, hostConfig := sessionContainerConfig(inspect)
+	if containerConfig.Image != "example/github-mcp:1.0" || containerConfig.Env[0] != "TOKEN=secret" ||
+		strings.Join(append(containerConfig.Entrypoint, containerConfig.Cmd...), " ") != "/server stdio" {
+		t.Errorf("expected the server's image, environment and command, got %+v", containerConfig)
+	}
+	if !containerConfig.OpenStdin || !containerConfig.StdinOnce || !containerConfig.AttachStdin {
+		t.Errorf("expected stdin to stay open for the session only, got %+v", containerConfig)
+	}
+
+	// Only the server's name, so the session container isn't mistaken for a managed server
+	if len(containerConfig.Labels) != 1 || containerConfig.Labels[labelSessionOf] != "github" {
+		t.Errorf("expected only the %s label, got %v", labelSessionOf, containerConfig.Labels)
+	}
+	if !hostConfig.AutoRemove || hostConfig.Memory != 512*1024*1024 || len(hostConfig.Mounts) != 1 || hostConfig.NetworkMode != "bridge" {
+		t.Errorf("expected the server's mounts, network and memory limit on a removed container, got %+v", hostConfig)
+	}
+
+	if command := serverCommand(inspect); strings.Join(command, " ") != "/server stdio" {
+		t.Errorf("expected entrypoint and command for shared sessions, got %v", command)
+	}
+}
+
+func TestBridgeStdio(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// The server echoes a line of stdin on stdout and logs on stderr, as Docker multiplexes it
+	go func() {
+		defer server.Close()
+		line := make([]byte, len("request\n"))
+		if _, err := io.ReadFull(server, line); err != nil {
+			return
+		}
+		stdcopy.NewStdWriter(server, stdcopy.Stdout).Write(bytes.ToUpper(line))
+		stdcopy.NewStdWriter(server, stdcopy.Stderr).Write([]byte("log\n"))
+	}()
+
+	var stdout, stderr bytes.Buffer
+	stream := types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}
+	if err := bridgeStdio(context.Background(), stream, strings.NewReader("request\n"), &stdout, &stderr); err != nil {
+		t.Fatalf("bridge failed: %v", err)
+	}
+	if stdout.String() != "REQUEST\n" || stderr.String() != "log\n" {
+		t.Errorf("expected the output split into stdout and stderr, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestAttachStdioRejectsHTTPServers(t *testing.T) {
+	ds := newTestDockerService(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/web/json") {
+			t.Errorf("unexpected Docker API call %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Id":"web","Name":"/web","State":{"Running":true},"Config":{"OpenStdin":false},"HostConfig":{}}`))
+	})
+
+	err := ds.AttachStdio(context.Background(), "web", strings.NewReader(""), io.Discard, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "does not run a stdio MCP server") {
+		t.Fatalf("expected an HTTP server to be refused, got %v", err)
+	}
+}
+
+func TestCreateContainerForStdioServer(t *testing.T) {
+	var created struct {
+		OpenStdin    bool
+		ExposedPorts map[string]struct{}
+		Labels       map[string]string
+		HostConfig   struct {
+			PortBindings map[string]interface{}
+		}
+	}
+	ds := newTestDockerService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"stdio-1"}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/stdio-1/json"):
+			w.Write([]byte(`{"Id":"stdio-1","Config":{"Labels":{}}}`))
+		default:
+			t.Errorf("unexpected Docker API call %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+
+	id, err := ds.CreateContainer(context.Background(), ContainerCreateConfig{
+		Name:      "github",
+		Image:     "example/github-mcp:1.0",
+		Labels:    map[string]string{LabelSessionContainers: "true"},
+		OpenStdin: true,
+	})
+	if err != nil || id != "stdio-1" {
+		t.Fatalf("expected the container to be created, got %q (%v)", id, err)
+	}
+	if !created.OpenStdin {
+		t.Error("expected stdin to stay open for a stdio server")
+	}
+	if len(created.ExposedPorts) != 0 || len(created.HostConfig.PortBindings) != 0 {
+		t.Errorf("expected no ports for a stdio server, got %v and %v", created.ExposedPorts, created.HostConfig.PortBindings)
+	}
+	if created.Labels["neobelt.managed-by"] != "true" || created.Labels[LabelSessionContainers] != "true" {
+		t.Errorf("expected the management and session labels, got %v", created.Labels)
+	}
+}
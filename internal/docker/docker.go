@@ -11,9 +11,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
@@ -53,8 +55,17 @@ type ContainerInfo struct {
 	CreatedAt   time.Time         `json:"created_at"`
 	StartedAt   time.Time         `json:"started_at"`
 	Labels      map[string]string `json:"labels"`
+	Health      string            `json:"health"`                 // HealthHealthy, HealthDegraded or HealthUnhealthy, empty if not checked yet
+	HealthError string            `json:"health_error,omitempty"` // why the last health check failed
 }
 
+// Health statuses of MCP servers, set by the health monitor
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
 // NewDockerService creates a new Docker service instance
 func NewDockerService() (*DockerService, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -81,7 +92,7 @@ func (ds *DockerService) GetManagedContainers(ctx context.Context) ([]ContainerI
 
 	var containerInfos []ContainerInfo
 	for _, cont := range containers {
-		info, err := ds.getContainerInfo(ctx, cont.ID)
+		info, err := ds.GetContainerInfo(ctx, cont.ID)
 		if err != nil {
 			logging.LogWarning("Failed to get info for container %s: %v", cont.ID, err)
 			continue
@@ -92,8 +103,47 @@ func (ds *DockerService) GetManagedContainers(ctx context.Context) ([]ContainerI
 	return containerInfos, nil
 }
 
-// getContainerInfo retrieves detailed information about a specific container
-func (ds *DockerService) getContainerInfo(ctx context.Context, containerID string) (*ContainerInfo, error) {
+// GetRunningManagedContainerIDs returns the short IDs of all running containers managed by neobelt
+func (ds *DockerService) GetRunningManagedContainerIDs(ctx context.Context) ([]string, error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", "neobelt.managed-by=true")
+	filterArgs.Add("status", "running")
+
+	containers, err := ds.client.ContainerList(ctx, container.ListOptions{
+		Filters: filterArgs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	ids := make([]string, 0, len(containers))
+	for _, cont := range containers {
+		ids = append(ids, cont.ID[:12])
+	}
+	return ids, nil
+}
+
+// GetContainerInfo retrieves detailed information about a specific container
+func (ds *DockerService) GetContainerInfo(ctx context.Context, containerID string) (*ContainerInfo, error) {
+	info, err := ds.InspectContainerInfo(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get real-time CPU and memory stats for running containers
+	if info.Status == "running" {
+		if cpuStat, memoryStat, err := ds.GetContainerStats(ctx, containerID); err == nil {
+			info.CPU = cpuStat
+			info.Memory = memoryStat
+		}
+	}
+
+	return info, nil
+}
+
+// InspectContainerInfo retrieves the information of a container like GetContainerInfo, but
+// without waiting for its CPU and memory stats
+func (ds *DockerService) InspectContainerInfo(ctx context.Context, containerID string) (*ContainerInfo, error) {
 	inspect, err := ds.client.ContainerInspect(ctx, containerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
@@ -144,16 +194,6 @@ func (ds *DockerService) getContainerInfo(ctx context.Context, containerID strin
 	createdAt, _ := time.Parse(time.RFC3339Nano, inspect.Created)
 	startedAt, _ := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
 
-	// Get real-time CPU and memory stats for running containers
-	cpu := "0%"
-	memory := "0MB"
-	if inspect.State.Running {
-		if cpuStat, memoryStat, err := ds.GetContainerStats(ctx, containerID); err == nil {
-			cpu = cpuStat
-			memory = memoryStat
-		}
-	}
-
 	return &ContainerInfo{
 		ID:          inspect.ID[:12], // Short ID
 		Name:        strings.TrimPrefix(inspect.Name, "/"),
@@ -161,8 +201,8 @@ func (ds *DockerService) getContainerInfo(ctx context.Context, containerID strin
 		Status:      inspect.State.Status,
 		State:       ds.mapContainerState(inspect.State.Status),
 		Uptime:      uptime,
-		CPU:         cpu,
-		Memory:      memory,
+		CPU:         "0%",
+		Memory:      "0MB",
 		Port:        port,
 		Version:     "", // Will be populated by App.GetManagedContainers
 		Environment: envMap,
@@ -173,6 +213,91 @@ func (ds *DockerService) getContainerInfo(ctx context.Context, containerID strin
 	}, nil
 }
 
+// RefreshStats updates uptime, CPU and memory of the running containers in place, fetching the
+// stats of all containers concurrently
+func (ds *DockerService) RefreshStats(ctx context.Context, containers []ContainerInfo) {
+	var wg sync.WaitGroup
+	for i := range containers {
+		container := &containers[i]
+		if container.Status != "running" {
+			continue
+		}
+
+		container.Uptime = formatUptime(time.Since(container.StartedAt))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cpu, memory, err := ds.GetContainerStats(ctx, container.ID); err == nil {
+				container.CPU = cpu
+				container.Memory = memory
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// ContainerEvent is a state change of a container managed by neobelt
+type ContainerEvent struct {
+	ContainerID string    `json:"container_id"` // short ID
+	Name        string    `json:"name"`
+	Action      string    `json:"action"`              // create, start, die, oom, health_status, destroy, ...
+	ExitCode    string    `json:"exit_code,omitempty"` // of die events
+	Health      string    `json:"health,omitempty"`    // Docker HEALTHCHECK status of health_status events
+	Time        time.Time `json:"time"`
+}
+
+// watchedContainerActions are the container events that change what neobelt shows
+var watchedContainerActions = []string{"create", "start", "restart", "die", "oom", "health_status", "pause", "unpause", "rename", "destroy"}
+
+// WatchManagedContainers streams state changes of the containers managed by neobelt. The event
+// channel is closed when the stream ends, the error channel then tells why. The stream has to be
+// reopened by the caller.
+func (ds *DockerService) WatchManagedContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("type", string(events.ContainerEventType))
+	filterArgs.Add("label", "neobelt.managed-by=true")
+	for _, action := range watchedContainerActions {
+		filterArgs.Add("event", action)
+	}
+
+	messages, errs := ds.client.Events(ctx, events.ListOptions{Filters: filterArgs})
+
+	containerEvents := make(chan ContainerEvent)
+	streamErr := make(chan error, 1)
+	go func() {
+		defer close(containerEvents)
+		for {
+			select {
+			case message := <-messages:
+				action, health, _ := strings.Cut(string(message.Action), ": ")
+				event := ContainerEvent{
+					ContainerID: message.Actor.ID,
+					Name:        message.Actor.Attributes["name"],
+					Action:      action,
+					ExitCode:    message.Actor.Attributes["exitCode"],
+					Health:      health,
+					Time:        time.Unix(0, message.TimeNano),
+				}
+				if len(event.ContainerID) > 12 {
+					event.ContainerID = event.ContainerID[:12]
+				}
+
+				select {
+				case containerEvents <- event:
+				case <-ctx.Done():
+					streamErr <- ctx.Err()
+					return
+				}
+			case err := <-errs:
+				streamErr <- fmt.Errorf("docker event stream ended: %w", err)
+				return
+			}
+		}
+	}()
+
+	return containerEvents, streamErr
+}
+
 // mapContainerState maps Docker container status to neobelt states
 func (ds *DockerService) mapContainerState(status string) string {
 	switch status {
@@ -409,6 +534,8 @@ func (ds *DockerService) CreateContainer(ctx context.Context, config ContainerCr
 		Env:    env,
 		Labels: config.Labels,
 		Cmd:    cmd,
+		// A stdio server waits for input instead of exiting when nothing is attached
+		OpenStdin: config.OpenStdin,
 	}
 
 	// Create host configuration
@@ -503,6 +630,7 @@ type ContainerCreateConfig struct {
 	DockerCommand string            `json:"docker_command"`  // Command arguments from registry
 	MemoryLimitMB int               `json:"memory_limit_mb"` // Memory limit in MB
 	RestartPolicy string            `json:"restart_policy"`  // Docker restart policy (no, always, on-failure, unless-stopped)
+	OpenStdin     bool              `json:"open_stdin"`      // keeps stdin open for stdio servers, see AttachStdio
 }
 
 // GetContainerStats gets real-time stats for a container
@@ -95,6 +95,15 @@ func (lb *LogBuffer) GetRecent(count int) []LogMessage {
 var appLogger *Logger
 var logBuffer *LogBuffer
 
+// consoleOutput receives info, warning and debug messages on the console
+var consoleOutput io.Writer = os.Stdout
+
+// SetConsoleOutput redirects console messages that normally go to stdout, which stdio-based
+// modes like the MCP gateway need for the protocol. Call it before InitLogger.
+func SetConsoleOutput(w io.Writer) {
+	consoleOutput = w
+}
+
 // InitLogger initializes the application logger
 func InitLogger(logDir string, debugMode bool) error {
 	// Create log file with current date
@@ -108,12 +117,12 @@ func InitLogger(logDir string, debugMode bool) error {
 	}
 
 	// Create multi-writers for both file and console output
-	infoWriter := io.MultiWriter(os.Stdout, logFile)
+	infoWriter := io.MultiWriter(consoleOutput, logFile)
 	errorWriter := io.MultiWriter(os.Stderr, logFile)
 	
 	var debugWriter io.Writer
 	if debugMode {
-		debugWriter = io.MultiWriter(os.Stdout, logFile)
+		debugWriter = io.MultiWriter(consoleOutput, logFile)
 	} else {
 		// When debug mode is off, don't write debug messages anywhere
 		debugWriter = io.Discard
@@ -154,7 +163,7 @@ func LogInfo(format string, args ...interface{}) {
 		appLogger.infoLogger.Printf(format, args...)
 	} else {
 		// Fallback to standard output if logger not initialized
-		fmt.Printf("[INFO] "+format+"\n", args...)
+		fmt.Fprintf(consoleOutput, "[INFO] "+format+"\n", args...)
 	}
 	
 	// Add to memory buffer
@@ -188,7 +197,7 @@ func LogDebug(format string, args ...interface{}) {
 		appLogger.debugLogger.Printf(format, args...)
 	} else if appLogger == nil {
 		// Fallback - only show if we can't determine debug mode
-		fmt.Printf("[DEBUG] "+format+"\n", args...)
+		fmt.Fprintf(consoleOutput, "[DEBUG] "+format+"\n", args...)
 	}
 	
 	// Add to memory buffer
@@ -205,7 +214,7 @@ func LogWarning(format string, args ...interface{}) {
 		// Use info logger with WARNING prefix
 		appLogger.infoLogger.Printf("[WARNING] "+format, args...)
 	} else {
-		fmt.Printf("[WARNING] "+format+"\n", args...)
+		fmt.Fprintf(consoleOutput, "[WARNING] "+format+"\n", args...)
 	}
 	
 	// Add to memory buffer
@@ -222,7 +231,7 @@ func SetDebugMode(enabled bool) {
 		// Recreate debug logger with appropriate writer
 		var debugWriter io.Writer
 		if enabled {
-			debugWriter = io.MultiWriter(os.Stdout, appLogger.logFile)
+			debugWriter = io.MultiWriter(consoleOutput, appLogger.logFile)
 		} else {
 			// When debug mode is off, don't write debug messages anywhere
 			debugWriter = io.Discard
@@ -0,0 +1,186 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"neobelt/internal/config"
+)
+
+// DefaultFile is the manifest read when no file is given
+const DefaultFile = "neobelt.yaml"
+
+// Manifest describes the registries and servers of a Neobelt setup, so it can be checked into
+// git and applied on every laptop of a team
+type Manifest struct {
+	Registries []Registry `json:"registries" yaml:"registries"`
+	Servers    []Server   `json:"servers" yaml:"servers"`
+
+	// Remove configured servers that aren't in the manifest
+	Prune bool `json:"prune" yaml:"prune"`
+}
+
+// Registry is a custom registry the servers can be installed from
+type Registry struct {
+	Name        string `json:"name" yaml:"name"`
+	URL         string `json:"url" yaml:"url"`
+	Description string `json:"description" yaml:"description"`
+	Auth        Auth   `json:"auth" yaml:"auth"`
+}
+
+// Auth is how to authenticate against a registry
+type Auth struct {
+	Type     string `json:"type" yaml:"type"` // none, basic or header
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Header   string `json:"header" yaml:"header"` // e.g. "Authorization: Bearer ${TOKEN}"
+}
+
+// Server is a configured server, i.e. a container of a registry server or a Docker image
+type Server struct {
+	Name     string `json:"name" yaml:"name"`         // container name
+	Server   string `json:"server" yaml:"server"`     // name of the registry server
+	Registry string `json:"registry" yaml:"registry"` // registry offering the server, if several do
+	Image    string `json:"image" yaml:"image"`       // Docker image, instead of a registry server
+	Version  string `json:"version" yaml:"version"`   // image tag, the registry's version by default
+	Port     int    `json:"port" yaml:"port"`         // host port, the first free one by default, none for stdio servers
+
+	Environment map[string]string `json:"environment" yaml:"environment"`
+	Volumes     map[string]string `json:"volumes" yaml:"volumes"` // host path -> container path
+	ToolPolicy  config.ToolPolicy `json:"tool_policy" yaml:"tool_policy"`
+
+	// Give every client session of a stdio server its own container
+	SessionContainers bool `json:"session_containers" yaml:"session_containers"`
+
+	// Register the server in Claude Desktop when it is created
+	Claude bool `json:"claude" yaml:"claude"`
+}
+
+// Load reads a manifest and replaces ${VAR} references with the environment variables of the
+// current process, so tokens stay out of git
+func Load(path string) (*Manifest, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse parses a manifest, expands its ${VAR} references and validates it
+func Parse(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if err := manifest.expand(); err != nil {
+		return nil, err
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Validate checks that servers and registries are named uniquely and fully described
+func (m *Manifest) Validate() error {
+	registries := make(map[string]bool)
+	for _, registry := range m.Registries {
+		switch {
+		case registry.Name == "":
+			return fmt.Errorf("registry %s has no name", registry.URL)
+		case registry.Name == "Official Registry":
+			return fmt.Errorf("the official registry is always configured and can't be changed")
+		case registries[registry.Name]:
+			return fmt.Errorf("registry %s is listed twice", registry.Name)
+		case !strings.HasPrefix(registry.URL, "https://"):
+			return fmt.Errorf("registry %s must use HTTPS", registry.Name)
+		}
+		switch registry.Auth.Type {
+		case "", "none", "basic", "header":
+		default:
+			return fmt.Errorf("registry %s has unknown auth type %q (expected none, basic or header)", registry.Name, registry.Auth.Type)
+		}
+		registries[registry.Name] = true
+	}
+
+	servers := make(map[string]bool)
+	for _, server := range m.Servers {
+		switch {
+		case server.Name == "":
+			return fmt.Errorf("every server needs a name")
+		case servers[server.Name]:
+			return fmt.Errorf("server %s is listed twice", server.Name)
+		case (server.Server == "") == (server.Image == ""):
+			return fmt.Errorf("server %s needs either a registry server or an image", server.Name)
+		case server.Image != "" && server.Registry != "":
+			return fmt.Errorf("server %s has an image, so it can't name a registry", server.Name)
+		case server.Image != "" && server.SessionContainers:
+			return fmt.Errorf("server %s has an image, only stdio registry servers can have session containers", server.Name)
+		case server.Port < 0 || server.Port > 65535:
+			return fmt.Errorf("server %s has invalid port %d", server.Name, server.Port)
+		}
+		if err := server.ToolPolicy.Validate(); err != nil {
+			return fmt.Errorf("server %s: %w", server.Name, err)
+		}
+		servers[server.Name] = true
+	}
+	return nil
+}
+
+// reference matches ${VAR} references to environment variables
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expand replaces the ${VAR} references in environment values, volumes and registry auth
+func (m *Manifest) expand() error {
+	var missing []string
+	expand := func(value string) string {
+		return reference.ReplaceAllStringFunc(value, func(match string) string {
+			name := reference.FindStringSubmatch(match)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+
+	for i := range m.Registries {
+		auth := &m.Registries[i].Auth
+		auth.Username = expand(auth.Username)
+		auth.Password = expand(auth.Password)
+		auth.Header = expand(auth.Header)
+	}
+	for i := range m.Servers {
+		server := &m.Servers[i]
+		for name, value := range server.Environment {
+			server.Environment[name] = expand(value)
+		}
+		volumes := make(map[string]string, len(server.Volumes))
+		for hostPath, containerPath := range server.Volumes {
+			volumes[expand(hostPath)] = expand(containerPath)
+		}
+		server.Volumes = volumes
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("the manifest references unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"neobelt/internal/config"
+)
+
+func TestParseExpandsReferences(t *testing.T) {
+	t.Setenv("NEOBELT_TEST_TOKEN", "secret")
+	t.Setenv("NEOBELT_TEST_HOME", "/home/jane")
+
+	manifest, err := Parse([]byte(`
+registries:
+  - name: Team
+    url: https://registry.example.com
+    auth:
+      type: header
+      header: "Authorization: Bearer ${NEOBELT_TEST_TOKEN}"
+servers:
+  - name: github
+    server: github
+    environment:
+      GITHUB_TOKEN: ${NEOBELT_TEST_TOKEN}
+      LITERAL: $NEOBELT_TEST_TOKEN
+    volumes:
+      ${NEOBELT_TEST_HOME}/data: /data
+`))
+	if err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+
+	if header := manifest.Registries[0].Auth.Header; header != "Authorization: Bearer secret" {
+		t.Errorf("expected the header to be expanded, got %q", header)
+	}
+	server := manifest.Servers[0]
+	if server.Environment["GITHUB_TOKEN"] != "secret" {
+		t.Errorf("expected the environment value to be expanded, got %q", server.Environment["GITHUB_TOKEN"])
+	}
+	if server.Environment["LITERAL"] != "$NEOBELT_TEST_TOKEN" {
+		t.Errorf("expected only ${VAR} to be expanded, got %q", server.Environment["LITERAL"])
+	}
+	if server.Volumes["/home/jane/data"] != "/data" {
+		t.Errorf("expected the volume path to be expanded, got %v", server.Volumes)
+	}
+}
+
+func TestParseReportsMissingVariables(t *testing.T) {
+	_, err := Parse([]byte(`
+servers:
+  - name: github
+    server: github
+    environment:
+      A: ${NEOBELT_TEST_MISSING_A}
+      B: ${NEOBELT_TEST_MISSING_A}-${NEOBELT_TEST_MISSING_B}
+`))
+	if err == nil {
+		t.Fatal("expected an error for unset variables")
+	}
+	for _, name := range []string{"NEOBELT_TEST_MISSING_A", "NEOBELT_TEST_MISSING_B"} {
+		if strings.Count(err.Error(), name) != 1 {
+			t.Errorf("expected %s to be named once, got %v", name, err)
+		}
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	if _, err := Parse([]byte("servers:\n  - name: github\n    server: github\n    enviroment:\n      A: b\n")); err == nil {
+		t.Error("expected an error for a misspelled field")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		err      string
+	}{
+		{name: "empty", manifest: Manifest{}},
+		{name: "registry server", manifest: Manifest{Servers: []Server{{Name: "github", Server: "github", Port: 8001}}}},
+		{name: "image", manifest: Manifest{Servers: []Server{{Name: "custom", Image: "example/mcp:1.0"}}}},
+		{name: "registry without name", manifest: Manifest{Registries: []Registry{{URL: "https://example.com"}}}, err: "has no name"},
+		{name: "official registry", manifest: Manifest{Registries: []Registry{{Name: "Official Registry", URL: "https://example.com"}}}, err: "always configured"},
+		{name: "registry twice", manifest: Manifest{Registries: []Registry{{Name: "Team", URL: "https://a.example.com"}, {Name: "Team", URL: "https://b.example.com"}}}, err: "listed twice"},
+		{name: "registry over HTTP", manifest: Manifest{Registries: []Registry{{Name: "Team", URL: "http://example.com"}}}, err: "must use HTTPS"},
+		{name: "unknown auth type", manifest: Manifest{Registries: []Registry{{Name: "Team", URL: "https://example.com", Auth: Auth{Type: "oauth"}}}}, err: "unknown auth type"},
+		{name: "server without name", manifest: Manifest{Servers: []Server{{Server: "github"}}}, err: "needs a name"},
+		{name: "server twice", manifest: Manifest{Servers: []Server{{Name: "github", Server: "github"}, {Name: "github", Image: "example/mcp"}}}, err: "listed twice"},
+		{name: "neither server nor image", manifest: Manifest{Servers: []Server{{Name: "github"}}}, err: "either a registry server or an image"},
+		{name: "both server and image", manifest: Manifest{Servers: []Server{{Name: "github", Server: "github", Image: "example/mcp"}}}, err: "either a registry server or an image"},
+		{name: "image with registry", manifest: Manifest{Servers: []Server{{Name: "custom", Image: "example/mcp", Registry: "Team"}}}, err: "can't name a registry"},
+		{name: "image with session containers", manifest: Manifest{Servers: []Server{{Name: "custom", Image: "example/mcp", SessionContainers: true}}}, err: "session containers"},
+		{name: "invalid port", manifest: Manifest{Servers: []Server{{Name: "github", Server: "github", Port: 70000}}}, err: "invalid port"},
+		{name: "invalid tool pattern", manifest: Manifest{Servers: []Server{{Name: "github", Server: "github", ToolPolicy: config.ToolPolicy{Deny: []string{"["}}}}}, err: "invalid tool pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.manifest.Validate()
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package manifest
+
+// Kinds of plan actions, in the order they are applied
+const (
+	ActionAddRegistry    = "add-registry"
+	ActionUpdateRegistry = "update-registry"
+	ActionInstall        = "install"
+	ActionRemove         = "remove"
+	ActionRecreate       = "recreate"
+	ActionCreate         = "create"
+	ActionUpdatePolicy   = "update-tool-policy"
+	ActionStart          = "start"
+)
+
+// Action is a change needed to converge on the manifest
+type Action struct {
+	Kind    string   `json:"kind"`
+	Target  string   `json:"target"`            // registry, server or image
+	Details []string `json:"details,omitempty"` // what differs, e.g. "version 1.0.0 -> 1.1.0"
+}
+
+// Plan lists the changes needed to converge on a manifest
+type Plan struct {
+	Actions []Action `json:"actions"`
+}
+
+// HasChanges reports whether the setup differs from the manifest
+func (p *Plan) HasChanges() bool {
+	return len(p.Actions) > 0
+}
+
+// Symbol returns the prefix of an action in plan output: + adds, ~ changes, - removes
+func (a Action) Symbol() string {
+	switch a.Kind {
+	case ActionAddRegistry, ActionInstall, ActionCreate:
+		return "+"
+	case ActionRemove:
+		return "-"
+	default:
+		return "~"
+	}
+}
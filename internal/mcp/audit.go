@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"neobelt/internal/audit"
+)
+
+// toolCallParams returns the params of a tools/call request, nil for any other message
+func toolCallParams(message JSONRPCMessage) json.RawMessage {
+	if message.Method != "tools/call" {
+		return nil
+	}
+	if message.Params == nil {
+		return json.RawMessage("{}")
+	}
+	return message.Params
+}
+
+// auditStatus classifies the outcome of a tools/call request by its response
+func auditStatus(result json.RawMessage, rpcErr *JSONRPCError) (string, string) {
+	if rpcErr != nil {
+		if rpcErr.Code == toolDeniedCode {
+			return audit.StatusDenied, rpcErr.Message
+		}
+		return audit.StatusError, rpcErr.Message
+	}
+
+	var toolResult struct {
+		IsError bool `json:"isError"`
+	}
+	if len(result) > 0 {
+		_ = json.Unmarshal(result, &toolResult)
+	}
+	if toolResult.IsError {
+		return audit.StatusToolError, ""
+	}
+	return audit.StatusSuccess, ""
+}
+
+// newAuditRecord describes a finished tools/call request
+func newAuditRecord(server, client string, params json.RawMessage, started time.Time, status, errorMessage string) audit.Record {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	_ = json.Unmarshal(params, &call)
+
+	return audit.Record{
+		Time:          started,
+		Server:        server,
+		Client:        client,
+		Method:        "tools/call",
+		Tool:          call.Name,
+		ArgumentsHash: audit.HashArguments(call.Arguments),
+		Arguments:     audit.RedactArguments(call.Arguments),
+		Status:        status,
+		Error:         errorMessage,
+		DurationMs:    time.Since(started).Milliseconds(),
+	}
+}
+
+// appendAuditRecord writes a record to the audit log, failures are only reported on stderr
+func appendAuditRecord(log *audit.Log, record audit.Record) {
+	if err := log.Append(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write audit record: %v\n", err)
+	}
+}
+
+// auditResponse records the response to a tools/call request
+func (p *MCPProxy) auditResponse(request *inflightRequest, response JSONRPCMessage) {
+	if p.auditLog == nil || request.params == nil {
+		return
+	}
+
+	status, errorMessage := auditStatus(response.Result, response.Error)
+	appendAuditRecord(p.auditLog, newAuditRecord(p.serverName, p.clientName(), request.params, request.started, status, errorMessage))
+}
+
+// auditCancelled records a tools/call request the client cancelled
+func (p *MCPProxy) auditCancelled(request *inflightRequest, reason string) {
+	if p.auditLog == nil || request.params == nil {
+		return
+	}
+
+	appendAuditRecord(p.auditLog, newAuditRecord(p.serverName, p.clientName(), request.params, request.started, audit.StatusCancelled, reason))
+}
+
+// auditRejected records a tools/call request the tool policy rejected
+func (p *MCPProxy) auditRejected(request JSONRPCMessage, rejection JSONRPCMessage) {
+	if p.auditLog == nil {
+		return
+	}
+
+	appendAuditRecord(p.auditLog, newAuditRecord(p.serverName, p.clientName(), toolCallParams(request), time.Now(), audit.StatusRejected, rejection.Error.Message))
+}
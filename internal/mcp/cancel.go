@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// cancelNotifyTimeout bounds how long we try to tell the server about cancelled requests on shutdown
+const cancelNotifyTimeout = 5 * time.Second
+
+// errRequestCancelled is the cancellation cause of requests the client cancelled
+var errRequestCancelled = errors.New("request cancelled")
+
+// cancelRequest stops waiting for the request referenced by a notifications/cancelled message.
+// Per the MCP spec no response is sent for it anymore, even if the server still answers.
+func (p *MCPProxy) cancelRequest(params json.RawMessage) {
+	var cancelled struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason"`
+	}
+	if err := json.Unmarshal(params, &cancelled); err != nil || cancelled.RequestID == nil {
+		return
+	}
+
+	key := idKey(cancelled.RequestID)
+
+	p.inflightMutex.Lock()
+	request, exists := p.inflight[key]
+	if exists {
+		delete(p.inflight, key)
+		close(request.done)
+	}
+	p.inflightMutex.Unlock()
+
+	if !exists {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Request %s (%s) cancelled by the client: %s\n", key, request.method, cancelled.Reason)
+	p.auditCancelled(request, cancelled.Reason)
+	if request.cancel != nil {
+		request.cancel()
+	}
+}
+
+// cancelAllRequests cancels every in-flight request and returns their IDs
+func (p *MCPProxy) cancelAllRequests() []interface{} {
+	p.inflightMutex.Lock()
+	var ids []interface{}
+	var cancels []func()
+	for key, request := range p.inflight {
+		var id interface{}
+		if err := json.Unmarshal([]byte(key), &id); err == nil {
+			ids = append(ids, id)
+		}
+		if request.cancel != nil {
+			cancels = append(cancels, request.cancel)
+		}
+		delete(p.inflight, key)
+		close(request.done)
+	}
+	p.inflightMutex.Unlock()
+
+	// Cancel functions look at the in-flight table themselves, so call them without the lock
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return ids
+}
+
+// notifyCancelled tells the server that the given requests won't be waited for anymore
+func (p *MCPProxy) notifyCancelled(ids []interface{}, reason string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+
+	for _, id := range ids {
+		params, err := json.Marshal(map[string]interface{}{
+			"requestId": id,
+			"reason":    reason,
+		})
+		if err != nil {
+			continue
+		}
+
+		p.dispatch(ctx, []JSONRPCMessage{{
+			JSONRPC: "2.0",
+			Method:  "notifications/cancelled",
+			Params:  params,
+		}}, false)
+	}
+}
@@ -0,0 +1,44 @@
+package mcp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCancelledRequestStopsBeforeServerIsTold(t *testing.T) {
+	// The server never answers anything, not even the cancellation
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	t.Cleanup(func() { close(release) })
+
+	tp.send(t, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"slow"}}`)
+	waitFor(t, func() bool { return tp.proxy.requestDone(7) != nil })
+
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user"}}`)
+	waitFor(t, func() bool { return tp.proxy.requestDone(7) == nil })
+}
+
+// waitFor polls condition until it holds, failing the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
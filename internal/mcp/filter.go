@@ -0,0 +1,354 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+)
+
+// ToolFilterOptions configure the tool policy, approval and auditing of a stdio connection
+type ToolFilterOptions struct {
+	ToolPolicy      config.ToolPolicy // tools hidden from tools/list, rejected in tools/call or requiring approval
+	ServerName      string            // name of the server shown when asking for approval and in the audit log
+	ApprovalTimeout time.Duration     // how long tool calls wait for approval, 0 for approval.DefaultTimeout
+	AuditLog        *audit.Log        // tools/call requests are recorded here, nil to disable auditing
+}
+
+// ToolFilter sits between an MCP client and a stdio server and applies the server's tool policy,
+// asks for approval and audits tool calls, like MCPProxy does for HTTP servers
+type ToolFilter struct {
+	toolPolicy      config.ToolPolicy
+	serverName      string
+	approvalTimeout time.Duration
+	auditLog        *audit.Log
+
+	// Requests of the client whose responses the filter has to see, by JSON-RPC ID
+	mutex      sync.Mutex
+	clientName string
+	toolLists  map[string]bool
+	toolCalls  map[string]filteredToolCall
+
+	serverMutex sync.Mutex
+	serverIn    io.Writer
+	clientMutex sync.Mutex
+	clientOut   io.Writer
+}
+
+// filteredToolCall is a tools/call request waiting for its response
+type filteredToolCall struct {
+	params  json.RawMessage
+	started time.Time
+}
+
+// NewToolFilter creates a filter for the given options
+func NewToolFilter(options ToolFilterOptions) *ToolFilter {
+	return &ToolFilter{
+		toolPolicy:      options.ToolPolicy,
+		serverName:      options.ServerName,
+		approvalTimeout: options.ApprovalTimeout,
+		auditLog:        options.AuditLog,
+		toolLists:       make(map[string]bool),
+		toolCalls:       make(map[string]filteredToolCall),
+	}
+}
+
+// Run connects the client's stdin and stdout to a server started by run, which talks to the
+// server through the given stdin and stdout, until the server's output ends
+func (f *ToolFilter) Run(ctx context.Context, in io.Reader, out io.Writer, run func(stdin io.Reader, stdout io.Writer) error) error {
+	serverInReader, serverInWriter := io.Pipe()
+	serverOutReader, serverOutWriter := io.Pipe()
+	f.serverIn = serverInWriter
+	f.clientOut = out
+
+	runDone := make(chan error, 1)
+	go func() {
+		err := run(serverInReader, serverOutWriter)
+		serverOutWriter.Close()
+		serverInReader.Close() // writes to a server that is gone fail
+		runDone <- err
+	}()
+
+	go func() {
+		f.readClient(ctx, in)
+		serverInWriter.Close()
+	}()
+
+	f.readServer(serverOutReader)
+	return <-runDone
+}
+
+// readClient forwards the client's messages to the server until stdin ends. Calls waiting
+// for approval are sent before the server's stdin is closed.
+func (f *ToolFilter) readClient(ctx context.Context, in io.Reader) {
+	var approvals sync.WaitGroup
+	defer approvals.Wait()
+
+	reader := bufio.NewReader(in)
+	for {
+		line, err := readLine(reader, 0)
+		if len(bytes.TrimSpace(line)) > 0 {
+			f.handleClientLine(ctx, line, &approvals)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleClientLine rejects tool calls the policy doesn't allow and forwards everything else,
+// after asking for approval where needed
+func (f *ToolFilter) handleClientLine(ctx context.Context, line []byte, approvals *sync.WaitGroup) {
+	messages, batch, err := parseMessages(line)
+	if err != nil {
+		// The server answers with a parse error
+		f.writeServer(line)
+		return
+	}
+
+	forward := make([]JSONRPCMessage, 0, len(messages))
+	var rejected []JSONRPCMessage
+	needsApproval := false
+	for _, message := range messages {
+		switch {
+		case message.Method == "initialize" && isRequest(message):
+			f.mutex.Lock()
+			f.clientName = initializeClientName(message.Params)
+			f.mutex.Unlock()
+
+		case message.Method == "tools/list" && isRequest(message) && !f.toolPolicy.IsEmpty():
+			f.mutex.Lock()
+			f.toolLists[idKey(message.ID)] = true
+			f.mutex.Unlock()
+
+		case message.Method == "notifications/cancelled":
+			f.cancelToolCall(message.Params)
+
+		case message.Method == "tools/call" && isRequest(message):
+			tool := toolCallName(message.Params)
+			if !f.toolPolicy.Allows(tool) {
+				fmt.Fprintf(os.Stderr, "Rejecting call of tool %q, it is not allowed by the tool policy\n", tool)
+				response := JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Error: toolNotAllowedError(tool)}
+				f.audit(toolCallParams(message), time.Now(), audit.StatusRejected, response.Error.Message)
+				rejected = append(rejected, response)
+				continue
+			}
+
+			f.mutex.Lock()
+			f.toolCalls[idKey(message.ID)] = filteredToolCall{params: toolCallParams(message), started: time.Now()}
+			f.mutex.Unlock()
+			needsApproval = needsApproval || f.toolPolicy.NeedsApproval(tool)
+		}
+		forward = append(forward, message)
+	}
+
+	if len(rejected) > 0 {
+		f.writeClientMessages(rejected, batch)
+	}
+	if len(forward) == 0 {
+		return
+	}
+
+	switch {
+	case needsApproval:
+		// Other messages keep flowing while the user decides
+		approvals.Add(1)
+		go func() {
+			defer approvals.Done()
+			if approved := f.approveToolCalls(ctx, forward, batch); len(approved) > 0 {
+				f.writeServerMessages(approved, batch)
+			}
+		}()
+	case len(rejected) > 0:
+		f.writeServerMessages(forward, batch)
+	default:
+		f.writeServer(line)
+	}
+}
+
+// approveToolCalls asks the user about every tools/call request that needs approval. Denied
+// requests are answered with an error and left out of the returned messages.
+func (f *ToolFilter) approveToolCalls(ctx context.Context, messages []JSONRPCMessage, batch bool) []JSONRPCMessage {
+	approved := make([]JSONRPCMessage, 0, len(messages))
+	var denied []JSONRPCMessage
+	for _, message := range messages {
+		tool := toolCallName(message.Params)
+		if message.Method != "tools/call" || !isRequest(message) || !f.toolPolicy.NeedsApproval(tool) {
+			approved = append(approved, message)
+			continue
+		}
+
+		ok, reason := askToolApproval(ctx, f.serverName, f.currentClientName(), message.Params, f.approvalTimeout)
+		if ok {
+			approved = append(approved, message)
+			continue
+		}
+
+		response := JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Error: toolDeniedError(tool, reason)}
+		if call, exists := f.finishToolCall(message.ID); exists {
+			f.audit(call.params, call.started, audit.StatusDenied, reason)
+		}
+		denied = append(denied, response)
+	}
+
+	if len(denied) > 0 {
+		f.writeClientMessages(denied, batch)
+	}
+	return approved
+}
+
+// cancelToolCall audits a tools/call request the client cancelled. The notification itself
+// still goes to the server.
+func (f *ToolFilter) cancelToolCall(params json.RawMessage) {
+	var cancelled struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason"`
+	}
+	if err := json.Unmarshal(params, &cancelled); err != nil || cancelled.RequestID == nil {
+		return
+	}
+
+	if call, exists := f.finishToolCall(cancelled.RequestID); exists {
+		f.audit(call.params, call.started, audit.StatusCancelled, cancelled.Reason)
+	}
+}
+
+// readServer forwards the server's messages to the client until its output ends
+func (f *ToolFilter) readServer(out io.Reader) {
+	reader := bufio.NewReader(out)
+	for {
+		line, err := readLine(reader, 0)
+		if len(bytes.TrimSpace(line)) > 0 {
+			f.handleServerLine(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleServerLine filters tools/list results and audits tool call responses
+func (f *ToolFilter) handleServerLine(line []byte) {
+	messages, batch, err := parseMessages(line)
+	if err != nil {
+		f.writeClient(line)
+		return
+	}
+
+	modified := false
+	for i, message := range messages {
+		if !isResponse(message) {
+			continue
+		}
+		key := idKey(message.ID)
+
+		f.mutex.Lock()
+		toolList := f.toolLists[key]
+		delete(f.toolLists, key)
+		f.mutex.Unlock()
+		if toolList && message.Result != nil {
+			if filtered, changed := filterToolsResult(message.Result, f.toolPolicy); changed {
+				messages[i].Result = filtered
+				modified = true
+			}
+		}
+
+		if call, exists := f.finishToolCall(message.ID); exists {
+			status, errorMessage := auditStatus(message.Result, message.Error)
+			f.audit(call.params, call.started, status, errorMessage)
+		}
+	}
+
+	if modified {
+		f.writeClientMessages(messages, batch)
+	} else {
+		f.writeClient(line)
+	}
+}
+
+// finishToolCall stops tracking a tools/call request, reporting whether it was tracked
+func (f *ToolFilter) finishToolCall(id interface{}) (filteredToolCall, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := idKey(id)
+	call, exists := f.toolCalls[key]
+	delete(f.toolCalls, key)
+	return call, exists
+}
+
+// currentClientName returns the name the MCP client gave in its initialize request
+func (f *ToolFilter) currentClientName() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.clientName
+}
+
+// audit records a finished tools/call request
+func (f *ToolFilter) audit(params json.RawMessage, started time.Time, status, errorMessage string) {
+	if f.auditLog == nil {
+		return
+	}
+	appendAuditRecord(f.auditLog, newAuditRecord(f.serverName, f.currentClientName(), params, started, status, errorMessage))
+}
+
+// writeServerMessages sends messages to the server, as a JSON array if they are part of a batch
+func (f *ToolFilter) writeServerMessages(messages []JSONRPCMessage, batch bool) {
+	if raw, err := marshalMessages(messages, batch); err == nil {
+		f.writeServer(raw)
+	}
+}
+
+// writeClientMessages sends messages to the client, as a JSON array if they answer a batch
+func (f *ToolFilter) writeClientMessages(messages []JSONRPCMessage, batch bool) {
+	if raw, err := marshalMessages(messages, batch); err == nil {
+		f.writeClient(raw)
+	}
+}
+
+// writeServer writes a line to the server's stdin
+func (f *ToolFilter) writeServer(line []byte) {
+	f.serverMutex.Lock()
+	defer f.serverMutex.Unlock()
+	f.serverIn.Write(terminateLine(line))
+}
+
+// writeClient writes a line to the client's stdout
+func (f *ToolFilter) writeClient(line []byte) {
+	f.clientMutex.Lock()
+	defer f.clientMutex.Unlock()
+	if _, err := f.clientOut.Write(terminateLine(line)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing message to stdout: %v\n", err)
+	}
+}
+
+// marshalMessages encodes a single message or a batch of messages
+func marshalMessages(messages []JSONRPCMessage, batch bool) ([]byte, error) {
+	var raw []byte
+	var err error
+	if batch {
+		raw, err = json.Marshal(messages)
+	} else {
+		raw, err = json.Marshal(messages[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling messages: %v\n", err)
+	}
+	return raw, err
+}
+
+// terminateLine makes sure a line ends with a newline
+func terminateLine(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		return line
+	}
+	return append(line, '\n')
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+)
+
+// stdioTestServer answers requests on stdin like a stdio MCP server and records the tools called
+type stdioTestServer struct {
+	mutex  sync.Mutex
+	called []string
+}
+
+func (s *stdioTestServer) run(stdin io.Reader, stdout io.Writer) error {
+	reader := bufio.NewReader(stdin)
+	for {
+		line, err := readLine(reader, 0)
+		if messages, batch, parseErr := parseMessages(line); parseErr == nil {
+			var responses []JSONRPCMessage
+			for _, message := range messages {
+				if !isRequest(message) {
+					continue
+				}
+				result := `{}`
+				switch message.Method {
+				case "tools/list":
+					result = `{"tools":[{"name":"issue_search"},{"name":"issue_delete"}]}`
+				case "tools/call":
+					s.mutex.Lock()
+					s.called = append(s.called, toolCallName(message.Params))
+					s.mutex.Unlock()
+					result = `{"content":[]}`
+				}
+				responses = append(responses, JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: json.RawMessage(result)})
+			}
+			if len(responses) > 0 {
+				raw, _ := marshalMessages(responses, batch)
+				stdout.Write(append(raw, '\n'))
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func TestToolFilterEnforcesPolicyAndAudits(t *testing.T) {
+	auditLog, err := audit.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+
+	filter := NewToolFilter(ToolFilterOptions{
+		ToolPolicy: config.ToolPolicy{Deny: []string{"*_delete"}},
+		ServerName: "stdio-server",
+		AuditLog:   auditLog,
+	})
+
+	server := &stdioTestServer{}
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- filter.Run(context.Background(), inReader, outWriter, server.run)
+		outWriter.Close()
+	}()
+	stdout := bufio.NewReader(outReader)
+	tp := &testProxy{stdin: inWriter, stdout: stdout}
+
+	tp.send(t, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"clientInfo":{"name":"claude-ai"}}}`)
+	tp.receive(t, 2*time.Second)
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	var result struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if response := tp.receive(t, 2*time.Second); json.Unmarshal(response.Result, &result) != nil || len(result.Tools) != 1 || result.Tools[0].Name != "issue_search" {
+		t.Fatalf("expected only issue_search to be listed, got %s", response.Result)
+	}
+
+	tp.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"issue_delete","arguments":{"id":7}}}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(2) || response.Error == nil || response.Error.Code != -32602 {
+		t.Fatalf("expected a tool not allowed error, got %+v", response)
+	}
+
+	// In a batch only the allowed call goes to the server, the rejection is answered separately
+	tp.send(t, `[{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"issue_delete"}},{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"issue_search"}}]`)
+	for _, want := range []float64{3, 4} {
+		var responses []JSONRPCMessage
+		line := tp.receiveLine(t, 2*time.Second)
+		if err := json.Unmarshal([]byte(line), &responses); err != nil || len(responses) != 1 || responses[0].ID != want {
+			t.Fatalf("expected a batch with the response to request %v, got %q", want, line)
+		}
+	}
+
+	inWriter.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("filter failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("filter did not stop after stdin was closed")
+	}
+
+	server.mutex.Lock()
+	if len(server.called) != 1 || server.called[0] != "issue_search" {
+		t.Errorf("expected only the allowed call to reach the server, got %v", server.called)
+	}
+	server.mutex.Unlock()
+
+	records, err := audit.Read(auditLog.Path(), audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, record := range records {
+		statuses = append(statuses, record.Tool+" "+record.Status)
+		if record.Server != "stdio-server" || record.Client != "claude-ai" {
+			t.Errorf("expected server and client in the audit record, got %+v", record)
+		}
+	}
+	want := []string{"issue_delete rejected", "issue_delete rejected", "issue_search success"}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected audit records %v, got %v", want, statuses)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("expected audit records %v, got %v", want, statuses)
+			break
+		}
+	}
+}
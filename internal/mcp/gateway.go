@@ -0,0 +1,910 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/version"
+)
+
+// DefaultGatewayPollInterval is how often the gateway retries servers that weren't ready yet.
+// Started and stopped containers are picked up right away from Docker events.
+const DefaultGatewayPollInterval = 10 * time.Second
+
+// gatewaySyncActions are the container events after which the gateway looks at the containers again
+var gatewaySyncActions = map[string]bool{
+	"start":         true,
+	"restart":       true,
+	"die":           true,
+	"pause":         true,
+	"unpause":       true,
+	"rename":        true,
+	"destroy":       true,
+	"health_status": true,
+}
+
+// GatewayNamespaceSeparator joins a server's namespace and the names of its tools and prompts
+const GatewayNamespaceSeparator = "__"
+
+// GatewayOptions holds optional settings for the MCP gateway
+type GatewayOptions struct {
+	PollInterval    time.Duration // how often to retry servers that weren't ready yet
+	ApprovalTimeout time.Duration // how long tool calls wait for approval, 0 for approval.DefaultTimeout
+	AuditLog        *audit.Log    // tools/call requests are recorded here, nil to disable auditing
+}
+
+// Gateway is a single MCP server on stdin/stdout that aggregates every running Neobelt
+// container. Tools and prompts are namespaced with the container name, resources keep their
+// URIs and are routed to the server that listed them.
+type Gateway struct {
+	docker          *docker.DockerService
+	configManager   *config.ConfigManager
+	pollInterval    time.Duration
+	approvalTimeout time.Duration
+	auditLog        *audit.Log
+	in              io.Reader
+	out             io.Writer
+	writeMutex      sync.Mutex
+
+	mutex            sync.Mutex
+	initializeParams json.RawMessage
+	backends         map[string]*gatewayBackend   // by namespace
+	resourceOwners   map[string]string            // resource URI -> namespace
+	toolPolicies     map[string]config.ToolPolicy // by namespace
+
+	// Requests from servers forwarded to the client, keyed by the ID the client sees
+	serverRequests      map[string]gatewayServerRequest
+	nextServerRequestID int
+
+	// Client requests being handled, for notifications/cancelled
+	clientRequests map[string]context.CancelCauseFunc
+
+	wg              sync.WaitGroup // request handlers and the container watcher
+	initializedOnce sync.Once
+	synced          chan struct{} // closed after the first look for containers
+}
+
+// gatewayServerRequest is a request from a server waiting for the client's response
+type gatewayServerRequest struct {
+	backend *gatewayBackend
+	id      interface{}
+}
+
+// NewGateway creates a gateway for the containers managed by Neobelt
+func NewGateway(options GatewayOptions) (*Gateway, error) {
+	dockerService, err := docker.NewDockerService()
+	if err != nil {
+		return nil, err
+	}
+
+	configManager, err := config.NewConfigManager()
+	if err != nil {
+		return nil, err
+	}
+
+	return newGateway(dockerService, configManager, options), nil
+}
+
+// newGateway creates a gateway on stdin/stdout with the given Docker service and configuration
+func newGateway(dockerService *docker.DockerService, configManager *config.ConfigManager, options GatewayOptions) *Gateway {
+	pollInterval := options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultGatewayPollInterval
+	}
+
+	return &Gateway{
+		docker:          dockerService,
+		configManager:   configManager,
+		pollInterval:    pollInterval,
+		approvalTimeout: options.ApprovalTimeout,
+		auditLog:        options.AuditLog,
+		in:              os.Stdin,
+		out:             os.Stdout,
+		backends:        make(map[string]*gatewayBackend),
+		resourceOwners:  make(map[string]string),
+		toolPolicies:    make(map[string]config.ToolPolicy),
+		serverRequests:  make(map[string]gatewayServerRequest),
+		clientRequests:  make(map[string]context.CancelCauseFunc),
+		synced:          make(chan struct{}),
+	}
+}
+
+// Start serves the client on stdin/stdout until stdin is closed
+func (g *Gateway) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	reader := bufio.NewReaderSize(g.in, 64*1024)
+	var err error
+	for {
+		line, readErr := readLine(reader, 0)
+		if len(bytes.TrimSpace(line)) > 0 {
+			g.handleLine(ctx, line)
+		}
+
+		if readErr != nil {
+			if readErr != io.EOF {
+				err = readErr
+			}
+			break
+		}
+	}
+
+	// Nobody is left to read the responses
+	cancel()
+	g.wg.Wait()
+	g.closeBackends()
+	return err
+}
+
+// handleLine handles a message or batch from the client. Requests run concurrently, except
+// initialize, which everything else depends on.
+func (g *Gateway) handleLine(ctx context.Context, line []byte) {
+	messages, batch, err := parseMessages(line)
+	if err != nil {
+		g.write(JSONRPCMessage{
+			JSONRPC: "2.0",
+			Error: &JSONRPCError{
+				Code:    -32700,
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		})
+		return
+	}
+
+	var requests []JSONRPCMessage
+	for _, message := range messages {
+		switch {
+		case isRequest(message):
+			requests = append(requests, message)
+		case isResponse(message):
+			g.forwardClientResponse(message)
+		default:
+			g.handleNotification(ctx, message)
+		}
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	if !batch && requests[0].Method == "initialize" {
+		if response, answered := g.handleRequest(ctx, requests[0]); answered {
+			g.write(response)
+		}
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		responses := make([]JSONRPCMessage, len(requests))
+		answered := make([]bool, len(requests))
+		var requestWG sync.WaitGroup
+		for i, request := range requests {
+			requestWG.Add(1)
+			go func(i int, request JSONRPCMessage) {
+				defer requestWG.Done()
+				responses[i], answered[i] = g.handleRequest(ctx, request)
+			}(i, request)
+		}
+		requestWG.Wait()
+
+		// Cancelled requests get no response
+		var sent []JSONRPCMessage
+		for i, response := range responses {
+			if answered[i] {
+				sent = append(sent, response)
+			}
+		}
+		switch {
+		case len(sent) == 0:
+		case batch:
+			g.write(sent)
+		default:
+			g.write(sent[0])
+		}
+	}()
+}
+
+// handleRequest answers a client request, asking the servers behind the gateway as needed. It
+// returns false if the client cancelled the request, which then must not be answered.
+func (g *Gateway) handleRequest(ctx context.Context, request JSONRPCMessage) (JSONRPCMessage, bool) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	key := idKey(request.ID)
+	g.mutex.Lock()
+	g.clientRequests[key] = cancel
+	g.mutex.Unlock()
+	defer func() {
+		g.mutex.Lock()
+		delete(g.clientRequests, key)
+		g.mutex.Unlock()
+	}()
+
+	var result interface{}
+	var rpcErr *JSONRPCError
+	switch request.Method {
+	case "initialize":
+		result = g.initialize(request.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result, rpcErr = g.listAll(ctx, request.Method, "tools", true)
+	case "prompts/list":
+		result, rpcErr = g.listAll(ctx, request.Method, "prompts", true)
+	case "resources/list":
+		result, rpcErr = g.listAll(ctx, request.Method, "resources", false)
+	case "resources/templates/list":
+		result, rpcErr = g.listAll(ctx, request.Method, "resourceTemplates", false)
+	case "tools/call", "prompts/get":
+		result, rpcErr = g.callNamespaced(ctx, request)
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+		result, rpcErr = g.callResource(ctx, request)
+	case "completion/complete":
+		result, rpcErr = g.complete(ctx, request)
+	case "logging/setLevel":
+		g.broadcast(ctx, request)
+		result = struct{}{}
+	default:
+		rpcErr = &JSONRPCError{
+			Code:    -32601,
+			Message: "Method not found",
+			Data:    request.Method,
+		}
+	}
+
+	response := JSONRPCMessage{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			response.Error = internalError(err)
+		} else {
+			response.Result = raw
+		}
+	}
+	return response, context.Cause(ctx) != errRequestCancelled
+}
+
+// initialize remembers the client's params for connecting to the servers and describes the gateway
+func (g *Gateway) initialize(params json.RawMessage) interface{} {
+	var request struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &request)
+
+	g.mutex.Lock()
+	g.initializeParams = params
+	g.mutex.Unlock()
+
+	return map[string]interface{}{
+		// The client's version is passed on to every server, which negotiate on their own
+		"protocolVersion": request.ProtocolVersion,
+		"capabilities": map[string]interface{}{
+			"tools":       map[string]interface{}{"listChanged": true},
+			"prompts":     map[string]interface{}{"listChanged": true},
+			"resources":   map[string]interface{}{"listChanged": true, "subscribe": true},
+			"logging":     map[string]interface{}{},
+			"completions": map[string]interface{}{},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "neobelt-gateway",
+			"version": version.Version,
+		},
+		"instructions": fmt.Sprintf("Aggregates all MCP servers running in Neobelt. Tool and prompt names are prefixed with the server name and %q.", GatewayNamespaceSeparator),
+	}
+}
+
+// handleNotification handles a notification from the client
+func (g *Gateway) handleNotification(ctx context.Context, message JSONRPCMessage) {
+	switch message.Method {
+	case "notifications/initialized":
+		g.initializedOnce.Do(func() {
+			g.wg.Add(1)
+			go func() {
+				defer g.wg.Done()
+				g.watchContainers(ctx)
+			}()
+		})
+
+	case "notifications/cancelled":
+		var cancelled struct {
+			RequestID interface{} `json:"requestId"`
+		}
+		if err := json.Unmarshal(message.Params, &cancelled); err != nil {
+			return
+		}
+		g.mutex.Lock()
+		cancel := g.clientRequests[idKey(cancelled.RequestID)]
+		g.mutex.Unlock()
+		if cancel != nil {
+			cancel(errRequestCancelled)
+		}
+
+	case "notifications/roots/list_changed":
+		for _, backend := range g.currentBackends() {
+			backend.notify(message.Method, message.Params)
+		}
+	}
+}
+
+// listAll merges a list method over every server. Names of tools and prompts get the server's
+// namespace, resources remember their owner so reads can be routed.
+func (g *Gateway) listAll(ctx context.Context, method, field string, namespaced bool) (interface{}, *JSONRPCError) {
+	// Right after initialization, wait until the running servers are connected
+	select {
+	case <-g.synced:
+	case <-ctx.Done():
+		return nil, internalError(ctx.Err())
+	}
+
+	backends := g.currentBackends()
+	lists := make([][]map[string]interface{}, len(backends))
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend *gatewayBackend) {
+			defer wg.Done()
+			items, err := listPages(ctx, backend, method, field)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] %s failed: %v\n", backend.namespace, method, err)
+				return
+			}
+			lists[i] = items
+		}(i, backend)
+	}
+	wg.Wait()
+
+	merged := []map[string]interface{}{}
+	for i, items := range lists {
+		namespace := backends[i].namespace
+		policy := g.toolPolicy(namespace)
+		for _, item := range items {
+			if namespaced {
+				name, _ := item["name"].(string)
+				if field == "tools" && !policy.Allows(name) {
+					continue
+				}
+				item["name"] = namespace + GatewayNamespaceSeparator + name
+			} else if uri, ok := item["uri"].(string); ok {
+				g.mutex.Lock()
+				if _, taken := g.resourceOwners[uri]; !taken || g.resourceOwners[uri] == namespace {
+					g.resourceOwners[uri] = namespace
+				}
+				g.mutex.Unlock()
+			}
+			merged = append(merged, item)
+		}
+	}
+
+	return map[string]interface{}{field: merged}, nil
+}
+
+// listPages collects every page of a list method from a server
+func listPages(ctx context.Context, backend *gatewayBackend, method, field string) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	cursor := ""
+	for {
+		var params json.RawMessage
+		if cursor != "" {
+			params, _ = json.Marshal(map[string]string{"cursor": cursor})
+		}
+
+		response, err := backend.call(ctx, method, params)
+		if err != nil {
+			return nil, err
+		}
+		if response.Error != nil {
+			return nil, fmt.Errorf("%s", response.Error.Message)
+		}
+
+		var page map[string]json.RawMessage
+		if err := json.Unmarshal(response.Result, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse %s result: %w", method, err)
+		}
+		var pageItems []map[string]interface{}
+		if raw, exists := page[field]; exists {
+			if err := json.Unmarshal(raw, &pageItems); err != nil {
+				return nil, fmt.Errorf("failed to parse %s result: %w", method, err)
+			}
+		}
+		items = append(items, pageItems...)
+
+		cursor = ""
+		if raw, exists := page["nextCursor"]; exists {
+			_ = json.Unmarshal(raw, &cursor)
+		}
+		if cursor == "" {
+			return items, nil
+		}
+	}
+}
+
+// callNamespaced routes tools/call and prompts/get to the server named in the namespaced name
+func (g *Gateway) callNamespaced(ctx context.Context, request JSONRPCMessage) (interface{}, *JSONRPCError) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	name, _ := params["name"].(string)
+	namespace, originalName, found := strings.Cut(name, GatewayNamespaceSeparator)
+	backend := g.backend(namespace)
+	if !found || backend == nil {
+		return nil, invalidParams(fmt.Sprintf("unknown name %q", name))
+	}
+
+	if request.Method == "prompts/get" {
+		params["name"] = originalName
+		return forward(ctx, backend, request.Method, params)
+	}
+
+	g.mutex.Lock()
+	client := initializeClientName(g.initializeParams)
+	g.mutex.Unlock()
+
+	started := time.Now()
+	callParams, _ := json.Marshal(map[string]interface{}{"name": originalName, "arguments": params["arguments"]})
+
+	if !g.toolPolicy(namespace).Allows(originalName) {
+		fmt.Fprintf(os.Stderr, "[%s] Rejecting call of tool %q, it is not allowed by the tool policy\n", namespace, originalName)
+		rpcErr := toolNotAllowedError(name)
+		g.audit(backend.containerName, client, callParams, started, audit.StatusRejected, rpcErr.Message)
+		return nil, rpcErr
+	}
+
+	if g.toolPolicy(namespace).NeedsApproval(originalName) {
+		if ok, reason := askToolApproval(ctx, backend.containerName, client, callParams, g.approvalTimeout); !ok {
+			rpcErr := toolDeniedError(name, reason)
+			g.audit(backend.containerName, client, callParams, started, audit.StatusDenied, rpcErr.Message)
+			return nil, rpcErr
+		}
+	}
+
+	params["name"] = originalName
+	result, rpcErr := forward(ctx, backend, request.Method, params)
+
+	status, errorMessage := auditStatus(asRawMessage(result), rpcErr)
+	if ctx.Err() != nil {
+		status, errorMessage = audit.StatusCancelled, "cancelled by the client"
+	}
+	g.audit(backend.containerName, client, callParams, started, status, errorMessage)
+	return result, rpcErr
+}
+
+// audit records a tools/call request in the audit log
+func (g *Gateway) audit(serverName, client string, params json.RawMessage, started time.Time, status, errorMessage string) {
+	if g.auditLog == nil {
+		return
+	}
+	appendAuditRecord(g.auditLog, newAuditRecord(serverName, client, params, started, status, errorMessage))
+}
+
+// asRawMessage returns a result forwarded from a server as raw JSON
+func asRawMessage(result interface{}) json.RawMessage {
+	if raw, ok := result.(json.RawMessage); ok {
+		return raw
+	}
+	return nil
+}
+
+// callResource routes a resource request to the server that listed the URI. Reads of unknown
+// URIs (e.g. from templates) are tried on every server until one succeeds.
+func (g *Gateway) callResource(ctx context.Context, request JSONRPCMessage) (interface{}, *JSONRPCError) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	uri, _ := params["uri"].(string)
+
+	g.mutex.Lock()
+	owner := g.resourceOwners[uri]
+	g.mutex.Unlock()
+	if backend := g.backend(owner); backend != nil {
+		return forward(ctx, backend, request.Method, params)
+	}
+
+	rpcErr := invalidParams(fmt.Sprintf("unknown resource %q", uri))
+	for _, backend := range g.currentBackends() {
+		var result interface{}
+		if result, rpcErr = forward(ctx, backend, request.Method, params); rpcErr == nil {
+			g.mutex.Lock()
+			g.resourceOwners[uri] = backend.namespace
+			g.mutex.Unlock()
+			return result, nil
+		}
+	}
+	return nil, rpcErr
+}
+
+// complete routes completion/complete by the prompt or resource it refers to
+func (g *Gateway) complete(ctx context.Context, request JSONRPCMessage) (interface{}, *JSONRPCError) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	ref, _ := params["ref"].(map[string]interface{})
+
+	var backend *gatewayBackend
+	if name, ok := ref["name"].(string); ok {
+		namespace, originalName, _ := strings.Cut(name, GatewayNamespaceSeparator)
+		backend = g.backend(namespace)
+		ref["name"] = originalName
+	} else if uri, ok := ref["uri"].(string); ok {
+		g.mutex.Lock()
+		owner := g.resourceOwners[uri]
+		g.mutex.Unlock()
+		backend = g.backend(owner)
+	}
+	if backend == nil {
+		return nil, invalidParams("unknown completion reference")
+	}
+
+	return forward(ctx, backend, request.Method, params)
+}
+
+// broadcast sends a request to every server, ignoring their answers
+func (g *Gateway) broadcast(ctx context.Context, request JSONRPCMessage) {
+	var wg sync.WaitGroup
+	for _, backend := range g.currentBackends() {
+		wg.Add(1)
+		go func(backend *gatewayBackend) {
+			defer wg.Done()
+			if _, err := backend.call(ctx, request.Method, request.Params); err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] %s failed: %v\n", backend.namespace, request.Method, err)
+			}
+		}(backend)
+	}
+	wg.Wait()
+}
+
+// forward sends a request to a server and returns its result or error
+func forward(ctx context.Context, backend *gatewayBackend, method string, params interface{}) (interface{}, *JSONRPCError) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	response, err := backend.call(ctx, method, raw)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+// forwardClientResponse returns the client's response to a server-initiated request to its server
+func (g *Gateway) forwardClientResponse(response JSONRPCMessage) {
+	key := idKey(response.ID)
+
+	g.mutex.Lock()
+	request, exists := g.serverRequests[key]
+	delete(g.serverRequests, key)
+	g.mutex.Unlock()
+
+	if !exists {
+		fmt.Fprintf(os.Stderr, "Dropping response for unknown request ID %s\n", key)
+		return
+	}
+
+	response.ID = request.id
+	request.backend.write(response)
+}
+
+// handleServerMessage forwards a notification or request from a server to the client. Requests
+// get an ID that is unique across servers, list changes are announced for the merged lists.
+func (g *Gateway) handleServerMessage(backend *gatewayBackend, raw json.RawMessage, message JSONRPCMessage) {
+	if isRequest(message) {
+		g.mutex.Lock()
+		g.nextServerRequestID++
+		id := fmt.Sprintf("%s-%d", backend.namespace, g.nextServerRequestID)
+		g.serverRequests[idKey(id)] = gatewayServerRequest{backend: backend, id: message.ID}
+		g.mutex.Unlock()
+
+		message.ID = id
+		g.write(message)
+		return
+	}
+
+	if isResponse(message) {
+		return // answers to calls that gave up waiting
+	}
+	g.writeRaw(raw)
+}
+
+// watchContainers connects to running containers and disconnects from stopped ones until the
+// client goes away. Docker events trigger a look at the containers right away, the poll interval
+// retries servers that weren't ready yet and covers the time the event stream is down.
+func (g *Gateway) watchContainers(ctx context.Context) {
+	ticker := time.NewTicker(g.pollInterval)
+	defer ticker.Stop()
+
+	var containerEvents <-chan docker.ContainerEvent
+	var streamErr <-chan error
+	first := true
+	for {
+		// Subscribe before looking at the containers, so no change gets lost in between
+		if containerEvents == nil {
+			containerEvents, streamErr = g.docker.WatchManagedContainers(ctx)
+		}
+
+		g.syncContainers(ctx, !first)
+		if first {
+			close(g.synced)
+			first = false
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				break wait
+			case event, ok := <-containerEvents:
+				if !ok {
+					if err := <-streamErr; ctx.Err() == nil {
+						fmt.Fprintf(os.Stderr, "Lost Docker event stream, reconnecting: %v\n", err)
+					}
+					containerEvents = nil // resubscribed on the next tick
+					continue
+				}
+				if gatewaySyncActions[event.Action] {
+					break wait
+				}
+			}
+		}
+	}
+}
+
+// syncContainers matches the connected servers to the running containers
+func (g *Gateway) syncContainers(ctx context.Context, announce bool) {
+	containers, err := g.docker.GetManagedContainers(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list containers: %v\n", err)
+		return
+	}
+
+	// Tool policies may have been changed in Neobelt since the last look
+	if err := g.configManager.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reload configuration: %v\n", err)
+	}
+	policiesByName := make(map[string]config.ToolPolicy)
+	stdioByName := make(map[string]bool)
+	for _, server := range g.configManager.GetConfiguredServers() {
+		policiesByName[server.ContainerName] = server.ToolPolicy
+		stdioByName[server.ContainerName] = server.Transport == config.TransportStdio
+	}
+
+	var running []docker.ContainerInfo
+	for _, info := range containers {
+		if info.State == "running" && (info.Port != 0 || stdioByName[info.Name]) {
+			running = append(running, info)
+		}
+	}
+	g.connectContainers(ctx, running, policiesByName, announce)
+}
+
+// connectContainers connects to the running containers that aren't connected yet and drops the
+// servers that stopped. With announce, changes are announced to the client as list changes.
+func (g *Gateway) connectContainers(ctx context.Context, running []docker.ContainerInfo, policiesByName map[string]config.ToolPolicy, announce bool) {
+	g.mutex.Lock()
+	current := make(map[string]string, len(g.backends))
+	for namespace, backend := range g.backends {
+		current[backend.containerID] = namespace
+	}
+	g.mutex.Unlock()
+
+	// Stdio servers are attached to, their URL only tells them apart
+	type target struct {
+		containerID, containerName, url string
+		stdio                           bool
+	}
+	targets := make(map[string]target)
+	toolPolicies := make(map[string]config.ToolPolicy)
+	namespaces := assignNamespaces(running, current)
+	for _, info := range running {
+		namespace := namespaces[info.ID]
+		server := target{
+			containerID:   info.ID,
+			containerName: info.Name,
+			url:           fmt.Sprintf("http://localhost:%d/mcp", info.Port),
+		}
+		if info.Port == 0 {
+			server.stdio = true
+			server.url = "stdio://" + info.Name
+		}
+		targets[namespace] = server
+		toolPolicies[namespace] = policiesByName[info.Name]
+	}
+
+	// Drop servers that stopped, moved or lost their connection
+	g.mutex.Lock()
+	g.toolPolicies = toolPolicies
+	initializeParams := g.initializeParams
+	var removed []*gatewayBackend
+	for namespace, backend := range g.backends {
+		if target, exists := targets[namespace]; !exists || target.url != backend.url || backend.closed() {
+			removed = append(removed, backend)
+			delete(g.backends, namespace)
+			for uri, owner := range g.resourceOwners {
+				if owner == namespace {
+					delete(g.resourceOwners, uri)
+				}
+			}
+		}
+	}
+	var added []string
+	for namespace := range targets {
+		if _, exists := g.backends[namespace]; !exists {
+			added = append(added, namespace)
+		}
+	}
+	g.mutex.Unlock()
+
+	for _, backend := range removed {
+		fmt.Fprintf(os.Stderr, "[%s] Disconnecting from %s\n", backend.namespace, backend.url)
+		backend.close()
+	}
+
+	var wg sync.WaitGroup
+	connected := 0
+	for _, namespace := range added {
+		wg.Add(1)
+		go func(namespace string, target target) {
+			defer wg.Done()
+			var backend *gatewayBackend
+			var err error
+			if target.stdio {
+				backend, err = startStdioGatewayBackend(ctx, g.docker, namespace, target.containerID, target.containerName, initializeParams, g.handleServerMessage)
+			} else {
+				backend, err = startGatewayBackend(ctx, namespace, target.containerID, target.url, ProxyOptions{}, initializeParams, g.handleServerMessage)
+			}
+			if err != nil {
+				// Servers that are still starting up are retried on the next poll
+				fmt.Fprintf(os.Stderr, "[%s] Failed to connect to %s: %v\n", namespace, target.url, err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "[%s] Connected to %s\n", namespace, target.url)
+			backend.containerName = target.containerName
+
+			g.mutex.Lock()
+			g.backends[namespace] = backend
+			connected++
+			g.mutex.Unlock()
+		}(namespace, targets[namespace])
+	}
+	wg.Wait()
+
+	if announce && (connected > 0 || len(removed) > 0) {
+		for _, method := range []string{"notifications/tools/list_changed", "notifications/prompts/list_changed", "notifications/resources/list_changed"} {
+			g.write(JSONRPCMessage{JSONRPC: "2.0", Method: method})
+		}
+	}
+}
+
+// toolPolicy returns the tool policy of the server with the given namespace
+func (g *Gateway) toolPolicy(namespace string) config.ToolPolicy {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.toolPolicies[namespace]
+}
+
+// backend returns the connected server for a namespace, nil if there is none
+func (g *Gateway) backend(namespace string) *gatewayBackend {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.backends[namespace]
+}
+
+// currentBackends returns the connected servers sorted by namespace
+func (g *Gateway) currentBackends() []*gatewayBackend {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	backends := make([]*gatewayBackend, 0, len(g.backends))
+	for _, backend := range g.backends {
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].namespace < backends[j].namespace
+	})
+	return backends
+}
+
+// closeBackends ends the sessions with all servers
+func (g *Gateway) closeBackends() {
+	for _, backend := range g.currentBackends() {
+		backend.close()
+	}
+}
+
+// write sends a message or batch to the client as a single line
+func (g *Gateway) write(v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling message: %v\n", err)
+		return
+	}
+	g.writeRaw(raw)
+}
+
+// writeRaw sends an already encoded message to the client
+func (g *Gateway) writeRaw(raw []byte) {
+	g.writeMutex.Lock()
+	defer g.writeMutex.Unlock()
+	g.out.Write(append(raw, '\n'))
+}
+
+// invalidNamespaceChars matches everything that may not appear in tool names
+var invalidNamespaceChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// gatewayNamespace turns a container name into a namespace that is valid in tool names.
+// Underscores are replaced as well, so the separator can't appear inside a namespace.
+func gatewayNamespace(containerName string) string {
+	return strings.Trim(invalidNamespaceChars.ReplaceAllString(containerName, "-"), "-")
+}
+
+// assignNamespaces returns the namespace of every container by ID. Containers keep the namespace
+// they are connected under (current, by container ID), so tool names don't change while the
+// client uses them. Names that collide after sanitising, e.g. foo_bar and foo.bar, get a suffix.
+func assignNamespaces(containers []docker.ContainerInfo, current map[string]string) map[string]string {
+	sorted := append([]docker.ContainerInfo(nil), containers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	namespaces := make(map[string]string, len(sorted))
+	taken := make(map[string]bool, len(sorted))
+	for _, info := range sorted {
+		base := gatewayNamespace(info.Name)
+		if namespace, exists := current[info.ID]; exists && (namespace == base || strings.HasPrefix(namespace, base+"-")) && !taken[namespace] {
+			namespaces[info.ID] = namespace
+			taken[namespace] = true
+		}
+	}
+
+	for _, info := range sorted {
+		if _, assigned := namespaces[info.ID]; assigned {
+			continue
+		}
+
+		base := gatewayNamespace(info.Name)
+		namespace := base
+		for i := 2; taken[namespace]; i++ {
+			namespace = fmt.Sprintf("%s-%d", base, i)
+		}
+		if namespace != base {
+			fmt.Fprintf(os.Stderr, "[%s] Namespace %s is taken by another container, serving %s as %s\n", namespace, base, info.Name, namespace)
+		}
+		namespaces[info.ID] = namespace
+		taken[namespace] = true
+	}
+	return namespaces
+}
+
+// invalidParams returns a JSON-RPC invalid params error
+func invalidParams(message string) *JSONRPCError {
+	return &JSONRPCError{Code: -32602, Message: "Invalid params", Data: message}
+}
+
+// internalError returns a JSON-RPC internal error
+func internalError(err error) *JSONRPCError {
+	return &JSONRPCError{Code: -32603, Message: "Internal error", Data: err.Error()}
+}
@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"neobelt/internal/docker"
+)
+
+// gatewayBackendInitTimeout bounds how long connecting to a backend may take
+const gatewayBackendInitTimeout = 30 * time.Second
+
+// errBackendClosed is returned for calls to a backend whose connection has ended
+var errBackendClosed = errors.New("connection to server closed")
+
+// gatewayBackend is the gateway's connection to one MCP server. It runs a regular MCPProxy
+// over pipes, so backends get the same transport handling (session recovery, retries,
+// HTTP+SSE fallback, server-initiated messages) as a proxy started by Claude Desktop.
+type gatewayBackend struct {
+	namespace     string
+	containerID   string
+	containerName string // tool calls are approved and audited under it, like in the proxy
+	url           string
+
+	initializeResult json.RawMessage // the server's answer to initialize
+
+	stdin      *io.PipeWriter
+	writeMutex sync.Mutex
+
+	// Requests sent by the gateway, keyed by JSON-RPC ID
+	pendingMutex sync.Mutex
+	pending      map[string]chan JSONRPCMessage
+	nextID       int
+
+	done chan struct{} // closed once the proxy has shut down
+}
+
+// startGatewayBackend connects to an HTTP server and initializes it with the client's initialize
+// params. Every message from the server that is not a response to the gateway goes to onMessage.
+func startGatewayBackend(ctx context.Context, namespace, containerID, url string, options ProxyOptions, initializeParams json.RawMessage, onMessage func(*gatewayBackend, json.RawMessage, JSONRPCMessage)) (*gatewayBackend, error) {
+	proxy := NewMCPProxy(url, nil, options)
+	run := func(stdin io.Reader, stdout io.Writer) error {
+		proxy.in = stdin
+		proxy.out = stdout
+		return proxy.Start(context.Background())
+	}
+	return connectGatewayBackend(ctx, namespace, containerID, url, run, initializeParams, onMessage)
+}
+
+// startStdioGatewayBackend connects to a stdio server in a container like startGatewayBackend,
+// running a session of it through docker attach
+func startStdioGatewayBackend(ctx context.Context, dockerService *docker.DockerService, namespace, containerID, containerName string, initializeParams json.RawMessage, onMessage func(*gatewayBackend, json.RawMessage, JSONRPCMessage)) (*gatewayBackend, error) {
+	run := func(stdin io.Reader, stdout io.Writer) error {
+		attachCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Once the gateway closes the connection, give the server a moment to exit on its own
+		serverStdin, serverStdinWriter := io.Pipe()
+		go func() {
+			io.Copy(serverStdinWriter, stdin)
+			serverStdinWriter.Close()
+			select {
+			case <-time.After(serveShutdownGrace):
+				cancel()
+			case <-attachCtx.Done():
+			}
+		}()
+
+		stderrReader, stderrWriter := io.Pipe()
+		defer stderrWriter.Close()
+		go copyPrefixed(stderrReader, fmt.Sprintf("[%s] ", namespace))
+
+		return dockerService.AttachStdio(attachCtx, containerName, serverStdin, stdout, stderrWriter)
+	}
+	return connectGatewayBackend(ctx, namespace, containerID, "stdio://"+containerName, run, initializeParams, onMessage)
+}
+
+// connectGatewayBackend runs a connection to a server that speaks newline-delimited JSON-RPC on
+// stdin and stdout, and initializes it
+func connectGatewayBackend(ctx context.Context, namespace, containerID, url string, run func(stdin io.Reader, stdout io.Writer) error, initializeParams json.RawMessage, onMessage func(*gatewayBackend, json.RawMessage, JSONRPCMessage)) (*gatewayBackend, error) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	backend := &gatewayBackend{
+		namespace:   namespace,
+		containerID: containerID,
+		url:         url,
+		stdin:       inWriter,
+		pending:     make(map[string]chan JSONRPCMessage),
+		done:        make(chan struct{}),
+	}
+
+	go func() {
+		defer close(backend.done)
+		defer outWriter.Close()
+		defer inReader.Close() // writes fail once the connection is gone
+		if err := run(inReader, outWriter); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] Connection error: %v\n", namespace, err)
+		}
+	}()
+	go backend.readMessages(outReader, onMessage)
+
+	initCtx, cancel := context.WithTimeout(ctx, gatewayBackendInitTimeout)
+	defer cancel()
+
+	response, err := backend.call(initCtx, "initialize", initializeParams)
+	if err == nil && response.Error != nil {
+		err = fmt.Errorf("initialize failed: %s", response.Error.Message)
+		if data, ok := response.Error.Data.(string); ok && data != "" {
+			err = fmt.Errorf("initialize failed: %s", data)
+		}
+	}
+	if err == nil {
+		backend.initializeResult = response.Result
+		err = backend.notify("notifications/initialized", nil)
+	}
+	if err != nil {
+		backend.close()
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// readMessages reads everything the proxy writes, handing responses to the waiting calls
+func (b *gatewayBackend) readMessages(reader io.Reader, onMessage func(*gatewayBackend, json.RawMessage, JSONRPCMessage)) {
+	buffered := bufio.NewReader(reader)
+	for {
+		line, readErr := readLine(buffered, 0)
+		if messages, _, err := parseMessages(line); err == nil {
+			for _, message := range messages {
+				if isResponse(message) && b.deliver(message) {
+					continue
+				}
+				raw, err := json.Marshal(message)
+				if err != nil {
+					continue
+				}
+				onMessage(b, raw, message)
+			}
+		}
+
+		if readErr != nil {
+			return
+		}
+	}
+}
+
+// deliver hands a response to the call waiting for it, reporting whether there was one
+func (b *gatewayBackend) deliver(response JSONRPCMessage) bool {
+	b.pendingMutex.Lock()
+	defer b.pendingMutex.Unlock()
+
+	responses, exists := b.pending[idKey(response.ID)]
+	if exists {
+		select {
+		case responses <- response:
+		default: // duplicate response
+		}
+	}
+	return exists
+}
+
+// call sends a request to the server and waits for its response. Responses carrying a JSON-RPC
+// error are returned as they are, cancelling the context tells the server to stop.
+func (b *gatewayBackend) call(ctx context.Context, method string, params json.RawMessage) (JSONRPCMessage, error) {
+	b.pendingMutex.Lock()
+	b.nextID++
+	id := b.nextID
+	key := idKey(id)
+	responses := make(chan JSONRPCMessage, 1)
+	b.pending[key] = responses
+	b.pendingMutex.Unlock()
+
+	defer func() {
+		b.pendingMutex.Lock()
+		delete(b.pending, key)
+		b.pendingMutex.Unlock()
+	}()
+
+	if err := b.write(JSONRPCMessage{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return JSONRPCMessage{}, err
+	}
+
+	select {
+	case response := <-responses:
+		return response, nil
+	case <-b.done:
+		return JSONRPCMessage{}, errBackendClosed
+	case <-ctx.Done():
+		cancelParams, _ := json.Marshal(map[string]interface{}{
+			"requestId": id,
+			"reason":    "cancelled by the gateway client",
+		})
+		b.notify("notifications/cancelled", cancelParams)
+		return JSONRPCMessage{}, ctx.Err()
+	}
+}
+
+// notify sends a notification to the server
+func (b *gatewayBackend) notify(method string, params json.RawMessage) error {
+	return b.write(JSONRPCMessage{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// write sends a message to the proxy as a single line
+func (b *gatewayBackend) write(message JSONRPCMessage) error {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+
+	if _, err := b.stdin.Write(append(raw, '\n')); err != nil {
+		return errBackendClosed
+	}
+	return nil
+}
+
+// closed reports whether the connection has ended
+func (b *gatewayBackend) closed() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close ends the session with the server and waits for the proxy to shut down
+func (b *gatewayBackend) close() {
+	b.stdin.Close()
+	<-b.done
+}
@@ -0,0 +1,299 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+)
+
+func TestAssignNamespacesResolvesCollisions(t *testing.T) {
+	containers := []docker.ContainerInfo{
+		{ID: "c", Name: "foo_bar"},
+		{ID: "a", Name: "foo.bar"},
+		{ID: "b", Name: "github"},
+	}
+
+	namespaces := assignNamespaces(containers, nil)
+	want := map[string]string{"a": "foo-bar", "b": "github", "c": "foo-bar-2"}
+	for id, namespace := range want {
+		if namespaces[id] != namespace {
+			t.Errorf("container %s: got namespace %q, want %q", id, namespaces[id], namespace)
+		}
+	}
+}
+
+func TestAssignNamespacesKeepsCurrentNamespaces(t *testing.T) {
+	// foo_bar was connected first, foo.bar started later
+	containers := []docker.ContainerInfo{
+		{ID: "a", Name: "foo.bar"},
+		{ID: "c", Name: "foo_bar"},
+	}
+
+	namespaces := assignNamespaces(containers, map[string]string{"c": "foo-bar"})
+	if namespaces["c"] != "foo-bar" || namespaces["a"] != "foo-bar-2" {
+		t.Errorf("expected the connected container to keep its namespace, got %v", namespaces)
+	}
+}
+
+// testGatewayServer is a server behind the gateway that names its tools and resources after itself
+type testGatewayServer struct {
+	port int
+
+	mutex  sync.Mutex
+	called []string // tools/call and resources/read requests
+}
+
+func newTestGatewayServer(t *testing.T, name string) *testGatewayServer {
+	server := &testGatewayServer{}
+	httpServer := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		var params struct {
+			Name string `json:"name"`
+			URI  string `json:"uri"`
+		}
+		json.Unmarshal(message.Params, &params)
+
+		switch message.Method {
+		case "initialize":
+			return `{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"` + name + `"}}`
+		case "tools/list":
+			return `{"tools":[{"name":"search"},{"name":"issue_delete"}]}`
+		case "resources/list":
+			return `{"resources":[{"uri":"file:///` + name + `"}]}`
+		case "tools/call", "resources/read":
+			server.mutex.Lock()
+			server.called = append(server.called, message.Method+" "+params.Name+params.URI)
+			server.mutex.Unlock()
+			return `{"content":[{"type":"text","text":"` + name + `"}]}`
+		}
+		return `{}`
+	})
+
+	parsed, _ := url.Parse(httpServer.URL)
+	server.port, _ = strconv.Atoi(parsed.Port())
+	return server
+}
+
+func (s *testGatewayServer) calls() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.called...)
+}
+
+// startTestGateway runs a gateway without Docker, the test connects it to servers itself
+func startTestGateway(t *testing.T) (*Gateway, *testProxy) {
+	t.Helper()
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	gateway := newGateway(nil, nil, GatewayOptions{})
+	gateway.in = inReader
+	gateway.out = outWriter
+
+	done := make(chan error, 1)
+	go func() {
+		done <- gateway.Start(context.Background())
+		outWriter.Close()
+	}()
+	t.Cleanup(func() {
+		inWriter.Close()
+		go io.Copy(io.Discard, outReader)
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("gateway did not shut down")
+		}
+	})
+
+	return gateway, &testProxy{stdin: inWriter, stdout: bufio.NewReader(outReader)}
+}
+
+// gatewayNames returns the names or URIs of the items of a merged list
+func gatewayNames(t *testing.T, response JSONRPCMessage, field, key string) []string {
+	t.Helper()
+
+	var result map[string][]map[string]interface{}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		t.Fatalf("invalid list result %s: %v", response.Result, err)
+	}
+	var names []string
+	for _, item := range result[field] {
+		name, _ := item[key].(string)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGatewayMergesAndRoutes(t *testing.T) {
+	alpha := newTestGatewayServer(t, "alpha")
+	beta := newTestGatewayServer(t, "beta")
+	gateway, client := startTestGateway(t)
+
+	client.send(t, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"claude-ai"}}}`)
+	client.receive(t, 2*time.Second)
+
+	// The containers are running, beta_db may not delete issues
+	running := []docker.ContainerInfo{
+		{ID: "a", Name: "alpha", State: "running", Port: alpha.port},
+		{ID: "b", Name: "beta_db", State: "running", Port: beta.port},
+	}
+	policies := map[string]config.ToolPolicy{"beta_db": {Deny: []string{"*_delete"}}}
+	gateway.connectContainers(context.Background(), running, policies, false)
+	close(gateway.synced)
+
+	client.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	want := []string{"alpha__issue_delete", "alpha__search", "beta-db__search"}
+	if got := gatewayNames(t, client.receive(t, 5*time.Second), "tools", "name"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the namespaced tools %v, got %v", want, got)
+	}
+
+	client.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"beta-db__search","arguments":{}}}`)
+	if response := client.receive(t, 5*time.Second); response.ID != float64(2) || response.Error != nil {
+		t.Fatalf("expected the call to succeed, got %+v", response)
+	}
+	client.send(t, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"beta-db__issue_delete"}}`)
+	if response := client.receive(t, 5*time.Second); response.Error == nil || response.Error.Code != -32602 {
+		t.Fatalf("expected a tool not allowed error, got %+v", response)
+	}
+
+	// Resources keep their URIs and are read from the server that listed them
+	client.send(t, `{"jsonrpc":"2.0","id":4,"method":"resources/list"}`)
+	want = []string{"file:///alpha", "file:///beta"}
+	if got := gatewayNames(t, client.receive(t, 5*time.Second), "resources", "uri"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the resources %v, got %v", want, got)
+	}
+	client.send(t, `{"jsonrpc":"2.0","id":5,"method":"resources/read","params":{"uri":"file:///alpha"}}`)
+	if response := client.receive(t, 5*time.Second); response.ID != float64(5) || response.Error != nil {
+		t.Fatalf("expected the read to succeed, got %+v", response)
+	}
+
+	if calls := alpha.calls(); len(calls) != 1 || calls[0] != "resources/read file:///alpha" {
+		t.Errorf("expected only the resource read on alpha, got %v", calls)
+	}
+	if calls := beta.calls(); len(calls) != 1 || calls[0] != "tools/call search" {
+		t.Errorf("expected only the allowed call without namespace on beta, got %v", calls)
+	}
+
+	// A stopped server is dropped and the lists are announced as changed
+	synced := make(chan struct{})
+	go func() {
+		gateway.connectContainers(context.Background(), running[:1], policies, true)
+		close(synced)
+	}()
+	for _, method := range []string{"notifications/tools/list_changed", "notifications/prompts/list_changed", "notifications/resources/list_changed"} {
+		if notification := client.receive(t, 5*time.Second); notification.Method != method {
+			t.Fatalf("expected %s, got %+v", method, notification)
+		}
+	}
+	<-synced
+	client.send(t, `{"jsonrpc":"2.0","id":6,"method":"tools/list"}`)
+	want = []string{"alpha__issue_delete", "alpha__search"}
+	if got := gatewayNames(t, client.receive(t, 5*time.Second), "tools", "name"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected only alpha's tools after beta stopped, got %v", got)
+	}
+}
+
+func TestGatewayRemapsServerRequestIDs(t *testing.T) {
+	gateway, client := startTestGateway(t)
+
+	// Both servers use ID 1 for their own requests
+	backends := make(map[string]*bufio.Reader)
+	ids := make(map[string]interface{})
+	for _, namespace := range []string{"alpha", "beta"} {
+		stdinReader, stdinWriter := io.Pipe()
+		t.Cleanup(func() { stdinWriter.Close() })
+		backend := &gatewayBackend{namespace: namespace, stdin: stdinWriter}
+		backends[namespace] = bufio.NewReader(stdinReader)
+
+		request := JSONRPCMessage{JSONRPC: "2.0", ID: float64(1), Method: "roots/list"}
+		raw, _ := json.Marshal(request)
+		go gateway.handleServerMessage(backend, raw, request)
+
+		forwarded := client.receive(t, 2*time.Second)
+		if forwarded.Method != "roots/list" || forwarded.ID == float64(1) {
+			t.Fatalf("expected the server's request under a new ID, got %+v", forwarded)
+		}
+		for other, id := range ids {
+			if id == forwarded.ID {
+				t.Fatalf("expected a unique ID per server, %s and %s got %v", other, namespace, id)
+			}
+		}
+		ids[namespace] = forwarded.ID
+	}
+
+	// Each response goes back to its server under the server's own ID
+	for _, namespace := range []string{"beta", "alpha"} {
+		response, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: ids[namespace], Result: json.RawMessage(`{"roots":[]}`)})
+		client.send(t, string(response))
+
+		lines := make(chan string, 1)
+		go func() {
+			line, _ := backends[namespace].ReadString('\n')
+			lines <- line
+		}()
+		select {
+		case line := <-lines:
+			var forwarded JSONRPCMessage
+			if err := json.Unmarshal([]byte(line), &forwarded); err != nil || forwarded.ID != float64(1) || forwarded.Result == nil {
+				t.Fatalf("expected %s to get the response to its request 1, got %q", namespace, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the response to be forwarded to %s", namespace)
+		}
+	}
+}
+
+// waitForClientRequest waits until the gateway is handling a client request, or is done with it
+func waitForClientRequest(t *testing.T, gateway *Gateway, id interface{}, handling bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		gateway.mutex.Lock()
+		_, ok := gateway.clientRequests[idKey(id)]
+		gateway.mutex.Unlock()
+		if ok == handling {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the gateway handling request %v to be %v", id, handling)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGatewayDropsResponsesToCancelledRequests(t *testing.T) {
+	gateway, client := startTestGateway(t)
+
+	// The lists wait for the first look for containers, which never comes
+	client.send(t, `[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`)
+	waitForClientRequest(t, gateway, float64(1), true)
+	client.send(t, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+
+	var responses []JSONRPCMessage
+	if line := client.receiveLine(t, 2*time.Second); json.Unmarshal([]byte(line), &responses) != nil || len(responses) != 1 || responses[0].ID != float64(2) {
+		t.Fatalf("expected the batch to answer only the ping, got %s", line)
+	}
+
+	client.send(t, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	waitForClientRequest(t, gateway, float64(3), true)
+	client.send(t, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":3}}`)
+	waitForClientRequest(t, gateway, float64(3), false)
+
+	client.send(t, `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	if response := client.receive(t, 2*time.Second); response.ID != float64(4) {
+		t.Fatalf("expected no response to the cancelled request, got %+v", response)
+	}
+}
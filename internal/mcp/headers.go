@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"neobelt/internal/crypto"
+)
+
+// Prefixes of header value references that are resolved when the proxy starts
+const (
+	headerRefEnv    = "env:"
+	headerRefFile   = "file:"
+	headerRefSecret = "neobelt-secret:"
+)
+
+// headerRefPrefixes are all reference prefixes, for spotting references that are not resolved
+var headerRefPrefixes = []string{headerRefEnv, headerRefFile, headerRefSecret}
+
+// headerAuthSchemes are the authorization schemes a reference may follow
+var headerAuthSchemes = []string{"Bearer", "Basic", "Token"}
+
+// resolveHeaders replaces header value references with the values they point to, so secrets
+// never have to be passed on the command line (and end up in the MCP client's config).
+// A reference is either the whole value or follows an authorization scheme, e.g.
+// "Bearer neobelt-secret:github". References that cannot be resolved are an error.
+func resolveHeaders(headers map[string]string) (map[string]string, error) {
+	var secrets *crypto.SecretStore
+
+	resolved := make(map[string]string, len(headers))
+	for name, value := range headers {
+		scheme, reference := splitAuthScheme(value)
+
+		var err error
+		switch {
+		case strings.HasPrefix(reference, headerRefEnv):
+			variable := strings.TrimPrefix(reference, headerRefEnv)
+			var exists bool
+			if reference, exists = os.LookupEnv(variable); !exists {
+				err = fmt.Errorf("environment variable %q is not set", variable)
+			}
+
+		case strings.HasPrefix(reference, headerRefFile):
+			path := strings.TrimPrefix(reference, headerRefFile)
+			var data []byte
+			if data, err = os.ReadFile(path); err != nil {
+				break
+			}
+			if reference = strings.TrimSpace(string(data)); reference == "" {
+				err = fmt.Errorf("file %s is empty", path)
+			}
+
+		case strings.HasPrefix(reference, headerRefSecret):
+			if secrets == nil {
+				if secrets, err = crypto.NewSecretStore(); err != nil {
+					break
+				}
+			}
+			reference, err = secrets.Get(strings.TrimPrefix(reference, headerRefSecret))
+
+		default:
+			// Anything else is sent as is, but a reference in the wrong place is likely a mistake
+			for _, prefix := range headerRefPrefixes {
+				if strings.Contains(value, " "+prefix) {
+					fmt.Fprintf(os.Stderr, "Warning: header %s contains %q but is sent as is, references must be the whole value or follow an authorization scheme\n", name, prefix)
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve header %s: %w", name, err)
+		}
+
+		resolved[name] = scheme + reference
+	}
+
+	return resolved, nil
+}
+
+// splitAuthScheme splits a value like "Bearer <token>" into the scheme with its space and the rest
+func splitAuthScheme(value string) (string, string) {
+	for _, scheme := range headerAuthSchemes {
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) && value[len(scheme)] == ' ' {
+			return value[:len(scheme)+1], value[len(scheme)+1:]
+		}
+	}
+	return "", value
+}
@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"neobelt/internal/crypto"
+)
+
+func TestResolveHeaders(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+	t.Setenv("NEOBELT_TEST_TOKEN", "from-env")
+
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token with spaces")
+	if err := os.WriteFile(tokenPath, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyPath := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := crypto.NewSecretStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Set("github", "from-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   string
+	}{
+		{name: "literal", value: "plain value", want: "plain value"},
+		{name: "env", value: "env:NEOBELT_TEST_TOKEN", want: "from-env"},
+		{name: "file with spaces in the path", value: "file:" + tokenPath, want: "from-file"},
+		{name: "secret", value: "neobelt-secret:github", want: "from-secret"},
+		{name: "after bearer", value: "Bearer env:NEOBELT_TEST_TOKEN", want: "Bearer from-env"},
+		{name: "after basic with file", value: "Basic file:" + tokenPath, want: "Basic from-file"},
+		{name: "scheme is case insensitive", value: "bearer neobelt-secret:github", want: "bearer from-secret"},
+		{name: "reference after another word is literal", value: "Custom env:NEOBELT_TEST_TOKEN", want: "Custom env:NEOBELT_TEST_TOKEN"},
+		{name: "missing env", value: "Bearer env:NEOBELT_TEST_MISSING", err: "NEOBELT_TEST_MISSING"},
+		{name: "missing file", value: "file:" + filepath.Join(dir, "missing"), err: "no such file"},
+		{name: "empty file", value: "file:" + emptyPath, err: "is empty"},
+		{name: "missing secret", value: "neobelt-secret:missing", err: `secret "missing" not found`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := resolveHeaders(map[string]string{"Authorization": test.value})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v (%q)", test.err, err, resolved["Authorization"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resolved["Authorization"] != test.want {
+				t.Errorf("expected %q, got %q", test.want, resolved["Authorization"])
+			}
+		})
+	}
+}
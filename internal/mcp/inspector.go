@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/version"
+)
+
+// InspectorProtocolVersion is the MCP version the inspector asks servers for
+const InspectorProtocolVersion = "2025-06-18"
+
+// ServerInspection describes what an MCP server offers
+type ServerInspection struct {
+	URL               string                   `json:"url"`
+	ProtocolVersion   string                   `json:"protocol_version"`
+	ServerInfo        map[string]interface{}   `json:"server_info"`
+	Capabilities      map[string]interface{}   `json:"capabilities"`
+	Instructions      string                   `json:"instructions,omitempty"`
+	Tools             []map[string]interface{} `json:"tools"`
+	Resources         []map[string]interface{} `json:"resources"`
+	ResourceTemplates []map[string]interface{} `json:"resource_templates"`
+	Prompts           []map[string]interface{} `json:"prompts"`
+	Errors            map[string]string        `json:"errors,omitempty"` // list method -> error
+	DurationMs        int64                    `json:"duration_ms"`
+}
+
+// ToolCallResult is the raw outcome of calling a tool from the inspector
+type ToolCallResult struct {
+	Request    string `json:"request"`  // the JSON-RPC request as sent, indented
+	Response   string `json:"response"` // the JSON-RPC response as received, indented
+	IsError    bool   `json:"is_error"` // JSON-RPC error or isError in the tool result
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Inspector is an MCP client for checking that a server works. It talks to the server through
+// a regular MCPProxy, so it sees the server the same way Claude Desktop does.
+type Inspector struct {
+	url     string
+	backend *gatewayBackend
+}
+
+// NewInspector connects to an MCP server and initializes a session. The options apply as for any
+// proxy, so tool calls are subject to the server's tool policy, approval and auditing.
+func NewInspector(ctx context.Context, url string, options ProxyOptions) (*Inspector, error) {
+	initializeParams, err := json.Marshal(map[string]interface{}{
+		"protocolVersion": InspectorProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]interface{}{
+			"name":    "neobelt-inspector",
+			"version": version.Version,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal initialize params: %w", err)
+	}
+
+	// Checking a server that is down should fail rather than wait for it to come back
+	options.NoQueue = true
+	backend, err := startGatewayBackend(ctx, "inspector", "", url, options, initializeParams, answerServerRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MCP session: %w", err)
+	}
+
+	return &Inspector{url: url, backend: backend}, nil
+}
+
+// answerServerRequest declines requests from the server (sampling, roots, elicitation), which
+// the inspector does not support, so the server does not wait for them
+func answerServerRequest(backend *gatewayBackend, raw json.RawMessage, message JSONRPCMessage) {
+	if !isRequest(message) {
+		return
+	}
+
+	response := JSONRPCMessage{JSONRPC: "2.0", ID: message.ID}
+	if message.Method == "ping" {
+		response.Result = json.RawMessage("{}")
+	} else {
+		response.Error = &JSONRPCError{Code: -32601, Message: "Method not found"}
+	}
+	backend.write(response)
+}
+
+// Inspect describes the server and lists its tools, resources and prompts. Lists the server
+// doesn't advertise are left empty, failing lists are reported in Errors.
+func (i *Inspector) Inspect(ctx context.Context) (*ServerInspection, error) {
+	started := time.Now()
+
+	var initialize struct {
+		ProtocolVersion string                 `json:"protocolVersion"`
+		ServerInfo      map[string]interface{} `json:"serverInfo"`
+		Capabilities    map[string]interface{} `json:"capabilities"`
+		Instructions    string                 `json:"instructions"`
+	}
+	if err := json.Unmarshal(i.backend.initializeResult, &initialize); err != nil {
+		return nil, fmt.Errorf("failed to parse initialize result: %w", err)
+	}
+
+	inspection := &ServerInspection{
+		URL:               i.url,
+		ProtocolVersion:   initialize.ProtocolVersion,
+		ServerInfo:        initialize.ServerInfo,
+		Capabilities:      initialize.Capabilities,
+		Instructions:      initialize.Instructions,
+		Tools:             []map[string]interface{}{},
+		Resources:         []map[string]interface{}{},
+		ResourceTemplates: []map[string]interface{}{},
+		Prompts:           []map[string]interface{}{},
+		Errors:            make(map[string]string),
+	}
+
+	lists := []struct {
+		capability string
+		method     string
+		field      string
+		items      *[]map[string]interface{}
+	}{
+		{"tools", "tools/list", "tools", &inspection.Tools},
+		{"resources", "resources/list", "resources", &inspection.Resources},
+		{"resources", "resources/templates/list", "resourceTemplates", &inspection.ResourceTemplates},
+		{"prompts", "prompts/list", "prompts", &inspection.Prompts},
+	}
+	for _, list := range lists {
+		if _, offered := initialize.Capabilities[list.capability]; !offered {
+			continue
+		}
+
+		items, err := listPages(ctx, i.backend, list.method, list.field)
+		if err != nil {
+			inspection.Errors[list.method] = err.Error()
+			continue
+		}
+		if items != nil {
+			*list.items = items
+		}
+	}
+
+	inspection.DurationMs = time.Since(started).Milliseconds()
+	return inspection, nil
+}
+
+// CallTool runs a tool with JSON object arguments and returns the raw exchange
+func (i *Inspector) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*ToolCallResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(arguments, &object); err != nil {
+		return nil, fmt.Errorf("arguments must be a JSON object: %w", err)
+	}
+
+	params, err := json.Marshal(map[string]interface{}{
+		"name":      name,
+		"arguments": arguments,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tool call: %w", err)
+	}
+
+	started := time.Now()
+	response, err := i.backend.call(ctx, "tools/call", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool: %w", err)
+	}
+
+	status, _ := auditStatus(response.Result, response.Error)
+	return &ToolCallResult{
+		Request:    indentJSON(JSONRPCMessage{JSONRPC: "2.0", ID: response.ID, Method: "tools/call", Params: params}),
+		Response:   indentJSON(response),
+		IsError:    status != audit.StatusSuccess,
+		DurationMs: time.Since(started).Milliseconds(),
+	}, nil
+}
+
+// Ping checks that the server still answers
+func (i *Inspector) Ping(ctx context.Context) error {
+	response, err := i.backend.call(ctx, "ping", nil)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("ping failed: %s", response.Error.Message)
+	}
+	return nil
+}
+
+// Close ends the session with the server
+func (i *Inspector) Close() {
+	i.backend.close()
+}
+
+// indentJSON formats a message for display
+func indentJSON(message JSONRPCMessage) string {
+	data, err := json.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
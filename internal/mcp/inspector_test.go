@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+)
+
+func TestInspectorAppliesToolPolicyAndAudits(t *testing.T) {
+	var mutex sync.Mutex
+	var called []string
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		switch message.Method {
+		case "initialize":
+			return `{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"stub"}}`
+		case "tools/list":
+			return `{"tools":[{"name":"issue_search"},{"name":"issue_delete"}]}`
+		case "tools/call":
+			mutex.Lock()
+			called = append(called, toolCallName(message.Params))
+			mutex.Unlock()
+			return `{"content":[]}`
+		}
+		return `{}`
+	})
+
+	logDir := t.TempDir()
+	auditLog, err := audit.Open(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+
+	ctx := context.Background()
+	inspector, err := NewInspector(ctx, server.URL, ProxyOptions{
+		ToolPolicy: config.ToolPolicy{Deny: []string{"*_delete"}},
+		ServerName: "stub",
+		AuditLog:   auditLog,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer inspector.Close()
+
+	inspection, err := inspector.Inspect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inspection.Tools) != 1 || inspection.Tools[0]["name"] != "issue_search" {
+		t.Errorf("expected only the allowed tool to be listed, got %v", inspection.Tools)
+	}
+
+	result, err := inspector.CallTool(ctx, "issue_delete", json.RawMessage(`{"id":1}`))
+	if err != nil || !result.IsError {
+		t.Fatalf("expected the denied call to fail, got %+v (%v)", result, err)
+	}
+	if result, err := inspector.CallTool(ctx, "issue_search", nil); err != nil || result.IsError {
+		t.Fatalf("expected the allowed call to succeed, got %+v (%v)", result, err)
+	}
+
+	mutex.Lock()
+	if len(called) != 1 || called[0] != "issue_search" {
+		t.Errorf("expected only the allowed call to reach the server, got %v", called)
+	}
+	mutex.Unlock()
+
+	records, err := audit.Read(auditLog.Path(), audit.Query{Server: "stub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Tool != "issue_delete" || records[0].Status != audit.StatusRejected || records[1].Status != audit.StatusSuccess {
+		t.Fatalf("expected a rejected and a successful call in the audit log, got %+v", records)
+	}
+	if records[0].Client != "neobelt-inspector" {
+		t.Errorf("expected the inspector as client, got %q", records[0].Client)
+	}
+}
+
+func TestQuietInspectorWritesNothingToStderr(t *testing.T) {
+	server := newSessionServer(t)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() { os.Stderr = stderr }()
+
+	ctx := context.Background()
+	inspector, err := NewInspector(ctx, server.URL, ProxyOptions{Quiet: true})
+	if err == nil {
+		err = inspector.Ping(ctx)
+		inspector.Close()
+	}
+	os.Stderr = stderr
+	writer.Close()
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(reader)
+	if len(output) > 0 {
+		t.Errorf("expected no output on stderr, got %q", output)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.deleted) != 1 {
+		t.Errorf("expected the session to be terminated, got %v", server.deleted)
+	}
+}
@@ -0,0 +1,406 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Transport modes supported by the proxy
+const (
+	TransportAuto           = "auto"            // Streamable HTTP, falling back to HTTP+SSE on 4xx
+	TransportStreamableHTTP = "streamable-http" // Streamable HTTP only
+	TransportSSE            = "sse"             // legacy HTTP+SSE transport (protocol version 2024-11-05)
+)
+
+// legacyEndpointTimeout bounds how long we wait for the endpoint event after opening the SSE stream
+const legacyEndpointTimeout = 30 * time.Second
+
+// legacyConnection is an open HTTP+SSE connection. Messages are POSTed to the endpoint
+// announced by the server, and every reply arrives on the SSE stream.
+type legacyConnection struct {
+	endpoint string
+	closed   chan struct{} // closed when the SSE stream ends
+}
+
+// transportMode returns the transport currently used to talk to the server
+func (p *MCPProxy) transportMode() string {
+	p.sessionMutex.RLock()
+	defer p.sessionMutex.RUnlock()
+	return p.transport
+}
+
+// usesLegacySSE reports whether the proxy talks to the server with the HTTP+SSE transport
+func (p *MCPProxy) usesLegacySSE() bool {
+	return p.transportMode() == TransportSSE
+}
+
+// switchToLegacySSE makes all further messages use the HTTP+SSE transport
+func (p *MCPProxy) switchToLegacySSE() {
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+	p.transport = TransportSSE
+}
+
+// forwardToLegacySSE posts a message or batch to the legacy message endpoint and waits until
+// every request in it has been answered on the SSE stream. Like postWithRetry it waits for an
+// unreachable server to come back, and idempotent requests are retried on 502/503 and when the
+// stream is lost before they were answered.
+func (p *MCPProxy) forwardToLegacySSE(ctx context.Context, messages []JSONRPCMessage, batch bool) error {
+	initialize := !batch && messages[0].Method == "initialize"
+	if initialize {
+		p.sessionMutex.Lock()
+		p.initializeParams = messages[0].Params
+		p.initialized = false
+		p.sessionMutex.Unlock()
+	}
+
+	var payload interface{} = messages[0]
+	if batch {
+		payload = messages
+	}
+
+	idempotent := isIdempotent(messages)
+	backoff := retryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err := p.waitForBackend(ctx); err != nil {
+			return err
+		}
+
+		conn, err := p.connectLegacySSE(ctx)
+		if err == nil {
+			err = p.postLegacy(ctx, conn, payload)
+		}
+		if err != nil && ctx.Err() == nil && isConnectionError(err) && !p.noQueue {
+			p.markBackendDown(err)
+			continue
+		}
+
+		var statusErr *legacyStatusError
+		retryable := errors.As(err, &statusErr) && isRetryableStatus(statusErr.statusCode)
+		if err == nil {
+			err = p.awaitLegacyResponses(ctx, conn, messages)
+			retryable = err == errLegacyConnectionLost
+		}
+		if err == nil {
+			if initialize {
+				p.sessionMutex.Lock()
+				p.initialized = true
+				p.sessionMutex.Unlock()
+			}
+			return nil
+		}
+
+		if !idempotent || !retryable || attempt >= retryMaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%v, retrying in %v (attempt %d of %d)\n", err, backoff, attempt+1, retryMaxAttempts)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// legacyStatusError is returned when the message endpoint answers a POST with a non-2xx status
+type legacyStatusError struct {
+	statusCode int
+	body       string
+}
+
+func (e *legacyStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d %s", e.statusCode, e.body)
+}
+
+// errLegacyConnectionLost is returned when the SSE stream ends while requests are waiting for answers
+var errLegacyConnectionLost = errors.New("connection to server lost before the request was answered")
+
+// postLegacy posts a message or batch to the message endpoint of an HTTP+SSE connection
+func (p *MCPProxy) postLegacy(ctx context.Context, conn *legacyConnection, payload interface{}) error {
+	messageBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", conn.endpoint, bytes.NewReader(messageBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.setCustomHeaders(req)
+
+	started := time.Now()
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		p.recordSent(messageBytes, "", started, 0, err)
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	p.recordSent(messageBytes, "", started, resp.StatusCode, nil)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Fprintf(os.Stderr, "HTTP error %d: %s\n", resp.StatusCode, string(body))
+		return &legacyStatusError{statusCode: resp.StatusCode, body: string(body)}
+	}
+	return nil
+}
+
+// awaitLegacyResponses waits until every request has been answered on the SSE stream
+func (p *MCPProxy) awaitLegacyResponses(ctx context.Context, conn *legacyConnection, messages []JSONRPCMessage) error {
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+
+		done := p.requestDone(message.ID)
+		if done == nil {
+			continue // already answered
+		}
+
+		select {
+		case <-done:
+		case <-conn.closed:
+			return errLegacyConnectionLost
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// connectLegacySSE returns the open HTTP+SSE connection, opening the SSE stream and waiting
+// for the server's endpoint event if there is none yet
+func (p *MCPProxy) connectLegacySSE(ctx context.Context) (*legacyConnection, error) {
+	p.legacyMutex.Lock()
+	defer p.legacyMutex.Unlock()
+
+	reconnect := false
+	if p.legacy != nil {
+		select {
+		case <-p.legacy.closed:
+			// The previous stream ended, the server forgot everything about it
+			fmt.Fprintf(os.Stderr, "HTTP+SSE connection was lost, reconnecting\n")
+			reconnect = true
+		default:
+			return p.legacy, nil
+		}
+	}
+
+	// The stream lives as long as the proxy, not just the request that opened it
+	req, err := http.NewRequestWithContext(p.listenerCtx, "GET", p.targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSE request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	p.setCustomHeaders(req)
+
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSE stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP error opening SSE stream: %d %s", resp.StatusCode, string(body))
+	}
+
+	// The first event announces where to POST messages
+	timer := time.AfterFunc(legacyEndpointTimeout, func() { resp.Body.Close() })
+	reader := NewSSEReader(resp.Body)
+	event, err := reader.Next()
+	timer.Stop()
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read endpoint event: %w", err)
+	}
+	if event.Event != "endpoint" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("expected endpoint event, got %q", event.Event)
+	}
+
+	endpoint, err := resolveEndpoint(p.targetURL, string(event.Data))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "HTTP+SSE message endpoint: %s\n", endpoint)
+
+	conn := &legacyConnection{
+		endpoint: endpoint,
+		closed:   make(chan struct{}),
+	}
+	p.legacy = conn
+
+	p.listenerWG.Add(1)
+	go func() {
+		defer p.listenerWG.Done()
+		defer close(conn.closed)
+		defer resp.Body.Close()
+		p.readLegacyStream(reader)
+	}()
+
+	// A new stream is a new session, restore it before anything else is sent on it
+	if reconnect && p.sessionInitialized() {
+		if err := p.reinitializeLegacySSE(ctx, conn); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to re-initialize after reconnecting: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// reinitializeLegacySSE replays the client's initialize request on a new HTTP+SSE connection,
+// followed by the initialized notification
+func (p *MCPProxy) reinitializeLegacySSE(ctx context.Context, conn *legacyConnection) error {
+	p.sessionMutex.Lock()
+	p.reinitCount++
+	initialize := JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      fmt.Sprintf("neobelt-reinitialize-%d", p.reinitCount),
+		Method:  "initialize",
+		Params:  p.initializeParams,
+	}
+	p.sessionMutex.Unlock()
+
+	// The response arrives on the stream, where it must not reach the client
+	responses := make(chan JSONRPCMessage, 1)
+	key := idKey(initialize.ID)
+	p.inflightMutex.Lock()
+	p.internalResponses[key] = responses
+	p.inflightMutex.Unlock()
+	defer func() {
+		p.inflightMutex.Lock()
+		delete(p.internalResponses, key)
+		p.inflightMutex.Unlock()
+	}()
+
+	if err := p.postLegacy(ctx, conn, initialize); err != nil {
+		return err
+	}
+
+	select {
+	case response := <-responses:
+		if response.Error != nil {
+			return fmt.Errorf("initialize failed: %s", response.Error.Message)
+		}
+	case <-conn.closed:
+		return errLegacyConnectionLost
+	case <-time.After(legacyEndpointTimeout):
+		return fmt.Errorf("no initialize response received")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	fmt.Fprintf(os.Stderr, "HTTP+SSE session re-initialized\n")
+	return p.postLegacy(ctx, conn, JSONRPCMessage{
+		JSONRPC: "2.0",
+		Method:  "notifications/initialized",
+	})
+}
+
+// takeInternalResponse hands a response to a request the proxy sent on its own, reporting
+// whether the response belonged to one
+func (p *MCPProxy) takeInternalResponse(message JSONRPCMessage) bool {
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	responses, exists := p.internalResponses[idKey(message.ID)]
+	if !exists {
+		return false
+	}
+	select {
+	case responses <- message:
+	default:
+	}
+	return true
+}
+
+// readLegacyStream forwards every message event on the HTTP+SSE stream until it ends
+func (p *MCPProxy) readLegacyStream(reader *SSEReader) {
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			if p.listenerCtx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "HTTP+SSE stream closed: %v\n", err)
+			}
+			return
+		}
+
+		if event.Event != "" && event.Event != "message" {
+			fmt.Fprintf(os.Stderr, "Ignoring SSE event of type %q\n", event.Event)
+			continue
+		}
+
+		messages, batch, err := parseMessages(event.Data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON parse error from SSE data: %v\n", err)
+			continue
+		}
+		p.routeServerMessages(event.Data, messages, batch, nil)
+	}
+}
+
+// resolveEndpoint resolves the (usually relative) endpoint URI against the SSE URL. Messages
+// carry the custom headers and the OAuth token, so endpoints on another origin are rejected.
+func resolveEndpoint(baseURL, endpoint string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid target URL: %w", err)
+	}
+	ref, err := url.Parse(string(bytes.TrimSpace([]byte(endpoint))))
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint URI %q: %w", endpoint, err)
+	}
+
+	resolved := base.ResolveReference(ref)
+	if !sameOrigin(base, resolved) {
+		return "", fmt.Errorf("endpoint %s is not on the origin of the target URL", resolved)
+	}
+	return resolved.String(), nil
+}
+
+// sameOrigin reports whether two URLs have the same scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		effectivePort(a) == effectivePort(b)
+}
+
+// effectivePort returns the port of a URL, the default port of its scheme if it has none
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
+
+// shouldFallBackToLegacySSE reports whether a failed Streamable HTTP initialize indicates
+// a server that only speaks the older HTTP+SSE transport
+func shouldFallBackToLegacySSE(statusCode int) bool {
+	if statusCode < 400 || statusCode > 499 {
+		return false
+	}
+	// Authentication and rate limiting failures say nothing about the transport
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "/messages?session=1", want: "https://mcp.example.com/messages?session=1"},
+		{endpoint: "messages", want: "https://mcp.example.com/sse/messages"},
+		{endpoint: "https://mcp.example.com:443/messages", want: "https://mcp.example.com:443/messages"},
+		{endpoint: "https://attacker.example.com/messages", wantErr: true},
+		{endpoint: "//attacker.example.com/messages", wantErr: true},
+		{endpoint: "http://mcp.example.com/messages", wantErr: true},
+		{endpoint: "https://mcp.example.com:8443/messages", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := resolveEndpoint("https://mcp.example.com/sse/", test.endpoint)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("resolveEndpoint(%q) = %q, want an error", test.endpoint, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveEndpoint(%q) failed: %v", test.endpoint, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("resolveEndpoint(%q) = %q, want %q", test.endpoint, got, test.want)
+		}
+	}
+}
+
+// legacyTestServer speaks the HTTP+SSE transport: GET /sse opens the stream and announces
+// /messages, where every request is accepted and answered on the stream with its method
+type legacyTestServer struct {
+	*httptest.Server
+	messages chan []byte // events for the open stream
+
+	mutex    sync.Mutex
+	requests []string // method and path of every request
+}
+
+// newLegacyTestServer starts an HTTP+SSE server. A Streamable HTTP POST to /sse is answered
+// with postStatus.
+func newLegacyTestServer(t *testing.T, postStatus int) *legacyTestServer {
+	server := &legacyTestServer{messages: make(chan []byte, 16)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.requests = append(server.requests, r.Method+" "+r.URL.RequestURI())
+		server.mutex.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/sse":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case message := <-server.messages:
+					fmt.Fprintf(w, "event: message\ndata: %s\n\n", message)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+		case r.Method == "POST" && r.URL.Path == "/sse":
+			w.WriteHeader(postStatus)
+
+		case r.Method == "POST" && r.URL.Path == "/messages":
+			body, _ := io.ReadAll(r.Body)
+			var message JSONRPCMessage
+			if err := json.Unmarshal(body, &message); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if isRequest(message) {
+				result, _ := json.Marshal(map[string]string{"method": message.Method})
+				response, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: result})
+				server.messages <- response
+			}
+			w.WriteHeader(http.StatusAccepted)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *legacyTestServer) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// legacyResultMethod returns the method a legacyTestServer answered
+func legacyResultMethod(t *testing.T, response JSONRPCMessage) string {
+	t.Helper()
+
+	var result struct {
+		Method string `json:"method"`
+	}
+	if response.Error != nil || json.Unmarshal(response.Result, &result) != nil {
+		t.Fatalf("expected a result from the server, got %+v", response)
+	}
+	return result.Method
+}
+
+func TestProxyTalksHTTPSSE(t *testing.T) {
+	server := newLegacyTestServer(t, http.StatusMethodNotAllowed)
+	tp := startTestProxy(t, server.URL+"/sse", ProxyOptions{Transport: TransportSSE})
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) || legacyResultMethod(t, response) != "initialize" {
+		t.Fatalf("expected the initialize response from the stream, got %+v", response)
+	}
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	tp.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(2) || legacyResultMethod(t, response) != "tools/list" {
+		t.Fatalf("expected the tools/list response from the stream, got %+v", response)
+	}
+
+	// One stream for the whole session, every message goes to the announced endpoint
+	want := "GET /sse,POST /messages?session=1,POST /messages?session=1,POST /messages?session=1"
+	if got := strings.Join(server.received(), ","); got != want {
+		t.Errorf("expected the requests %s, got %s", want, got)
+	}
+}
+
+func TestProxyFallsBackToHTTPSSE(t *testing.T) {
+	tests := []struct {
+		status   int
+		fallBack bool
+	}{
+		{http.StatusNotFound, true},
+		{http.StatusMethodNotAllowed, true},
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusTooManyRequests, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			server := newLegacyTestServer(t, test.status)
+			tp := startTestProxy(t, server.URL+"/sse", ProxyOptions{})
+
+			tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+			response := tp.receive(t, 2*time.Second)
+			if !test.fallBack {
+				if response.Error == nil {
+					t.Fatalf("expected the failed initialize to be reported, got %+v", response)
+				}
+				if requests := server.received(); len(requests) != 1 {
+					t.Errorf("expected no HTTP+SSE requests after HTTP %d, got %v", test.status, requests)
+				}
+				return
+			}
+
+			if legacyResultMethod(t, response) != "initialize" {
+				t.Fatalf("expected the initialize response over HTTP+SSE, got %+v", response)
+			}
+			if !tp.proxy.usesLegacySSE() {
+				t.Error("expected the proxy to stay on HTTP+SSE")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reconnect backoff for the server-initiated message stream
+const (
+	listenerInitialBackoff = 1 * time.Second
+	listenerMaxBackoff     = 30 * time.Second
+)
+
+// startListener opens the standalone GET SSE stream in the background (only once per proxy)
+func (p *MCPProxy) startListener() {
+	// The HTTP+SSE transport already delivers everything on its own stream
+	if p.usesLegacySSE() {
+		return
+	}
+
+	p.listenerOnce.Do(func() {
+		p.listenerWG.Add(1)
+		go func() {
+			defer p.listenerWG.Done()
+			p.listen(p.listenerCtx)
+		}()
+	})
+}
+
+// listen keeps a GET SSE stream open to receive server-initiated notifications and requests
+// (sampling, elicitation, roots/list, ...). The client's replies to those requests arrive on
+// stdin as responses and are routed back to the server with POST like any other message.
+func (p *MCPProxy) listen(ctx context.Context) {
+	backoff := listenerInitialBackoff
+	lastEventID := ""
+	streamSessionID := ""
+
+	for {
+		// Event IDs are only meaningful within the session they were issued in
+		sessionID := p.currentSessionID()
+		if sessionID != streamSessionID {
+			lastEventID = ""
+			streamSessionID = sessionID
+		}
+
+		connected, retry, err := p.openServerStream(ctx, &lastEventID)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == errStreamNotSupported {
+			p.logInfo("Server does not offer a GET stream for server-initiated messages\n")
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Server message stream error: %v\n", err)
+		}
+
+		// A stream that was established resets the backoff, the server's retry hint wins if given
+		if connected {
+			backoff = listenerInitialBackoff
+		}
+		delay := backoff
+		if retry > 0 {
+			delay = retry
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if !connected {
+			backoff *= 2
+			if backoff > listenerMaxBackoff {
+				backoff = listenerMaxBackoff
+			}
+		}
+	}
+}
+
+// errStreamNotSupported is returned when the server answers the GET stream with 405
+var errStreamNotSupported = errors.New("server does not support the GET stream")
+
+// openServerStream opens a single GET SSE stream and forwards its messages until it ends.
+// It reports whether the stream was established and the reconnection delay requested by the server.
+func (p *MCPProxy) openServerStream(ctx context.Context, lastEventID *string) (bool, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.targetURL, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	p.setSessionHeaders(req)
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return false, 0, errStreamNotSupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, 0, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, string(body))
+	}
+	mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+	if mediaType != "text/event-stream" {
+		return false, 0, errStreamNotSupported
+	}
+
+	p.logInfo("Server message stream connected\n")
+
+	reader := NewSSEReader(resp.Body)
+	for {
+		event, err := reader.Next()
+		retry := reader.RetryDelay()
+		if id := reader.LastEventID(); id != "" {
+			*lastEventID = id
+		}
+		if err == io.EOF {
+			return true, retry, nil
+		}
+		if err != nil {
+			return true, retry, fmt.Errorf("failed to read SSE stream: %w", err)
+		}
+
+		// Only "message" events (the default type) carry JSON-RPC messages
+		if event.Event != "" && event.Event != "message" {
+			continue
+		}
+
+		messages, batch, err := parseMessages(event.Data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON parse error from SSE data: %v\n", err)
+			continue
+		}
+
+		// On resumption the server may replay responses to requests still in flight
+		p.routeServerMessages(event.Data, messages, batch, nil)
+	}
+}
+
+// currentSessionID returns the session ID currently negotiated with the server
+func (p *MCPProxy) currentSessionID() string {
+	p.sessionMutex.RLock()
+	defer p.sessionMutex.RUnlock()
+	return p.sessionID
+}
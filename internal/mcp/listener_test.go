@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamRequest is a GET stream request a test server received
+type streamRequest struct {
+	at          time.Time
+	lastEventID string
+}
+
+// newStreamTestServer answers POSTs with 202 and hands every GET stream to stream, numbered
+// from 0. It keeps the GET requests it received.
+func newStreamTestServer(t *testing.T, stream func(n int, w http.ResponseWriter, r *http.Request)) (*httptest.Server, func() []streamRequest) {
+	var mutex sync.Mutex
+	var requests []streamRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		mutex.Lock()
+		n := len(requests)
+		requests = append(requests, streamRequest{at: time.Now(), lastEventID: r.Header.Get("Last-Event-ID")})
+		mutex.Unlock()
+		stream(n, w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []streamRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]streamRequest{}, requests...)
+	}
+}
+
+// startTestListener makes a test proxy open its GET stream, which it does once the client
+// completed initialization
+func startTestListener(t *testing.T, tp *testProxy) {
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+}
+
+func TestListenerResumesWithLastEventID(t *testing.T) {
+	server, requests := newStreamTestServer(t, func(n int, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 0 {
+			// The stream drops after the first event, the server asks to reconnect right away
+			fmt.Fprint(w, "retry: 10\n\nid: event-1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/tools/list_changed\"}\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: event-2\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/resources/list_changed\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	startTestListener(t, tp)
+
+	for _, method := range []string{"notifications/tools/list_changed", "notifications/resources/list_changed"} {
+		if notification := tp.receive(t, 2*time.Second); notification.Method != method {
+			t.Fatalf("expected %s, got %+v", method, notification)
+		}
+	}
+
+	got := requests()
+	if len(got) != 2 || got[0].lastEventID != "" || got[1].lastEventID != "event-1" {
+		t.Fatalf("expected the second stream to resume after event-1, got %+v", got)
+	}
+	if wait := got[1].at.Sub(got[0].at); wait >= listenerInitialBackoff {
+		t.Errorf("expected the server's retry delay instead of the backoff, reconnected after %v", wait)
+	}
+}
+
+func TestListenerStopsWithoutGETStream(t *testing.T) {
+	server, requests := newStreamTestServer(t, func(n int, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	startTestListener(t, tp)
+
+	// Longer than the first reconnect would take
+	time.Sleep(listenerInitialBackoff + 500*time.Millisecond)
+	if got := requests(); len(got) != 1 {
+		t.Errorf("expected a single GET, got %d", len(got))
+	}
+}
+
+func TestListenerBacksOffBetweenFailedReconnects(t *testing.T) {
+	server, requests := newStreamTestServer(t, func(n int, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	startTestListener(t, tp)
+
+	deadline := time.Now().Add(3*listenerInitialBackoff + 2*time.Second)
+	for len(requests()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 attempts, got %d", len(requests()))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The delay doubles after each attempt that didn't get a stream
+	got := requests()
+	const slack = 50 * time.Millisecond
+	if wait := got[1].at.Sub(got[0].at); wait < listenerInitialBackoff-slack || wait >= 2*listenerInitialBackoff {
+		t.Errorf("expected the first retry after %v, got %v", listenerInitialBackoff, wait)
+	}
+	if wait := got[2].at.Sub(got[1].at); wait < 2*listenerInitialBackoff-slack {
+		t.Errorf("expected the second retry after %v, got %v", 2*listenerInitialBackoff, wait)
+	}
+}
@@ -0,0 +1,408 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"neobelt/internal/config"
+)
+
+// Timing of the OAuth token handling
+const (
+	oauthRequestTimeout = 30 * time.Second // timeout for discovery, registration and token requests
+	oauthRefreshMargin  = 30 * time.Second // tokens expiring within this margin are refreshed up front
+)
+
+// oauthTransport adds OAuth bearer tokens to requests for the MCP server and runs the MCP
+// authorization flow whenever the server answers with 401 Unauthorized
+type oauthTransport struct {
+	base     http.RoundTripper
+	client   *http.Client // used for discovery, registration and token requests
+	resource string       // canonical URI of the MCP server, the audience of our tokens
+	clientID string       // pre-registered client ID, empty to use dynamic client registration
+	openURL  func(string) // shows the authorization URL to the user
+
+	mutex      sync.Mutex
+	state      *oauthState // cached state, nil until loaded from disk
+	generation int         // incremented whenever the access token changes
+}
+
+// oauthState is what gets cached on disk per MCP server
+type oauthState struct {
+	Resource                string      `json:"resource"`
+	Issuer                  string      `json:"issuer,omitempty"`
+	TokenEndpoint           string      `json:"token_endpoint,omitempty"`
+	ClientID                string      `json:"client_id,omitempty"`
+	ClientSecret            string      `json:"client_secret,omitempty"`
+	TokenEndpointAuthMethod string      `json:"token_endpoint_auth_method,omitempty"`
+	RedirectURI             string      `json:"redirect_uri,omitempty"`
+	Token                   *oauthToken `json:"token,omitempty"`
+}
+
+// oauthToken is an access token with its optional refresh token
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// bearerChallenge holds the parameters of a WWW-Authenticate: Bearer header
+type bearerChallenge struct {
+	resourceMetadata string
+	scope            string
+	err              string
+}
+
+// newOAuthTransport creates the OAuth transport for the given MCP server URL
+func newOAuthTransport(targetURL, clientID string) *oauthTransport {
+	return &oauthTransport{
+		base:     http.DefaultTransport,
+		client:   &http.Client{Timeout: oauthRequestTimeout},
+		resource: canonicalResourceURI(targetURL),
+		clientID: clientID,
+		openURL:  openBrowser,
+	}
+}
+
+// RoundTrip sends the request with the current access token, authorizing and retrying once on 401
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, generation := t.accessToken(req.Context())
+
+	resp, err := t.send(req, req.Body, token)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := parseBearerChallenge(resp.Header.Values("WWW-Authenticate"))
+	insufficientScope := resp.StatusCode == http.StatusForbidden && challenge.err == "insufficient_scope"
+	if resp.StatusCode != http.StatusUnauthorized && !insufficientScope {
+		return resp, nil
+	}
+
+	// Without a way to replay the body the caller has to deal with the 401 itself
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	// The user may take a while to log in, which must not count against the request's timeout
+	timer := requestTimerFrom(req.Context())
+	timer.pause()
+	err = t.authorize(req.Context(), generation, challenge)
+	timer.resume()
+	if err != nil {
+		return nil, fmt.Errorf("OAuth authorization failed: %w", err)
+	}
+
+	body := req.Body
+	if req.GetBody != nil {
+		if body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+	}
+	token, _ = t.accessToken(req.Context())
+	return t.send(req, body, token)
+}
+
+// send performs the request with the given body and bearer token without modifying the original
+func (t *oauthTransport) send(req *http.Request, body io.ReadCloser, token string) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	if token != "" {
+		clone.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.base.RoundTrip(clone)
+}
+
+// accessToken returns the cached access token, refreshing it first if it is about to expire
+func (t *oauthTransport) accessToken(ctx context.Context) (string, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state, err := t.loadState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load OAuth tokens: %v\n", err)
+		return "", t.generation
+	}
+	if state.Token == nil {
+		return "", t.generation
+	}
+
+	expiring := !state.Token.ExpiresAt.IsZero() && time.Until(state.Token.ExpiresAt) < oauthRefreshMargin
+	if expiring && state.Token.RefreshToken != "" {
+		if err := t.refresh(ctx, state); err != nil {
+			// Keep going, the server will answer 401 and trigger a new authorization
+			fmt.Fprintf(os.Stderr, "Failed to refresh OAuth token: %v\n", err)
+		}
+	}
+
+	return state.Token.AccessToken, t.generation
+}
+
+// authorize obtains a new access token, silently through the refresh token if possible
+// and through the interactive browser flow otherwise
+func (t *oauthTransport) authorize(ctx context.Context, generation int, challenge bearerChallenge) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Another request already got a new token while this one was waiting
+	if t.generation != generation {
+		return nil
+	}
+
+	state, err := t.loadState()
+	if err != nil {
+		return err
+	}
+
+	// A refreshed token has the same scopes, so it cannot help with insufficient_scope
+	if challenge.err != "insufficient_scope" && state.Token != nil && state.Token.RefreshToken != "" {
+		err := t.refresh(ctx, state)
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "Failed to refresh OAuth token, starting a new authorization: %v\n", err)
+	}
+
+	return t.authorizeInteractive(ctx, state, challenge)
+}
+
+// refresh exchanges the refresh token for a new access token
+func (t *oauthTransport) refresh(ctx context.Context, state *oauthState) error {
+	if state.TokenEndpoint == "" {
+		return fmt.Errorf("no token endpoint known")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", state.Token.RefreshToken)
+	form.Set("resource", state.Resource)
+
+	token, err := t.requestToken(ctx, state, form)
+	if err != nil {
+		return err
+	}
+
+	// Servers that don't rotate refresh tokens omit them from the response
+	if token.RefreshToken == "" {
+		token.RefreshToken = state.Token.RefreshToken
+	}
+
+	return t.storeToken(state, token)
+}
+
+// requestToken posts a token request with the client's credentials and parses the response
+func (t *oauthTransport) requestToken(ctx context.Context, state *oauthState, form url.Values) (*oauthToken, error) {
+	if state.TokenEndpointAuthMethod != "client_secret_basic" {
+		form.Set("client_id", state.ClientID)
+	}
+	if state.TokenEndpointAuthMethod == "client_secret_post" {
+		form.Set("client_secret", state.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", state.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if state.TokenEndpointAuthMethod == "client_secret_basic" {
+		req.SetBasicAuth(url.QueryEscape(state.ClientID), url.QueryEscape(state.ClientSecret))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		RefreshToken     string `json:"refresh_token"`
+		Scope            string `json:"scope"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("token request rejected: %s %s", response.Error, response.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || response.AccessToken == "" {
+		return nil, fmt.Errorf("token request failed with HTTP %d", resp.StatusCode)
+	}
+
+	token := &oauthToken{
+		AccessToken:  response.AccessToken,
+		RefreshToken: response.RefreshToken,
+		TokenType:    response.TokenType,
+		Scope:        response.Scope,
+	}
+	if response.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// storeToken makes a new token current and persists it
+func (t *oauthTransport) storeToken(state *oauthState, token *oauthToken) error {
+	state.Token = token
+	t.generation++
+	return t.saveState(state)
+}
+
+// loadState returns the cached state, reading it from disk the first time
+func (t *oauthTransport) loadState() (*oauthState, error) {
+	if t.state != nil {
+		return t.state, nil
+	}
+
+	path, err := t.statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &oauthState{Resource: t.resource}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read OAuth token cache: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			// A corrupt cache only means logging in again
+			fmt.Fprintf(os.Stderr, "Ignoring unreadable OAuth token cache %s: %v\n", path, err)
+			state = &oauthState{Resource: t.resource}
+		}
+	}
+
+	t.state = state
+	return state, nil
+}
+
+// saveState writes the state to disk, readable by the current user only
+func (t *oauthTransport) saveState(state *oauthState) error {
+	path, err := t.statePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OAuth token cache: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a half-written cache behind
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write OAuth token cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write OAuth token cache: %w", err)
+	}
+	return nil
+}
+
+// statePath returns the token cache file for this MCP server under the Neobelt config dir
+func (t *oauthTransport) statePath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	oauthDir := filepath.Join(configDir, "oauth")
+	if err := os.MkdirAll(oauthDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create OAuth directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(t.resource))
+	return filepath.Join(oauthDir, hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// canonicalResourceURI strips query and fragment from the server URL and normalizes scheme and host
+func canonicalResourceURI(targetURL string) string {
+	parsed, err := url.Parse(targetURL)
+	if err != nil {
+		return targetURL
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// parseBearerChallenge extracts the parameters of the Bearer challenge from WWW-Authenticate headers
+func parseBearerChallenge(headers []string) bearerChallenge {
+	var challenge bearerChallenge
+	for _, header := range headers {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			continue
+		}
+
+		for key, value := range parseAuthParams(params) {
+			switch key {
+			case "resource_metadata":
+				challenge.resourceMetadata = value
+			case "scope":
+				challenge.scope = value
+			case "error":
+				challenge.err = value
+			}
+		}
+	}
+	return challenge
+}
+
+// parseAuthParams parses a comma separated list of key=value or key="quoted value" pairs
+func parseAuthParams(input string) map[string]string {
+	params := make(map[string]string)
+	for input != "" {
+		input = strings.TrimLeft(input, " \t,")
+		key, rest, found := strings.Cut(input, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			input = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			input = rest[end:]
+		}
+
+		params[key] = value.String()
+	}
+	return params
+}
@@ -0,0 +1,408 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// oauthAuthorizationTimeout is how long we wait for the user to finish logging in
+const oauthAuthorizationTimeout = 5 * time.Minute
+
+// protectedResourceMetadata is the OAuth 2.0 Protected Resource Metadata (RFC 9728) of the MCP server
+type protectedResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported"`
+}
+
+// authorizationServerMetadata is the OAuth 2.0 Authorization Server Metadata (RFC 8414)
+type authorizationServerMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RegistrationEndpoint          string   `json:"registration_endpoint"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// authorizeInteractive runs the authorization code flow with PKCE in the user's browser
+func (t *oauthTransport) authorizeInteractive(ctx context.Context, state *oauthState, challenge bearerChallenge) error {
+	resourceMetadata := t.discoverProtectedResource(ctx, challenge.resourceMetadata)
+
+	// Servers without resource metadata (2025-03-26 spec) use their own origin as authorization server
+	issuer := originOf(t.resource)
+	scope := challenge.scope
+	if resourceMetadata != nil {
+		if len(resourceMetadata.AuthorizationServers) > 0 {
+			issuer = resourceMetadata.AuthorizationServers[0]
+		}
+		// A server must not get the user to authorize a token for another server's resource (RFC 9728 section 3.3)
+		if resourceMetadata.Resource != "" {
+			if canonicalResourceURI(resourceMetadata.Resource) != t.resource {
+				return fmt.Errorf("protected resource metadata is for %s instead of %s", resourceMetadata.Resource, t.resource)
+			}
+			state.Resource = resourceMetadata.Resource
+		}
+		if scope == "" {
+			scope = strings.Join(resourceMetadata.ScopesSupported, " ")
+		}
+	}
+
+	metadata, err := t.discoverAuthorizationServer(ctx, issuer)
+	if err != nil {
+		return err
+	}
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		return fmt.Errorf("authorization server %s does not support PKCE with S256", issuer)
+	}
+
+	// Registrations belong to an authorization server, start over if it changed
+	if state.Issuer != issuer {
+		state.ClientID = ""
+		state.ClientSecret = ""
+		state.TokenEndpointAuthMethod = ""
+		state.RedirectURI = ""
+	}
+	state.Issuer = issuer
+	state.TokenEndpoint = metadata.TokenEndpoint
+
+	listener, redirectURI, err := listenForRedirect(state.RedirectURI)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	switch {
+	case t.clientID != "":
+		state.ClientID = t.clientID
+		state.ClientSecret = ""
+		state.TokenEndpointAuthMethod = "none"
+	case state.ClientID == "" || state.RedirectURI != redirectURI:
+		if metadata.RegistrationEndpoint == "" {
+			return fmt.Errorf("authorization server %s does not support dynamic client registration, pass --oauth-client-id", issuer)
+		}
+		if err := t.registerClient(ctx, metadata.RegistrationEndpoint, redirectURI, state); err != nil {
+			return err
+		}
+	}
+	state.RedirectURI = redirectURI
+	if err := t.saveState(state); err != nil {
+		return err
+	}
+
+	verifier, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	expectedState, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+	challengeSum := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", state.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challengeSum[:]))
+	query.Set("code_challenge_method", "S256")
+	query.Set("state", expectedState)
+	query.Set("resource", state.Resource)
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	authorizationURL := metadata.AuthorizationEndpoint + "?" + query.Encode()
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		authorizationURL = metadata.AuthorizationEndpoint + "&" + query.Encode()
+	}
+
+	// stdout belongs to the MCP client, so all interaction goes through stderr and the browser
+	fmt.Fprintf(os.Stderr, "Authorization required for %s, open this URL to log in:\n%s\n", t.resource, authorizationURL)
+	t.openURL(authorizationURL)
+
+	code, err := waitForAuthorizationCode(ctx, listener, expectedState)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+	form.Set("resource", state.Resource)
+
+	token, err := t.requestToken(ctx, state, form)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Authorization for %s completed\n", t.resource)
+	return t.storeToken(state, token)
+}
+
+// discoverProtectedResource fetches the resource metadata, from the URL in the challenge
+// or from the well-known locations. It returns nil if the server doesn't publish any.
+func (t *oauthTransport) discoverProtectedResource(ctx context.Context, metadataURL string) *protectedResourceMetadata {
+	var candidates []string
+	if metadataURL != "" {
+		candidates = append(candidates, metadataURL)
+	}
+	candidates = append(candidates, wellKnownURLs(t.resource, "oauth-protected-resource")...)
+
+	for _, candidate := range candidates {
+		var metadata protectedResourceMetadata
+		if err := t.getJSON(ctx, candidate, &metadata); err != nil {
+			continue
+		}
+		if len(metadata.AuthorizationServers) > 0 {
+			return &metadata
+		}
+	}
+	return nil
+}
+
+// discoverAuthorizationServer fetches the authorization server metadata, trying OAuth and
+// OpenID Connect discovery and falling back to the default endpoint paths
+func (t *oauthTransport) discoverAuthorizationServer(ctx context.Context, issuer string) (*authorizationServerMetadata, error) {
+	candidates := wellKnownURLs(issuer, "oauth-authorization-server")
+	candidates = append(candidates, wellKnownURLs(issuer, "openid-configuration")...)
+	candidates = append(candidates, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+
+	for _, candidate := range candidates {
+		var metadata authorizationServerMetadata
+		if err := t.getJSON(ctx, candidate, &metadata); err != nil {
+			continue
+		}
+		if metadata.AuthorizationEndpoint != "" && metadata.TokenEndpoint != "" {
+			return &metadata, nil
+		}
+	}
+
+	origin := originOf(issuer)
+	if origin == "" {
+		return nil, fmt.Errorf("invalid authorization server %q", issuer)
+	}
+	fmt.Fprintf(os.Stderr, "No authorization server metadata found for %s, using default endpoints\n", issuer)
+	return &authorizationServerMetadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: origin + "/authorize",
+		TokenEndpoint:         origin + "/token",
+		RegistrationEndpoint:  origin + "/register",
+	}, nil
+}
+
+// registerClient registers Neobelt as a public client through dynamic client registration (RFC 7591)
+func (t *oauthTransport) registerClient(ctx context.Context, registrationEndpoint, redirectURI string, state *oauthState) error {
+	request := map[string]interface{}{
+		"client_name":                "Neobelt",
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal client registration: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", registrationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create client registration request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to register client: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var registration struct {
+		ClientID                string `json:"client_id"`
+		ClientSecret            string `json:"client_secret"`
+		TokenEndpointAuthMethod string `json:"token_endpoint_auth_method"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registration); err != nil {
+		return fmt.Errorf("failed to decode client registration (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK || registration.ClientID == "" {
+		return fmt.Errorf("client registration failed with HTTP %d", resp.StatusCode)
+	}
+
+	state.ClientID = registration.ClientID
+	state.ClientSecret = registration.ClientSecret
+	state.TokenEndpointAuthMethod = registration.TokenEndpointAuthMethod
+	if state.TokenEndpointAuthMethod == "" && state.ClientSecret != "" {
+		state.TokenEndpointAuthMethod = "client_secret_basic"
+	}
+
+	fmt.Fprintf(os.Stderr, "Registered OAuth client %s\n", state.ClientID)
+	return nil
+}
+
+// getJSON fetches a metadata document
+func (t *oauthTransport) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(ProtocolVersionHeader, "2025-06-18")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// listenForRedirect opens the loopback listener for the redirect. The port of a previous
+// redirect URI is reused when possible so the registered client stays valid.
+func listenForRedirect(previousRedirectURI string) (net.Listener, string, error) {
+	address := "127.0.0.1:0"
+	if parsed, err := url.Parse(previousRedirectURI); err == nil && parsed.Port() != "" {
+		address = "127.0.0.1:" + parsed.Port()
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil && address != "127.0.0.1:0" {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start redirect listener: %w", err)
+	}
+
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+	return listener, redirectURI, nil
+}
+
+// waitForAuthorizationCode serves the redirect endpoint until the authorization server sends the user back
+func waitForAuthorizationCode(ctx context.Context, listener net.Listener, expectedState string) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var res result
+		switch {
+		case query.Get("state") != expectedState:
+			// Not ours, most likely a stale browser tab
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("authorization denied: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			res.err = fmt.Errorf("no authorization code received")
+		default:
+			res.code = query.Get("code")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if res.err != nil {
+			fmt.Fprintf(w, "<html><body><h1>Authorization failed</h1><p>%s</p></body></html>", html.EscapeString(res.err.Error()))
+		} else {
+			fmt.Fprint(w, "<html><body><h1>Authorization complete</h1><p>You can close this window and return to your MCP client.</p></body></html>")
+		}
+
+		select {
+		case results <- res:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	timer := time.NewTimer(oauthAuthorizationTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-timer.C:
+		return "", fmt.Errorf("timed out waiting for authorization")
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// wellKnownURLs returns the well-known metadata locations for a URL, path-specific first
+func wellKnownURLs(base, suffix string) []string {
+	parsed, err := url.Parse(base)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+
+	origin := parsed.Scheme + "://" + parsed.Host
+	path := strings.TrimSuffix(parsed.Path, "/")
+	if path == "" {
+		return []string{origin + "/.well-known/" + suffix}
+	}
+	return []string{
+		origin + "/.well-known/" + suffix + path,
+		origin + "/.well-known/" + suffix,
+	}
+}
+
+// originOf returns scheme://host of a URL
+func originOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// randomToken returns a URL-safe random string with n bytes of entropy
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// openBrowser tries to open the URL in the default browser, the URL is printed anyway
+func openBrowser(target string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,294 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubAuthServer is a protected MCP endpoint and the authorization server it trusts in one
+type stubAuthServer struct {
+	*httptest.Server
+
+	mutex         sync.Mutex
+	advertised    string // resource named in the protected resource metadata, the MCP endpoint if empty
+	redirectURI   string // registered through dynamic client registration
+	codeChallenge string // of the pending authorization
+	resource      string // requested for the pending authorization
+	validToken    string // the only access token the MCP endpoint accepts
+	grants        []string
+	seenTokens    []string
+}
+
+func newStubAuthServer(t *testing.T) *stubAuthServer {
+	stub := &stubAuthServer{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		stub.mutex.Lock()
+		defer stub.mutex.Unlock()
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		stub.seenTokens = append(stub.seenTokens, token)
+		if stub.validToken == "" || token != stub.validToken {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp", scope="mcp"`, stub.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// RFC 9728 protected resource metadata, pointing to the authorization server
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		stub.mutex.Lock()
+		resource := stub.advertised
+		stub.mutex.Unlock()
+		if resource == "" {
+			resource = stub.URL + "/mcp"
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"resource":              resource,
+			"authorization_servers": []string{stub.URL + "/auth"},
+		})
+	})
+
+	// RFC 8414 authorization server metadata at the path-specific location
+	mux.HandleFunc("/.well-known/oauth-authorization-server/auth", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                           stub.URL + "/auth",
+			"authorization_endpoint":           stub.URL + "/auth/authorize",
+			"token_endpoint":                   stub.URL + "/auth/token",
+			"registration_endpoint":            stub.URL + "/auth/register",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+
+	mux.HandleFunc("/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		var registration struct {
+			RedirectURIs []string `json:"redirect_uris"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&registration); err != nil || len(registration.RedirectURIs) != 1 {
+			http.Error(w, "invalid registration", http.StatusBadRequest)
+			return
+		}
+
+		stub.mutex.Lock()
+		stub.redirectURI = registration.RedirectURIs[0]
+		stub.mutex.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"client_id": "stub-client"})
+	})
+
+	mux.HandleFunc("/auth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		stub.mutex.Lock()
+		defer stub.mutex.Unlock()
+
+		if query.Get("client_id") != "stub-client" || query.Get("redirect_uri") != stub.redirectURI || query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "invalid authorization request", http.StatusBadRequest)
+			return
+		}
+		stub.codeChallenge = query.Get("code_challenge")
+		stub.resource = query.Get("resource")
+
+		redirect := stub.redirectURI + "?" + url.Values{"code": {"stub-code"}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		stub.mutex.Lock()
+		defer stub.mutex.Unlock()
+
+		grant := r.PostForm.Get("grant_type")
+		stub.grants = append(stub.grants, grant)
+
+		var accessToken string
+		switch grant {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if r.PostForm.Get("code") != "stub-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != stub.codeChallenge {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			accessToken = "access-1"
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			accessToken = "access-2"
+		}
+
+		stub.validToken = accessToken
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  accessToken,
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh-1",
+		})
+	})
+
+	stub.Server = httptest.NewServer(mux)
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func TestOAuthAuthorizationAndRefresh(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	stub := newStubAuthServer(t)
+	transport := newOAuthTransport(stub.URL+"/mcp", "")
+
+	// The browser follows the redirect back to the loopback listener
+	transport.openURL = func(authorizationURL string) {
+		go func() {
+			resp, err := http.Get(authorizationURL)
+			if err != nil {
+				t.Errorf("failed to follow the authorization URL: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(stub.URL + "/mcp")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to succeed after authorization, got HTTP %d", resp.StatusCode)
+	}
+
+	// The token is cached for the next run
+	path, err := transport.statePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("token cache was not written: %v", err)
+	}
+	if !strings.Contains(string(data), "access-1") {
+		t.Errorf("token cache does not contain the access token: %s", data)
+	}
+
+	// A token about to expire is refreshed silently before the request goes out
+	transport.mutex.Lock()
+	transport.state.Token.ExpiresAt = time.Now()
+	transport.mutex.Unlock()
+
+	resp, err = client.Get(stub.URL + "/mcp")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to succeed with the refreshed token, got HTTP %d", resp.StatusCode)
+	}
+
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	if stub.resource != stub.URL+"/mcp" {
+		t.Errorf("expected the resource %s in the authorization request, got %q", stub.URL+"/mcp", stub.resource)
+	}
+	if got := strings.Join(stub.grants, ","); got != "authorization_code,refresh_token" {
+		t.Errorf("expected an authorization code exchange and a refresh, got %s", got)
+	}
+	if got := stub.seenTokens[len(stub.seenTokens)-1]; got != "access-2" {
+		t.Errorf("expected the refreshed token to be sent, got %q", got)
+	}
+}
+
+func TestOAuthRejectsMetadataForAnotherResource(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	stub := newStubAuthServer(t)
+	stub.advertised = "https://other.example.com/mcp"
+	transport := newOAuthTransport(stub.URL+"/mcp", "")
+
+	opened := false
+	transport.openURL = func(string) { opened = true }
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(stub.URL + "/mcp")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the authorization to fail, got HTTP %d", resp.StatusCode)
+	}
+	if !strings.Contains(err.Error(), "other.example.com") {
+		t.Errorf("expected the error to name the foreign resource, got %v", err)
+	}
+	if opened {
+		t.Error("expected no authorization URL to be opened")
+	}
+
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	if stub.resource != "" || len(stub.grants) > 0 {
+		t.Errorf("expected no authorization or token request, got resource %q and grants %v", stub.resource, stub.grants)
+	}
+}
+
+func TestWaitForAuthorizationCodeRejectsMismatchedState(t *testing.T) {
+	listener, redirectURI, err := listenForRedirect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		code, err := waitForAuthorizationCode(ctx, listener, "expected-state")
+		results <- result{code, err}
+	}()
+
+	resp, err := http.Get(redirectURI + "?code=forged&state=other-state")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a mismatched state to be rejected, got HTTP %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(redirectURI + "?code=genuine&state=expected-state")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	res := <-results
+	if res.err != nil || res.code != "genuine" {
+		t.Fatalf("expected only the code with the matching state, got %q (%v)", res.code, res.err)
+	}
+}
@@ -0,0 +1,213 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"neobelt/internal/approval"
+	"neobelt/internal/config"
+)
+
+// toolCallName returns the name of the tool a tools/call request wants to run
+func toolCallName(params json.RawMessage) string {
+	var call struct {
+		Name string `json:"name"`
+	}
+	if len(params) > 0 {
+		_ = json.Unmarshal(params, &call)
+	}
+	return call.Name
+}
+
+// toolNotAllowedError is the error returned for tools/call requests the tool policy rejects
+func toolNotAllowedError(tool string) *JSONRPCError {
+	return &JSONRPCError{
+		Code:    -32602,
+		Message: "Tool not allowed",
+		Data:    fmt.Sprintf("tool %q is not allowed by the Neobelt tool policy", tool),
+	}
+}
+
+// filterToolsResult removes the tools the policy does not allow from a tools/list result,
+// reporting whether anything was removed
+func filterToolsResult(result json.RawMessage, policy config.ToolPolicy) (json.RawMessage, bool) {
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal(result, &parsed); err != nil {
+		return result, false
+	}
+
+	var tools []json.RawMessage
+	if err := json.Unmarshal(parsed["tools"], &tools); err != nil {
+		return result, false
+	}
+
+	allowed := make([]json.RawMessage, 0, len(tools))
+	for _, tool := range tools {
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(tool, &named); err == nil && policy.Allows(named.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	if len(allowed) == len(tools) {
+		return result, false
+	}
+
+	parsed["tools"], _ = json.Marshal(allowed)
+	filtered, err := json.Marshal(parsed)
+	if err != nil {
+		return result, false
+	}
+	return filtered, true
+}
+
+// checkToolPolicy returns an error response for a tools/call request the policy rejects
+func (p *MCPProxy) checkToolPolicy(message JSONRPCMessage) *JSONRPCMessage {
+	if message.Method != "tools/call" || p.toolPolicy.IsEmpty() {
+		return nil
+	}
+
+	tool := toolCallName(message.Params)
+	if p.toolPolicy.Allows(tool) {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Rejecting call of tool %q, it is not allowed by the tool policy\n", tool)
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      message.ID,
+		Error:   toolNotAllowedError(tool),
+	}
+}
+
+// applyToolPolicy filters the result of a tools/list response, reporting whether it changed
+func (p *MCPProxy) applyToolPolicy(response *JSONRPCMessage) bool {
+	if p.toolPolicy.IsEmpty() || response.Result == nil || p.requestMethod(response.ID) != "tools/list" {
+		return false
+	}
+
+	filtered, changed := filterToolsResult(response.Result, p.toolPolicy)
+	response.Result = filtered
+	return changed
+}
+
+// requestMethod returns the method of an in-flight request, empty if it is unknown
+func (p *MCPProxy) requestMethod(id interface{}) string {
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	if request, exists := p.inflight[idKey(id)]; exists {
+		return request.method
+	}
+	return ""
+}
+
+// toolDeniedCode is the JSON-RPC error code of tool calls the user did not approve
+const toolDeniedCode = -32001
+
+// toolDeniedError is the error returned for tool calls the user did not approve
+func toolDeniedError(tool string, reason string) *JSONRPCError {
+	return &JSONRPCError{
+		Code:    toolDeniedCode,
+		Message: "Tool call denied",
+		Data:    fmt.Sprintf("call of tool %q was not approved: %s", tool, reason),
+	}
+}
+
+// askToolApproval asks the user whether a tool call may run, returning the reason if not
+func askToolApproval(ctx context.Context, server, client string, params json.RawMessage, timeout time.Duration) (bool, string) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	_ = json.Unmarshal(params, &call)
+
+	fmt.Fprintf(os.Stderr, "Waiting for approval of tool %q\n", call.Name)
+	decision, err := approval.Ask(ctx, approval.Request{
+		Server:    server,
+		Tool:      call.Name,
+		Arguments: call.Arguments,
+		Client:    client,
+	}, timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Approval of tool %q failed: %v\n", call.Name, err)
+		return false, err.Error()
+	}
+	if !decision.Approved {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by the user"
+		}
+		fmt.Fprintf(os.Stderr, "Call of tool %q was denied: %s\n", call.Name, reason)
+		return false, reason
+	}
+
+	fmt.Fprintf(os.Stderr, "Call of tool %q was approved\n", call.Name)
+	return true, ""
+}
+
+// approveToolCalls asks the user about every tools/call request that needs approval. Denied
+// requests are answered with an error and left out of the returned messages.
+func (p *MCPProxy) approveToolCalls(ctx context.Context, messages []JSONRPCMessage, batch bool) []JSONRPCMessage {
+	if len(p.toolPolicy.RequireApproval) == 0 {
+		return messages
+	}
+
+	// Waiting for the user is not inactivity of the server
+	timer := requestTimerFrom(ctx)
+	timer.pause()
+	defer timer.resume()
+
+	approved := make([]JSONRPCMessage, 0, len(messages))
+	var denied []JSONRPCMessage
+	for _, message := range messages {
+		tool := toolCallName(message.Params)
+		if message.Method != "tools/call" || !isRequest(message) || !p.toolPolicy.NeedsApproval(tool) {
+			approved = append(approved, message)
+			continue
+		}
+
+		ok, reason := askToolApproval(ctx, p.serverName, p.clientName(), message.Params, p.approvalTimeout)
+		if ok {
+			approved = append(approved, message)
+			continue
+		}
+		response := JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      message.ID,
+			Error:   toolDeniedError(tool, reason),
+		}
+		if p.completeRequest(response) {
+			denied = append(denied, response)
+		}
+	}
+
+	if len(denied) > 0 {
+		p.sendMessages(denied, batch)
+	}
+	return approved
+}
+
+// clientName returns the name the MCP client gave in its initialize request
+func (p *MCPProxy) clientName() string {
+	p.sessionMutex.RLock()
+	defer p.sessionMutex.RUnlock()
+	return initializeClientName(p.initializeParams)
+}
+
+// initializeClientName returns the clientInfo name of initialize params
+func initializeClientName(params json.RawMessage) string {
+	var initialize struct {
+		ClientInfo struct {
+			Name string `json:"name"`
+		} `json:"clientInfo"`
+	}
+	if len(params) > 0 {
+		_ = json.Unmarshal(params, &initialize)
+	}
+	return initialize.ClientInfo.Name
+}
@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"neobelt/internal/approval"
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+)
+
+func TestProxyEnforcesToolPolicy(t *testing.T) {
+	var mutex sync.Mutex
+	var called []string
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		switch message.Method {
+		case "tools/list":
+			return `{"tools":[{"name":"issue_search"},{"name":"issue_delete"},{"name":"wiki_search"}]}`
+		case "tools/call":
+			mutex.Lock()
+			called = append(called, toolCallName(message.Params))
+			mutex.Unlock()
+			return `{"content":[]}`
+		}
+		return `{}`
+	})
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{ToolPolicy: config.ToolPolicy{
+		Allow: []string{"issue_*"},
+		Deny:  []string{"*_delete"},
+	}})
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	response := tp.receive(t, 2*time.Second)
+	var result struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(response.Result, &result); err != nil || len(result.Tools) != 1 || result.Tools[0].Name != "issue_search" {
+		t.Fatalf("expected only issue_search to be listed, got %s", response.Result)
+	}
+
+	// Denied and not allowed tools are answered by the proxy itself
+	for id, tool := range map[int]string{2: "issue_delete", 3: "wiki_search"} {
+		params, _ := json.Marshal(map[string]string{"name": tool})
+		message, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: id, Method: "tools/call", Params: params})
+		tp.send(t, string(message))
+		if response := tp.receive(t, 2*time.Second); response.ID != float64(id) || response.Error == nil || response.Error.Code != -32602 {
+			t.Fatalf("expected a tool not allowed error for %s, got %+v", tool, response)
+		}
+	}
+
+	tp.send(t, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"issue_search"}}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(4) || response.Error != nil {
+		t.Fatalf("expected the allowed call to succeed, got %+v", response)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(called) != 1 || called[0] != "issue_search" {
+		t.Errorf("expected only the allowed call to reach the server, got %v", called)
+	}
+}
+
+func TestProxyAnswersDeniedToolCalls(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	// The approver only lets searches through
+	ctx, cancel := context.WithCancel(context.Background())
+	listening := make(chan error, 1)
+	go func() {
+		listening <- approval.Listen(ctx, func(ctx context.Context, request approval.Request) approval.Decision {
+			if request.Server != "jira" {
+				return approval.Decision{Approved: false, Reason: "unexpected server " + request.Server}
+			}
+			return approval.Decision{Approved: request.Tool == "issue_search", Reason: "not now"}
+		})
+	}()
+	defer func() {
+		cancel()
+		<-listening
+	}()
+	socketPath, err := approval.SocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		_, err := os.Stat(socketPath)
+		return err == nil
+	})
+
+	var mutex sync.Mutex
+	var called []string
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		mutex.Lock()
+		called = append(called, toolCallName(message.Params))
+		mutex.Unlock()
+		return `{"content":[]}`
+	})
+
+	auditLog, err := audit.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{
+		ToolPolicy: config.ToolPolicy{RequireApproval: []string{"issue_*"}},
+		ServerName: "jira",
+		AuditLog:   auditLog,
+	})
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"issue_delete","arguments":{"id":7}}}`)
+	response := tp.receive(t, 5*time.Second)
+	if response.ID != float64(1) || response.Error == nil || response.Error.Code != -32001 {
+		t.Fatalf("expected a tool call denied error, got %+v", response)
+	}
+	if data, _ := response.Error.Data.(string); !strings.Contains(data, "not now") {
+		t.Errorf("expected the approver's reason in the error, got %+v", response.Error)
+	}
+
+	tp.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"issue_search"}}`)
+	if response := tp.receive(t, 5*time.Second); response.ID != float64(2) || response.Error != nil {
+		t.Fatalf("expected the approved call to succeed, got %+v", response)
+	}
+
+	mutex.Lock()
+	if len(called) != 1 || called[0] != "issue_search" {
+		t.Errorf("expected only the approved call to reach the server, got %v", called)
+	}
+	mutex.Unlock()
+
+	records, err := audit.Read(auditLog.Path(), audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Tool != "issue_delete" || records[0].Status != audit.StatusDenied || records[1].Status != audit.StatusSuccess {
+		t.Errorf("expected a denied and a successful call in the audit log, got %+v", records)
+	}
+}
@@ -0,0 +1,17 @@
+package mcp
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows, killProcessGroup walks the process tree instead
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of a command and every process it spawned
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
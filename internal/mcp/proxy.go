@@ -0,0 +1,1143 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+)
+
+// JSON-RPC 2.0 message structures
+type JSONRPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// MarshalJSON keeps "id": null on responses without an ID (e.g. parse errors),
+// since JSON-RPC requires the member on every response
+func (m JSONRPCMessage) MarshalJSON() ([]byte, error) {
+	type plainMessage JSONRPCMessage
+	if m.ID != nil || (m.Result == nil && m.Error == nil) {
+		return json.Marshal(plainMessage(m))
+	}
+
+	return json.Marshal(struct {
+		plainMessage
+		ID json.RawMessage `json:"id"`
+	}{plainMessage(m), json.RawMessage("null")})
+}
+
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Streamable HTTP transport headers
+const (
+	SessionIDHeader       = "Mcp-Session-Id"
+	ProtocolVersionHeader = "Mcp-Protocol-Version"
+)
+
+// DefaultMaxConcurrency is the default number of requests forwarded to the server at the same time
+const DefaultMaxConcurrency = 10
+
+// ProxyOptions holds optional settings for the MCP proxy
+type ProxyOptions struct {
+	MaxConcurrency int               // maximum number of in-flight requests, 0 uses DefaultMaxConcurrency
+	Transport      string            // TransportAuto (default), TransportStreamableHTTP or TransportSSE
+	OAuth          bool              // authorize against the server with the MCP OAuth flow
+	OAuthClientID  string            // pre-registered OAuth client ID, empty to use dynamic client registration
+	MaxMessageSize int               // maximum size of a message read from stdin in bytes, 0 for no limit
+	RecordFile     string            // append all traffic as JSON lines to this file
+	ToolPolicy     config.ToolPolicy // tools hidden from tools/list, rejected in tools/call or requiring approval
+
+	ServerName      string        // name of the server shown when asking for approval, defaults to the target URL
+	ApprovalTimeout time.Duration // how long tool calls wait for approval, 0 for approval.DefaultTimeout
+	AuditLog        *audit.Log    // tools/call requests are recorded here, nil to disable auditing
+	NoQueue         bool          // fail requests while the server is unreachable instead of waiting for it
+	Quiet           bool          // only report problems on stderr, for internal checks that run often
+
+	// Inactivity timeout per method, merged over DefaultRequestTimeouts. "*" applies to every
+	// method without its own entry, 0 disables the timeout.
+	RequestTimeouts map[string]time.Duration
+}
+
+// MCPProxy handles the stdio <-> HTTP bridging
+type MCPProxy struct {
+	targetURL       string
+	headers         map[string]string
+	httpClient      *http.Client
+	maxConcurrency  int
+	maxMessageSize  int
+	timeouts        map[string]time.Duration
+	recordFile      string
+	recorder        *Recorder
+	toolPolicy      config.ToolPolicy
+	serverName      string
+	approvalTimeout time.Duration
+	auditLog        *audit.Log
+	noQueue         bool
+	quiet           bool
+	backendWait     time.Duration // how long requests wait for an unreachable server
+	in              io.Reader
+	out             io.Writer
+
+	// Session state negotiated with the target server
+	sessionMutex     sync.RWMutex
+	transport        string
+	reinitMutex      sync.Mutex
+	sessionID        string
+	protocolVersion  string
+	initializeParams json.RawMessage
+	initialized      bool // the server answered the client's initialize
+	reinitCount      int
+
+	// Closed once an unreachable backend is back, nil while it is reachable
+	backendMutex sync.Mutex
+	backendUp    chan struct{}
+
+	// Requests from the client that have not been answered yet, keyed by JSON-RPC ID
+	inflightMutex sync.Mutex
+	inflight      map[string]*inflightRequest
+
+	// Responses to requests the proxy sends on its own, keyed by JSON-RPC ID
+	internalResponses map[string]chan JSONRPCMessage
+
+	// All stdout writes go through this channel to a single writer goroutine
+	outgoing chan []byte
+
+	// Background GET stream for server-initiated messages
+	streamClient   *http.Client
+	listenerOnce   sync.Once
+	listenerCtx    context.Context
+	listenerCancel context.CancelFunc
+	listenerWG     sync.WaitGroup
+
+	// Open connection when talking the legacy HTTP+SSE transport
+	legacyMutex sync.Mutex
+	legacy      *legacyConnection
+}
+
+// inflightRequest tracks a client request that is waiting for its response
+type inflightRequest struct {
+	method        string
+	started       time.Time
+	progressToken string          // progress token of the request, if the client asked for progress
+	params        json.RawMessage // params of tools/call requests, for the audit log
+	timer         *requestTimer   // inactivity timeout, restarted by progress notifications
+	cancel        func()          // cancels the forwarding of the request, set once it is dispatched
+	done          chan struct{}   // closed once the request has been answered or cancelled
+}
+
+// NewMCPProxy creates a proxy that bridges stdin/stdout to the given Streamable HTTP endpoint
+func NewMCPProxy(targetURL string, headersList []string, options ProxyOptions) *MCPProxy {
+	headers := make(map[string]string)
+	for _, header := range headersList {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) == 2 {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	maxConcurrency := options.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+
+	transport := options.Transport
+	if transport == "" {
+		transport = TransportAuto
+	}
+
+	serverName := options.ServerName
+	if serverName == "" {
+		serverName = targetURL
+	}
+
+	timeouts := DefaultRequestTimeouts()
+	for method, timeout := range options.RequestTimeouts {
+		timeouts[method] = timeout
+	}
+
+	// Requests are bounded by their inactivity timeout instead of a client timeout, which
+	// would cut off long-running tool calls and SSE streams
+	httpClient := &http.Client{}
+	streamClient := &http.Client{}
+	if options.OAuth {
+		// Both clients share the token cache
+		oauth := newOAuthTransport(targetURL, options.OAuthClientID)
+		httpClient = &http.Client{Transport: oauth}
+		streamClient = &http.Client{Transport: oauth}
+	}
+
+	return &MCPProxy{
+		targetURL:         targetURL,
+		headers:           headers,
+		httpClient:        httpClient,
+		streamClient:      streamClient,
+		maxConcurrency:    maxConcurrency,
+		maxMessageSize:    options.MaxMessageSize,
+		timeouts:          timeouts,
+		recordFile:        options.RecordFile,
+		toolPolicy:        options.ToolPolicy,
+		serverName:        serverName,
+		approvalTimeout:   options.ApprovalTimeout,
+		auditLog:          options.AuditLog,
+		noQueue:           options.NoQueue,
+		quiet:             options.Quiet,
+		backendWait:       DefaultBackendWait,
+		transport:         transport,
+		in:                os.Stdin,
+		out:               os.Stdout,
+		inflight:          make(map[string]*inflightRequest),
+		internalResponses: make(map[string]chan JSONRPCMessage),
+	}
+}
+
+// Start the MCP proxy server
+func (p *MCPProxy) Start(ctx context.Context) error {
+	headers, err := resolveHeaders(p.headers)
+	if err != nil {
+		return err
+	}
+	p.headers = headers
+
+	if p.recordFile != "" {
+		recorder, err := NewRecorder(p.recordFile)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		p.recorder = recorder
+		p.logInfo("Recording traffic to %s\n", p.recordFile)
+	}
+
+	p.outgoing = make(chan []byte, 64)
+	writerDone := make(chan struct{})
+	go p.runWriter(writerDone)
+
+	p.listenerCtx, p.listenerCancel = context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, p.maxConcurrency)
+
+	// Notifications, responses and initialize are dispatched one after another in the order they
+	// were read, without holding up the reader while the server is slow or unreachable. Each of
+	// them waits for the previous one, later requests wait for the last of them.
+	queueCtx, cancelQueue := context.WithCancel(ctx)
+	defer cancelQueue()
+	lastQueued := make(chan struct{})
+	close(lastQueued)
+	enqueue := func(messages []JSONRPCMessage, batch bool) {
+		previous := lastQueued
+		done := make(chan struct{})
+		lastQueued = done
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			<-previous
+
+			p.dispatch(queueCtx, messages, batch)
+		}()
+	}
+
+	handleLine := func(line []byte) {
+		// Parse JSON-RPC message or batch from stdin
+		messages, batch, err := p.parseClientMessages(line)
+		if err != nil {
+			// Send error response to stdout
+			errorResponse := JSONRPCMessage{
+				JSONRPC: "2.0",
+				ID:      nil,
+				Error: &JSONRPCError{
+					Code:    -32700,
+					Message: "Parse error",
+					Data:    err.Error(),
+				},
+			}
+			p.sendResponse(errorResponse)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		// Stop waiting for cancelled requests right away, telling the server may take a while
+		for _, message := range messages {
+			if message.Method == "notifications/cancelled" {
+				p.cancelRequest(message.Params)
+			}
+		}
+
+		// Notifications and responses keep their order relative to later requests
+		// (e.g. notifications/initialized before tools/list)
+		if !containsRequest(messages) {
+			enqueue(messages, batch)
+			return
+		}
+
+		// Nothing else is valid before the session exists, so initialize is never run concurrently
+		if !batch && messages[0].Method == "initialize" {
+			enqueue(messages, batch)
+			return
+		}
+
+		// Requests run concurrently, limited by the semaphore
+		wg.Add(1)
+		go func(messages []JSONRPCMessage, batch bool, after <-chan struct{}) {
+			defer wg.Done()
+			<-after
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			p.dispatch(ctx, messages, batch)
+		}(messages, batch, lastQueued)
+	}
+
+	// Messages are newline-delimited but can be arbitrarily large (e.g. base64 images)
+	reader := bufio.NewReaderSize(p.in, 64*1024)
+	var readErr error
+	for {
+		line, err := readLine(reader, p.maxMessageSize)
+		if err == errMessageTooLarge {
+			p.sendResponse(JSONRPCMessage{
+				JSONRPC: "2.0",
+				Error: &JSONRPCError{
+					Code:    -32600,
+					Message: "Invalid Request",
+					Data:    fmt.Sprintf("message exceeds the maximum size of %d bytes", p.maxMessageSize),
+				},
+			})
+			continue
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			handleLine(line)
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		}
+	}
+
+	// Nobody is left to read the responses, so stop forwarding in-flight requests. Queued
+	// notifications get a moment to reach the server, but don't hold up the shutdown.
+	cancelled := p.cancelAllRequests()
+	drainTimer := time.AfterFunc(cancelNotifyTimeout, cancelQueue)
+	wg.Wait()
+	drainTimer.Stop()
+	p.notifyCancelled(cancelled, "client disconnected")
+	p.listenerCancel()
+	p.listenerWG.Wait()
+	p.closeSession()
+
+	close(p.outgoing)
+	<-writerDone
+
+	return readErr
+}
+
+// parseClientMessages decodes a line from stdin into a single message or a batch and registers
+// every request in it as in flight. Invalid batch entries and duplicate request IDs are answered
+// directly and left out of the returned messages.
+func (p *MCPProxy) parseClientMessages(line []byte) ([]JSONRPCMessage, bool, error) {
+	messages, batch, err := parseMessages(line)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if batch && len(messages) == 0 {
+		p.sendResponse(JSONRPCMessage{
+			JSONRPC: "2.0",
+			Error: &JSONRPCError{
+				Code:    -32600,
+				Message: "Invalid Request",
+				Data:    "empty batch",
+			},
+		})
+		return nil, true, nil
+	}
+
+	var accepted []JSONRPCMessage
+	var rejected []JSONRPCMessage
+	for _, message := range messages {
+		if message.JSONRPC != "2.0" {
+			rejected = append(rejected, JSONRPCMessage{
+				JSONRPC: "2.0",
+				ID:      message.ID,
+				Error: &JSONRPCError{
+					Code:    -32600,
+					Message: "Invalid Request",
+					Data:    "jsonrpc must be \"2.0\"",
+				},
+			})
+			continue
+		}
+
+		if rejection := p.checkToolPolicy(message); rejection != nil {
+			p.auditRejected(message, *rejection)
+			rejected = append(rejected, *rejection)
+			continue
+		}
+
+		if isRequest(message) && !p.trackRequest(message) {
+			rejected = append(rejected, JSONRPCMessage{
+				JSONRPC: "2.0",
+				ID:      message.ID,
+				Error: &JSONRPCError{
+					Code:    -32600,
+					Message: "Invalid Request",
+					Data:    fmt.Sprintf("request ID %s is already in flight", idKey(message.ID)),
+				},
+			})
+			continue
+		}
+
+		accepted = append(accepted, message)
+	}
+
+	if len(rejected) > 0 {
+		p.sendMessages(rejected, batch)
+	}
+
+	return accepted, batch, nil
+}
+
+// dispatch forwards a message or batch and reports forwarding failures back to the client.
+// Notifications and responses never get a reply, failures for them are only logged.
+func (p *MCPProxy) dispatch(ctx context.Context, messages []JSONRPCMessage, batch bool) {
+	// Each dispatch has its own context, cancelled once all of its requests have been cancelled
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	ctx, timer, stopTimer := withRequestTimer(ctx, p.requestTimeout(messages))
+	defer stopTimer()
+
+	cancelIfDone := func() {
+		for _, message := range messages {
+			if isRequest(message) && p.requestDone(message.ID) != nil {
+				return // other requests of the batch still want their responses
+			}
+		}
+		cancel(errRequestCancelled)
+	}
+	if !p.attachDispatch(messages, timer, cancelIfDone) {
+		return // every request was cancelled while waiting for a free slot
+	}
+
+	// Tool calls the user denied have been answered already
+	if messages = p.approveToolCalls(ctx, messages, batch); len(messages) == 0 {
+		return
+	}
+
+	// Forward messages to HTTP endpoint, responses are streamed to stdout as they arrive
+	var err error
+	if p.usesLegacySSE() {
+		err = p.forwardToLegacySSE(ctx, messages, batch)
+	} else {
+		err = p.forwardToHTTP(ctx, messages, batch)
+	}
+
+	if err != nil && context.Cause(ctx) != nil && context.Cause(ctx) != context.Canceled {
+		// Report the timeout rather than the resulting "context canceled"
+		err = context.Cause(ctx)
+	}
+	if err == errRequestCancelled {
+		return // nothing left to answer
+	}
+	if err == nil {
+		// Once the client completed initialization the server may push messages on its own
+		for _, message := range messages {
+			if message.Method == "notifications/initialized" {
+				p.startListener()
+			}
+		}
+		return
+	}
+
+	// Only answer requests that are still waiting for a response
+	var errorResponses []JSONRPCMessage
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+
+		errorResponse := JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      message.ID,
+			Error: &JSONRPCError{
+				Code:    -32603,
+				Message: "Internal error",
+				Data:    err.Error(),
+			},
+		}
+		if p.completeRequest(errorResponse) {
+			errorResponses = append(errorResponses, errorResponse)
+		}
+	}
+
+	if len(errorResponses) == 0 {
+		fmt.Fprintf(os.Stderr, "Failed to forward message: %v\n", err)
+		return
+	}
+
+	p.sendMessages(errorResponses, batch)
+}
+
+// trackRequest registers a client request as in flight, returning false if its ID is already in use
+func (p *MCPProxy) trackRequest(message JSONRPCMessage) bool {
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	key := idKey(message.ID)
+	if _, exists := p.inflight[key]; exists {
+		return false
+	}
+	p.inflight[key] = &inflightRequest{
+		method:        message.Method,
+		started:       time.Now(),
+		progressToken: progressToken(message.Params),
+		params:        toolCallParams(message),
+		done:          make(chan struct{}),
+	}
+	return true
+}
+
+// attachDispatch registers the inactivity timer and cancel function of a dispatch with its
+// requests. It returns false if the dispatch has requests but all of them were already cancelled.
+func (p *MCPProxy) attachDispatch(messages []JSONRPCMessage, timer *requestTimer, cancel func()) bool {
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	hasRequests := false
+	attached := false
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+		hasRequests = true
+
+		if request, exists := p.inflight[idKey(message.ID)]; exists {
+			request.timer = timer
+			request.cancel = cancel
+			attached = true
+		}
+	}
+	return attached || !hasRequests
+}
+
+// touchProgress restarts the timer of the request a progress notification belongs to and
+// reports whether the notification belongs to a request that is still in flight
+func (p *MCPProxy) touchProgress(message JSONRPCMessage) bool {
+	token := progressToken(message.Params)
+
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	known := false
+	for _, request := range p.inflight {
+		if token != "" && request.progressToken == token {
+			request.timer.touch()
+			known = true
+		}
+	}
+	return known
+}
+
+// completeRequest removes the request a response answers from the in-flight table and reports
+// whether it was still pending
+func (p *MCPProxy) completeRequest(response JSONRPCMessage) bool {
+	if response.ID == nil {
+		return false
+	}
+
+	p.inflightMutex.Lock()
+	key := idKey(response.ID)
+	request, exists := p.inflight[key]
+	if exists {
+		delete(p.inflight, key)
+		close(request.done)
+	}
+	p.inflightMutex.Unlock()
+
+	if exists {
+		p.auditResponse(request, response)
+	}
+	return exists
+}
+
+// requestDone returns a channel that is closed once the request is answered, nil if it is not in flight
+func (p *MCPProxy) requestDone(id interface{}) <-chan struct{} {
+	p.inflightMutex.Lock()
+	defer p.inflightMutex.Unlock()
+
+	if request, exists := p.inflight[idKey(id)]; exists {
+		return request.done
+	}
+	return nil
+}
+
+// Forward a JSON-RPC message or batch to the HTTP endpoint and write every message the server
+// sends back (including progress notifications and server requests) to stdout
+func (p *MCPProxy) forwardToHTTP(ctx context.Context, messages []JSONRPCMessage, batch bool) error {
+	// initialize must not be part of a batch, so only single messages can start a session
+	initialize := !batch && messages[0].Method == "initialize"
+
+	// A new initialize always starts a new session
+	if initialize {
+		p.sessionMutex.Lock()
+		p.initializeParams = messages[0].Params
+		p.initialized = false
+		p.sessionID = ""
+		p.sessionMutex.Unlock()
+	}
+
+	var payload interface{} = messages[0]
+	if batch {
+		payload = messages
+	}
+
+	idempotent := isIdempotent(messages)
+	resp, sentSessionID, err := p.postWithRetry(ctx, payload, idempotent)
+	if err != nil {
+		return err
+	}
+
+	// A 404 on a request carrying a session ID means the server has dropped the session
+	if resp.StatusCode == http.StatusNotFound && sentSessionID != "" && !initialize {
+		resp.Body.Close()
+		fmt.Fprintf(os.Stderr, "Session %s expired, re-initializing\n", sentSessionID)
+		if err := p.reinitialize(ctx, sentSessionID); err != nil {
+			return fmt.Errorf("failed to re-initialize expired session: %w", err)
+		}
+
+		resp, _, err = p.postWithRetry(ctx, payload, idempotent)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	// Servers that only speak the older HTTP+SSE transport reject the initialize POST with a 4xx
+	if initialize && p.transportMode() == TransportAuto && shouldFallBackToLegacySSE(resp.StatusCode) {
+		fmt.Fprintf(os.Stderr, "Streamable HTTP initialize failed with HTTP %d, falling back to HTTP+SSE transport\n", resp.StatusCode)
+		p.switchToLegacySSE()
+		return p.forwardToLegacySSE(ctx, messages, batch)
+	}
+
+	// Notifications and responses are acknowledged with 202 Accepted and no body
+	pending := make(map[string]bool)
+	for _, message := range messages {
+		if isRequest(message) {
+			pending[idKey(message.ID)] = true
+		}
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		if len(pending) > 0 {
+			return fmt.Errorf("server accepted the request without returning a response")
+		}
+		return nil
+	}
+
+	// Check HTTP status
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "HTTP error %d: %s\n", resp.StatusCode, string(body))
+		return fmt.Errorf("HTTP error: %d %s", resp.StatusCode, string(body))
+	}
+
+	timer := requestTimerFrom(ctx)
+	err = p.readMessages(resp, func(raw json.RawMessage, received []JSONRPCMessage, receivedBatch bool) {
+		// Anything on the request's own stream shows the server is still working on it
+		timer.touch()
+		p.routeServerMessages(raw, received, receivedBatch, func(response JSONRPCMessage) {
+			key := idKey(response.ID)
+			if pending[key] {
+				delete(pending, key)
+				if initialize {
+					p.rememberSession(resp, response)
+				}
+			}
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("server closed the response stream before answering the request")
+	}
+
+	return nil
+}
+
+// routeServerMessages writes messages received from the server to stdout. Responses are matched
+// to pending client requests by ID (calling onResponse first), anything unmatched is dropped.
+func (p *MCPProxy) routeServerMessages(raw json.RawMessage, received []JSONRPCMessage, batch bool, onResponse func(JSONRPCMessage)) {
+	forward := make([]JSONRPCMessage, 0, len(received))
+	modified := false
+	for _, message := range received {
+		p.recordReceived(message)
+
+		// Progress for requests that were answered or cancelled must not reach the client anymore
+		if message.Method == "notifications/progress" && !p.touchProgress(message) {
+			fmt.Fprintf(os.Stderr, "Dropping progress notification for unknown progress token %s\n", progressToken(message.Params))
+			continue
+		}
+
+		if isResponse(message) {
+			if p.takeInternalResponse(message) {
+				continue
+			}
+			if onResponse != nil {
+				onResponse(message)
+			}
+			if p.applyToolPolicy(&message) {
+				modified = true
+			}
+
+			if !p.completeRequest(message) {
+				fmt.Fprintf(os.Stderr, "Dropping response for unknown or completed request ID %s\n", idKey(message.ID))
+				continue
+			}
+		}
+		forward = append(forward, message)
+	}
+
+	if len(forward) == len(received) && !modified {
+		p.writeMessage(raw)
+		return
+	}
+	if len(forward) == 0 {
+		return
+	}
+
+	var filtered interface{} = forward
+	if !batch {
+		filtered = forward[0]
+	}
+	filteredBytes, err := json.Marshal(filtered)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling server messages: %v\n", err)
+		return
+	}
+	p.writeMessage(filteredBytes)
+}
+
+// post sends a JSON-RPC message or batch to the target URL with the current session headers.
+// The caller is responsible for closing the response body.
+func (p *MCPProxy) post(ctx context.Context, payload interface{}) (*http.Response, string, error) {
+	// Serialize message
+	messageBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", p.targetURL, bytes.NewReader(messageBytes))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	sessionID := p.setSessionHeaders(req)
+
+	// Send request
+	started := time.Now()
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		p.recordSent(messageBytes, sessionID, started, 0, err)
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	p.recordSent(messageBytes, sessionID, started, resp.StatusCode, nil)
+
+	return resp, sessionID, nil
+}
+
+// messageHandler receives every message (or batch) read from the server together with its raw JSON
+type messageHandler func(raw json.RawMessage, messages []JSONRPCMessage, batch bool)
+
+// readMessages reads JSON-RPC messages from a response body, which is either a single
+// JSON document or an SSE stream, and calls handle for each message as soon as it arrives
+func (p *MCPProxy) readMessages(resp *http.Response, handle messageHandler) error {
+	mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+
+	if mediaType != "text/event-stream" {
+		// Read response
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		// Some servers answer notifications with 200 and an empty body instead of 202
+		if len(bytes.TrimSpace(body)) == 0 {
+			return nil
+		}
+
+		messages, batch, err := parseMessages(body)
+		if err != nil {
+			// Some servers send SSE without the matching content type
+			p.logInfo("Direct JSON parse failed, trying SSE format...\n")
+			return p.readSSEMessages(bytes.NewReader(body), handle)
+		}
+		handle(body, messages, batch)
+		return nil
+	}
+
+	return p.readSSEMessages(resp.Body, handle)
+}
+
+// readSSEMessages parses an SSE stream incrementally and calls handle for every JSON-RPC message event
+func (p *MCPProxy) readSSEMessages(stream io.Reader, handle messageHandler) error {
+	reader := NewSSEReader(stream)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read SSE stream: %w", err)
+		}
+
+		// Only "message" events (the default type) carry JSON-RPC messages
+		if event.Event != "" && event.Event != "message" {
+			p.logInfo("Ignoring SSE event of type %q\n", event.Event)
+			continue
+		}
+
+		messages, batch, err := parseMessages(event.Data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON parse error from SSE data: %v\n", err)
+			continue
+		}
+		handle(event.Data, messages, batch)
+	}
+}
+
+// setCustomHeaders applies the headers given on the command line to a request
+func (p *MCPProxy) setCustomHeaders(req *http.Request) {
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// logInfo reports what the proxy is doing on stderr, unless it runs quietly
+func (p *MCPProxy) logInfo(format string, args ...interface{}) {
+	if !p.quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// setSessionHeaders applies the custom headers and the negotiated session headers to a request
+func (p *MCPProxy) setSessionHeaders(req *http.Request) string {
+	p.setCustomHeaders(req)
+
+	p.sessionMutex.RLock()
+	defer p.sessionMutex.RUnlock()
+
+	if p.sessionID != "" {
+		req.Header.Set(SessionIDHeader, p.sessionID)
+	}
+	if p.protocolVersion != "" {
+		req.Header.Set(ProtocolVersionHeader, p.protocolVersion)
+	}
+
+	return p.sessionID
+}
+
+// rememberSession stores the session ID and negotiated protocol version from an initialize response
+func (p *MCPProxy) rememberSession(resp *http.Response, response JSONRPCMessage) {
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(response.Result) > 0 {
+		_ = json.Unmarshal(response.Result, &result)
+	}
+
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+
+	p.sessionID = resp.Header.Get(SessionIDHeader)
+	p.initialized = true
+	if result.ProtocolVersion != "" {
+		p.protocolVersion = result.ProtocolVersion
+	}
+
+	if p.sessionID != "" {
+		p.logInfo("MCP session established: %s\n", p.sessionID)
+	}
+}
+
+// reinitialize transparently starts a new session by replaying the client's original
+// initialize request, followed by the initialized notification
+func (p *MCPProxy) reinitialize(ctx context.Context, expiredSessionID string) error {
+	// Concurrent requests may all see the expired session, only one of them re-initializes
+	p.reinitMutex.Lock()
+	defer p.reinitMutex.Unlock()
+
+	p.sessionMutex.Lock()
+	if p.sessionID != expiredSessionID {
+		// Another request already re-initialized the session
+		p.sessionMutex.Unlock()
+		return nil
+	}
+	if p.initializeParams == nil {
+		p.sessionMutex.Unlock()
+		return fmt.Errorf("no initialize request to replay")
+	}
+	p.sessionID = ""
+	p.reinitCount++
+	initialize := JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      fmt.Sprintf("neobelt-reinitialize-%d", p.reinitCount),
+		Method:  "initialize",
+		Params:  p.initializeParams,
+	}
+	p.sessionMutex.Unlock()
+
+	resp, _, err := p.post(ctx, initialize)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP error: %d %s", resp.StatusCode, string(body))
+	}
+
+	var initializeErr error
+	initialized := false
+	err = p.readMessages(resp, func(raw json.RawMessage, received []JSONRPCMessage, batch bool) {
+		for _, response := range received {
+			if !isResponse(response) || idKey(response.ID) != idKey(initialize.ID) {
+				continue
+			}
+			initialized = true
+			p.recordReceived(response)
+			if response.Error != nil {
+				initializeErr = fmt.Errorf("initialize failed: %s", response.Error.Message)
+				continue
+			}
+			p.rememberSession(resp, response)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if initializeErr != nil {
+		return initializeErr
+	}
+	if !initialized {
+		return fmt.Errorf("no initialize response received")
+	}
+
+	notification := JSONRPCMessage{
+		JSONRPC: "2.0",
+		Method:  "notifications/initialized",
+	}
+	notifyResp, _, err := p.post(ctx, notification)
+	if err != nil {
+		return err
+	}
+	defer notifyResp.Body.Close()
+	if notifyResp.StatusCode != http.StatusOK && notifyResp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(notifyResp.Body)
+		return fmt.Errorf("HTTP error: %d %s", notifyResp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// closeSession explicitly terminates the session on the server with a DELETE request
+func (p *MCPProxy) closeSession() {
+	p.sessionMutex.Lock()
+	sessionID := p.sessionID
+	p.sessionID = ""
+	p.sessionMutex.Unlock()
+
+	if sessionID == "" {
+		return
+	}
+
+	// The proxy context may already be done at this point, so use a fresh one
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", p.targetURL, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create session termination request: %v\n", err)
+		return
+	}
+	p.setCustomHeaders(req)
+	req.Header.Set(SessionIDHeader, sessionID)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to terminate session %s: %v\n", sessionID, err)
+		return
+	}
+	resp.Body.Close()
+
+	// 405 means the server does not allow clients to terminate sessions
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		p.logInfo("Server does not support session termination\n")
+		return
+	}
+
+	p.logInfo("MCP session %s terminated (HTTP %d)\n", sessionID, resp.StatusCode)
+}
+
+// errMessageTooLarge is returned by readLine for lines over the configured maximum size
+var errMessageTooLarge = errors.New("message too large")
+
+// readLine reads the next newline-terminated line without any size limit of its own. Lines
+// over maxSize (if positive) are skipped and reported as errMessageTooLarge.
+func readLine(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLarge {
+			line = append(line, chunk...)
+			if maxSize > 0 && len(bytes.TrimRight(line, "\r\n")) > maxSize {
+				// Keep consuming the line, but don't hold on to it
+				tooLarge = true
+				line = nil
+			}
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLarge {
+			return nil, errMessageTooLarge
+		}
+		return line, err
+	}
+}
+
+// parseMessages decodes a JSON-RPC message or a batch of messages
+func parseMessages(raw []byte) ([]JSONRPCMessage, bool, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var messages []JSONRPCMessage
+		if err := json.Unmarshal(trimmed, &messages); err != nil {
+			return nil, true, err
+		}
+		return messages, true, nil
+	}
+
+	var message JSONRPCMessage
+	if err := json.Unmarshal(trimmed, &message); err != nil {
+		return nil, false, err
+	}
+	return []JSONRPCMessage{message}, false, nil
+}
+
+// isRequest reports whether a message is a request that expects a response
+func isRequest(message JSONRPCMessage) bool {
+	return message.Method != "" && message.ID != nil
+}
+
+// isResponse reports whether a message is a response to an earlier request
+func isResponse(message JSONRPCMessage) bool {
+	return message.Method == "" && message.ID != nil
+}
+
+// containsRequest reports whether any of the messages expects a response
+func containsRequest(messages []JSONRPCMessage) bool {
+	for _, message := range messages {
+		if isRequest(message) {
+			return true
+		}
+	}
+	return false
+}
+
+// idKey returns a comparable key for a JSON-RPC ID, so that numeric and string IDs
+// decoded from different sources can be matched
+func idKey(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	key, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Sprint(id)
+	}
+	return string(key)
+}
+
+// Send JSON-RPC response to stdout
+func (p *MCPProxy) sendResponse(message JSONRPCMessage) {
+	responseBytes, err := json.Marshal(message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling response: %v\n", err)
+		return
+	}
+
+	p.recordProxyReply(responseBytes)
+	p.writeMessage(responseBytes)
+}
+
+// sendMessages writes messages generated by the proxy to stdout, as a JSON array if they answer a batch
+func (p *MCPProxy) sendMessages(messages []JSONRPCMessage, batch bool) {
+	if !batch {
+		for _, message := range messages {
+			p.sendResponse(message)
+		}
+		return
+	}
+
+	batchBytes, err := json.Marshal(messages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling batch response: %v\n", err)
+		return
+	}
+	p.recordProxyReply(batchBytes)
+	p.writeMessage(batchBytes)
+}
+
+// writeMessage queues a raw JSON-RPC message to be written to stdout as a single line
+func (p *MCPProxy) writeMessage(raw []byte) {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, raw); err != nil {
+		fmt.Fprintf(os.Stderr, "Error compacting message: %v\n", err)
+		return
+	}
+	compacted.WriteByte('\n')
+
+	p.outgoing <- compacted.Bytes()
+}
+
+// runWriter is the only goroutine writing to stdout, so concurrent responses never interleave
+func (p *MCPProxy) runWriter(done chan<- struct{}) {
+	defer close(done)
+
+	for line := range p.outgoing {
+		if _, err := p.out.Write(line); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing message to stdout: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProxy runs a proxy against a test server, with pipes in place of stdin and stdout
+type testProxy struct {
+	proxy  *MCPProxy
+	stdin  *io.PipeWriter
+	stdout *bufio.Reader
+	done   chan error
+}
+
+// startTestProxy starts a proxy for the given URL. It is stopped when the test ends, before
+// cleanups registered earlier such as closing the test server.
+func startTestProxy(t *testing.T, url string, options ProxyOptions) *testProxy {
+	t.Helper()
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	proxy := NewMCPProxy(url, nil, options)
+	proxy.in = inReader
+	proxy.out = outWriter
+
+	tp := &testProxy{
+		proxy:  proxy,
+		stdin:  inWriter,
+		stdout: bufio.NewReader(outReader),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		err := proxy.Start(context.Background())
+		outWriter.Close()
+		tp.done <- err
+	}()
+
+	t.Cleanup(func() {
+		inWriter.Close()
+		go io.Copy(io.Discard, outReader)
+		select {
+		case <-tp.done:
+		case <-time.After(10 * time.Second):
+			t.Error("proxy did not shut down")
+		}
+	})
+	return tp
+}
+
+// send writes a line to the proxy's stdin
+func (tp *testProxy) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := io.WriteString(tp.stdin, line+"\n"); err != nil {
+		t.Fatalf("failed to write to the proxy: %v", err)
+	}
+}
+
+// receiveLine reads the next line the proxy writes to stdout
+func (tp *testProxy) receiveLine(t *testing.T, timeout time.Duration) string {
+	t.Helper()
+
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := tp.stdout.ReadString('\n')
+		lines <- line
+	}()
+
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(timeout):
+		t.Fatalf("no message from the proxy within %v", timeout)
+		return ""
+	}
+}
+
+// receive reads the next message the proxy writes to stdout
+func (tp *testProxy) receive(t *testing.T, timeout time.Duration) JSONRPCMessage {
+	t.Helper()
+
+	line := tp.receiveLine(t, timeout)
+	var message JSONRPCMessage
+	if err := json.Unmarshal([]byte(line), &message); err != nil {
+		t.Fatalf("proxy wrote an invalid message %q: %v", line, err)
+	}
+	return message
+}
+
+// newTestMCPServer starts a Streamable HTTP server that answers every request with the result
+// of answer. Notifications and responses are acknowledged with 202 Accepted.
+func newTestMCPServer(t *testing.T, answer func(JSONRPCMessage) string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		messages, batch, err := parseMessages(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var responses []JSONRPCMessage
+		for _, message := range messages {
+			if isRequest(message) {
+				result := answer(message)
+				responses = append(responses, JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: json.RawMessage(result)})
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if batch {
+			json.NewEncoder(w).Encode(responses)
+		} else {
+			json.NewEncoder(w).Encode(responses[0])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyKeepsReadingWhileNotificationIsStuck(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "notifications/initialized") {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	t.Cleanup(func() { close(release) })
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	tp.send(t, `{not json`)
+
+	response := tp.receive(t, 2*time.Second)
+	if response.Error == nil || response.Error.Code != -32700 {
+		t.Fatalf("expected a parse error while the notification is pending, got %+v", response)
+	}
+}
+
+func TestProxyShutsDownWhileNotificationIsStuck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	tp.stdin.Close()
+
+	select {
+	case <-tp.done:
+		tp.done <- nil // for the cleanup
+	case <-time.After(cancelNotifyTimeout + 5*time.Second):
+		t.Fatal("proxy did not shut down after stdin was closed")
+	}
+}
+
+func TestProxyAnswersRequestsConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		if message.Method == "tools/call" {
+			<-release
+			return `{"content":[]}`
+		}
+		return `{}`
+	})
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","id":"slow","method":"tools/call","params":{"name":"slow"}}`)
+	tp.send(t, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+
+	// The ping overtakes the tool call and each response keeps the ID of its request
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(2) {
+		t.Fatalf("expected the ping response first, got %+v", response)
+	}
+	close(release)
+	if response := tp.receive(t, 2*time.Second); response.ID != "slow" || string(response.Result) != `{"content":[]}` {
+		t.Fatalf("expected the tool call response, got %+v", response)
+	}
+}
+
+func TestProxyLimitsConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		mutex.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mutex.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return `{}`
+	})
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{MaxConcurrency: 2})
+	for i := 1; i <= 6; i++ {
+		tp.send(t, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"ping"}`, i))
+	}
+
+	seen := make(map[float64]bool)
+	for i := 0; i < 6; i++ {
+		seen[tp.receive(t, 2*time.Second).ID.(float64)] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("expected a response for each request, got %v", seen)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 requests at a time, got %d", maxRunning)
+	}
+}
+
+func TestProxyRejectsDuplicateRequestIDs(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		<-release
+		return `{}`
+	})
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	t.Cleanup(func() { close(release) })
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`)
+	waitFor(t, func() bool { return tp.proxy.requestDone(1) != nil })
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) || response.Error == nil {
+		t.Fatalf("expected an error for the reused ID, got %+v", response)
+	}
+}
+
+func TestProxyNeverAnswersNotifications(t *testing.T) {
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string { return `{}` })
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+
+	// The 202 for the notification produces nothing, so the ping response comes first
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) || response.Error != nil {
+		t.Fatalf("expected only the ping response, got %+v", response)
+	}
+}
+
+func TestProxyForwardsBatches(t *testing.T) {
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		return fmt.Sprintf(`{"method":%q}`, message.Method)
+	})
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	tp.send(t, `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/roots/list_changed"},{"jsonrpc":"2.0","id":2,"method":"tools/list"}]`)
+
+	var responses []JSONRPCMessage
+	line := tp.receiveLine(t, 2*time.Second)
+	if err := json.Unmarshal([]byte(line), &responses); err != nil {
+		t.Fatalf("expected a batch response, got %q: %v", line, err)
+	}
+	if len(responses) != 2 || responses[0].ID != float64(1) || responses[1].ID != float64(2) {
+		t.Fatalf("expected responses to both requests of the batch, got %s", line)
+	}
+}
+
+func TestProxyRejectsInvalidBatchEntries(t *testing.T) {
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string { return `{}` })
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	tp.send(t, `[]`)
+	if response := tp.receive(t, 2*time.Second); response.Error == nil || response.Error.Code != -32600 {
+		t.Fatalf("expected an invalid request error for an empty batch, got %+v", response)
+	}
+
+	// Invalid entries are answered on their own, the rest of the batch still goes out
+	tp.send(t, `[{"jsonrpc":"1.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`)
+	for _, want := range []float64{1, 2} {
+		var responses []JSONRPCMessage
+		line := tp.receiveLine(t, 2*time.Second)
+		if err := json.Unmarshal([]byte(line), &responses); err != nil || len(responses) != 1 || responses[0].ID != want {
+			t.Fatalf("expected a batch with the response to request %v, got %q", want, line)
+		}
+	}
+}
+
+func TestReadLineHandlesLargeMessages(t *testing.T) {
+	large := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"data":"` + strings.Repeat("a", 1<<20) + `"}}`
+	reader := bufio.NewReaderSize(strings.NewReader(large+"\n"+`{"jsonrpc":"2.0","id":2,"method":"ping"}`+"\n"), 64*1024)
+
+	line, err := readLine(reader, 0)
+	if err != nil || strings.TrimSpace(string(line)) != large {
+		t.Fatalf("expected the whole %d byte message, got %d bytes (%v)", len(large), len(line), err)
+	}
+	if line, err := readLine(reader, 0); err != nil || !strings.Contains(string(line), `"ping"`) {
+		t.Fatalf("expected the next message, got %q (%v)", line, err)
+	}
+}
+
+func TestReadLineEnforcesMaxSize(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader(strings.Repeat("a", 100*1024)+"\n"+`{"jsonrpc":"2.0","id":2,"method":"ping"}`+"\n"), 16)
+
+	if _, err := readLine(reader, 1024); err != errMessageTooLarge {
+		t.Fatalf("expected errMessageTooLarge, got %v", err)
+	}
+
+	// The rest of the oversized line is skipped, not taken for the next message
+	if line, err := readLine(reader, 1024); err != nil || !strings.Contains(string(line), `"ping"`) {
+		t.Fatalf("expected the next message, got %q (%v)", line, err)
+	}
+}
+
+// sessionServer is a Streamable HTTP server that hands out a new session on every initialize
+// and records the session ID each request carried
+type sessionServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	sessions int
+	current  string
+	expired  bool
+	seen     []string // method and session ID of every POST
+	deleted  []string
+}
+
+func newSessionServer(t *testing.T) *sessionServer {
+	server := &sessionServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		sessionID := r.Header.Get(SessionIDHeader)
+		switch r.Method {
+		case "DELETE":
+			server.deleted = append(server.deleted, sessionID)
+			w.WriteHeader(http.StatusOK)
+			return
+		case "POST":
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var message JSONRPCMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.seen = append(server.seen, message.Method+" "+sessionID)
+
+		if message.Method == "initialize" {
+			server.sessions++
+			server.current = fmt.Sprintf("session-%d", server.sessions)
+			server.expired = false
+			w.Header().Set(SessionIDHeader, server.current)
+		} else if sessionID != server.current || server.expired {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if !isRequest(message) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// expire makes the server forget the current session
+func (s *sessionServer) expire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.expired = true
+}
+
+func (s *sessionServer) requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.seen...)
+}
+
+// initializeTestSession runs the initialize handshake through the proxy
+func initializeTestSession(t *testing.T, tp *testProxy, server *sessionServer) {
+	t.Helper()
+	tp.send(t, `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != "init" || response.Error != nil {
+		t.Fatalf("expected the initialize response, got %+v", response)
+	}
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	waitFor(t, func() bool { return len(server.requests()) == 2 })
+}
+
+func TestProxyEchoesSessionID(t *testing.T) {
+	server := newSessionServer(t)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	initializeTestSession(t, tp, server)
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) || response.Error != nil {
+		t.Fatalf("expected the ping response, got %+v", response)
+	}
+
+	want := []string{"initialize ", "notifications/initialized session-1", "ping session-1"}
+	if got := server.requests(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected requests %q, got %q", want, got)
+	}
+}
+
+func TestProxyReinitializesExpiredSession(t *testing.T) {
+	server := newSessionServer(t)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	initializeTestSession(t, tp, server)
+	server.expire()
+
+	// The client never learns about the expired session, the request is answered in the new one
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) || response.Error != nil {
+		t.Fatalf("expected the ping response, got %+v", response)
+	}
+
+	want := []string{
+		"initialize ",
+		"notifications/initialized session-1",
+		"ping session-1",
+		"initialize ",
+		"notifications/initialized session-2",
+		"ping session-2",
+	}
+	if got := server.requests(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected requests %q, got %q", want, got)
+	}
+}
+
+func TestProxyDeletesSessionOnStdinClose(t *testing.T) {
+	server := newSessionServer(t)
+
+	tp := startTestProxy(t, server.URL, ProxyOptions{})
+	initializeTestSession(t, tp, server)
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	tp.receive(t, 2*time.Second)
+	tp.stdin.Close()
+
+	select {
+	case err := <-tp.done:
+		tp.done <- err // for the cleanup
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not shut down after stdin was closed")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.deleted) != 1 || server.deleted[0] != "session-1" {
+		t.Errorf("expected a DELETE for session-1, got %q", server.deleted)
+	}
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Directions of recorded traffic
+const (
+	DirectionClientToServer = "client_to_server"
+	DirectionServerToClient = "server_to_client"
+	DirectionProxyToClient  = "proxy_to_client" // replies generated by the proxy itself, e.g. errors
+)
+
+// RecordEntry is a single line of a traffic recording
+type RecordEntry struct {
+	Time       time.Time       `json:"time"`
+	Direction  string          `json:"direction"`
+	Message    json.RawMessage `json:"message,omitempty"`
+	Method     string          `json:"method,omitempty"`      // for responses, the method of the request they answer
+	LatencyMS  float64         `json:"latency_ms,omitempty"`  // time until the HTTP response, or until the response message arrived
+	HTTPStatus int             `json:"http_status,omitempty"` // status of the HTTP request that carried the message
+	SessionID  string          `json:"session_id,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Recorder appends traffic as JSON lines to a file
+type Recorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewRecorder opens (or creates) a recording file for appending
+func NewRecorder(path string) (*Recorder, error) {
+	// Recordings contain everything the server returned, keep them private
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+
+	return &Recorder{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Record writes an entry, a nil recorder records nothing
+func (r *Recorder) Record(entry RecordEntry) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.encoder.Encode(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write recording: %v\n", err)
+	}
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.file.Close()
+}
+
+// recordSent records a message or batch sent to the server along with the HTTP outcome
+func (p *MCPProxy) recordSent(payload []byte, sessionID string, started time.Time, statusCode int, err error) {
+	if p.recorder == nil {
+		return
+	}
+
+	entry := RecordEntry{
+		Time:       started,
+		Direction:  DirectionClientToServer,
+		Message:    payload,
+		LatencyMS:  milliseconds(time.Since(started)),
+		HTTPStatus: statusCode,
+		SessionID:  sessionID,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	p.recorder.Record(entry)
+}
+
+// recordReceived records a message the server sent to the client. Responses get the latency
+// and method of the request they answer, so this has to run before the request is completed.
+func (p *MCPProxy) recordReceived(message JSONRPCMessage) {
+	if p.recorder == nil {
+		return
+	}
+
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	entry := RecordEntry{
+		Time:      time.Now(),
+		Direction: DirectionServerToClient,
+		Message:   raw,
+		SessionID: p.currentSessionID(),
+	}
+	if isResponse(message) {
+		p.inflightMutex.Lock()
+		if request, exists := p.inflight[idKey(message.ID)]; exists {
+			entry.Method = request.method
+			entry.LatencyMS = milliseconds(time.Since(request.started))
+		}
+		p.inflightMutex.Unlock()
+	}
+	p.recorder.Record(entry)
+}
+
+// recordProxyReply records a message or batch the proxy sends to the client on its own
+func (p *MCPProxy) recordProxyReply(raw []byte) {
+	if p.recorder == nil {
+		return
+	}
+
+	p.recorder.Record(RecordEntry{
+		Time:      time.Now(),
+		Direction: DirectionProxyToClient,
+		Message:   raw,
+	})
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
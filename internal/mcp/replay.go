@@ -0,0 +1,263 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Replayer acts as an MCP server on stdin/stdout that answers requests with the responses
+// from a recording made with --record
+type Replayer struct {
+	exchanges []*replayExchange
+	in        io.Reader
+	out       io.Writer
+}
+
+// replayExchange is a recorded request together with everything the server sent back for it
+type replayExchange struct {
+	method   string
+	params   string           // normalized params, see normalizeParams
+	progress []JSONRPCMessage // progress notifications sent for the request
+	response *JSONRPCMessage
+	used     bool
+}
+
+// NewReplayer loads a recording and pairs every recorded request with its response
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var exchanges []*replayExchange
+	pending := make(map[string]*replayExchange)
+	byToken := make(map[string]*replayExchange)
+
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := readLine(reader, 0)
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry RecordEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return nil, fmt.Errorf("invalid recording entry on line %d: %w", lineNumber, err)
+			}
+
+			messages, _, err := parseMessages(entry.Message)
+			if err != nil || len(entry.Message) == 0 {
+				continue // failed sends have no usable message
+			}
+
+			for _, message := range messages {
+				switch {
+				case entry.Direction == DirectionClientToServer && isRequest(message):
+					exchange := &replayExchange{
+						method: message.Method,
+						params: normalizeParams(message.Params),
+					}
+					exchanges = append(exchanges, exchange)
+					pending[idKey(message.ID)] = exchange
+					if token := progressToken(message.Params); token != "" {
+						byToken[token] = exchange
+					}
+
+				case entry.Direction != DirectionClientToServer && isResponse(message):
+					key := idKey(message.ID)
+					if exchange, exists := pending[key]; exists {
+						response := message
+						exchange.response = &response
+						delete(pending, key)
+					}
+
+				case entry.Direction == DirectionServerToClient && message.Method == "notifications/progress":
+					if exchange, exists := byToken[progressToken(message.Params)]; exists {
+						exchange.progress = append(exchange.progress, message)
+					}
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", readErr)
+		}
+	}
+
+	// Requests that never got an answer (e.g. resent after a session expired) can't be replayed
+	replayer := &Replayer{in: os.Stdin, out: os.Stdout}
+	for _, exchange := range exchanges {
+		if exchange.response != nil {
+			replayer.exchanges = append(replayer.exchanges, exchange)
+		}
+	}
+	if len(replayer.exchanges) == 0 {
+		return nil, fmt.Errorf("recording %s contains no answered requests", path)
+	}
+
+	return replayer, nil
+}
+
+// Start answers requests from stdin until it is closed
+func (r *Replayer) Start(ctx context.Context) error {
+	fmt.Fprintf(os.Stderr, "Replaying %d recorded exchanges\n", len(r.exchanges))
+
+	reader := bufio.NewReader(r.in)
+	for ctx.Err() == nil {
+		line, readErr := readLine(reader, 0)
+		if len(bytes.TrimSpace(line)) > 0 {
+			r.handleLine(line)
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	return ctx.Err()
+}
+
+// handleLine answers every request in a message or batch, notifications are ignored
+func (r *Replayer) handleLine(line []byte) {
+	messages, batch, err := parseMessages(line)
+	if err != nil {
+		r.write(JSONRPCMessage{
+			JSONRPC: "2.0",
+			Error: &JSONRPCError{
+				Code:    -32700,
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		})
+		return
+	}
+
+	var responses []JSONRPCMessage
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+
+		progress, response := r.answer(message)
+		for _, notification := range progress {
+			r.write(notification)
+		}
+		responses = append(responses, response)
+	}
+
+	if len(responses) == 0 {
+		return
+	}
+	if batch {
+		r.write(responses)
+		return
+	}
+	r.write(responses[0])
+}
+
+// answer picks the recorded exchange for a request: an unused one with identical params first,
+// then any unused one for the method, then the last one used for the method
+func (r *Replayer) answer(request JSONRPCMessage) ([]JSONRPCMessage, JSONRPCMessage) {
+	params := normalizeParams(request.Params)
+
+	var match, unused, used *replayExchange
+	for _, exchange := range r.exchanges {
+		if exchange.method != request.Method {
+			continue
+		}
+		switch {
+		case !exchange.used && exchange.params == params && match == nil:
+			match = exchange
+		case !exchange.used && unused == nil:
+			unused = exchange
+		case exchange.used:
+			used = exchange
+		}
+	}
+	if match == nil {
+		match = unused
+	}
+	if match == nil {
+		match = used
+	}
+
+	if match == nil {
+		fmt.Fprintf(os.Stderr, "No recorded response for %s\n", request.Method)
+		return nil, JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32603,
+				Message: "Internal error",
+				Data:    fmt.Sprintf("no recorded response for %s", request.Method),
+			},
+		}
+	}
+	match.used = true
+
+	// Progress is only replayed if the client asked for it, under the client's token
+	var progress []JSONRPCMessage
+	if token := progressTokenRaw(request.Params); token != nil && string(token) != "null" {
+		for _, notification := range match.progress {
+			var notificationParams map[string]json.RawMessage
+			if err := json.Unmarshal(notification.Params, &notificationParams); err != nil {
+				continue
+			}
+			notificationParams["progressToken"] = token
+			notification.Params, _ = json.Marshal(notificationParams)
+			progress = append(progress, notification)
+		}
+	}
+
+	response := *match.response
+	response.ID = request.ID
+	return progress, response
+}
+
+// write sends a message or batch to stdout as a single line
+func (r *Replayer) write(v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling response: %v\n", err)
+		return
+	}
+	r.out.Write(append(raw, '\n'))
+}
+
+// normalizeParams returns params in a canonical form without _meta, which carries per-call
+// values like progress tokens that never match between recording and replay
+func normalizeParams(params json.RawMessage) string {
+	var parsed interface{}
+	if len(params) == 0 || json.Unmarshal(params, &parsed) != nil {
+		return string(params)
+	}
+
+	if object, ok := parsed.(map[string]interface{}); ok {
+		delete(object, "_meta")
+	}
+
+	// Maps marshal with sorted keys, so equal params give equal strings
+	normalized, _ := json.Marshal(parsed)
+	return string(normalized)
+}
+
+// progressTokenRaw returns params._meta.progressToken as raw JSON, nil if there is none
+func progressTokenRaw(params json.RawMessage) json.RawMessage {
+	var parsed struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if len(params) == 0 || json.Unmarshal(params, &parsed) != nil {
+		return nil
+	}
+	return parsed.Meta.ProgressToken
+}
@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newProgressTestServer answers tools/call with the query argument as text. Calls that ask for
+// progress get a progress notification before the result, both on an SSE stream.
+func newProgressTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var message JSONRPCMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !isRequest(message) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var params struct {
+			Arguments struct {
+				Query string `json:"query"`
+			} `json:"arguments"`
+		}
+		json.Unmarshal(message.Params, &params)
+		result, _ := json.Marshal(map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": params.Arguments.Query}},
+		})
+		response, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: result})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if token := progressTokenRaw(message.Params); token != nil {
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{\"progressToken\":%s,\"progress\":50,\"total\":100}}\n\n", token)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// replayText returns the text content of a replayed tools/call result
+func replayText(t *testing.T, message JSONRPCMessage) string {
+	t.Helper()
+
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(message.Result, &result); err != nil || len(result.Content) != 1 {
+		t.Fatalf("unexpected replayed result %+v", message)
+	}
+	return result.Content[0].Text
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	recordFile := filepath.Join(t.TempDir(), "traffic.jsonl")
+	server := newProgressTestServer(t)
+	tp := startTestProxy(t, server.URL, ProxyOptions{RecordFile: recordFile})
+
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"query":"alpha"},"_meta":{"progressToken":"recorded-token"}}}`)
+	if notification := tp.receive(t, 2*time.Second); notification.Method != "notifications/progress" {
+		t.Fatalf("expected a progress notification, got %+v", notification)
+	}
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(1) {
+		t.Fatalf("expected the response to request 1, got %+v", response)
+	}
+	tp.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"search","arguments":{"query":"beta"}}}`)
+	if response := tp.receive(t, 2*time.Second); response.ID != float64(2) {
+		t.Fatalf("expected the response to request 2, got %+v", response)
+	}
+
+	replayer, err := NewReplayer(recordFile)
+	if err != nil {
+		t.Fatalf("failed to load the recording: %v", err)
+	}
+	if len(replayer.exchanges) != 2 {
+		t.Fatalf("expected 2 recorded exchanges, got %d", len(replayer.exchanges))
+	}
+
+	// Params select the exchange regardless of order and _meta, unmatched calls take an
+	// unused exchange and then the last one used
+	replayer.in = strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":"b","method":"tools/call","params":{"name":"search","arguments":{"query":"beta"},"_meta":{"progressToken":7}}}`,
+		`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"_meta":{"progressToken":"replay-token"},"arguments":{"query":"alpha"},"name":"search"}}`,
+		`{"jsonrpc":"2.0","id":"c","method":"tools/call","params":{"name":"search","arguments":{"query":"gamma"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"d","method":"resources/list"}`,
+	}, "\n"))
+	var out bytes.Buffer
+	replayer.out = &out
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := replayer.Start(ctx); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	var messages []JSONRPCMessage
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var message JSONRPCMessage
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("replayer wrote an invalid message %q: %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) != 5 {
+		t.Fatalf("expected 4 responses and 1 progress notification, got %d messages: %s", len(messages), out.String())
+	}
+
+	if messages[0].ID != "b" || replayText(t, messages[0]) != "beta" {
+		t.Errorf("expected the beta exchange under ID b, got %+v", messages[0])
+	}
+
+	// The recorded progress is replayed under the client's token
+	var progress struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+	}
+	json.Unmarshal(messages[1].Params, &progress)
+	if messages[1].Method != "notifications/progress" || string(progress.ProgressToken) != `"replay-token"` {
+		t.Errorf("expected progress with the replay token, got %+v", messages[1])
+	}
+	if messages[2].ID != "a" || replayText(t, messages[2]) != "alpha" {
+		t.Errorf("expected the alpha exchange under ID a, got %+v", messages[2])
+	}
+
+	// Both exchanges are used up, so the last one is answered again
+	if messages[3].ID != "c" || replayText(t, messages[3]) != "beta" {
+		t.Errorf("expected the last used exchange under ID c, got %+v", messages[3])
+	}
+	if messages[4].ID != "d" || messages[4].Error == nil || messages[4].Error.Code != -32603 {
+		t.Errorf("expected an error for a method without recording, got %+v", messages[4])
+	}
+}
+
+func TestNormalizeParamsIgnoresMetaAndKeyOrder(t *testing.T) {
+	a := normalizeParams(json.RawMessage(`{"name":"search","arguments":{"query":"x"},"_meta":{"progressToken":1}}`))
+	b := normalizeParams(json.RawMessage(`{"arguments":{"query":"x"},"name":"search"}`))
+	if a != b {
+		t.Errorf("expected equal normalized params, got %s and %s", a, b)
+	}
+	if c := normalizeParams(json.RawMessage(`{"arguments":{"query":"y"},"name":"search"}`)); c == b {
+		t.Errorf("expected different arguments to stay different, got %s", c)
+	}
+}
@@ -0,0 +1,244 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Backoff for retrying requests and probing a backend that went away
+const (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 30 * time.Second
+	retryMaxAttempts    = 5 // attempts for an idempotent request answered with 502/503
+	retryProbeTimeout   = 5 * time.Second
+)
+
+// DefaultBackendWait is how long requests wait for an unreachable server before they fail
+const DefaultBackendWait = 2 * time.Minute
+
+// idempotentMethods can safely be sent again when a gateway failed somewhere between us and the
+// server. Everything else (tools/call above all) might already have run and is never repeated.
+var idempotentMethods = map[string]bool{
+	"initialize":                       true,
+	"ping":                             true,
+	"tools/list":                       true,
+	"prompts/list":                     true,
+	"prompts/get":                      true,
+	"resources/list":                   true,
+	"resources/templates/list":         true,
+	"resources/read":                   true,
+	"resources/subscribe":              true,
+	"resources/unsubscribe":            true,
+	"completion/complete":              true,
+	"logging/setLevel":                 true,
+	"notifications/initialized":        true,
+	"notifications/roots/list_changed": true,
+}
+
+// isIdempotent reports whether every message of a dispatch may be sent more than once
+func isIdempotent(messages []JSONRPCMessage) bool {
+	for _, message := range messages {
+		if !idempotentMethods[message.Method] {
+			return false
+		}
+	}
+	return true
+}
+
+// isConnectionError reports whether a request failed before reaching the server, e.g. with
+// connection refused while its container restarts. Such a request was never delivered, so it
+// can be sent again whatever its method.
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isRetryableStatus reports whether an HTTP status means the backend is temporarily unavailable
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable
+}
+
+// postWithRetry posts like post, but rides out backend restarts: requests that could not connect
+// wait until the backend is back, idempotent requests answered with 502/503 are retried with
+// exponential backoff. The caller is responsible for closing the response body.
+func (p *MCPProxy) postWithRetry(ctx context.Context, payload interface{}, idempotent bool) (*http.Response, string, error) {
+	backoff := retryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err := p.waitForBackend(ctx); err != nil {
+			return nil, "", err
+		}
+
+		resp, sessionID, err := p.post(ctx, payload)
+		if err != nil {
+			if ctx.Err() != nil || !isConnectionError(err) || p.noQueue {
+				return nil, "", err
+			}
+			p.markBackendDown(err)
+			continue
+		}
+
+		if !idempotent || !isRetryableStatus(resp.StatusCode) || attempt >= retryMaxAttempts {
+			return resp, sessionID, nil
+		}
+
+		delay := retryDelay(resp, backoff)
+		resp.Body.Close()
+		fmt.Fprintf(os.Stderr, "Server answered with HTTP %d, retrying in %v (attempt %d of %d)\n", resp.StatusCode, delay, attempt+1, retryMaxAttempts)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, "", err
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// waitForBackend blocks while the backend is known to be unreachable, so requests queue up
+// instead of failing one after another. The wait doesn't count against the inactivity timeout
+// of the request, it is bounded by backendWait instead.
+func (p *MCPProxy) waitForBackend(ctx context.Context) error {
+	p.backendMutex.Lock()
+	up := p.backendUp
+	p.backendMutex.Unlock()
+
+	if up == nil {
+		return nil
+	}
+
+	timer := requestTimerFrom(ctx)
+	timer.pause()
+	defer timer.resume()
+
+	wait := time.NewTimer(p.backendWait)
+	defer wait.Stop()
+
+	select {
+	case <-up:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wait.C:
+		return fmt.Errorf("server is still unreachable after %v", p.backendWait)
+	}
+}
+
+// markBackendDown makes new requests wait and starts probing the backend until it is back
+func (p *MCPProxy) markBackendDown(cause error) {
+	p.backendMutex.Lock()
+	defer p.backendMutex.Unlock()
+
+	if p.backendUp != nil {
+		return // already recovering
+	}
+	fmt.Fprintf(os.Stderr, "Server is unreachable (%v), queueing requests until it is back\n", cause)
+
+	up := make(chan struct{})
+	p.backendUp = up
+	p.listenerWG.Add(1)
+	go func() {
+		defer p.listenerWG.Done()
+		p.recoverBackend(up)
+	}()
+}
+
+// recoverBackend probes the backend with exponential backoff. Once it answers again, the session
+// is restored by replaying the client's initialize request before the queued requests go out.
+func (p *MCPProxy) recoverBackend(up chan struct{}) {
+	ctx := p.listenerCtx
+	backoff := retryInitialBackoff
+	started := time.Now()
+
+	for {
+		if err := sleepContext(ctx, backoff); err != nil {
+			return
+		}
+		backoff = nextBackoff(backoff)
+
+		if err := p.probeBackend(ctx); err != nil {
+			continue
+		}
+
+		// The HTTP+SSE transport re-initializes when it reconnects its stream
+		if !p.usesLegacySSE() && p.sessionInitialized() {
+			if err := p.reinitialize(ctx, p.currentSessionID()); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to re-initialize after the server came back: %v\n", err)
+				continue
+			}
+		}
+		break
+	}
+
+	fmt.Fprintf(os.Stderr, "Server is reachable again after %v, sending queued requests\n", time.Since(started).Round(time.Millisecond))
+
+	p.backendMutex.Lock()
+	p.backendUp = nil
+	p.backendMutex.Unlock()
+	close(up)
+}
+
+// probeBackend checks whether the server accepts connections again. Any HTTP answer counts
+// except the gateway errors that mean the server behind it is still down.
+func (p *MCPProxy) probeBackend(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, retryProbeTimeout)
+	defer cancel()
+
+	// HEAD keeps servers from opening a stream, and a plain client never triggers an OAuth login
+	req, err := http.NewRequestWithContext(ctx, "HEAD", p.targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create probe request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if isRetryableStatus(resp.StatusCode) {
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sessionInitialized reports whether the client completed an initialize that can be replayed
+func (p *MCPProxy) sessionInitialized() bool {
+	p.sessionMutex.RLock()
+	defer p.sessionMutex.RUnlock()
+	return p.initialized && p.initializeParams != nil
+}
+
+// retryDelay returns the server's Retry-After hint in seconds if it sent one, otherwise backoff
+func retryDelay(resp *http.Response, backoff time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return backoff
+	}
+	delay := time.Duration(seconds) * time.Second
+	if delay > retryMaxBackoff {
+		delay = retryMaxBackoff
+	}
+	return delay
+}
+
+// nextBackoff doubles a backoff up to retryMaxBackoff
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > retryMaxBackoff {
+		backoff = retryMaxBackoff
+	}
+	return backoff
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitForBackendHasItsOwnBound(t *testing.T) {
+	proxy := NewMCPProxy("http://127.0.0.1:0", nil, ProxyOptions{})
+	proxy.backendWait = 200 * time.Millisecond
+	proxy.backendUp = make(chan struct{})
+
+	// The inactivity timeout of the request is shorter than the wait, but doesn't apply to it
+	ctx, _, stop := withRequestTimer(context.Background(), 50*time.Millisecond)
+	defer stop()
+
+	err := proxy.waitForBackend(ctx)
+	if err == nil || !strings.Contains(err.Error(), "still unreachable") {
+		t.Fatalf("expected the wait to give up on its own, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("request timed out while waiting for the server: %v", context.Cause(ctx))
+	}
+}
+
+func TestWaitForBackendReturnsOnceBackendIsUp(t *testing.T) {
+	proxy := NewMCPProxy("http://127.0.0.1:0", nil, ProxyOptions{})
+	up := make(chan struct{})
+	proxy.backendUp = up
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(up)
+	}()
+
+	if err := proxy.waitForBackend(context.Background()); err != nil {
+		t.Fatalf("expected the wait to end once the server is up, got %v", err)
+	}
+}
+
+// methodLog keeps the methods a test server was sent, in order
+type methodLog struct {
+	mutex   sync.Mutex
+	methods []string
+}
+
+func (l *methodLog) add(body []byte) {
+	messages, _, _ := parseMessages(body)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, message := range messages {
+		l.methods = append(l.methods, message.Method)
+	}
+}
+
+func (l *methodLog) get() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string{}, l.methods...)
+}
+
+// newFlakyGateway puts a gateway in front of a server that answers the first failures POSTs
+// with 503, like while the server behind it restarts. It logs the methods of every POST.
+func newFlakyGateway(t *testing.T, target *httptest.Server, failures int) (*httptest.Server, *methodLog) {
+	log := &methodLog{}
+	targetURL, _ := url.Parse(target.URL)
+	reverseProxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	var mutex sync.Mutex
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := io.ReadAll(r.Body)
+			log.add(body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			mutex.Lock()
+			fail := failures > 0
+			failures--
+			mutex.Unlock()
+			if fail {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		reverseProxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(gateway.Close)
+	return gateway, log
+}
+
+func TestProxyRetriesIdempotentRequestsOnGatewayErrors(t *testing.T) {
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		return `{"tools":[]}`
+	})
+	gateway, log := newFlakyGateway(t, server, 2)
+
+	tp := startTestProxy(t, gateway.URL, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if response := tp.receive(t, 5*time.Second); response.ID != float64(1) || response.Error != nil {
+		t.Fatalf("expected the list after the server came back, got %+v", response)
+	}
+	if methods := log.get(); strings.Join(methods, ",") != "tools/list,tools/list,tools/list" {
+		t.Errorf("expected tools/list to be sent three times, got %v", methods)
+	}
+}
+
+func TestProxyNeverRetriesToolCalls(t *testing.T) {
+	server := newTestMCPServer(t, func(message JSONRPCMessage) string {
+		return `{"content":[]}`
+	})
+	gateway, log := newFlakyGateway(t, server, 1)
+
+	tp := startTestProxy(t, gateway.URL, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"issue_create"}}`)
+	if response := tp.receive(t, 5*time.Second); response.ID != float64(1) || response.Error == nil {
+		t.Fatalf("expected the failed call to be reported, got %+v", response)
+	}
+	if methods := log.get(); len(methods) != 1 {
+		t.Errorf("expected the tool call to be sent once, got %v", methods)
+	}
+}
+
+func TestProxyReinitializesOnceBackendIsBack(t *testing.T) {
+	log := &methodLog{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		log.add(body)
+
+		var message JSONRPCMessage
+		json.Unmarshal(body, &message)
+		if !isRequest(message) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	first := httptest.NewUnstartedServer(handler)
+	first.Listener = listener
+	first.Start()
+
+	tp := startTestProxy(t, "http://"+address, ProxyOptions{})
+	tp.send(t, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"test"}}}`)
+	tp.receive(t, 2*time.Second)
+	tp.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	for deadline := time.Now().Add(2 * time.Second); len(log.get()) < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the initialized notification to reach the server")
+		}
+	}
+
+	// The server restarts, the request waits for it instead of failing
+	first.Close()
+	tp.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"issue_create"}}`)
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		tp.proxy.backendMutex.Lock()
+		down := tp.proxy.backendUp != nil
+		tp.proxy.backendMutex.Unlock()
+		if down {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the proxy to notice the server is gone")
+		}
+	}
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("failed to restart the server: %v", err)
+	}
+	second := httptest.NewUnstartedServer(handler)
+	second.Listener = listener
+	second.Start()
+	t.Cleanup(second.Close)
+
+	if response := tp.receive(t, 5*time.Second); response.ID != float64(1) || response.Error != nil {
+		t.Fatalf("expected the call to go through once the server is back, got %+v", response)
+	}
+
+	// The new server process gets the client's initialize before the queued call
+	want := "initialize,notifications/initialized,initialize,notifications/initialized,tools/call"
+	if methods := log.get(); strings.Join(methods, ",") != want {
+		t.Errorf("expected %s, got %v", want, methods)
+	}
+}
@@ -0,0 +1,770 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for serving a stdio MCP server over Streamable HTTP
+const (
+	DefaultServeSessionTimeout = 30 * time.Minute
+	serveShutdownGrace         = 5 * time.Second
+	serveBacklogSize           = 100
+)
+
+// ServeOptions holds settings for exposing a stdio MCP server over Streamable HTTP
+type ServeOptions struct {
+	Host           string        // interface to listen on, defaults to 127.0.0.1
+	Port           int           // port to listen on
+	Path           string        // MCP endpoint path, defaults to /mcp
+	SessionTimeout time.Duration // idle time after which a session's child process is stopped
+}
+
+// StdioServer spawns a stdio MCP server per session and serves it as a Streamable HTTP endpoint
+type StdioServer struct {
+	command []string
+	options ServeOptions
+
+	sessionsMutex sync.Mutex
+	sessions      map[string]*serveSession
+}
+
+// serveSession is a single MCP session backed by its own child process
+type serveSession struct {
+	id     string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	exited chan struct{} // closed when the child process has exited
+
+	writeMutex sync.Mutex // serializes writes to the child's stdin
+
+	mutex      sync.Mutex
+	pending    map[string]*serveStream // request ID -> stream waiting for the response
+	progress   map[string]*serveStream // progress token -> stream of the request that owns it
+	streams    map[*serveStream]bool   // open POST response streams
+	standalone *serveStream            // open GET stream for server-initiated messages
+	backlog    [][]byte                // messages that had no stream to go to
+	lastActive time.Time
+}
+
+// serveStream is an open HTTP response that messages from the child are delivered to
+type serveStream struct {
+	messages chan serveMessage
+	closed   chan struct{} // closed once the HTTP handler stops reading
+	sse      bool          // whether messages other than responses can be delivered
+}
+
+// newServeStream creates a stream with room for a burst of messages
+func newServeStream(sse bool) *serveStream {
+	return &serveStream{
+		messages: make(chan serveMessage, serveBacklogSize),
+		closed:   make(chan struct{}),
+		sse:      sse,
+	}
+}
+
+// deliver hands a message to the stream, applying backpressure to the child while the
+// client is slow and giving up once the handler has gone away
+func (stream *serveStream) deliver(message serveMessage) {
+	select {
+	case stream.messages <- message:
+	case <-stream.closed:
+	}
+}
+
+// serveMessage is a single message from the child on its way to an HTTP stream
+type serveMessage struct {
+	raw      []byte
+	response bool
+}
+
+// NewStdioServer creates a server that runs the given command for every MCP session
+func NewStdioServer(command []string, options ServeOptions) *StdioServer {
+	if options.Host == "" {
+		options.Host = "127.0.0.1"
+	}
+	if options.Path == "" {
+		options.Path = "/mcp"
+	}
+	if options.SessionTimeout <= 0 {
+		options.SessionTimeout = DefaultServeSessionTimeout
+	}
+
+	return &StdioServer{
+		command:  command,
+		options:  options,
+		sessions: make(map[string]*serveSession),
+	}
+}
+
+// ListenAndServe serves the MCP endpoint until the context is cancelled, then stops all child processes
+func (s *StdioServer) ListenAndServe(ctx context.Context) error {
+	if len(s.command) == 0 {
+		return fmt.Errorf("no command to serve")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.options.Path, s.handleMCP)
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(s.options.Host, fmt.Sprintf("%d", s.options.Port)),
+		Handler: mux,
+	}
+
+	reaperDone := make(chan struct{})
+	go s.reapIdleSessions(ctx, reaperDone)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "Serving %q as Streamable HTTP on http://%s%s\n", strings.Join(s.command, " "), server.Addr, s.options.Path)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownGrace)
+		defer cancel()
+		// Open SSE streams would keep Shutdown waiting, so stop the children first
+		s.closeAllSessions()
+		err = server.Shutdown(shutdownCtx)
+	}
+	<-reaperDone
+
+	s.closeAllSessions()
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// handleMCP implements the Streamable HTTP transport on the MCP endpoint
+func (s *StdioServer) handleMCP(w http.ResponseWriter, r *http.Request) {
+	// Validate Origin to protect local servers against DNS rebinding
+	if !isAllowedOrigin(r.Header.Get("Origin")) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost forwards client messages to the child process and streams back its replies
+func (s *StdioServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	messages, batch, err := parseMessages(body)
+	if err != nil || len(messages) == 0 {
+		writeJSONRPCError(w, http.StatusBadRequest, -32700, "Parse error")
+		return
+	}
+
+	initialize := !batch && messages[0].Method == "initialize"
+	var session *serveSession
+	if initialize {
+		session, err = s.startSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start MCP server process: %v\n", err)
+			writeJSONRPCError(w, http.StatusInternalServerError, -32603, "Failed to start MCP server process")
+			return
+		}
+		w.Header().Set(SessionIDHeader, session.id)
+	} else {
+		var status int
+		session, status = s.lookupSession(r)
+		if session == nil {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
+	// Notifications and responses only need to reach the child
+	if !containsRequest(messages) {
+		if err := session.write(body); err != nil {
+			http.Error(w, "MCP server process is not running", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	sse := acceptsEventStream(r)
+	stream := session.openStream(messages, sse)
+	defer session.closeStream(stream)
+
+	if err := session.write(body); err != nil {
+		http.Error(w, "MCP server process is not running", http.StatusNotFound)
+		return
+	}
+
+	remaining := 0
+	for _, message := range messages {
+		if isRequest(message) {
+			remaining++
+		}
+	}
+
+	if sse {
+		s.streamResponses(w, r, session, stream, remaining)
+		return
+	}
+
+	// Plain JSON clients get their responses in one document
+	var responses []json.RawMessage
+	for remaining > 0 {
+		select {
+		case message := <-stream.messages:
+			if message.response {
+				responses = append(responses, message.raw)
+				remaining--
+			}
+		case <-session.exited:
+			http.Error(w, "MCP server process exited", http.StatusBadGateway)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+	w.Write(responses[0])
+}
+
+// streamResponses writes messages for a POST as an SSE stream until every request is answered
+func (s *StdioServer) streamResponses(w http.ResponseWriter, r *http.Request, session *serveSession, stream *serveStream, remaining int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for remaining > 0 {
+		select {
+		case message := <-stream.messages:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", message.raw)
+			flusher.Flush()
+			if message.response {
+				remaining--
+			}
+		case <-session.exited:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleGet opens the standalone SSE stream for server-initiated messages
+func (s *StdioServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		http.Error(w, "text/event-stream must be accepted", http.StatusNotAcceptable)
+		return
+	}
+
+	session, status := s.lookupSession(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	stream, backlog := session.openStandalone()
+	if stream == nil {
+		http.Error(w, "a stream is already open for this session", http.StatusConflict)
+		return
+	}
+	defer session.closeStandalone(stream)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, raw := range backlog {
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", raw)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case message := <-stream.messages:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", message.raw)
+			flusher.Flush()
+		case <-session.exited:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleDelete terminates a session and its child process
+func (s *StdioServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, status := s.lookupSession(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	s.closeSession(session)
+	w.WriteHeader(http.StatusOK)
+}
+
+// lookupSession finds the session referenced by the request, returning the HTTP status to use if there is none
+func (s *StdioServer) lookupSession(r *http.Request) (*serveSession, int) {
+	sessionID := r.Header.Get(SessionIDHeader)
+	if sessionID == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, http.StatusNotFound
+	}
+
+	session.mutex.Lock()
+	session.lastActive = time.Now()
+	session.mutex.Unlock()
+
+	return session, http.StatusOK
+}
+
+// startSession spawns a new child process and registers it as a session
+func (s *StdioServer) startSession() (*serveSession, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	setProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", s.command[0], err)
+	}
+
+	session := &serveSession{
+		id:         sessionID,
+		cmd:        cmd,
+		stdin:      stdin,
+		exited:     make(chan struct{}),
+		pending:    make(map[string]*serveStream),
+		progress:   make(map[string]*serveStream),
+		streams:    make(map[*serveStream]bool),
+		lastActive: time.Now(),
+	}
+
+	s.sessionsMutex.Lock()
+	s.sessions[sessionID] = session
+	s.sessionsMutex.Unlock()
+
+	fmt.Fprintf(os.Stderr, "Session %s started MCP server process (pid %d)\n", sessionID, cmd.Process.Pid)
+
+	var stderrDone sync.WaitGroup
+	stderrDone.Add(1)
+	go func() {
+		defer stderrDone.Done()
+		copyPrefixed(stderr, fmt.Sprintf("[%s] ", sessionID[:8]))
+	}()
+
+	// stdout carries newline-delimited JSON-RPC messages. Wait closes the pipes, so it
+	// must not be called before both readers are done.
+	go func() {
+		session.readOutput(stdout)
+		stderrDone.Wait()
+		err := cmd.Wait()
+		close(session.exited)
+		fmt.Fprintf(os.Stderr, "Session %s MCP server process exited: %v\n", sessionID, err)
+
+		s.sessionsMutex.Lock()
+		delete(s.sessions, sessionID)
+		s.sessionsMutex.Unlock()
+	}()
+
+	return session, nil
+}
+
+// closeSession stops a session's child process, closing stdin first so it can exit cleanly.
+// Processes it spawned are killed along with it.
+func (s *StdioServer) closeSession(session *serveSession) {
+	s.sessionsMutex.Lock()
+	delete(s.sessions, session.id)
+	s.sessionsMutex.Unlock()
+
+	session.writeMutex.Lock()
+	session.stdin.Close()
+	session.writeMutex.Unlock()
+
+	select {
+	case <-session.exited:
+		// Whatever the child left behind goes with it
+		killProcessGroup(session.cmd)
+	case <-time.After(serveShutdownGrace):
+		fmt.Fprintf(os.Stderr, "Session %s MCP server process did not exit, killing it\n", session.id)
+		if err := killProcessGroup(session.cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Session %s: failed to kill MCP server process: %v\n", session.id, err)
+		}
+		<-session.exited
+	}
+}
+
+// closeAllSessions stops every child process
+func (s *StdioServer) closeAllSessions() {
+	s.sessionsMutex.Lock()
+	sessions := make([]*serveSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionsMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *serveSession) {
+			defer wg.Done()
+			s.closeSession(session)
+		}(session)
+	}
+	wg.Wait()
+}
+
+// reapIdleSessions stops child processes of sessions that have been idle for too long
+func (s *StdioServer) reapIdleSessions(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.stopIdleSessions()
+	}
+}
+
+// stopIdleSessions stops the child processes of sessions idle for longer than the session timeout
+func (s *StdioServer) stopIdleSessions() {
+	s.sessionsMutex.Lock()
+	var idle []*serveSession
+	for _, session := range s.sessions {
+		if session.isIdle(s.options.SessionTimeout) {
+			idle = append(idle, session)
+		}
+	}
+	s.sessionsMutex.Unlock()
+
+	for _, session := range idle {
+		fmt.Fprintf(os.Stderr, "Session %s idle for %s, stopping it\n", session.id, s.options.SessionTimeout)
+		s.closeSession(session)
+	}
+}
+
+// write sends a message or batch to the child's stdin as a single line
+func (ss *serveSession) write(raw []byte) error {
+	var line bytes.Buffer
+	if err := json.Compact(&line, raw); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+
+	ss.writeMutex.Lock()
+	defer ss.writeMutex.Unlock()
+
+	_, err := ss.stdin.Write(line.Bytes())
+	return err
+}
+
+// readOutput routes every message the child writes to stdout to the right HTTP stream
+func (ss *serveSession) readOutput(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			messages, _, parseErr := parseMessages(line)
+			if parseErr != nil {
+				fmt.Fprintf(os.Stderr, "Session %s: ignoring non JSON-RPC output: %s", ss.id, line)
+			}
+			for _, message := range messages {
+				ss.route(message)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// route delivers a single message from the child: responses go to the stream waiting for them,
+// progress to the stream of the request that owns the token, everything else to the GET stream.
+// The stream is delivered to outside the session lock so a slow client only holds up the child's
+// output, not other requests of the session. Only readOutput calls route, which keeps the order.
+func (ss *serveSession) route(message JSONRPCMessage) {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	if stream := ss.pickStream(message, raw); stream != nil {
+		stream.deliver(serveMessage{raw: raw, response: isResponse(message)})
+	}
+}
+
+// pickStream returns the stream a message from the child goes to, or nil if the message was
+// dropped or kept in the backlog until the client opens a GET stream
+func (ss *serveSession) pickStream(message JSONRPCMessage, raw []byte) *serveStream {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.lastActive = time.Now()
+
+	if isResponse(message) {
+		key := idKey(message.ID)
+		if stream, exists := ss.pending[key]; exists {
+			delete(ss.pending, key)
+			return stream
+		}
+		fmt.Fprintf(os.Stderr, "Session %s: dropping response for unknown request ID %s\n", ss.id, key)
+		return nil
+	}
+
+	if message.Method == "notifications/progress" {
+		if stream, exists := ss.progress[progressToken(message.Params)]; exists && stream.sse {
+			return stream
+		}
+	}
+
+	if ss.standalone != nil {
+		return ss.standalone
+	}
+	for stream := range ss.streams {
+		if stream.sse {
+			return stream
+		}
+	}
+
+	// Keep it until the client opens a GET stream
+	if len(ss.backlog) >= serveBacklogSize {
+		ss.backlog = ss.backlog[1:]
+	}
+	ss.backlog = append(ss.backlog, raw)
+	return nil
+}
+
+// openStream registers a POST response stream for the requests in messages
+func (ss *serveSession) openStream(messages []JSONRPCMessage, sse bool) *serveStream {
+	stream := newServeStream(sse)
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.streams[stream] = true
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+		ss.pending[idKey(message.ID)] = stream
+		if token := progressToken(message.Params); token != "" {
+			ss.progress[token] = stream
+		}
+	}
+	return stream
+}
+
+// closeStream unregisters a POST stream. Responses still owed to it are dropped, since
+// a disconnect is not a cancellation and the client cannot receive them anymore.
+func (ss *serveSession) closeStream(stream *serveStream) {
+	close(stream.closed)
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	delete(ss.streams, stream)
+	for key, owner := range ss.pending {
+		if owner == stream {
+			delete(ss.pending, key)
+		}
+	}
+	for token, owner := range ss.progress {
+		if owner == stream {
+			delete(ss.progress, token)
+		}
+	}
+	ss.lastActive = time.Now()
+}
+
+// openStandalone registers the GET stream, returning nil if one is already open, plus any backlog
+func (ss *serveSession) openStandalone() (*serveStream, [][]byte) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.standalone != nil {
+		return nil, nil
+	}
+	ss.standalone = newServeStream(true)
+	backlog := ss.backlog
+	ss.backlog = nil
+	return ss.standalone, backlog
+}
+
+// closeStandalone unregisters the GET stream
+func (ss *serveSession) closeStandalone(stream *serveStream) {
+	close(stream.closed)
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.standalone == stream {
+		ss.standalone = nil
+	}
+	ss.lastActive = time.Now()
+}
+
+// isIdle reports whether the session has no open streams and has not been used for the given time
+func (ss *serveSession) isIdle(timeout time.Duration) bool {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	return len(ss.streams) == 0 && ss.standalone == nil && time.Since(ss.lastActive) > timeout
+}
+
+// progressToken extracts params._meta.progressToken as a comparable key
+func progressToken(params json.RawMessage) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	var parsed struct {
+		ProgressToken interface{} `json:"progressToken"`
+		Meta          struct {
+			ProgressToken interface{} `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &parsed); err != nil {
+		return ""
+	}
+
+	// Requests carry the token in _meta, progress notifications at the top level
+	if parsed.Meta.ProgressToken != nil {
+		return idKey(parsed.Meta.ProgressToken)
+	}
+	return idKey(parsed.ProgressToken)
+}
+
+// acceptsEventStream reports whether the client accepts SSE responses
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isAllowedOrigin only lets browsers on the local machine talk to the server
+func isAllowedOrigin(origin string) bool {
+	if origin == "" {
+		return true // not a browser request
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	switch parsed.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// writeJSONRPCError writes a JSON-RPC error response that is not tied to any request
+func writeJSONRPCError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(JSONRPCMessage{
+		JSONRPC: "2.0",
+		Error: &JSONRPCError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// newSessionID generates a cryptographically random session ID
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// copyPrefixed copies the child's stderr to our stderr, prefixing every line
+func copyPrefixed(r io.Reader, prefix string) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			fmt.Fprint(os.Stderr, prefix+strings.TrimRight(line, "\n")+"\n")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
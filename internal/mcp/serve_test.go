@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteDoesNotBlockSessionOnSlowClient(t *testing.T) {
+	session := &serveSession{
+		id:       "test",
+		pending:  make(map[string]*serveStream),
+		progress: make(map[string]*serveStream),
+		streams:  make(map[*serveStream]bool),
+	}
+
+	// A client that stopped reading, its stream is full
+	stream := newServeStream(true)
+	for i := 0; i < serveBacklogSize; i++ {
+		stream.messages <- serveMessage{}
+	}
+	session.streams[stream] = true
+	session.pending[idKey(1)] = stream
+
+	routed := make(chan struct{})
+	go func() {
+		defer close(routed)
+		session.route(JSONRPCMessage{JSONRPC: "2.0", ID: 1, Result: json.RawMessage(`{}`)})
+	}()
+	defer func() {
+		close(stream.closed)
+		<-routed
+	}()
+
+	locked := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond) // let route get stuck in deliver
+		session.mutex.Lock()
+		session.mutex.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("session stayed locked while delivering to a slow client")
+	}
+}
+
+// serveTestServerEnv makes the test binary act as a stdio MCP server that answers every
+// request with its method
+const serveTestServerEnv = "NEOBELT_SERVE_TEST_SERVER"
+
+// runServeTestServer answers requests on stdin until it is closed, if the test binary was
+// started as the stdio server of a test
+func runServeTestServer() {
+	if os.Getenv(serveTestServerEnv) == "" {
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var message JSONRPCMessage
+		if json.Unmarshal(scanner.Bytes(), &message) != nil || !isRequest(message) {
+			continue
+		}
+		result, _ := json.Marshal(map[string]string{"method": message.Method})
+		response, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: message.ID, Result: result})
+		fmt.Printf("%s\n", response)
+	}
+	os.Exit(0)
+}
+
+// startServeTest serves the test binary as a stdio server over Streamable HTTP
+func startServeTest(t *testing.T, options ServeOptions) (*StdioServer, *httptest.Server) {
+	t.Setenv(serveTestServerEnv, "1")
+	server := NewStdioServer([]string{os.Args[0], "-test.run=^TestServeSessionLifecycle$"}, options)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleMCP))
+	t.Cleanup(func() {
+		server.closeAllSessions()
+		httpServer.Close()
+	})
+	return server, httpServer
+}
+
+// serveRequest sends a request to the MCP endpoint with the given session ID and Accept header
+func serveRequest(t *testing.T, method, url, sessionID, accept, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessionID != "" {
+		req.Header.Set(SessionIDHeader, sessionID)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServeSessionLifecycle(t *testing.T) {
+	runServeTestServer()
+	_, httpServer := startServeTest(t, ServeOptions{})
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`
+	const toolsList = `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+
+	// initialize starts a session and answers with plain JSON if that's all the client takes
+	resp := serveRequest(t, "POST", httpServer.URL, "", "application/json", initialize)
+	sessionID := resp.Header.Get(SessionIDHeader)
+	var response JSONRPCMessage
+	if resp.StatusCode != http.StatusOK || sessionID == "" {
+		t.Fatalf("expected a session from initialize, got HTTP %d and session %q", resp.StatusCode, sessionID)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || response.ID != float64(1) {
+		t.Fatalf("expected the initialize response, got %+v (%v)", response, err)
+	}
+
+	// Everything else needs a known session
+	if resp := serveRequest(t, "POST", httpServer.URL, "", "application/json", toolsList); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a session, got %d", resp.StatusCode)
+	}
+	if resp := serveRequest(t, "POST", httpServer.URL, "unknown", "application/json", toolsList); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+	if resp := serveRequest(t, "GET", httpServer.URL, "unknown", "text/event-stream", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for the GET stream of an unknown session, got %d", resp.StatusCode)
+	}
+
+	// Clients that take SSE get the response as a message event
+	resp = serveRequest(t, "POST", httpServer.URL, sessionID, "application/json, text/event-stream", toolsList)
+	if contentType := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || contentType != "text/event-stream" {
+		t.Fatalf("expected an SSE response, got HTTP %d with %q", resp.StatusCode, contentType)
+	}
+	event, err := NewSSEReader(resp.Body).Next()
+	if err != nil || event.Event != "message" || json.Unmarshal(event.Data, &response) != nil || response.ID != float64(2) {
+		t.Fatalf("expected the tools/list response as a message event, got %+v (%v)", event, err)
+	}
+
+	// Notifications are only accepted
+	if resp := serveRequest(t, "POST", httpServer.URL, sessionID, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	// DELETE ends the session
+	if resp := serveRequest(t, "DELETE", httpServer.URL, sessionID, "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the session to be deleted, got %d", resp.StatusCode)
+	}
+	if resp := serveRequest(t, "POST", httpServer.URL, sessionID, "application/json", toolsList); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted session, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeStopsIdleSessions(t *testing.T) {
+	server, httpServer := startServeTest(t, ServeOptions{SessionTimeout: 100 * time.Millisecond})
+
+	resp := serveRequest(t, "POST", httpServer.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	sessionID := resp.Header.Get(SessionIDHeader)
+	if sessionID == "" {
+		t.Fatalf("expected a session from initialize, got HTTP %d", resp.StatusCode)
+	}
+
+	// A session in use is kept
+	server.stopIdleSessions()
+	if resp := serveRequest(t, "POST", httpServer.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the active session to be kept, got %d", resp.StatusCode)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	server.stopIdleSessions()
+	if resp := serveRequest(t, "POST", httpServer.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 once the session timed out, got %d", resp.StatusCode)
+	}
+}
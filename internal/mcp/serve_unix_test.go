@@ -0,0 +1,57 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCloseSessionKillsSpawnedProcesses(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	server := NewStdioServer([]string{"sh", "-c", "sleep 1000 >/dev/null 2>&1 & echo $! > " + pidFile + "; exec cat"}, ServeOptions{})
+
+	session, err := server.startSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pid int
+	waitFor(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil || !strings.HasSuffix(string(data), "\n") {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	})
+
+	server.closeSession(session)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("process %d spawned by the server survived the session", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether a process exists and is not a zombie waiting to be reaped
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent represents a single dispatched Server-Sent Event
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  []byte
+}
+
+// SSEReader incrementally parses a Server-Sent Events stream
+type SSEReader struct {
+	reader      *bufio.Reader
+	lastEventID string
+	idBuffer    string // ID of the event being read, it becomes the last event ID once dispatched
+	retry       time.Duration
+}
+
+// NewSSEReader creates a new SSE reader on top of the given stream
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{reader: bufio.NewReader(r)}
+}
+
+// LastEventID returns the most recent event ID seen on the stream
+func (r *SSEReader) LastEventID() string {
+	return r.lastEventID
+}
+
+// RetryDelay returns the reconnection time requested by the server, 0 if none was sent
+func (r *SSEReader) RetryDelay() time.Duration {
+	return r.retry
+}
+
+// Next blocks until the next event with data is dispatched and returns it.
+// It returns io.EOF when the stream ends. An event cut off by the end of the stream, before its
+// terminating blank line, is discarded as the SSE specification requires.
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	event := &SSEEvent{}
+	var data bytes.Buffer
+	hasData := false
+
+	for {
+		// A last line without a newline can only belong to an event that is never terminated
+		line, err := r.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event
+		if line == "" {
+			r.lastEventID = r.idBuffer
+			if hasData {
+				event.ID = r.lastEventID
+				event.Data = data.Bytes()
+				return event, nil
+			}
+			// Nothing to dispatch, start over
+			event = &SSEEvent{}
+			continue
+		}
+
+		// Lines starting with a colon are comments (often used as keep-alives)
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			event.Event = value
+		case "id":
+			// IDs containing NULL are ignored per the SSE specification
+			if !strings.Contains(value, "\x00") {
+				r.idBuffer = value
+			}
+		case "retry":
+			if retry, convErr := strconv.Atoi(value); convErr == nil {
+				r.retry = time.Duration(retry) * time.Millisecond
+			}
+		}
+	}
+}
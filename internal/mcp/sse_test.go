@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEReader(t *testing.T) {
+	type event struct {
+		id, event, data string
+	}
+
+	tests := []struct {
+		name   string
+		stream string
+		want   []event
+		retry  time.Duration
+		lastID string
+	}{
+		{
+			name:   "single event",
+			stream: "data: {\"a\":1}\n\n",
+			want:   []event{{data: `{"a":1}`}},
+		},
+		{
+			name:   "multi-line data is joined with newlines",
+			stream: "data: first\ndata: second\ndata:third\n\n",
+			want:   []event{{data: "first\nsecond\nthird"}},
+		},
+		{
+			name:   "event type and ID",
+			stream: "event: message\nid: 7\ndata: x\n\nevent: ping\ndata: y\n\n",
+			want:   []event{{id: "7", event: "message", data: "x"}, {id: "7", event: "ping", data: "y"}},
+		},
+		{
+			name:   "ID with NULL is ignored",
+			stream: "id: 1\ndata: x\n\nid: 2\x00\ndata: y\n\n",
+			want:   []event{{id: "1", data: "x"}, {id: "1", data: "y"}},
+		},
+		{
+			name:   "comments and events without data are skipped",
+			stream: ": keep-alive\n\nevent: empty\n\n: another\ndata: x\n\n",
+			want:   []event{{data: "x"}},
+		},
+		{
+			name:   "CRLF line endings",
+			stream: "id: 3\r\ndata: a\r\ndata: b\r\n\r\n",
+			want:   []event{{id: "3", data: "a\nb"}},
+			lastID: "3",
+		},
+		{
+			name:   "retry delay",
+			stream: "retry: 1500\ndata: x\n\n",
+			want:   []event{{data: "x"}},
+			retry:  1500 * time.Millisecond,
+		},
+		{
+			name:   "event cut off by the end of the stream is discarded",
+			stream: "data: x\n\ndata: y",
+			want:   []event{{data: "x"}},
+		},
+		{
+			name:   "event without its blank line is discarded",
+			stream: "id: 1\ndata: x\n\nid: 2\ndata: y\n",
+			want:   []event{{id: "1", data: "x"}},
+			lastID: "1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewSSEReader(strings.NewReader(test.stream))
+
+			var got []event
+			for {
+				next, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got = append(got, event{id: next.ID, event: next.Event, data: string(next.Data)})
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %d events, got %+v", len(test.want), got)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("event %d: expected %+v, got %+v", i, test.want[i], got[i])
+				}
+			}
+			if test.lastID != "" && reader.LastEventID() != test.lastID {
+				t.Errorf("expected last event ID %q, got %q", test.lastID, reader.LastEventID())
+			}
+			if reader.RetryDelay() != test.retry {
+				t.Errorf("expected retry delay %v, got %v", test.retry, reader.RetryDelay())
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRequestTimeout is how long a request may go without any activity from the server
+const DefaultRequestTimeout = 30 * time.Second
+
+// DefaultRequestTimeouts returns the default inactivity timeouts per method. The "*" entry
+// applies to every method without its own entry, 0 disables the timeout.
+func DefaultRequestTimeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		"*": DefaultRequestTimeout,
+		// Tool calls may run for as long as they like, as long as the client doesn't cancel them
+		"tools/call": 0,
+	}
+}
+
+// requestTimer cancels a request after a period without any activity from the server.
+// All methods are safe to call on a nil timer, which never fires.
+type requestTimer struct {
+	mutex   sync.Mutex
+	timeout time.Duration
+	timer   *time.Timer
+	paused  int
+}
+
+// requestTimerKey is the context key under which the timer of a request is stored
+type requestTimerKey struct{}
+
+// withRequestTimer derives a context that is cancelled once the timeout passes without activity
+func withRequestTimer(ctx context.Context, timeout time.Duration) (context.Context, *requestTimer, context.CancelFunc) {
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, nil, cancel
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := &requestTimer{timeout: timeout}
+	timer.timer = time.AfterFunc(timeout, func() {
+		cancel(fmt.Errorf("request timed out after %s without activity", timeout))
+	})
+
+	ctx = context.WithValue(ctx, requestTimerKey{}, timer)
+	return ctx, timer, func() {
+		timer.stop()
+		cancel(context.Canceled)
+	}
+}
+
+// requestTimerFrom returns the timer of the request the context belongs to, if any
+func requestTimerFrom(ctx context.Context) *requestTimer {
+	timer, _ := ctx.Value(requestTimerKey{}).(*requestTimer)
+	return timer
+}
+
+// touch restarts the timeout, called whenever the server shows signs of life for the request
+func (t *requestTimer) touch() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.paused == 0 {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+// pause stops the timeout while the proxy itself is busy, e.g. waiting for the user to log in
+func (t *requestTimer) pause() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.paused++
+	t.timer.Stop()
+}
+
+// resume restarts the timeout after pause
+func (t *requestTimer) resume() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.paused--
+	if t.paused == 0 {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+// stop disarms the timer for good
+func (t *requestTimer) stop() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.paused++
+	t.timer.Stop()
+}
+
+// requestTimeout returns the inactivity timeout for a message or batch. A batch gets the
+// longest timeout of its requests, with 0 (no timeout) winning over everything.
+func (p *MCPProxy) requestTimeout(messages []JSONRPCMessage) time.Duration {
+	var timeout time.Duration
+	found := false
+	for _, message := range messages {
+		if !isRequest(message) {
+			continue
+		}
+
+		methodTimeout, exists := p.timeouts[message.Method]
+		if !exists {
+			methodTimeout = p.timeouts["*"]
+		}
+		if methodTimeout <= 0 {
+			return 0
+		}
+		if !found || methodTimeout > timeout {
+			timeout = methodTimeout
+			found = true
+		}
+	}
+
+	if !found {
+		return p.timeouts["*"]
+	}
+	return timeout
+}
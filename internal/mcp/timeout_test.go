@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimerRestartsOnActivity(t *testing.T) {
+	ctx, timer, stop := withRequestTimer(context.Background(), 100*time.Millisecond)
+	defer stop()
+
+	// Activity keeps the request alive well past its timeout
+	for i := 0; i < 6; i++ {
+		time.Sleep(40 * time.Millisecond)
+		timer.touch()
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("request timed out despite activity: %v", context.Cause(ctx))
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request did not time out without activity")
+	}
+	if cause := context.Cause(ctx); cause == nil || !strings.Contains(cause.Error(), "timed out") {
+		t.Errorf("expected a timeout as the cause, got %v", cause)
+	}
+}
+
+func TestRequestTimeoutPerMethod(t *testing.T) {
+	proxy := NewMCPProxy("http://127.0.0.1:0", nil, ProxyOptions{
+		RequestTimeouts: map[string]time.Duration{"tools/list": time.Minute},
+	})
+
+	tests := []struct {
+		messages []JSONRPCMessage
+		want     time.Duration
+	}{
+		{[]JSONRPCMessage{{ID: 1, Method: "ping"}}, DefaultRequestTimeout},
+		{[]JSONRPCMessage{{ID: 1, Method: "tools/list"}}, time.Minute},
+		{[]JSONRPCMessage{{ID: 1, Method: "tools/call"}}, 0},
+		{[]JSONRPCMessage{{ID: 1, Method: "ping"}, {ID: 2, Method: "tools/list"}}, time.Minute},
+		{[]JSONRPCMessage{{ID: 1, Method: "tools/list"}, {ID: 2, Method: "tools/call"}}, 0},
+	}
+
+	for _, test := range tests {
+		if got := proxy.requestTimeout(test.messages); got != test.want {
+			t.Errorf("requestTimeout(%v) = %v, want %v", test.messages, got, test.want)
+		}
+	}
+}
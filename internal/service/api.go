@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/crypto"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/manifest"
+	"neobelt/internal/mcp"
+)
+
+// API is what the GUI and the command line need from Neobelt. The Service implements it in the
+// same process, the daemon client over the control API of a running daemon.
+type API interface {
+	// Registries
+	FetchOfficialRegistry(ctx context.Context) ([]config.RegistryServer, error)
+	FetchCustomRegistry(ctx context.Context, url string) ([]config.RegistryServer, error)
+	GetRegistries() []config.Registry
+	AddCustomRegistry(ctx context.Context, name, url, description, authType, authUsername, authPassword, authHeader string) error
+	FetchAllRegistries(ctx context.Context) ([]config.RegistryServer, error)
+	RemoveCustomRegistry(url string) error
+	UpdateCustomRegistry(ctx context.Context, oldURL, name, newURL, description, authType, authUsername, authPassword, authHeader string) error
+
+	// Settings
+	GetConfiguration() (*config.Configuration, error)
+	GetConfigPath() string
+	GetLogDir() string
+	GetServerDefaults() (*config.ServerDefaultsConfig, error)
+	UpdateServerDefaults(ctx context.Context, serverDefaults config.ServerDefaultsConfig) (bool, error)
+	GetAppConfig() (*config.AppConfig, error)
+	UpdateAppConfig(appConfig config.AppConfig) error
+	GetRemoteAccess() (*config.RemoteAccessConfig, error)
+	UpdateRemoteAccess(remoteAccess config.RemoteAccessConfig) error
+	GetClaudeIntegration() (*config.ClaudeIntegrationConfig, error)
+	UpdateClaudeIntegration(claudeIntegration config.ClaudeIntegrationConfig) error
+	GenerateSSHKeys() (*crypto.SSHKeyPair, error)
+	GetSSHPublicKey() (string, error)
+	ListSecrets() ([]string, error)
+	SetSecret(name, value string) error
+	DeleteSecret(name string) error
+	ClearAllLogs() error
+	EncryptConfiguration(password string) ([]byte, error)
+	ImportConfiguration(encryptedData, password string) error
+	GetRecentLogMessages(count int) ([]logging.LogMessage, error)
+	GetAuditRecords(query audit.Query) ([]audit.Record, error)
+	ExportAuditRecords(w io.Writer, query audit.Query, format string) (int, error)
+
+	// Servers and containers
+	GetManagedContainers(ctx context.Context) ([]docker.ContainerInfo, error)
+	StartContainer(ctx context.Context, containerID string) error
+	StopContainer(ctx context.Context, containerID string) error
+	RestartContainer(ctx context.Context, containerID string) error
+	GetContainerLogs(ctx context.Context, containerID string, lines int) (string, error)
+	RemoveContainer(ctx context.Context, containerID string, force bool) error
+	GetInstalledServersWithVersionCheck(ctx context.Context) ([]map[string]any, error)
+	PullImage(ctx context.Context, imageName string) error
+	CreateContainer(ctx context.Context, config docker.ContainerCreateConfig) (string, error)
+	InstallServer(ctx context.Context, server config.RegistryServer) error
+	InstallManualServer(dockerImage, name, description string) (string, error)
+	GetInstalledServers() ([]config.InstalledServer, error)
+	GetConfiguredServers() ([]config.ConfiguredServer, error)
+	CreateConfiguredServer(installedServerID, containerName, containerID string, port int, environment, volumes map[string]string) error
+	CreateServer(ctx context.Context, installedServerID, containerName string, environment, volumes map[string]string, sessionContainers bool) (*config.ConfiguredServer, error)
+	UpdateToolPolicy(serverID string, policy config.ToolPolicy) error
+	InspectMCPServer(ctx context.Context, serverID string) (*mcp.ServerInspection, error)
+	CallMCPServerTool(ctx context.Context, serverID, toolName, argumentsJSON string) (*mcp.ToolCallResult, error)
+	RemoveInstalledServer(ctx context.Context, serverID string, removeImage bool) error
+	GetOrphanedContainers(ctx context.Context) ([]docker.ContainerInfo, error)
+	CleanupOrphanedContainers(ctx context.Context) error
+	CheckServerHealth(ctx context.Context, serverID string) (string, string, error)
+	CheckDockerStatus(ctx context.Context) (*docker.DockerStatus, error)
+	StartDockerDesktop() error
+
+	// Manifests
+	PlanManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error)
+	ApplyManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error)
+
+	// Claude Desktop
+	DetectClaudeConfig() (string, error)
+	TestClaudeConfig(configPath string) (map[string]interface{}, error)
+	AddMCPServerToClaude(containerName string, port int) error
+	RemoveMCPServerFromClaude(containerName string) error
+	RemoveAllNeobeltMCPServersFromClaude() error
+	CleanupClaudeConfiguration() error
+
+	// Tool approvals
+	RespondToolApproval(requestID string, approved bool) error
+}
+
+var _ API = (*Service)(nil)
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"neobelt/internal/approval"
+	"neobelt/internal/logging"
+)
+
+// handleToolApproval shows an approval request to the user and waits for the answer
+func (s *Service) handleToolApproval(ctx context.Context, request approval.Request) approval.Decision {
+	decisions := make(chan approval.Decision, 1)
+
+	s.approvalMutex.Lock()
+	s.pendingApprovals[request.ID] = decisions
+	s.approvalMutex.Unlock()
+
+	defer func() {
+		s.approvalMutex.Lock()
+		delete(s.pendingApprovals, request.ID)
+		s.approvalMutex.Unlock()
+	}()
+
+	logging.LogInfo("Tool %s of server %s needs approval", request.Tool, request.Server)
+	s.emit("tool_approval_requested", request)
+
+	select {
+	case decision := <-decisions:
+		logging.LogInfo("Tool %s of server %s approved: %t", request.Tool, request.Server, decision.Approved)
+		return decision
+	case <-ctx.Done():
+		// Timed out or the MCP client cancelled the call
+		s.emit("tool_approval_cancelled", request.ID)
+		return approval.Decision{Approved: false, Reason: "no decision"}
+	}
+}
+
+// RespondToolApproval answers a pending tool approval request
+func (s *Service) RespondToolApproval(requestID string, approved bool) error {
+	s.approvalMutex.Lock()
+	decisions, exists := s.pendingApprovals[requestID]
+	s.approvalMutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("approval request %s has expired", requestID)
+	}
+
+	decision := approval.Decision{Approved: approved}
+	if !approved {
+		decision.Reason = "denied by the user in Neobelt"
+	}
+
+	select {
+	case decisions <- decision:
+	default: // already answered
+	}
+	return nil
+}
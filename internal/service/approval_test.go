@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"neobelt/internal/approval"
+)
+
+// waitForEvents waits until the sink got the given number of events
+func waitForEvents(t *testing.T, sink *recordingSink, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sink.mutex.Lock()
+		got := len(sink.names)
+		sink.mutex.Unlock()
+		if got >= count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events, got %d", count, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestToolApprovalWaitsForResponse(t *testing.T) {
+	sink := &recordingSink{}
+	service := New(sink)
+
+	decisions := make(chan approval.Decision, 1)
+	go func() {
+		decisions <- service.handleToolApproval(context.Background(), approval.Request{ID: "request-1", Server: "jira", Tool: "issue_delete"})
+	}()
+
+	waitForEvents(t, sink, 1)
+	if request, ok := sink.events[0].(approval.Request); sink.names[0] != "tool_approval_requested" || !ok || request.ID != "request-1" {
+		t.Fatalf("expected the request to be shown to the user, got %s %+v", sink.names[0], sink.events[0])
+	}
+
+	if err := service.RespondToolApproval("request-1", false); err != nil {
+		t.Fatal(err)
+	}
+	decision := <-decisions
+	if decision.Approved || decision.Reason == "" {
+		t.Errorf("expected a denial with a reason, got %+v", decision)
+	}
+
+	if err := service.RespondToolApproval("request-1", true); err == nil {
+		t.Error("expected an answered request to be gone")
+	}
+}
+
+func TestToolApprovalCancelledByRequester(t *testing.T) {
+	sink := &recordingSink{}
+	service := New(sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	decisions := make(chan approval.Decision, 1)
+	go func() {
+		decisions <- service.handleToolApproval(ctx, approval.Request{ID: "request-1", Server: "jira", Tool: "issue_delete"})
+	}()
+
+	waitForEvents(t, sink, 1)
+	cancel()
+	if decision := <-decisions; decision.Approved {
+		t.Errorf("expected a cancelled request not to be approved, got %+v", decision)
+	}
+
+	// The frontend is told to drop the prompt
+	if payload := sink.events[len(sink.events)-1]; sink.names[len(sink.names)-1] != "tool_approval_cancelled" || payload != "request-1" {
+		t.Errorf("expected tool_approval_cancelled for request-1, got %v %v", sink.names, payload)
+	}
+	if err := service.RespondToolApproval("request-1", true); err == nil {
+		t.Error("expected a cancelled request to be gone")
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"neobelt/internal/logging"
+)
+
+// DetectClaudeConfig attempts to automatically detect the Claude Desktop configuration file
+func (s *Service) DetectClaudeConfig() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	var possiblePaths []string
+
+	// Add platform-specific paths
+	possiblePaths = append(possiblePaths,
+		filepath.Join(homeDir, "Library", "Application Support", "Claude", "claude_desktop_config.json"), // macOS
+		filepath.Join(os.Getenv("APPDATA"), "Claude", "claude_desktop_config.json"),                      // Windows
+		filepath.Join(homeDir, ".config", "Claude", "claude_desktop_config.json"),                        // Linux
+	)
+
+	// Check each possible path
+	for _, path := range possiblePaths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			logging.LogInfo("Found Claude Desktop configuration at: %s", path)
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("Claude Desktop configuration file not found")
+}
+
+// TestClaudeConfig tests if the specified Claude configuration file is valid and accessible
+func (s *Service) TestClaudeConfig(configPath string) (map[string]interface{}, error) {
+	if configPath == "" {
+		return nil, fmt.Errorf("configuration path is required")
+	}
+
+	// Check if file exists and is readable
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("configuration file does not exist: %s", configPath)
+	}
+
+	// Try to read and parse the JSON
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid JSON in configuration file: %w", err)
+	}
+
+	// Count existing MCP servers
+	existingServers := 0
+	if mcpServers, ok := config["mcpServers"]; ok {
+		if servers, ok := mcpServers.(map[string]interface{}); ok {
+			existingServers = len(servers)
+		}
+	}
+
+	return map[string]interface{}{
+		"valid":            true,
+		"existing_servers": existingServers,
+	}, nil
+}
+
+// AddMCPServerToClaude adds an MCP server entry to Claude Desktop configuration
+func (s *Service) AddMCPServerToClaude(containerName string, port int) error {
+	// Get Claude integration settings
+	claudeConfig, err := s.GetClaudeIntegration()
+	if err != nil {
+		return fmt.Errorf("failed to get Claude integration settings: %w", err)
+	}
+
+	if !claudeConfig.Enabled || claudeConfig.ConfigPath == "" {
+		return fmt.Errorf("Claude integration is not enabled or configured")
+	}
+
+	// Get the current executable path
+	executablePath := getExecutablePath()
+
+	// Read existing Claude configuration
+	var config map[string]interface{}
+	data, err := os.ReadFile(claudeConfig.ConfigPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Create new config if file doesn't exist
+			config = make(map[string]interface{})
+		} else {
+			return fmt.Errorf("failed to read Claude configuration: %w", err)
+		}
+	} else {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to parse Claude configuration: %w", err)
+		}
+	}
+
+	// Ensure mcpServers section exists
+	if _, ok := config["mcpServers"]; !ok {
+		config["mcpServers"] = make(map[string]interface{})
+	}
+
+	mcpServers, ok := config["mcpServers"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid mcpServers section in Claude configuration")
+	}
+
+	// Add the new MCP server entry
+	args := []string{
+		"--mcp-proxy",
+		// Lets the proxy apply the server's tool policy
+		"--server", containerName,
+		fmt.Sprintf("http://localhost:%d/mcp", port),
+	}
+	if port <= 0 {
+		// Stdio servers have no port, Claude Desktop talks to them through Docker
+		args = []string{"--mcp-attach", containerName}
+	}
+	mcpServers[containerName] = map[string]interface{}{
+		"command": executablePath,
+		"args":    args,
+	}
+
+	// Write back to file
+	updatedData, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal Claude configuration: %w", err)
+	}
+
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(claudeConfig.ConfigPath), 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+
+	if err := os.WriteFile(claudeConfig.ConfigPath, updatedData, 0644); err != nil {
+		return fmt.Errorf("failed to write Claude configuration: %w", err)
+	}
+
+	logging.LogInfo("Successfully added MCP server '%s' to Claude Desktop configuration", containerName)
+	return nil
+}
+
+// hasMCPServerInClaude reports whether Claude Desktop's configuration has an entry for a server
+func (s *Service) hasMCPServerInClaude(containerName string) bool {
+	claudeConfig, err := s.GetClaudeIntegration()
+	if err != nil || !claudeConfig.Enabled || claudeConfig.ConfigPath == "" {
+		return false
+	}
+
+	data, err := os.ReadFile(claudeConfig.ConfigPath)
+	if err != nil {
+		return false
+	}
+	var config struct {
+		MCPServers map[string]json.RawMessage `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return false
+	}
+	_, exists := config.MCPServers[containerName]
+	return exists
+}
+
+// RemoveMCPServerFromClaude removes an MCP server entry from Claude Desktop configuration
+func (s *Service) RemoveMCPServerFromClaude(containerName string) error {
+	// Get Claude integration settings
+	claudeConfig, err := s.GetClaudeIntegration()
+	if err != nil {
+		logging.LogWarning("Failed to get Claude integration settings for cleanup: %v", err)
+		return nil // Don't fail the main operation
+	}
+
+	if !claudeConfig.Enabled || claudeConfig.ConfigPath == "" {
+		logging.LogDebug("Claude integration not enabled or configured, skipping cleanup")
+		return nil
+	}
+
+	// Read existing Claude configuration
+	data, err := os.ReadFile(claudeConfig.ConfigPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logging.LogDebug("Claude configuration file does not exist, nothing to clean up")
+			return nil
+		}
+		logging.LogWarning("Failed to read Claude configuration for cleanup: %v", err)
+		return nil // Don't fail the main operation
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		logging.LogWarning("Failed to parse Claude configuration for cleanup: %v", err)
+		return nil // Don't fail the main operation
+	}
+
+	// Check if mcpServers section exists
+	mcpServers, ok := config["mcpServers"].(map[string]interface{})
+	if !ok {
+		logging.LogDebug("No mcpServers section found in Claude configuration")
+		return nil
+	}
+
+	// Check if our server exists in the configuration
+	if _, exists := mcpServers[containerName]; !exists {
+		logging.LogDebug("MCP server '%s' not found in Claude configuration", containerName)
+		return nil
+	}
+
+	// Remove the server entry
+	delete(mcpServers, containerName)
+	logging.LogInfo("Removed MCP server '%s' from Claude Desktop configuration", containerName)
+
+	// If mcpServers is now empty, we can keep it as an empty object
+	// This maintains the structure for future additions
+
+	// Write back to file
+	updatedData, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		logging.LogWarning("Failed to marshal Claude configuration after cleanup: %v", err)
+		return nil // Don't fail the main operation
+	}
+
+	if err := os.WriteFile(claudeConfig.ConfigPath, updatedData, 0644); err != nil {
+		logging.LogWarning("Failed to write Claude configuration after cleanup: %v", err)
+		return nil // Don't fail the main operation
+	}
+
+	logging.LogInfo("Successfully cleaned up Claude Desktop configuration for server '%s'", containerName)
+	return nil
+}
+
+// RemoveAllNeobeltMCPServersFromClaude removes all Neobelt-managed MCP servers from Claude Desktop configuration
+func (s *Service) RemoveAllNeobeltMCPServersFromClaude() error {
+	// Get Claude integration settings
+	claudeConfig, err := s.GetClaudeIntegration()
+	if err != nil {
+		logging.LogWarning("Failed to get Claude integration settings for bulk cleanup: %v", err)
+		return nil // Don't fail the operation
+	}
+
+	if claudeConfig.ConfigPath == "" {
+		logging.LogDebug("No Claude configuration path specified, skipping bulk cleanup")
+		return nil
+	}
+
+	// Read existing Claude configuration
+	data, err := os.ReadFile(claudeConfig.ConfigPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logging.LogDebug("Claude configuration file does not exist, nothing to clean up")
+			return nil
+		}
+		logging.LogWarning("Failed to read Claude configuration for bulk cleanup: %v", err)
+		return nil // Don't fail the operation
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		logging.LogWarning("Failed to parse Claude configuration for bulk cleanup: %v", err)
+		return nil // Don't fail the operation
+	}
+
+	// Check if mcpServers section exists
+	mcpServers, ok := config["mcpServers"].(map[string]interface{})
+	if !ok {
+		logging.LogDebug("No mcpServers section found in Claude configuration")
+		return nil
+	}
+
+	// Get our executable path to identify Neobelt-managed servers
+	executablePath := getExecutablePath()
+	var removedServers []string
+
+	// Find and collect all Neobelt-managed MCP servers
+	for serverName, serverConfig := range mcpServers {
+		if serverConfigMap, ok := serverConfig.(map[string]interface{}); ok {
+			if command, ok := serverConfigMap["command"].(string); ok {
+				// Check if this server is managed by our binary
+				if command == executablePath {
+					// Also check if it has the --mcp-proxy or --mcp-attach argument to be sure
+					if argsInterface, hasArgs := serverConfigMap["args"]; hasArgs {
+						if args, isArray := argsInterface.([]interface{}); isArray && len(args) > 0 {
+							if firstArg, isString := args[0].(string); isString && (firstArg == "--mcp-proxy" || firstArg == "--mcp-attach") {
+								removedServers = append(removedServers, serverName)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Remove all identified Neobelt-managed servers
+	for _, serverName := range removedServers {
+		delete(mcpServers, serverName)
+		logging.LogInfo("Removed Neobelt-managed MCP server '%s' from Claude configuration during bulk cleanup", serverName)
+	}
+
+	if len(removedServers) == 0 {
+		logging.LogInfo("No Neobelt-managed MCP servers found in Claude configuration")
+		return nil
+	}
+
+	// Write back to file
+	updatedData, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		logging.LogWarning("Failed to marshal Claude configuration after bulk cleanup: %v", err)
+		return nil // Don't fail the operation
+	}
+
+	if err := os.WriteFile(claudeConfig.ConfigPath, updatedData, 0644); err != nil {
+		logging.LogWarning("Failed to write Claude configuration after bulk cleanup: %v", err)
+		return nil // Don't fail the operation
+	}
+
+	logging.LogInfo("Successfully removed %d Neobelt-managed MCP servers from Claude Desktop configuration", len(removedServers))
+	return nil
+}
+
+// CleanupClaudeConfiguration manually removes all Neobelt-managed MCP servers from Claude Desktop configuration
+func (s *Service) CleanupClaudeConfiguration() error {
+	logging.LogInfo("Manual Claude configuration cleanup requested")
+	return s.RemoveAllNeobeltMCPServersFromClaude()
+}
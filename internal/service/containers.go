@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+)
+
+const (
+	containerWatchInitialBackoff = 2 * time.Second
+	containerWatchMaxBackoff     = time.Minute
+)
+
+// ContainerWatcher keeps the state of the managed containers in memory, updated from the Docker
+// event stream instead of inspecting every container on each refresh of the UI
+type ContainerWatcher struct {
+	service *Service
+	cancel  context.CancelFunc
+
+	mutex      sync.RWMutex
+	containers map[string]docker.ContainerInfo // by short container ID
+	synced     bool                            // the cache follows the event stream
+}
+
+// NewContainerWatcher creates a new container watcher
+func NewContainerWatcher(service *Service) *ContainerWatcher {
+	return &ContainerWatcher{
+		service:    service,
+		containers: make(map[string]docker.ContainerInfo),
+	}
+}
+
+// Start subscribes to Docker events, reconnecting whenever the stream ends
+func (cw *ContainerWatcher) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	cw.cancel = cancel
+
+	go func() {
+		backoff := containerWatchInitialBackoff
+		for ctx.Err() == nil {
+			if cw.watch(ctx) {
+				backoff = containerWatchInitialBackoff
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > containerWatchMaxBackoff {
+				backoff = containerWatchMaxBackoff
+			}
+		}
+	}()
+}
+
+// Stop stops watching Docker events
+func (cw *ContainerWatcher) Stop() {
+	if cw.cancel != nil {
+		cw.cancel()
+	}
+}
+
+// Containers returns the cached containers, or nil and false while the cache isn't in sync
+func (cw *ContainerWatcher) Containers() ([]docker.ContainerInfo, bool) {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+
+	if !cw.synced {
+		return nil, false
+	}
+
+	containers := make([]docker.ContainerInfo, 0, len(cw.containers))
+	for _, container := range cw.containers {
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].CreatedAt.After(containers[j].CreatedAt)
+	})
+	return containers, true
+}
+
+// watch follows the event stream until it ends, reporting whether it was connected
+func (cw *ContainerWatcher) watch(ctx context.Context) bool {
+	dockerService := cw.service.dockerService
+	if dockerService == nil {
+		return false
+	}
+
+	// Subscribe before loading the containers, so no change gets lost in between
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	containerEvents, streamErr := dockerService.WatchManagedContainers(watchCtx)
+
+	containers, err := dockerService.GetManagedContainers(watchCtx)
+	if err != nil {
+		logging.LogDebug("Container watcher can't load containers: %v", err)
+		return false
+	}
+
+	cw.mutex.Lock()
+	cw.containers = make(map[string]docker.ContainerInfo, len(containers))
+	for _, container := range containers {
+		cw.containers[container.ID] = container
+	}
+	cw.synced = true
+	cw.mutex.Unlock()
+	logging.LogInfo("Watching Docker events of %d managed containers", len(containers))
+
+	for event := range containerEvents {
+		cw.handleEvent(ctx, event)
+	}
+
+	cw.mutex.Lock()
+	cw.synced = false
+	cw.mutex.Unlock()
+
+	if err := <-streamErr; ctx.Err() == nil {
+		logging.LogWarning("Lost Docker event stream, reconnecting: %v", err)
+	}
+	return true
+}
+
+// handleEvent updates the cached container and reports the change
+func (cw *ContainerWatcher) handleEvent(ctx context.Context, event docker.ContainerEvent) {
+	logging.LogDebug("Container %s (%s): %s %s", event.Name, event.ContainerID, event.Action, event.Health)
+
+	var container *docker.ContainerInfo
+	switch event.Action {
+	case "destroy":
+		cw.mutex.Lock()
+		delete(cw.containers, event.ContainerID)
+		cw.mutex.Unlock()
+	case "health_status":
+		// Fires on every HEALTHCHECK run without changing what inspect returns
+		cw.mutex.RLock()
+		if cached, exists := cw.containers[event.ContainerID]; exists {
+			container = &cached
+		}
+		cw.mutex.RUnlock()
+	default:
+		// Stats are fetched when the containers are listed, not once per event
+		info, err := cw.service.dockerService.InspectContainerInfo(ctx, event.ContainerID)
+		if err != nil {
+			logging.LogWarning("Failed to refresh container %s after %s event: %v", event.ContainerID, event.Action, err)
+		} else {
+			container = info
+			cw.mutex.Lock()
+			cw.containers[info.ID] = *info
+			cw.mutex.Unlock()
+		}
+	}
+
+	if event.Action == "oom" {
+		logging.LogWarning("Container %s ran out of memory", event.Name)
+	}
+
+	cw.service.emit("container_state_changed", map[string]interface{}{
+		"event":     event,
+		"container": container,
+	})
+}
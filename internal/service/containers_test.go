@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"neobelt/internal/docker"
+)
+
+// recordingSink keeps the events emitted by a service
+type recordingSink struct {
+	mutex  sync.Mutex
+	names  []string
+	events []interface{}
+}
+
+func (rs *recordingSink) Emit(name string, data interface{}) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	rs.names = append(rs.names, name)
+	rs.events = append(rs.events, data)
+}
+
+// last returns the payload of the last event, which must have the given name
+func (rs *recordingSink) last(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if len(rs.names) == 0 || rs.names[len(rs.names)-1] != name {
+		t.Fatalf("expected a %s event, got %v", name, rs.names)
+	}
+	payload, ok := rs.events[len(rs.events)-1].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected %s payload %#v", name, rs.events[len(rs.events)-1])
+	}
+	return payload
+}
+
+func TestContainerWatcherHandlesEventsFromCache(t *testing.T) {
+	sink := &recordingSink{}
+	watcher := NewContainerWatcher(New(sink))
+
+	if _, cached := watcher.Containers(); cached {
+		t.Fatal("expected no cached containers before the watcher is in sync")
+	}
+
+	older := docker.ContainerInfo{ID: "aaaaaaaaaaaa", Name: "older", Status: "running", CreatedAt: time.Unix(100, 0)}
+	newer := docker.ContainerInfo{ID: "bbbbbbbbbbbb", Name: "newer", Status: "running", CreatedAt: time.Unix(200, 0)}
+	watcher.containers[older.ID] = older
+	watcher.containers[newer.ID] = newer
+	watcher.synced = true
+
+	containers, cached := watcher.Containers()
+	if !cached || len(containers) != 2 || containers[0].ID != newer.ID {
+		t.Fatalf("expected both containers, newest first, got %+v (cached %v)", containers, cached)
+	}
+
+	// A health_status event is answered from the cache, without asking Docker
+	event := docker.ContainerEvent{ContainerID: older.ID, Name: older.Name, Action: "health_status", Health: "unhealthy"}
+	watcher.handleEvent(context.Background(), event)
+	payload := sink.last(t, "container_state_changed")
+	if payload["event"] != event {
+		t.Errorf("expected the event in the payload, got %+v", payload["event"])
+	}
+	if container, ok := payload["container"].(*docker.ContainerInfo); !ok || container == nil || container.Name != "older" {
+		t.Errorf("expected the cached container in the payload, got %#v", payload["container"])
+	}
+
+	// A destroyed container leaves the cache and is reported without a container
+	watcher.handleEvent(context.Background(), docker.ContainerEvent{ContainerID: older.ID, Name: older.Name, Action: "destroy"})
+	payload = sink.last(t, "container_state_changed")
+	if container, _ := payload["container"].(*docker.ContainerInfo); container != nil {
+		t.Errorf("expected no container for a destroy event, got %+v", container)
+	}
+	containers, _ = watcher.Containers()
+	if len(containers) != 1 || containers[0].ID != newer.ID {
+		t.Errorf("expected only %s to be cached, got %+v", newer.ID, containers)
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/mcp"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second // when the registry entry has no interval
+	defaultHealthCheckFailures = 3                // failed checks in a row before a restart
+	healthCheckTimeout         = 10 * time.Second
+	healthCheckSlowThreshold   = 3 * time.Second  // slower answers count as degraded
+	healthCheckRestartGrace    = 30 * time.Second // time a restarted server gets to come up
+)
+
+// serverHealth is the health of one running server
+type serverHealth struct {
+	status    string
+	message   string
+	failures  int // failed checks in a row
+	nextCheck time.Time
+}
+
+// HealthMonitor probes every running MCP server and restarts servers that stay unhealthy
+type HealthMonitor struct {
+	service *Service
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	restartContainer func(ctx context.Context, containerID string) error
+
+	mutex  sync.Mutex
+	health map[string]*serverHealth // by short container ID
+}
+
+// NewHealthMonitor creates a new health monitor
+func NewHealthMonitor(service *Service) *HealthMonitor {
+	return &HealthMonitor{
+		service: service,
+		restartContainer: func(ctx context.Context, containerID string) error {
+			return service.dockerService.RestartContainer(ctx, containerID)
+		},
+		health: make(map[string]*serverHealth),
+	}
+}
+
+// Start begins checking servers whose check is due every 5 seconds, until the context ends
+func (hm *HealthMonitor) Start(ctx context.Context) {
+	hm.ctx, hm.cancel = context.WithCancel(ctx)
+	ticker := time.NewTicker(5 * time.Second)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hm.ctx.Done():
+				return
+			case <-ticker.C:
+				hm.checkServers()
+			}
+		}
+	}()
+}
+
+// Stop stops the health monitoring
+func (hm *HealthMonitor) Stop() {
+	if hm.cancel != nil {
+		hm.cancel()
+	}
+}
+
+// Health returns the health status and error message of a container, empty if it wasn't checked yet
+func (hm *HealthMonitor) Health(containerID string) (string, string) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+
+	for id, health := range hm.health {
+		if sameContainerID(id, containerID) {
+			return health.status, health.message
+		}
+	}
+	return "", ""
+}
+
+// checkServers runs the due checks of all running configured servers
+func (hm *HealthMonitor) checkServers() {
+	if hm.service.dockerService == nil || hm.service.configManager == nil {
+		return
+	}
+
+	runningIDs, err := hm.service.dockerService.GetRunningManagedContainerIDs(hm.ctx)
+	if err != nil {
+		logging.LogDebug("Skipping health checks: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	checked := make(map[string]bool)
+	for _, server := range hm.service.configManager.GetConfiguredServers() {
+		if server.Port <= 0 {
+			continue
+		}
+
+		containerID := ""
+		for _, id := range runningIDs {
+			if sameContainerID(id, server.ContainerID) {
+				containerID = id
+				break
+			}
+		}
+		if containerID == "" {
+			continue // stopped servers aren't checked
+		}
+		checked[containerID] = true
+
+		hm.mutex.Lock()
+		health, exists := hm.health[containerID]
+		if !exists {
+			health = &serverHealth{}
+			hm.health[containerID] = health
+		}
+		due := !time.Now().Before(health.nextCheck)
+		hm.mutex.Unlock()
+
+		if due {
+			wg.Add(1)
+			go func(server config.ConfiguredServer, containerID string) {
+				defer wg.Done()
+				hm.checkServer(server, containerID)
+			}(server, containerID)
+		}
+	}
+
+	// Forget the health of servers that stopped or were removed
+	hm.mutex.Lock()
+	for id := range hm.health {
+		if !checked[id] {
+			delete(hm.health, id)
+		}
+	}
+	hm.mutex.Unlock()
+
+	wg.Wait()
+}
+
+// checkServer probes a server, updates its status and restarts it after too many failures
+func (hm *HealthMonitor) checkServer(server config.ConfiguredServer, containerID string) {
+	endpoint, interval := hm.service.healthCheckConfig(server)
+
+	started := time.Now()
+	err := probeServer(hm.ctx, server.Port, endpoint)
+	elapsed := time.Since(started)
+
+	defaults := hm.service.configManager.GetConfig().ServerDefaults
+	maxFailures := defaults.HealthCheckFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultHealthCheckFailures
+	}
+
+	hm.mutex.Lock()
+	health, exists := hm.health[containerID]
+	if !exists {
+		hm.mutex.Unlock()
+		return // the server stopped while it was checked
+	}
+
+	previous := health.status
+	health.nextCheck = time.Now().Add(interval)
+	switch {
+	case err == nil && elapsed > healthCheckSlowThreshold:
+		health.failures = 0
+		health.status = docker.HealthDegraded
+		health.message = fmt.Sprintf("health check took %s", elapsed.Round(time.Millisecond))
+	case err == nil:
+		health.failures = 0
+		health.status = docker.HealthHealthy
+		health.message = ""
+	default:
+		health.failures++
+		health.message = err.Error()
+		health.status = docker.HealthDegraded
+		if health.failures >= maxFailures {
+			health.status = docker.HealthUnhealthy
+		}
+	}
+
+	restart := health.status == docker.HealthUnhealthy && defaults.RestartOnFailure
+	if restart {
+		health.failures = 0
+		health.nextCheck = time.Now().Add(interval + healthCheckRestartGrace)
+	}
+	status, message := health.status, health.message
+	hm.mutex.Unlock()
+
+	if status != previous {
+		logging.LogInfo("Server %s is %s %s", server.Name, status, message)
+		hm.service.emit("server_health_changed", map[string]interface{}{
+			"container_id": containerID,
+			"health":       status,
+			"health_error": message,
+		})
+	}
+
+	if restart {
+		logging.LogWarning("Restarting unhealthy server %s after %d failed health checks: %s", server.Name, maxFailures, message)
+		if err := hm.restartContainer(hm.ctx, containerID); err != nil {
+			logging.LogError("Failed to restart unhealthy server %s: %v", server.Name, err)
+		}
+	}
+}
+
+// CheckServerHealth probes a configured server once and returns its health status and error
+// message. Without the monitor's history a single failed check makes it unhealthy.
+func (s *Service) CheckServerHealth(ctx context.Context, serverID string) (string, string, error) {
+	if s.configManager == nil {
+		return "", "", fmt.Errorf("configuration manager not available")
+	}
+
+	server := s.configManager.FindConfiguredServer(serverID)
+	if server == nil {
+		return "", "", fmt.Errorf("configured server %s not found", serverID)
+	}
+	if server.Port <= 0 {
+		return "", "", fmt.Errorf("server %s has no port", server.ContainerName)
+	}
+
+	endpoint, _ := s.healthCheckConfig(*server)
+	started := time.Now()
+	if err := probeServer(ctx, server.Port, endpoint); err != nil {
+		return docker.HealthUnhealthy, err.Error(), nil
+	}
+	if elapsed := time.Since(started); elapsed > healthCheckSlowThreshold {
+		return docker.HealthDegraded, fmt.Sprintf("health check took %s", elapsed.Round(time.Millisecond)), nil
+	}
+	return docker.HealthHealthy, "", nil
+}
+
+// healthCheckConfig returns the health endpoint and interval of a server from its registry entry.
+// Without an endpoint the server is checked with an MCP handshake.
+func (s *Service) healthCheckConfig(server config.ConfiguredServer) (string, time.Duration) {
+	endpoint := ""
+	interval := defaultHealthCheckInterval
+
+	installed := s.findInstalledServerByID(server.InstalledServerID)
+	if installed == nil {
+		return endpoint, interval
+	}
+
+	if value, ok := installed.HealthCheck["endpoint"].(string); ok {
+		endpoint = strings.TrimSpace(value)
+	}
+	if value, ok := installed.HealthCheck["interval"].(string); ok {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			interval = parsed
+		} else {
+			logging.LogDebug("Invalid health check interval %q of %s, using %s", value, installed.Name, interval)
+		}
+	}
+	return endpoint, interval
+}
+
+// probeServer checks a server's HTTP health endpoint, or runs an MCP initialize and ping
+func probeServer(ctx context.Context, port int, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if endpoint != "" {
+		if !strings.HasPrefix(endpoint, "/") {
+			endpoint = "/" + endpoint
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://localhost:%d%s", port, endpoint), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create health check request: %w", err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return fmt.Errorf("health endpoint unreachable: %w", err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return fmt.Errorf("health endpoint returned status %d", response.StatusCode)
+		}
+		return nil
+	}
+
+	inspector, err := mcp.NewInspector(ctx, fmt.Sprintf("http://localhost:%d/mcp", port), mcp.ProxyOptions{Quiet: true})
+	if err != nil {
+		return err
+	}
+	defer inspector.Close()
+
+	return inspector.Ping(ctx)
+}
+
+// sameContainerID reports whether two container IDs, short or full, refer to the same container
+func sameContainerID(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	length := min(len(a), len(b))
+	return a[:length] == b[:length]
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+)
+
+func TestHealthMonitorTransitionsAndRestarts(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	var mutex sync.Mutex
+	healthy := true
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path != "/health" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer endpoint.Close()
+	setHealthy := func(value bool) {
+		mutex.Lock()
+		healthy = value
+		mutex.Unlock()
+	}
+
+	parsed, _ := url.Parse(endpoint.URL)
+	port, _ := strconv.Atoi(parsed.Port())
+
+	configManager, err := config.NewConfigManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.AddOrUpdateInstalledServer(config.InstalledServer{
+		ID:          "installed-1",
+		Name:        "checked",
+		HealthCheck: map[string]any{"endpoint": "health"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	server := config.ConfiguredServer{ID: "configured-1", Name: "checked", InstalledServerID: "installed-1", ContainerName: "checked", ContainerID: "container-1", Port: port}
+	defaults := &configManager.GetConfig().ServerDefaults
+	defaults.HealthCheckFailures = 2
+	defaults.RestartOnFailure = true
+
+	sink := &recordingSink{}
+	service := New(sink)
+	service.configManager = configManager
+
+	monitor := NewHealthMonitor(service)
+	monitor.ctx = context.Background()
+	var restarted []string
+	monitor.restartContainer = func(ctx context.Context, containerID string) error {
+		restarted = append(restarted, containerID)
+		return nil
+	}
+	monitor.health["container-1"] = &serverHealth{}
+
+	steps := []struct {
+		healthy    bool
+		want       string
+		restarts   int
+		wantsEvent bool
+	}{
+		{true, docker.HealthHealthy, 0, true},
+		{false, docker.HealthDegraded, 0, true},
+		{false, docker.HealthUnhealthy, 1, true}, // the second failure in a row reaches the limit
+		{false, docker.HealthDegraded, 1, true},  // the restart starts the count over
+		{false, docker.HealthUnhealthy, 2, true},
+		{true, docker.HealthHealthy, 2, true},
+		{true, docker.HealthHealthy, 2, false},
+	}
+	for i, step := range steps {
+		setHealthy(step.healthy)
+		events := len(sink.names)
+		monitor.checkServer(server, "container-1")
+
+		status, message := monitor.Health("container-1")
+		if status != step.want {
+			t.Fatalf("step %d: expected %s, got %s (%s)", i, step.want, status, message)
+		}
+		if status != docker.HealthHealthy && message == "" {
+			t.Errorf("step %d: expected an error message for %s", i, status)
+		}
+		if len(restarted) != step.restarts {
+			t.Fatalf("step %d: expected %d restarts, got %d", i, step.restarts, len(restarted))
+		}
+		if emitted := len(sink.names) > events; emitted != step.wantsEvent {
+			t.Errorf("step %d: expected a server_health_changed event %v, got %v", i, step.wantsEvent, emitted)
+		}
+	}
+
+	payload := sink.last(t, "server_health_changed")
+	if payload["container_id"] != "container-1" || payload["health"] != docker.HealthHealthy {
+		t.Errorf("unexpected server_health_changed payload %+v", payload)
+	}
+
+	// Only restarted servers get a grace period on top of the interval
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if wait := time.Until(monitor.health["container-1"].nextCheck); wait > defaultHealthCheckInterval {
+		t.Errorf("expected a healthy server to be checked within the interval, next check in %s", wait)
+	}
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/manifest"
+)
+
+// manifestStep is an action of a plan together with what carries it out
+type manifestStep struct {
+	action manifest.Action
+	apply  func(ctx context.Context) error
+}
+
+// manifestActionOrder is the order steps are applied in. Servers are removed before others are
+// created, so their names and ports are free again.
+var manifestActionOrder = []string{
+	manifest.ActionAddRegistry,
+	manifest.ActionUpdateRegistry,
+	manifest.ActionInstall,
+	manifest.ActionRemove,
+	manifest.ActionRecreate,
+	manifest.ActionCreate,
+	manifest.ActionUpdatePolicy,
+	manifest.ActionStart,
+}
+
+// PlanManifest compares a manifest with the configuration and the containers and returns the
+// changes ApplyManifest would make
+func (s *Service) PlanManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error) {
+	steps, err := s.manifestSteps(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &manifest.Plan{Actions: []manifest.Action{}}
+	for _, step := range steps {
+		plan.Actions = append(plan.Actions, step.action)
+	}
+	return plan, nil
+}
+
+// ApplyManifest converges on a manifest by adding registries and installing, creating,
+// recreating, starting and removing servers. It returns the changes made, stopping at the
+// first one that fails.
+func (s *Service) ApplyManifest(ctx context.Context, m manifest.Manifest) (*manifest.Plan, error) {
+	steps, err := s.manifestSteps(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := &manifest.Plan{Actions: []manifest.Action{}}
+	for _, step := range steps {
+		logging.LogInfo("Applying manifest: %s %s", step.action.Kind, step.action.Target)
+		if err := step.apply(ctx); err != nil {
+			return applied, fmt.Errorf("failed to %s %s: %w", strings.ReplaceAll(step.action.Kind, "-", " "), step.action.Target, err)
+		}
+		applied.Actions = append(applied.Actions, step.action)
+	}
+	return applied, nil
+}
+
+// manifestSteps works out what differs from the manifest, in the order it has to be applied
+func (s *Service) manifestSteps(ctx context.Context, m manifest.Manifest) ([]manifestStep, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+	if s.dockerService == nil {
+		return nil, fmt.Errorf("Docker service not available")
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	steps := s.registrySteps(m)
+
+	registryServers, err := s.manifestRegistryServers(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := s.dockerService.GetManagedContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	containersByName := make(map[string]docker.ContainerInfo)
+	for _, container := range containers {
+		containersByName[container.Name] = container
+	}
+
+	installing := make(map[string]bool)
+	for _, server := range m.Servers {
+		serverSteps, err := s.serverSteps(server, registryServers, containersByName, installing)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, serverSteps...)
+	}
+
+	if m.Prune {
+		steps = append(steps, s.pruneSteps(m, containersByName)...)
+	}
+
+	sortManifestSteps(steps)
+	return steps, nil
+}
+
+// sortManifestSteps orders steps by manifestActionOrder, keeping the order of steps of a kind
+func sortManifestSteps(steps []manifestStep) {
+	sort.SliceStable(steps, func(i, j int) bool {
+		return slices.Index(manifestActionOrder, steps[i].action.Kind) < slices.Index(manifestActionOrder, steps[j].action.Kind)
+	})
+}
+
+// manifestRegistry converts a registry of the manifest to the configuration's form
+func manifestRegistry(registry manifest.Registry) config.Registry {
+	authType := registry.Auth.Type
+	if authType == "" {
+		authType = "none"
+	}
+	return config.Registry{
+		Name:         registry.Name,
+		URL:          registry.URL,
+		Description:  registry.Description,
+		AuthType:     authType,
+		AuthUsername: registry.Auth.Username,
+		AuthPassword: registry.Auth.Password,
+		AuthHeader:   registry.Auth.Header,
+	}
+}
+
+// registrySteps adds the registries of the manifest that aren't configured yet and updates the
+// ones that differ. Registries missing from the manifest are kept.
+func (s *Service) registrySteps(m manifest.Manifest) []manifestStep {
+	configured := make(map[string]config.Registry)
+	for _, registry := range s.configManager.GetRegistries() {
+		configured[registry.Name] = registry
+	}
+
+	var steps []manifestStep
+	for _, entry := range m.Registries {
+		registry := manifestRegistry(entry)
+		existing, ok := configured[registry.Name]
+		if !ok {
+			steps = append(steps, manifestStep{
+				action: manifest.Action{Kind: manifest.ActionAddRegistry, Target: registry.Name, Details: []string{registry.URL}},
+				apply: func(ctx context.Context) error {
+					return s.AddCustomRegistry(ctx, registry.Name, registry.URL, registry.Description, registry.AuthType, registry.AuthUsername, registry.AuthPassword, registry.AuthHeader)
+				},
+			})
+			continue
+		}
+
+		var details []string
+		if existing.URL != registry.URL {
+			details = append(details, fmt.Sprintf("url %s -> %s", existing.URL, registry.URL))
+		}
+		if existing.Description != registry.Description {
+			details = append(details, "description changed")
+		}
+		// Credentials are never printed, they may come from the environment
+		if existing.AuthType != registry.AuthType || existing.AuthUsername != registry.AuthUsername ||
+			existing.AuthPassword != registry.AuthPassword || existing.AuthHeader != registry.AuthHeader {
+			details = append(details, "authentication changed")
+		}
+		if len(details) == 0 {
+			continue
+		}
+
+		oldURL := existing.URL
+		steps = append(steps, manifestStep{
+			action: manifest.Action{Kind: manifest.ActionUpdateRegistry, Target: registry.Name, Details: details},
+			apply: func(ctx context.Context) error {
+				return s.UpdateCustomRegistry(ctx, oldURL, registry.Name, registry.URL, registry.Description, registry.AuthType, registry.AuthUsername, registry.AuthPassword, registry.AuthHeader)
+			},
+		})
+	}
+	return steps
+}
+
+// manifestRegistryServers fetches the servers of the registries as they will be once the
+// manifest is applied, if any server of the manifest comes from a registry
+func (s *Service) manifestRegistryServers(ctx context.Context, m manifest.Manifest) ([]config.RegistryServer, error) {
+	needed := false
+	for _, server := range m.Servers {
+		needed = needed || server.Server != ""
+	}
+	if !needed {
+		return nil, nil
+	}
+
+	fromManifest := make(map[string]config.Registry)
+	for _, registry := range m.Registries {
+		fromManifest[registry.Name] = manifestRegistry(registry)
+	}
+
+	var registries []config.Registry
+	for _, registry := range s.GetRegistries() {
+		if replacement, ok := fromManifest[registry.Name]; ok {
+			registry = replacement
+			delete(fromManifest, registry.Name)
+		}
+		registries = append(registries, registry)
+	}
+	for _, registry := range m.Registries {
+		if _, ok := fromManifest[registry.Name]; ok {
+			registries = append(registries, fromManifest[registry.Name])
+		}
+	}
+
+	var servers []config.RegistryServer
+	for _, registry := range registries {
+		registryServers, err := s.fetchRegistryServers(ctx, registry)
+		if err != nil {
+			// A registry of the manifest has to work, others only matter if servers come from them
+			for _, entry := range m.Registries {
+				if entry.Name == registry.Name {
+					return nil, fmt.Errorf("failed to fetch registry %s: %w", registry.Name, err)
+				}
+			}
+			logging.LogWarning("Failed to fetch from registry %s: %v", registry.Name, err)
+			continue
+		}
+		servers = append(servers, registryServers...)
+	}
+	return servers, nil
+}
+
+// findRegistryServer returns the registry server a server of the manifest refers to
+func findRegistryServer(server manifest.Server, registryServers []config.RegistryServer) (*config.RegistryServer, error) {
+	var matches []config.RegistryServer
+	for _, registryServer := range registryServers {
+		if server.Registry != "" && !strings.EqualFold(registryServer.SourceRegistryName, server.Registry) {
+			continue
+		}
+		if strings.EqualFold(registryServer.Name, server.Server) {
+			matches = append(matches, registryServer)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("server %s: %s not found in the registries", server.Name, server.Server)
+	case 1:
+		return &matches[0], nil
+	default:
+		var registries []string
+		for _, match := range matches {
+			registries = append(registries, match.SourceRegistryName)
+		}
+		return nil, fmt.Errorf("server %s: %s is offered by several registries (%s), choose one with registry", server.Name, server.Server, strings.Join(registries, ", "))
+	}
+}
+
+// imageWithTag replaces the tag of a Docker image reference
+func imageWithTag(image, tag string) string {
+	if index := strings.Index(image, "@"); index >= 0 {
+		image = image[:index]
+	}
+	if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+		image = image[:index]
+	}
+	return image + ":" + tag
+}
+
+// serverSteps installs, creates, recreates, updates and starts a server of the manifest as
+// needed. Images installed by earlier servers are tracked in installing.
+func (s *Service) serverSteps(server manifest.Server, registryServers []config.RegistryServer, containers map[string]docker.ContainerInfo, installing map[string]bool) ([]manifestStep, error) {
+	// What to install: a registry server, maybe in another version, or a plain image
+	var registryServer *config.RegistryServer
+	image := server.Image
+	version := ""
+	stdio := false
+	if server.Server != "" {
+		var err error
+		registryServer, err = findRegistryServer(server, registryServers)
+		if err != nil {
+			return nil, err
+		}
+
+		image = registryServer.DockerImage
+		version = registryServer.Version
+		stdio = registryServer.Transport == config.TransportStdio
+		if server.Version != "" && server.Version != version {
+			image = imageWithTag(image, server.Version)
+			version = server.Version
+		}
+	} else if server.Version != "" {
+		image = imageWithTag(image, server.Version)
+	}
+
+	switch {
+	case stdio && server.Port != 0:
+		return nil, fmt.Errorf("server %s: %s only speaks stdio, so it can't have a port", server.Name, server.Server)
+	case !stdio && server.SessionContainers:
+		return nil, fmt.Errorf("server %s: %s is not a stdio server, so it can't have session containers", server.Name, server.Server)
+	}
+
+	installedServer := s.findInstalledServerFor(image, version)
+	var steps []manifestStep
+	if installedServer == nil && !installing[image+"@"+version] {
+		installing[image+"@"+version] = true
+		steps = append(steps, s.installStep(server, registryServer, image, version))
+	}
+
+	// The environment the container will get, to compare it with the current one
+	environmentSource := installedServer
+	if environmentSource == nil && registryServer != nil {
+		environmentSource = &config.InstalledServer{Name: registryServer.Name, EnvironmentVariables: registryServer.EnvironmentVariables}
+	}
+	if environmentSource == nil {
+		environmentSource = &config.InstalledServer{Name: server.Name}
+	}
+	environment, err := serverEnvironment(environmentSource, server.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("server %s: %w", server.Name, err)
+	}
+	volumes := server.Volumes
+	if volumes == nil {
+		volumes = make(map[string]string)
+	}
+
+	existing := s.configManager.FindConfiguredServer(server.Name)
+	container, hasContainer := containers[server.Name]
+	if existing == nil {
+		if hasContainer {
+			// A container of that name that isn't configured has to go first
+			steps = append(steps, s.createStep(manifest.ActionRecreate, server, image, version, &container, nil, []string{"container isn't configured"}))
+		} else {
+			steps = append(steps, s.createStep(manifest.ActionCreate, server, image, version, nil, nil, nil))
+		}
+		return steps, nil
+	}
+
+	var reasons []string
+	switch {
+	case existing.DockerImage != image:
+		reasons = append(reasons, fmt.Sprintf("image %s -> %s", existing.DockerImage, image))
+	case registryServer != nil && existing.Version != version:
+		reasons = append(reasons, fmt.Sprintf("version %s -> %s", existing.Version, version))
+	}
+	reasons = append(reasons, environmentChanges(existing.Environment, environment)...)
+	if !maps.Equal(existing.Volumes, volumes) {
+		reasons = append(reasons, "volumes changed")
+	}
+	// Without a port in the manifest, the current one is kept
+	stdio = stdio || installedServer != nil && installedServer.Transport == config.TransportStdio
+	switch {
+	case stdio && existing.Port != 0:
+		reasons = append(reasons, fmt.Sprintf("port %d -> none", existing.Port))
+	case !stdio && server.Port != 0 && existing.Port != server.Port:
+		reasons = append(reasons, fmt.Sprintf("port %d -> %d", existing.Port, server.Port))
+	}
+	if hasContainer && (container.Labels[docker.LabelSessionContainers] == "true") != server.SessionContainers {
+		if server.SessionContainers {
+			reasons = append(reasons, "session containers enabled")
+		} else {
+			reasons = append(reasons, "session containers disabled")
+		}
+	}
+	if !hasContainer {
+		reasons = append(reasons, "container is missing")
+	}
+
+	if len(reasons) > 0 {
+		var current *docker.ContainerInfo
+		if hasContainer {
+			current = &container
+		}
+		steps = append(steps, s.createStep(manifest.ActionRecreate, server, image, version, current, existing, reasons))
+		return steps, nil
+	}
+
+	if !equalToolPolicies(existing.ToolPolicy, server.ToolPolicy) {
+		policy := server.ToolPolicy
+		steps = append(steps, manifestStep{
+			action: manifest.Action{Kind: manifest.ActionUpdatePolicy, Target: server.Name},
+			apply: func(ctx context.Context) error {
+				return s.UpdateToolPolicy(existing.ID, policy)
+			},
+		})
+	}
+
+	if container.State != "running" {
+		containerID := container.ID
+		steps = append(steps, manifestStep{
+			action: manifest.Action{Kind: manifest.ActionStart, Target: server.Name, Details: []string{"container is " + container.State}},
+			apply: func(ctx context.Context) error {
+				return s.StartContainer(ctx, containerID)
+			},
+		})
+	}
+	return steps, nil
+}
+
+// findInstalledServerFor returns the installed server of an image, in the given version for
+// registry servers, and of the image alone for plain images (version "")
+func (s *Service) findInstalledServerFor(image, version string) *config.InstalledServer {
+	for _, server := range s.configManager.GetInstalledServers() {
+		if server.DockerImage == image && (version == "" || server.Version == version) {
+			return &server
+		}
+	}
+	return nil
+}
+
+// installStep pulls the image of a server and adds it to the installed servers
+func (s *Service) installStep(server manifest.Server, registryServer *config.RegistryServer, image, version string) manifestStep {
+	if registryServer == nil {
+		return manifestStep{
+			action: manifest.Action{Kind: manifest.ActionInstall, Target: image},
+			apply: func(ctx context.Context) error {
+				if err := s.PullImage(ctx, image); err != nil {
+					return err
+				}
+				_, err := s.InstallManualServer(image, server.Name, "")
+				return err
+			},
+		}
+	}
+
+	install := *registryServer
+	install.DockerImage = image
+	install.Version = version
+	return manifestStep{
+		action: manifest.Action{Kind: manifest.ActionInstall, Target: image, Details: []string{fmt.Sprintf("%s %s from %s", install.Name, version, install.SourceRegistryName)}},
+		apply: func(ctx context.Context) error {
+			return s.InstallServer(ctx, install)
+		},
+	}
+}
+
+// createStep creates and starts the container of a server, removing the current container and
+// configured server entry first when recreating. A recreated server keeps its port and its
+// Claude Desktop entry unless the manifest says otherwise.
+func (s *Service) createStep(kind string, server manifest.Server, image, version string, container *docker.ContainerInfo, existing *config.ConfiguredServer, reasons []string) manifestStep {
+	return manifestStep{
+		action: manifest.Action{Kind: kind, Target: server.Name, Details: reasons},
+		apply: func(ctx context.Context) error {
+			inClaude := server.Claude || s.hasMCPServerInClaude(server.Name)
+			if container != nil {
+				// Not s.RemoveContainer, that would take the server out of Claude Desktop as well
+				if err := s.dockerService.RemoveContainer(ctx, container.ID, true); err != nil {
+					return fmt.Errorf("failed to remove container: %w", err)
+				}
+			}
+			if existing != nil && s.configManager.FindConfiguredServer(existing.ID) != nil {
+				if err := s.configManager.RemoveConfiguredServer(existing.ID); err != nil {
+					return fmt.Errorf("failed to remove configured server entry: %w", err)
+				}
+			}
+
+			installedServer := s.findInstalledServerFor(image, version)
+			if installedServer == nil {
+				return fmt.Errorf("%s is not installed", image)
+			}
+
+			port := server.Port
+			if port == 0 && existing != nil {
+				port = existing.Port
+			}
+			if installedServer.Transport == config.TransportStdio {
+				port = 0
+			} else if port == 0 {
+				port = s.findAvailablePort(s.configManager.GetConfig().ServerDefaults.DefaultPort)
+				if port == 0 {
+					return fmt.Errorf("no available ports found in valid range")
+				}
+			} else if s.findAvailablePort(port) != port {
+				return fmt.Errorf("port %d is used by another server", port)
+			}
+
+			configuredServer, err := s.createServer(ctx, installedServer, server.Name, server.Environment, server.Volumes, port, server.SessionContainers)
+			if err != nil {
+				return err
+			}
+			if !server.ToolPolicy.IsEmpty() {
+				if err := s.UpdateToolPolicy(configuredServer.ID, server.ToolPolicy); err != nil {
+					return err
+				}
+			}
+			if err := s.StartContainer(ctx, configuredServer.ContainerID); err != nil {
+				return fmt.Errorf("failed to start container: %w", err)
+			}
+			if inClaude {
+				if err := s.AddMCPServerToClaude(configuredServer.ContainerName, configuredServer.Port); err != nil {
+					return fmt.Errorf("failed to add server to Claude Desktop: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// pruneSteps removes the configured servers that aren't in the manifest
+func (s *Service) pruneSteps(m manifest.Manifest, containers map[string]docker.ContainerInfo) []manifestStep {
+	inManifest := make(map[string]bool)
+	for _, server := range m.Servers {
+		inManifest[server.Name] = true
+	}
+
+	var steps []manifestStep
+	for _, configured := range s.configManager.GetConfiguredServers() {
+		if inManifest[configured.ContainerName] {
+			continue
+		}
+
+		configured := configured
+		container, hasContainer := containers[configured.ContainerName]
+		steps = append(steps, manifestStep{
+			action: manifest.Action{Kind: manifest.ActionRemove, Target: configured.ContainerName},
+			apply: func(ctx context.Context) error {
+				if hasContainer {
+					return s.RemoveContainer(ctx, container.ID, true)
+				}
+				s.RemoveMCPServerFromClaude(configured.ContainerName)
+				return s.configManager.RemoveConfiguredServer(configured.ID)
+			},
+		})
+	}
+	return steps
+}
+
+// environmentChanges names the variables that differ, without their values, which may be secret
+func environmentChanges(current, desired map[string]string) []string {
+	var changes []string
+	for _, name := range slices.Sorted(maps.Keys(desired)) {
+		value, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("environment %s added", name))
+		case value != desired[name]:
+			changes = append(changes, fmt.Sprintf("environment %s changed", name))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := desired[name]; !ok {
+			changes = append(changes, fmt.Sprintf("environment %s removed", name))
+		}
+	}
+	return changes
+}
+
+// equalToolPolicies reports whether two tool policies have the same patterns
+func equalToolPolicies(a, b config.ToolPolicy) bool {
+	return slices.Equal(a.Allow, b.Allow) && slices.Equal(a.Deny, b.Deny) && slices.Equal(a.RequireApproval, b.RequireApproval)
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/manifest"
+)
+
+func TestImageWithTag(t *testing.T) {
+	tests := []struct {
+		image, tag, want string
+	}{
+		{"example/mcp", "1.1", "example/mcp:1.1"},
+		{"example/mcp:1.0", "1.1", "example/mcp:1.1"},
+		{"example/mcp@sha256:abc", "1.1", "example/mcp:1.1"},
+		{"example/mcp:1.0@sha256:abc", "1.1", "example/mcp:1.1"},
+		{"localhost:5000/mcp", "1.1", "localhost:5000/mcp:1.1"},
+		{"localhost:5000/team/mcp:1.0", "1.1", "localhost:5000/team/mcp:1.1"},
+		{"registry.example.com:443/mcp@sha256:abc", "1.1", "registry.example.com:443/mcp:1.1"},
+	}
+
+	for _, test := range tests {
+		if got := imageWithTag(test.image, test.tag); got != test.want {
+			t.Errorf("imageWithTag(%q, %q): expected %q, got %q", test.image, test.tag, test.want, got)
+		}
+	}
+}
+
+func TestEnvironmentChanges(t *testing.T) {
+	current := map[string]string{"KEEP": "1", "CHANGE": "old", "REMOVE": "x"}
+	desired := map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "y"}
+
+	want := "environment ADD added,environment CHANGE changed,environment REMOVE removed"
+	if got := strings.Join(environmentChanges(current, desired), ","); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if changes := environmentChanges(current, current); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+// newManifestTestService returns a service with an installed and a configured server of the
+// image example/mcp:1.0, named custom
+func newManifestTestService(t *testing.T) *Service {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+
+	configManager, err := config.NewConfigManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.AddOrUpdateInstalledServer(config.InstalledServer{ID: "installed-1", Name: "custom", DockerImage: "example/mcp:1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.AddOrUpdateConfiguredServer(config.ConfiguredServer{
+		ID:            "configured-1",
+		ContainerName: "custom",
+		DockerImage:   "example/mcp:1.0",
+		Port:          8001,
+		Environment:   map[string]string{"LOG_LEVEL": "info"},
+		ToolPolicy:    config.ToolPolicy{Deny: []string{"*_delete"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Service{configManager: configManager}
+}
+
+func TestServerSteps(t *testing.T) {
+	unchanged := manifest.Server{
+		Name:        "custom",
+		Image:       "example/mcp:1.0",
+		Port:        8001,
+		Environment: map[string]string{"LOG_LEVEL": "info"},
+		ToolPolicy:  config.ToolPolicy{Deny: []string{"*_delete"}},
+	}
+	running := map[string]docker.ContainerInfo{"custom": {ID: "container-1", Name: "custom", State: "running"}}
+
+	tests := []struct {
+		name       string
+		change     func(server *manifest.Server)
+		containers map[string]docker.ContainerInfo
+		want       []string // kind and details of every step
+	}{
+		{
+			name:       "unchanged",
+			containers: running,
+		},
+		{
+			name:       "tool policy only updates the policy",
+			change:     func(server *manifest.Server) { server.ToolPolicy = config.ToolPolicy{Deny: []string{"*"}} },
+			containers: running,
+			want:       []string{"update-tool-policy"},
+		},
+		{
+			name: "environment recreates, the new container gets the policy",
+			change: func(server *manifest.Server) {
+				server.Environment = map[string]string{"LOG_LEVEL": "debug"}
+				server.ToolPolicy = config.ToolPolicy{}
+			},
+			containers: running,
+			want:       []string{"recreate environment LOG_LEVEL changed"},
+		},
+		{
+			name:       "version installs and recreates",
+			change:     func(server *manifest.Server) { server.Version = "1.1" },
+			containers: running,
+			want:       []string{"install", "recreate image example/mcp:1.0 -> example/mcp:1.1"},
+		},
+		{
+			name:       "volumes recreate",
+			change:     func(server *manifest.Server) { server.Volumes = map[string]string{"/data": "/data"} },
+			containers: running,
+			want:       []string{"recreate volumes changed"},
+		},
+		{
+			name:       "port recreates",
+			change:     func(server *manifest.Server) { server.Port = 8002 },
+			containers: running,
+			want:       []string{"recreate port 8001 -> 8002"},
+		},
+		{
+			name:       "no port keeps the current one",
+			change:     func(server *manifest.Server) { server.Port = 0 },
+			containers: running,
+		},
+		{
+			name:       "stopped container is started",
+			containers: map[string]docker.ContainerInfo{"custom": {ID: "container-1", Name: "custom", State: "exited"}},
+			want:       []string{"start container is exited"},
+		},
+		{
+			name:       "missing container recreates",
+			containers: map[string]docker.ContainerInfo{},
+			want:       []string{"recreate container is missing"},
+		},
+		{
+			name:       "new server is created",
+			change:     func(server *manifest.Server) { server.Name = "other" },
+			containers: running,
+			want:       []string{"create"},
+		},
+		{
+			name:       "unconfigured container is replaced",
+			change:     func(server *manifest.Server) { server.Name = "other" },
+			containers: map[string]docker.ContainerInfo{"other": {ID: "container-2", Name: "other", State: "running"}},
+			want:       []string{"recreate container isn't configured"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newManifestTestService(t)
+
+			server := unchanged
+			if test.change != nil {
+				test.change(&server)
+			}
+			steps, err := s.serverSteps(server, nil, test.containers, make(map[string]bool))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, step := range steps {
+				got = append(got, strings.TrimSpace(step.action.Kind+" "+strings.Join(step.action.Details, ", ")))
+			}
+			if strings.Join(got, "; ") != strings.Join(test.want, "; ") {
+				t.Errorf("expected steps %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestServerStepsInstallsEachImageOnce(t *testing.T) {
+	s := newManifestTestService(t)
+	installing := make(map[string]bool)
+
+	for _, name := range []string{"first", "second"} {
+		steps, err := s.serverSteps(manifest.Server{Name: name, Image: "example/other:2.0"}, nil, nil, installing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		installs := 0
+		for _, step := range steps {
+			if step.action.Kind == manifest.ActionInstall {
+				installs++
+			}
+		}
+		if want := map[string]int{"first": 1, "second": 0}[name]; installs != want {
+			t.Errorf("expected %d install steps for %s, got %d", want, name, installs)
+		}
+	}
+}
+
+func TestSortManifestSteps(t *testing.T) {
+	step := func(kind, target string) manifestStep {
+		return manifestStep{action: manifest.Action{Kind: kind, Target: target}}
+	}
+	steps := []manifestStep{
+		step(manifest.ActionStart, "a"),
+		step(manifest.ActionCreate, "b"),
+		step(manifest.ActionInstall, "image-b"),
+		step(manifest.ActionRecreate, "c"),
+		step(manifest.ActionCreate, "d"),
+		step(manifest.ActionRemove, "e"),
+		step(manifest.ActionAddRegistry, "team"),
+		step(manifest.ActionUpdatePolicy, "f"),
+	}
+	sortManifestSteps(steps)
+
+	var got []string
+	for _, step := range steps {
+		got = append(got, step.action.Kind+" "+step.action.Target)
+	}
+	want := "add-registry team,install image-b,remove e,recreate c,create b,create d,update-tool-policy f,start a"
+	if strings.Join(got, ",") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+// newRecreateDockerAPI serves the Docker API calls of a recreate and keeps the host port the
+// new container was created with
+func newRecreateDockerAPI(t *testing.T, hostPort *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.45")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Write([]byte("OK"))
+		case r.Method == "DELETE" && strings.HasSuffix(r.URL.Path, "/containers/container-1"):
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/create"):
+			var body struct {
+				HostConfig struct {
+					PortBindings map[string][]struct{ HostPort string }
+				}
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, bindings := range body.HostConfig.PortBindings {
+				*hostPort = bindings[0].HostPort
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"container-2"}`))
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/containers/container-2/json"):
+			w.Write([]byte(`{"Id":"container-2","State":{"Status":"created"},"Config":{"Labels":{}}}`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/container-2/start"):
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected Docker API call %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRecreateKeepsPortAndClaudeEntry(t *testing.T) {
+	s := newManifestTestService(t)
+
+	var hostPort string
+	dockerAPI := newRecreateDockerAPI(t, &hostPort)
+	t.Setenv("DOCKER_HOST", "tcp://"+strings.TrimPrefix(dockerAPI.URL, "http://"))
+	dockerService, err := docker.NewDockerService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.dockerService = dockerService
+
+	claudeConfigPath := filepath.Join(t.TempDir(), "claude_desktop_config.json")
+	if err := os.WriteFile(claudeConfigPath, []byte(`{"mcpServers":{"custom":{"command":"neobelt"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.configManager.GetConfig().ClaudeIntegration = config.ClaudeIntegrationConfig{Enabled: true, ConfigPath: claudeConfigPath}
+
+	// Neither the port nor Claude Desktop are in the manifest
+	server := manifest.Server{Name: "custom", Image: "example/mcp:1.0", Environment: map[string]string{"LOG_LEVEL": "debug"}}
+	steps, err := s.serverSteps(server, nil, map[string]docker.ContainerInfo{"custom": {ID: "container-1", Name: "custom", State: "running"}}, make(map[string]bool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].action.Kind != manifest.ActionRecreate {
+		t.Fatalf("expected a recreate, got %+v", steps)
+	}
+	if err := steps[0].apply(context.Background()); err != nil {
+		t.Fatalf("recreate failed: %v", err)
+	}
+
+	if hostPort != "8001" {
+		t.Errorf("expected the new container on port 8001, got %q", hostPort)
+	}
+	if configured := s.configManager.FindConfiguredServer("custom"); configured == nil || configured.Port != 8001 || configured.ContainerID != "container-2" {
+		t.Errorf("expected the configured server to keep port 8001, got %+v", configured)
+	}
+	data, _ := os.ReadFile(claudeConfigPath)
+	if !strings.Contains(string(data), "http://localhost:8001/mcp") {
+		t.Errorf("expected the server to stay in Claude Desktop, got %s", data)
+	}
+}
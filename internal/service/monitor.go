@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+)
+
+// DockerMonitor handles periodic Docker status checks
+type DockerMonitor struct {
+	service *Service
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewDockerMonitor creates a new Docker monitor
+func NewDockerMonitor(service *Service) *DockerMonitor {
+	return &DockerMonitor{
+		service: service,
+	}
+}
+
+// Start begins the periodic Docker status monitoring (every 15 seconds) until the context ends
+func (dm *DockerMonitor) Start(ctx context.Context) {
+	dm.ctx, dm.cancel = context.WithCancel(ctx)
+	ticker := time.NewTicker(15 * time.Second)
+
+	go func() {
+		defer ticker.Stop()
+
+		// Wait 2 seconds for frontend to be ready before the first check
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+			dm.checkDockerStatus()
+		}
+
+		// Then check every 15 seconds
+		for {
+			select {
+			case <-dm.ctx.Done():
+				return
+			case <-ticker.C:
+				dm.checkDockerStatus()
+			}
+		}
+	}()
+}
+
+// Stop stops the Docker monitoring
+func (dm *DockerMonitor) Stop() {
+	if dm.cancel != nil {
+		dm.cancel()
+	}
+}
+
+// checkDockerStatus performs the actual Docker status check and handles the results
+func (dm *DockerMonitor) checkDockerStatus() {
+	status, err := dm.service.CheckDockerStatus(dm.ctx)
+	if err != nil {
+		logging.LogError("Failed to check Docker status: %v", err)
+		return
+	}
+
+	if !status.IsDockerDesktopInstalled {
+		// Docker Desktop is not installed - emit event to show modal
+		dm.emitDockerStatusEvent("docker_not_installed", status)
+		return
+	}
+
+	if !status.IsRunning {
+		// Docker is installed but not running - show modal to ask user to start it
+		logging.LogInfo("Docker is not running, showing modal to user...")
+		dm.emitDockerStatusEvent("docker_not_running", status)
+		return
+	}
+
+	// Docker is running - emit success event
+	dm.emitDockerStatusEvent("docker_running", status)
+}
+
+// emitDockerStatusEvent reports the Docker status
+func (dm *DockerMonitor) emitDockerStatusEvent(eventType string, status *docker.DockerStatus) {
+	dm.service.emit("docker_status_update", map[string]interface{}{
+		"type":   eventType,
+		"status": status,
+	})
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"neobelt/internal/config"
+	"neobelt/internal/logging"
+)
+
+// FetchOfficialRegistry fetches servers from the official registry
+func (s *Service) FetchOfficialRegistry(ctx context.Context) ([]config.RegistryServer, error) {
+	return s.fetchRegistryFromURL(ctx, "https://dennis.paul.hamburg/neobelt/registry.json")
+}
+
+// FetchCustomRegistry fetches servers from a custom registry URL
+func (s *Service) FetchCustomRegistry(ctx context.Context, url string) ([]config.RegistryServer, error) {
+	return s.fetchRegistryFromURLWithAuth(ctx, url, config.Registry{AuthType: "none"})
+}
+
+// fetchRegistryFromURL is a helper function to fetch registry data from any URL (for official registry)
+func (s *Service) fetchRegistryFromURL(ctx context.Context, url string) ([]config.RegistryServer, error) {
+	return s.fetchRegistryFromURLWithAuth(ctx, url, config.Registry{AuthType: "none"})
+}
+
+// fetchRegistryFromURLWithAuth is a helper function to fetch registry data with authentication
+func (s *Service) fetchRegistryFromURLWithAuth(ctx context.Context, url string, registry config.Registry) ([]config.RegistryServer, error) {
+	// Enforce HTTPS for custom registries (allow HTTP only for official registry)
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("custom registries must use HTTPS")
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add authentication based on registry settings
+	switch registry.AuthType {
+	case "basic":
+		if registry.AuthUsername != "" && registry.AuthPassword != "" {
+			req.SetBasicAuth(registry.AuthUsername, registry.AuthPassword)
+		}
+	case "header":
+		if registry.AuthHeader != "" {
+			// Parse header format: "Header-Name: Header-Value"
+			parts := strings.SplitN(registry.AuthHeader, ":", 2)
+			if len(parts) == 2 {
+				headerName := strings.TrimSpace(parts[0])
+				headerValue := strings.TrimSpace(parts[1])
+				req.Header.Set(headerName, headerValue)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var servers []config.RegistryServer
+	err = json.Unmarshal(body, &servers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry JSON: %w", err)
+	}
+
+	return servers, nil
+}
+
+// GetRegistries returns the list of configured registries with hardcoded official registry
+func (s *Service) GetRegistries() []config.Registry {
+	// Always include the hardcoded official registry
+	officialRegistry := config.Registry{
+		Name:        "Official Registry",
+		URL:         "https://dennis.paul.hamburg/neobelt/registry.json",
+		Description: "Handpicked MCP servers by the Neobelt team",
+		AuthType:    "none",
+	}
+
+	registries := []config.Registry{officialRegistry}
+
+	if s.configManager != nil {
+		// Add custom registries from config
+		customRegistries := s.configManager.GetRegistries()
+		registries = append(registries, customRegistries...)
+	}
+
+	return registries
+}
+
+// AddCustomRegistry adds a new custom registry
+func (s *Service) AddCustomRegistry(ctx context.Context, name, url, description, authType, authUsername, authPassword, authHeader string) error {
+	// Create the registry entry for validation
+	newRegistry := config.Registry{
+		Name:         name,
+		URL:          url,
+		Description:  description,
+		AuthType:     authType,
+		AuthUsername: authUsername,
+		AuthPassword: authPassword,
+		AuthHeader:   authHeader,
+	}
+
+	// Validate that we can fetch from the URL with authentication
+	_, err := s.fetchRegistryFromURLWithAuth(ctx, url, newRegistry)
+	if err != nil {
+		return fmt.Errorf("failed to validate registry: %w", err)
+	}
+
+	// Add to persistent configuration
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	return s.configManager.AddRegistry(newRegistry)
+}
+
+// FetchAllRegistries fetches servers from all configured registries
+func (s *Service) FetchAllRegistries(ctx context.Context) ([]config.RegistryServer, error) {
+	registries := s.GetRegistries()
+	var allServers []config.RegistryServer
+
+	for _, registry := range registries {
+		servers, err := s.fetchRegistryServers(ctx, registry)
+		if err != nil {
+			// Log the error but continue with other registries
+			logging.LogWarning("Failed to fetch from registry %s: %v", registry.Name, err)
+			continue
+		}
+
+		// Add servers from this registry to the combined list
+		allServers = append(allServers, servers...)
+	}
+
+	return allServers, nil
+}
+
+// fetchRegistryServers fetches the servers of a registry and marks them with their source
+func (s *Service) fetchRegistryServers(ctx context.Context, registry config.Registry) ([]config.RegistryServer, error) {
+	servers, err := s.fetchRegistryFromURLWithAuth(ctx, registry.URL, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark each server with its source registry information
+	for i := range servers {
+		servers[i].SourceRegistryName = registry.Name
+		servers[i].SourceRegistryURL = registry.URL
+		servers[i].IsOfficial = registry.Name == "Official Registry"
+	}
+	return servers, nil
+}
+
+// RemoveCustomRegistry removes a custom registry
+func (s *Service) RemoveCustomRegistry(url string) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	// Prevent removal of the official registry
+	if url == "https://dennis.paul.hamburg/neobelt/registry.json" {
+		return fmt.Errorf("cannot remove the official registry")
+	}
+
+	return s.configManager.RemoveRegistry(url)
+}
+
+// UpdateCustomRegistry updates an existing registry
+func (s *Service) UpdateCustomRegistry(ctx context.Context, oldURL, name, newURL, description, authType, authUsername, authPassword, authHeader string) error {
+	// Prevent updating the official registry
+	if oldURL == "https://dennis.paul.hamburg/neobelt/registry.json" {
+		return fmt.Errorf("cannot update the official registry")
+	}
+
+	// Create the updated registry entry
+	updatedRegistry := config.Registry{
+		Name:         name,
+		URL:          newURL,
+		Description:  description,
+		AuthType:     authType,
+		AuthUsername: authUsername,
+		AuthPassword: authPassword,
+		AuthHeader:   authHeader,
+	}
+
+	// Validate that we can fetch from the new URL with authentication
+	_, err := s.fetchRegistryFromURLWithAuth(ctx, newURL, updatedRegistry)
+	if err != nil {
+		return fmt.Errorf("failed to validate registry: %w", err)
+	}
+
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	return s.configManager.UpdateRegistry(oldURL, updatedRegistry)
+}
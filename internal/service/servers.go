@@ -0,0 +1,819 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/mcp"
+)
+
+// GetManagedContainers returns all Docker containers managed by neobelt
+func (s *Service) GetManagedContainers(ctx context.Context) ([]docker.ContainerInfo, error) {
+	if s.dockerService == nil {
+		return nil, fmt.Errorf("docker service not available")
+	}
+
+	// Clean up orphaned containers before returning the managed ones
+	if err := s.CleanupOrphanedContainers(ctx); err != nil {
+		logging.LogWarning("Failed to cleanup orphaned containers: %v", err)
+		// Continue even if cleanup fails - we still want to return the current containers
+	}
+
+	// The container watcher's cache only lacks current resource usage
+	var containers []docker.ContainerInfo
+	cached := false
+	if s.containers != nil {
+		containers, cached = s.containers.Containers()
+	}
+	if cached {
+		s.dockerService.RefreshStats(ctx, containers)
+	} else {
+		var err error
+		containers, err = s.dockerService.GetManagedContainers(ctx)
+		if err != nil {
+			logging.LogError("Docker service GetManagedContainers failed: %v", err)
+			return nil, err
+		}
+	}
+
+	s.enrichContainers(containers)
+	return containers, nil
+}
+
+// enrichContainers adds the version and display name of their configured servers to the
+// containers, and their health
+func (s *Service) enrichContainers(containers []docker.ContainerInfo) {
+	if s.configManager == nil {
+		return
+	}
+
+	configuredServers := s.configManager.GetConfiguredServers()
+
+	// Create a map for quick lookup by container ID
+	configMap := make(map[string]config.ConfiguredServer)
+	for _, config := range configuredServers {
+		if config.ContainerID != "" {
+			configMap[config.ContainerID] = config
+		}
+	}
+
+	// Enrich each container with version and display name information
+	for i := range containers {
+		container := &containers[i]
+
+		// Look for matching configured server by container ID (handle both short and full IDs)
+		for configContainerID, config := range configMap {
+			if configContainerID == container.ID ||
+				configContainerID[:min(len(configContainerID), len(container.ID))] == container.ID ||
+				container.ID[:min(len(configContainerID), len(container.ID))] == configContainerID {
+
+				container.Version = config.Version
+				container.DisplayName = config.Name
+				break
+			}
+		}
+
+		if s.healthMonitor != nil {
+			container.Health, container.HealthError = s.healthMonitor.Health(container.ID)
+		}
+
+		// Set defaults if no configuration found
+		if container.Version == "" {
+			container.Version = "unknown"
+		}
+		if container.DisplayName == "" {
+			container.DisplayName = container.Name // Fallback to container name
+		}
+	}
+}
+
+// StartContainer starts a Docker container
+func (s *Service) StartContainer(ctx context.Context, containerID string) error {
+	logging.LogDebug("App.StartContainer called for container: %s", containerID)
+
+	if s.dockerService == nil {
+		logging.LogError("Docker service not available")
+		return fmt.Errorf("Docker service not available")
+	}
+
+	err := s.dockerService.StartContainer(ctx, containerID)
+	if err != nil {
+		logging.LogError("Docker service StartContainer failed for %s: %v", containerID, err)
+		return err
+	}
+
+	logging.LogDebug("App.StartContainer completed successfully for: %s", containerID)
+	return nil
+}
+
+// StopContainer stops a Docker container
+func (s *Service) StopContainer(ctx context.Context, containerID string) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("Docker service not available")
+	}
+
+	return s.dockerService.StopContainer(ctx, containerID)
+}
+
+// RestartContainer restarts a Docker container
+func (s *Service) RestartContainer(ctx context.Context, containerID string) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("Docker service not available")
+	}
+
+	return s.dockerService.RestartContainer(ctx, containerID)
+}
+
+// GetContainerLogs retrieves logs from a Docker container
+func (s *Service) GetContainerLogs(ctx context.Context, containerID string, lines int) (string, error) {
+	if s.dockerService == nil {
+		return "", fmt.Errorf("Docker service not available")
+	}
+
+	return s.dockerService.GetContainerLogs(ctx, containerID, lines)
+}
+
+// RemoveContainer removes a Docker container and its configuration
+func (s *Service) RemoveContainer(ctx context.Context, containerID string, force bool) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("Docker service not available")
+	}
+
+	// Remove the Docker container
+	err := s.dockerService.RemoveContainer(ctx, containerID, force)
+	if err != nil {
+		return err
+	}
+
+	// Remove configured server entry if it exists
+	if s.configManager != nil {
+		configuredServers := s.configManager.GetConfiguredServers()
+		for _, server := range configuredServers {
+			// Handle both short and full container IDs (similar to frontend logic)
+			if server.ContainerID == containerID ||
+				(len(server.ContainerID) > len(containerID) && strings.HasPrefix(server.ContainerID, containerID)) ||
+				(len(containerID) > len(server.ContainerID) && strings.HasPrefix(containerID, server.ContainerID)) {
+
+				// Clean up Claude Desktop configuration before removing the server entry
+				s.RemoveMCPServerFromClaude(server.ContainerName)
+
+				if removeErr := s.configManager.RemoveConfiguredServer(server.ID); removeErr != nil {
+					// Log the error but don't fail the operation
+					logging.LogWarning("Failed to remove configured server entry for container %s: %v", containerID, removeErr)
+				} else {
+					logging.LogInfo("Successfully removed configured server entry for container %s", containerID)
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetInstalledServersWithVersionCheck returns installed servers with version check information
+func (s *Service) GetInstalledServersWithVersionCheck(ctx context.Context) ([]map[string]any, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	installedServers := s.configManager.GetInstalledServers()
+	result := make([]map[string]any, 0, len(installedServers))
+
+	// Get all registry servers for version comparison
+	registryServers, err := s.FetchAllRegistries(ctx)
+	if err != nil {
+		// If we can't fetch registry data, just return installed servers without version info
+		for _, server := range installedServers {
+			serverInfo := map[string]any{
+				"installed_server": server,
+				"update_available": false,
+				"latest_version":   "",
+				"registry_error":   err.Error(),
+			}
+			result = append(result, serverInfo)
+		}
+		return result, nil
+	}
+
+	// Create a map for quick lookup of registry servers
+	registryMap := make(map[string]config.RegistryServer)
+	for _, regServer := range registryServers {
+		registryMap[regServer.DockerImage] = regServer
+	}
+
+	// Check each installed server for updates
+	for _, installed := range installedServers {
+		serverInfo := map[string]any{
+			"installed_server": installed,
+			"update_available": false,
+			"latest_version":   installed.Version,
+		}
+
+		// Find matching registry server
+		if regServer, exists := registryMap[installed.DockerImage]; exists {
+			serverInfo["registry_server"] = regServer
+			serverInfo["latest_version"] = regServer.Version
+
+			// Simple version comparison - in reality you'd want semantic versioning
+			if regServer.Version != installed.Version {
+				serverInfo["update_available"] = true
+			}
+		}
+
+		result = append(result, serverInfo)
+	}
+
+	return result, nil
+}
+
+// PullImage pulls a Docker image
+func (s *Service) PullImage(ctx context.Context, imageName string) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("Docker service not available")
+	}
+
+	return s.dockerService.PullImage(ctx, imageName)
+}
+
+// CreateContainer creates a new Docker container with neobelt labels
+func (s *Service) CreateContainer(ctx context.Context, config docker.ContainerCreateConfig) (string, error) {
+	logging.LogDebug("App.CreateContainer called with config: %+v", config)
+
+	if s.dockerService == nil {
+		logging.LogError("Docker service not available")
+		return "", fmt.Errorf("Docker service not available")
+	}
+
+	containerId, err := s.dockerService.CreateContainer(ctx, config)
+	if err != nil {
+		logging.LogError("Docker service CreateContainer failed: %v", err)
+		return "", err
+	}
+
+	logging.LogDebug("App.CreateContainer completed successfully, returning ID: %s", containerId)
+	return containerId, nil
+}
+
+// InstallServer installs a server from the registry (pulls image and updates config)
+func (s *Service) InstallServer(ctx context.Context, server config.RegistryServer) error {
+	// Pull the Docker image
+	if err := s.PullImage(ctx, server.DockerImage); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", server.DockerImage, err)
+	}
+
+	// Add server to installed servers configuration
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	installedServer := config.InstalledServer{
+		ID:                   fmt.Sprintf("%s-%d", strings.ToLower(strings.ReplaceAll(server.Name, " ", "-")), time.Now().Unix()),
+		Name:                 server.Name,
+		DockerImage:          server.DockerImage,
+		Version:              server.Version,
+		Description:          server.Description,
+		SetupDescription:     server.SetupDescription,
+		SupportURL:           server.SupportURL,
+		License:              server.License,
+		Maintainer:           server.Maintainer,
+		Tags:                 server.Tags,
+		Architecture:         server.Architecture,
+		HealthCheck:          server.HealthCheck,
+		ResourceRequirements: server.ResourceRequirements,
+		DockerCommand:        server.DockerCommand,
+		EnvironmentVariables: server.EnvironmentVariables,
+		Ports:                server.Ports,
+		Volumes:              server.Volumes,
+		Transport:            server.Transport,
+		InstallDate:          time.Now().Format(time.RFC3339),
+		LastUpdated:          time.Now().Format(time.RFC3339),
+		SourceRegistry:       server.SourceRegistryName,
+		IsOfficial:           server.IsOfficial,
+	}
+
+	return s.configManager.AddOrUpdateInstalledServer(installedServer)
+}
+
+// InstallManualServer creates an installed server entry for a manually pulled Docker image
+func (s *Service) InstallManualServer(dockerImage, name, description string) (string, error) {
+	if s.configManager == nil {
+		return "", fmt.Errorf("configuration manager not available")
+	}
+
+	serverID := fmt.Sprintf("manual-%s-%d", strings.ToLower(strings.ReplaceAll(name, " ", "-")), time.Now().Unix())
+	installedServer := config.InstalledServer{
+		ID:                   serverID,
+		Name:                 name,
+		DockerImage:          dockerImage,
+		Version:              "latest",
+		Description:          description,
+		SetupDescription:     fmt.Sprintf("Manually configured Docker container from image %s", dockerImage),
+		SupportURL:           "",
+		License:              "Unknown",
+		Maintainer:           "Custom",
+		Tags:                 []string{"manual", "custom"},
+		Architecture:         []string{"amd64"},
+		HealthCheck:          make(map[string]any),
+		ResourceRequirements: make(map[string]any),
+		DockerCommand:        "",
+		EnvironmentVariables: make(map[string]any),
+		Ports:                make(map[string]any),
+		Volumes:              []any{},
+		InstallDate:          time.Now().Format(time.RFC3339),
+		LastUpdated:          time.Now().Format(time.RFC3339),
+		SourceRegistry:       "Custom Docker",
+		IsOfficial:           false,
+	}
+
+	err := s.configManager.AddOrUpdateInstalledServer(installedServer)
+	if err != nil {
+		return "", err
+	}
+	return serverID, nil
+}
+
+// GetInstalledServers returns all installed servers (those that have images pulled)
+func (s *Service) GetInstalledServers() ([]config.InstalledServer, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	return s.configManager.GetInstalledServers(), nil
+}
+
+// GetConfiguredServers returns all configured servers (actual Docker containers)
+func (s *Service) GetConfiguredServers() ([]config.ConfiguredServer, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	return s.configManager.GetConfiguredServers(), nil
+}
+
+// getMCPPortFromRegistry extracts the MCP port from registry server data
+func getMCPPortFromRegistry(server *config.RegistryServer) int {
+	if server.Ports == nil {
+		return 0
+	}
+
+	// Try to get the "mcp" port from the ports map
+	if mcpPort, exists := server.Ports["mcp"]; exists {
+		switch v := mcpPort.(type) {
+		case float64:
+			return int(v)
+		case int:
+			return v
+		case string:
+			if port, err := strconv.Atoi(v); err == nil {
+				return port
+			}
+		}
+	}
+
+	return 0
+}
+
+// getMCPPortFromInstalledServer extracts the MCP port from installed server data
+func getMCPPortFromInstalledServer(server *config.InstalledServer) int {
+	if server.Ports == nil {
+		return 0
+	}
+
+	// Try to get the "mcp" port from the ports map
+	if mcpPort, exists := server.Ports["mcp"]; exists {
+		switch v := mcpPort.(type) {
+		case float64:
+			return int(v)
+		case int:
+			return v
+		case string:
+			if port, err := strconv.Atoi(v); err == nil {
+				return port
+			}
+		}
+	}
+
+	return 0
+}
+
+// CreateConfiguredServer creates a new configured server entry when a container is created
+func (s *Service) CreateConfiguredServer(installedServerID, containerName, containerID string, port int, environment, volumes map[string]string) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	// Get the installed server details
+	installedServer := s.findInstalledServerByID(installedServerID)
+	if installedServer == nil {
+		return fmt.Errorf("installed server with ID %s not found", installedServerID)
+	}
+
+	// Extract container port from registry data
+	containerPort := getMCPPortFromInstalledServer(installedServer)
+
+	configuredServer := config.ConfiguredServer{
+		ID:                fmt.Sprintf("configured-%d", time.Now().UnixNano()),
+		Version:           installedServer.Version,
+		Name:              installedServer.Name,
+		ContainerName:     containerName,
+		ContainerID:       containerID,
+		InstalledServerID: installedServerID,
+		DockerImage:       installedServer.DockerImage,
+		DockerCommand:     installedServer.DockerCommand,
+		Port:              port,
+		ContainerPort:     containerPort,
+		Transport:         installedServer.Transport,
+		Environment:       environment,
+		Volumes:           volumes,
+		CreatedDate:       time.Now().Format(time.RFC3339),
+		LastStarted:       "",
+		AutoStart:         false,
+	}
+
+	return s.configManager.AddOrUpdateConfiguredServer(configuredServer)
+}
+
+// CreateServer creates a container for an installed server and its configured server entry,
+// like the create dialog of the frontend. The port is the first free one from the default port,
+// registry defaults fill in missing environment variables, and the container is started if
+// the server defaults ask for it. Stdio servers get no port, sessionContainers gives each of
+// their client sessions a new container.
+func (s *Service) CreateServer(ctx context.Context, installedServerID, containerName string, environment, volumes map[string]string, sessionContainers bool) (*config.ConfiguredServer, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	installedServer := s.findInstalledServerByID(installedServerID)
+	if installedServer == nil {
+		return nil, fmt.Errorf("installed server with ID %s not found", installedServerID)
+	}
+
+	if containerName == "" {
+		containerName = fmt.Sprintf("%s-%d", strings.ToLower(strings.ReplaceAll(installedServer.Name, " ", "-")), time.Now().UnixMilli())
+	}
+
+	port := 0
+	if installedServer.Transport != config.TransportStdio {
+		port = s.findAvailablePort(s.configManager.GetConfig().ServerDefaults.DefaultPort)
+		if port == 0 {
+			return nil, fmt.Errorf("no available ports found in valid range")
+		}
+	}
+
+	configuredServer, err := s.createServer(ctx, installedServer, containerName, environment, volumes, port, sessionContainers)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.configManager.GetConfig().ServerDefaults.AutoStart {
+		if err := s.StartContainer(ctx, configuredServer.ContainerID); err != nil {
+			return configuredServer, fmt.Errorf("server %s was created but failed to start: %w", containerName, err)
+		}
+	}
+
+	return configuredServer, nil
+}
+
+// createServer creates the container of a server on the given port and its configured server
+// entry, without starting it
+func (s *Service) createServer(ctx context.Context, installedServer *config.InstalledServer, containerName string, environment, volumes map[string]string, port int, sessionContainers bool) (*config.ConfiguredServer, error) {
+	if s.configManager.FindConfiguredServer(containerName) != nil {
+		return nil, fmt.Errorf("a server named %s already exists", containerName)
+	}
+	stdio := installedServer.Transport == config.TransportStdio
+	if sessionContainers && !stdio {
+		return nil, fmt.Errorf("%s is not a stdio server, only stdio servers can have session containers", installedServer.Name)
+	}
+
+	serverEnvironment, err := serverEnvironment(installedServer, environment)
+	if err != nil {
+		return nil, err
+	}
+	if volumes == nil {
+		volumes = make(map[string]string)
+	}
+
+	serverDefaults := s.configManager.GetConfig().ServerDefaults
+	memoryLimit := serverDefaults.MaxMemoryMB
+	if memoryLimit <= 0 {
+		memoryLimit = 512
+	}
+	restartPolicy := "no"
+	if serverDefaults.RestartOnFailure {
+		restartPolicy = "on-failure"
+	}
+
+	labels := map[string]string{
+		"neobelt.server-id":   installedServer.ID,
+		"neobelt.server-name": installedServer.Name,
+	}
+	if sessionContainers {
+		labels[docker.LabelSessionContainers] = "true"
+	}
+
+	containerID, err := s.CreateContainer(ctx, docker.ContainerCreateConfig{
+		Name:          containerName,
+		Image:         installedServer.DockerImage,
+		Port:          port,
+		ContainerPort: getMCPPortFromInstalledServer(installedServer),
+		Environment:   serverEnvironment,
+		Volumes:       volumes,
+		DockerCommand: installedServer.DockerCommand,
+		MemoryLimitMB: memoryLimit,
+		RestartPolicy: restartPolicy,
+		Labels:        labels,
+		OpenStdin:     stdio,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.CreateConfiguredServer(installedServer.ID, containerName, containerID, port, serverEnvironment, volumes); err != nil {
+		return nil, fmt.Errorf("failed to create configured server entry: %w", err)
+	}
+	return s.configManager.FindConfiguredServer(containerName), nil
+}
+
+// findAvailablePort returns the first port from startPort that no configured server uses, 0 if
+// there is none
+func (s *Service) findAvailablePort(startPort int) int {
+	if startPort <= 0 {
+		startPort = 8000
+	}
+
+	usedPorts := make(map[int]bool)
+	for _, server := range s.configManager.GetConfiguredServers() {
+		usedPorts[server.Port] = true
+	}
+
+	for port := startPort; port <= 65535; port++ {
+		if !usedPorts[port] {
+			return port
+		}
+	}
+	return 0
+}
+
+// serverEnvironment returns the environment of a server's container: the registry's required
+// and default values, overridden by the given ones. Required variables must have a value.
+func serverEnvironment(installedServer *config.InstalledServer, environment map[string]string) (map[string]string, error) {
+	// Registry values first, so the given environment overrides them
+	serverEnvironment := make(map[string]string)
+	for _, kind := range []string{"required", "default"} {
+		for _, variable := range registryEnvironmentVariables(installedServer, kind) {
+			if value, ok := variable["value"].(string); ok && value != "" {
+				serverEnvironment[variable["name"].(string)] = value
+			}
+		}
+	}
+	for name, value := range environment {
+		serverEnvironment[name] = value
+	}
+	for _, variable := range registryEnvironmentVariables(installedServer, "required") {
+		name := variable["name"].(string)
+		if serverEnvironment[name] == "" {
+			return nil, fmt.Errorf("environment variable %s is required by %s", name, installedServer.Name)
+		}
+	}
+	return serverEnvironment, nil
+}
+
+// registryEnvironmentVariables returns the required, default or optional environment variables
+// of an installed server as listed in its registry entry
+func registryEnvironmentVariables(server *config.InstalledServer, kind string) []map[string]any {
+	entries, ok := server.EnvironmentVariables[kind].([]any)
+	if !ok {
+		return nil
+	}
+
+	var variables []map[string]any
+	for _, entry := range entries {
+		variable, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, ok := variable["name"].(string); ok && name != "" {
+			variables = append(variables, variable)
+		}
+	}
+	return variables
+}
+
+// UpdateToolPolicy sets which tools of a configured server MCP clients may list and call.
+// Proxies pick up the policy when Claude Desktop starts them again.
+func (s *Service) UpdateToolPolicy(serverID string, policy config.ToolPolicy) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	server := s.configManager.FindConfiguredServer(serverID)
+	if server == nil {
+		return fmt.Errorf("configured server with ID %s not found", serverID)
+	}
+
+	server.ToolPolicy = policy
+	if err := s.configManager.AddOrUpdateConfiguredServer(*server); err != nil {
+		return fmt.Errorf("failed to save tool policy: %w", err)
+	}
+
+	logging.LogInfo("Updated tool policy of server %s: allow %v, deny %v", server.Name, policy.Allow, policy.Deny)
+	return nil
+}
+
+// InspectMCPServer connects to a configured server and lists its tools, resources and prompts
+func (s *Service) InspectMCPServer(ctx context.Context, serverID string) (*mcp.ServerInspection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	inspector, closeInspector, err := s.openInspector(ctx, serverID)
+	if err != nil {
+		logging.LogWarning("Inspection of server %s failed: %v", serverID, err)
+		return nil, err
+	}
+	defer closeInspector()
+
+	return inspector.Inspect(ctx)
+}
+
+// CallMCPServerTool runs a tool of a configured server with JSON arguments and returns the raw response
+func (s *Service) CallMCPServerTool(ctx context.Context, serverID, toolName, argumentsJSON string) (*mcp.ToolCallResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	inspector, closeInspector, err := s.openInspector(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInspector()
+
+	logging.LogInfo("Calling tool %s of server %s from the inspector", toolName, serverID)
+	return inspector.CallTool(ctx, toolName, json.RawMessage(strings.TrimSpace(argumentsJSON)))
+}
+
+// openInspector connects the inspector to a configured server. Its tool policy applies and tool
+// calls are approved and audited like calls from Claude Desktop. The returned function ends the
+// session.
+func (s *Service) openInspector(ctx context.Context, serverID string) (*mcp.Inspector, func(), error) {
+	url, err := s.configuredServerURL(serverID)
+	if err != nil {
+		return nil, nil, err
+	}
+	server := s.configManager.FindConfiguredServer(serverID)
+
+	auditLog, err := audit.Open(s.configManager.GetLogDir())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	inspector, err := mcp.NewInspector(ctx, url, mcp.ProxyOptions{
+		ToolPolicy: server.ToolPolicy,
+		ServerName: server.ContainerName,
+		AuditLog:   auditLog,
+	})
+	if err != nil {
+		auditLog.Close()
+		return nil, nil, err
+	}
+
+	return inspector, func() {
+		inspector.Close()
+		auditLog.Close()
+	}, nil
+}
+
+// configuredServerURL returns the local MCP endpoint of a configured server
+func (s *Service) configuredServerURL(serverID string) (string, error) {
+	if s.configManager == nil {
+		return "", fmt.Errorf("configuration manager not available")
+	}
+
+	server := s.configManager.FindConfiguredServer(serverID)
+	if server == nil {
+		return "", fmt.Errorf("configured server with ID %s not found", serverID)
+	}
+	if server.Transport == config.TransportStdio {
+		return "", fmt.Errorf("server %s only speaks stdio, connect to it with neobelt --mcp-attach %s", server.Name, server.ContainerName)
+	}
+	if server.Port <= 0 {
+		return "", fmt.Errorf("server %s has no port mapped", server.Name)
+	}
+
+	return fmt.Sprintf("http://localhost:%d/mcp", server.Port), nil
+}
+
+// RemoveInstalledServer removes an installed server and optionally its Docker image
+func (s *Service) RemoveInstalledServer(ctx context.Context, serverID string, removeImage bool) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	// Get the installed server first
+	installedServer := s.findInstalledServerByID(serverID)
+	if installedServer == nil {
+		return fmt.Errorf("installed server with ID %s not found", serverID)
+	}
+
+	// Remove any configured servers that depend on this installed server
+	configuredServers := s.configManager.GetConfiguredServers()
+	for _, configServer := range configuredServers {
+		if configServer.InstalledServerID == serverID {
+			// Remove the container first if it exists
+			if configServer.ContainerID != "" && s.dockerService != nil {
+				_ = s.dockerService.RemoveContainer(ctx, configServer.ContainerID, true)
+			}
+
+			// Clean up Claude Desktop configuration before removing the server entry
+			s.RemoveMCPServerFromClaude(configServer.ContainerName)
+
+			// Remove the configured server entry
+			_ = s.configManager.RemoveConfiguredServer(configServer.ID)
+		}
+	}
+
+	// Remove the Docker image if requested
+	if removeImage && s.dockerService != nil {
+		if err := s.dockerService.RemoveImage(ctx, installedServer.DockerImage, false); err != nil {
+			logging.LogWarning("Failed to remove Docker image %s: %v", installedServer.DockerImage, err)
+		}
+	}
+
+	// Remove the installed server entry
+	return s.configManager.RemoveInstalledServer(serverID)
+}
+
+// findInstalledServerByID finds an installed server by ID
+func (s *Service) findInstalledServerByID(id string) *config.InstalledServer {
+	if s.configManager == nil {
+		return nil
+	}
+
+	installedServers := s.configManager.GetInstalledServers()
+	for _, server := range installedServers {
+		if server.ID == id {
+			return &server
+		}
+	}
+	return nil
+}
+
+// GetOrphanedContainers returns containers managed by neobelt but not in configuration
+func (s *Service) GetOrphanedContainers(ctx context.Context) ([]docker.ContainerInfo, error) {
+	if s.dockerService == nil {
+		return nil, fmt.Errorf("Docker service not available")
+	}
+
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	configuredServers := s.configManager.GetConfiguredServers()
+	return s.dockerService.GetOrphanedManagedContainers(ctx, configuredServers)
+}
+
+// CleanupOrphanedContainers removes orphaned neobelt containers
+func (s *Service) CleanupOrphanedContainers(ctx context.Context) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("Docker service not available")
+	}
+
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	configuredServers := s.configManager.GetConfiguredServers()
+	return s.dockerService.CleanupOrphanedContainers(ctx, configuredServers)
+}
+
+// CheckDockerStatus checks the current status of Docker daemon and Docker Desktop installation
+func (s *Service) CheckDockerStatus(ctx context.Context) (*docker.DockerStatus, error) {
+	if s.dockerService == nil {
+		return &docker.DockerStatus{
+			IsRunning:                false,
+			IsDockerDesktopInstalled: false,
+		}, nil
+	}
+
+	return s.dockerService.CheckDockerStatus(ctx), nil
+}
+
+// StartDockerDesktop attempts to start Docker Desktop
+func (s *Service) StartDockerDesktop() error {
+	if s.dockerService == nil {
+		return fmt.Errorf("docker service not available")
+	}
+
+	return s.dockerService.StartDockerDesktop()
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"neobelt/internal/approval"
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+)
+
+// min returns the minimum of two integers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// EventSink receives the events of the service, e.g. container state changes, to pass them on
+// to the frontend or other clients
+type EventSink interface {
+	Emit(name string, data interface{})
+}
+
+// configStore is the part of config.ConfigManager the service uses, so tests can keep the
+// configuration in memory
+type configStore interface {
+	GetConfig() *config.Configuration
+	SetConfig(config *config.Configuration)
+	Save() error
+	GetConfigPath() string
+	GetLogDir() string
+
+	GetRegistries() []config.Registry
+	AddRegistry(registry config.Registry) error
+	UpdateRegistry(oldURL string, registry config.Registry) error
+	RemoveRegistry(url string) error
+
+	GetInstalledServers() []config.InstalledServer
+	AddOrUpdateInstalledServer(server config.InstalledServer) error
+	RemoveInstalledServer(serverID string) error
+
+	GetConfiguredServers() []config.ConfiguredServer
+	FindConfiguredServer(idOrContainerName string) *config.ConfiguredServer
+	AddOrUpdateConfiguredServer(server config.ConfiguredServer) error
+	RemoveConfiguredServer(serverID string) error
+}
+
+// Service manages the MCP servers, registries, Claude Desktop integration and settings of
+// Neobelt. It doesn't depend on a GUI: every operation takes a plain context and events go to
+// an EventSink.
+type Service struct {
+	configManager configStore
+	dockerService *docker.DockerService
+	events        EventSink
+	dockerMonitor *DockerMonitor
+	healthMonitor *HealthMonitor
+	containers    *ContainerWatcher
+
+	// Tool calls waiting for the user's approval, by request ID
+	approvalMutex    sync.Mutex
+	pendingApprovals map[string]chan approval.Decision
+}
+
+// New creates a service that reports its events to the sink, which may be nil
+func New(events EventSink) *Service {
+	return &Service{
+		events:           events,
+		pendingApprovals: make(map[string]chan approval.Decision),
+	}
+}
+
+// NewInitialized creates and initializes a service without a GUI, for the command line
+func NewInitialized(events EventSink) (*Service, error) {
+	s := New(events)
+	if err := s.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize configuration manager: %w", err)
+	}
+	return s, nil
+}
+
+// Initialize loads the configuration, sets up logging and connects to Docker. Without Docker
+// the service still works, but container operations fail.
+func (s *Service) Initialize() error {
+	// Initialize configuration manager
+	configManager, err := config.NewConfigManager()
+	if err != nil {
+		return err
+	}
+
+	s.configManager = configManager
+	logging.LogInfo("Configuration loaded from: %s", configManager.GetConfigPath())
+
+	// Initialize logger
+	config := configManager.GetConfig()
+	debugMode := false
+	logRetention := 30
+	if config != nil {
+		debugMode = config.App.DebugMode
+		logRetention = config.App.LogRetention
+	}
+
+	logDir := configManager.GetLogDir()
+	if err := logging.InitLogger(logDir, debugMode); err != nil {
+		fmt.Printf("Warning: Failed to initialize logger: %v\n", err) // Keep as fmt since logger isn't initialized yet
+	} else {
+		logging.LogInfo("Logger initialized successfully")
+		// Clean up old logs
+		if err := logging.CleanupOldLogs(logDir, logRetention); err != nil {
+			logging.LogWarning("Failed to cleanup old logs: %v", err)
+		}
+	}
+
+	// Initialize Docker service
+	dockerService, err := docker.NewDockerService()
+	if err != nil {
+		logging.LogWarning("Failed to initialize Docker service: %v", err)
+		// Continue without Docker functionality
+	} else {
+		s.dockerService = dockerService
+		logging.LogInfo("Docker service initialized successfully")
+	}
+
+	return nil
+}
+
+// StartMonitors watches Docker and the running servers and answers tool approval requests of
+// MCP proxies until the context ends
+func (s *Service) StartMonitors(ctx context.Context) {
+	// Initialize and start Docker monitor
+	s.dockerMonitor = NewDockerMonitor(s)
+	s.dockerMonitor.Start(ctx)
+	logging.LogInfo("Docker monitor started")
+
+	// Keep container state up to date from Docker events
+	s.containers = NewContainerWatcher(s)
+	s.containers.Start(ctx)
+
+	// Initialize and start MCP health checks
+	s.healthMonitor = NewHealthMonitor(s)
+	s.healthMonitor.Start(ctx)
+	logging.LogInfo("Health monitor started")
+
+	// Answer approval requests of MCP proxies
+	go func() {
+		if err := approval.Listen(ctx, s.handleToolApproval); err != nil {
+			logging.LogWarning("Failed to listen for tool approval requests: %v", err)
+		}
+	}()
+}
+
+// emit reports an event to the sink, if there is one
+func (s *Service) emit(name string, data interface{}) {
+	if s.events != nil {
+		s.events.Emit(name, data)
+	}
+}
@@ -0,0 +1,854 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/crypto"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+
+	"github.com/emersion/go-autostart"
+)
+
+// GetConfiguration returns the current application configuration
+func (s *Service) GetConfiguration() (*config.Configuration, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	return s.configManager.GetConfig(), nil
+}
+
+// GetConfigPath returns the path to the configuration file
+func (s *Service) GetConfigPath() string {
+	if s.configManager == nil {
+		return ""
+	}
+
+	return s.configManager.GetConfigPath()
+}
+
+// GetLogDir returns the path to the logs directory
+func (s *Service) GetLogDir() string {
+	if s.configManager == nil {
+		return ""
+	}
+
+	return s.configManager.GetLogDir()
+}
+
+// GetServerDefaults returns the current server default settings
+func (s *Service) GetServerDefaults() (*config.ServerDefaultsConfig, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return nil, fmt.Errorf("no configuration available")
+	}
+
+	return &config.ServerDefaults, nil
+}
+
+// UpdateServerDefaults updates the server default settings and returns whether containers were recreated
+func (s *Service) UpdateServerDefaults(ctx context.Context, serverDefaults config.ServerDefaultsConfig) (bool, error) {
+	if s.configManager == nil {
+		return false, fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return false, fmt.Errorf("no configuration available")
+	}
+
+	// Check what settings have changed and need container recreation
+	oldDefaults := config.ServerDefaults
+	portChanged := oldDefaults.DefaultPort != serverDefaults.DefaultPort
+	memoryChanged := oldDefaults.MaxMemoryMB != serverDefaults.MaxMemoryMB
+	restartPolicyChanged := oldDefaults.RestartOnFailure != serverDefaults.RestartOnFailure
+
+	// Only recreate containers if settings that affect them have changed
+	containerRecreationNeeded := memoryChanged || restartPolicyChanged || portChanged
+
+	config.ServerDefaults = serverDefaults
+
+	// Save configuration first
+	if err := s.configManager.Save(); err != nil {
+		return false, err
+	}
+
+	// If port range changed, reallocate ports for existing servers
+	if portChanged {
+		if err := s.reallocatePorts(ctx, serverDefaults.DefaultPort); err != nil {
+			logging.LogWarning("Failed to reallocate ports: %v", err)
+			// Don't fail the settings update if port reallocation fails
+		}
+	}
+
+	// Apply new memory limits and restart policies to existing containers only if needed
+	if containerRecreationNeeded {
+		logMessage := "Container recreation needed due to changes in:"
+		if memoryChanged {
+			logMessage += fmt.Sprintf(" memory limit (%d -> %d MB)", oldDefaults.MaxMemoryMB, serverDefaults.MaxMemoryMB)
+		}
+		if restartPolicyChanged {
+			logMessage += fmt.Sprintf(" restart policy (%t -> %t)", oldDefaults.RestartOnFailure, serverDefaults.RestartOnFailure)
+		}
+		if portChanged {
+			logMessage += fmt.Sprintf(" default port (%d -> %d)", oldDefaults.DefaultPort, serverDefaults.DefaultPort)
+		}
+		logging.LogInfo(logMessage)
+
+		if err := s.applySettingsToExistingContainers(ctx, serverDefaults); err != nil {
+			logging.LogWarning("Failed to apply some settings to existing containers: %v", err)
+			// Don't fail the settings update if container updates fail
+		}
+	} else {
+		logging.LogInfo("No container recreation needed - only AutoStart setting changed")
+	}
+
+	return containerRecreationNeeded, nil
+}
+
+// reallocatePorts reallocates ports for all configured servers starting from the new default port
+func (s *Service) reallocatePorts(ctx context.Context, newDefaultPort int) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	configuredServers := s.configManager.GetConfiguredServers()
+	if len(configuredServers) == 0 {
+		return nil // No servers to update
+	}
+
+	// Create a map to track new port assignments
+	usedPorts := make(map[int]bool)
+	nextPort := newDefaultPort
+
+	// Reallocate ports for each configured server
+	for _, server := range configuredServers {
+		// Stdio servers have no port
+		if server.Transport == config.TransportStdio {
+			continue
+		}
+
+		// Find next available port
+		for usedPorts[nextPort] {
+			nextPort++
+			if nextPort > 65535 {
+				return fmt.Errorf("no available ports in valid range")
+			}
+		}
+
+		oldPort := server.Port
+		server.Port = nextPort
+		usedPorts[nextPort] = true
+
+		// Update the configured server in the database
+		if err := s.configManager.AddOrUpdateConfiguredServer(server); err != nil {
+			return fmt.Errorf("failed to update configured server %s: %w", server.ID, err)
+		}
+
+		// Update the actual Docker container port mapping if it exists and is running
+		if s.dockerService != nil && server.ContainerID != "" {
+			if err := s.updateContainerPort(ctx, server.ContainerID, oldPort, nextPort); err != nil {
+				logging.LogWarning("Failed to update container port for %s: %v", server.ContainerID, err)
+				// Continue with other servers even if one fails
+			}
+		}
+
+		nextPort++
+		logging.LogInfo("Reallocated port for server %s: %d -> %d", server.Name, oldPort, server.Port)
+	}
+
+	return nil
+}
+
+// updateContainerPort updates the port mapping for an existing container by recreating it
+func (s *Service) updateContainerPort(ctx context.Context, containerID string, oldPort, newPort int) error {
+	if oldPort == newPort {
+		return nil // No change needed
+	}
+
+	if s.dockerService == nil {
+		return fmt.Errorf("docker service not available")
+	}
+
+	// Get container information first
+	containers, err := s.dockerService.GetManagedContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container info: %w", err)
+	}
+
+	var containerInfo *docker.ContainerInfo
+	for _, container := range containers {
+		if container.ID == containerID || strings.HasPrefix(container.ID, containerID) {
+			containerInfo = &container
+			break
+		}
+	}
+
+	if containerInfo == nil {
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	// Check if container is running - only recreate if it's running
+	if containerInfo.State != "running" {
+		logging.LogInfo("Container %s is not running (%s), port change will apply on next start", containerID, containerInfo.State)
+		return nil
+	}
+
+	logging.LogInfo("Recreating container %s to change port mapping: %d -> %d", containerID, oldPort, newPort)
+
+	// Get server defaults for recreation
+	serverDefaults, err := s.GetServerDefaults()
+	if err != nil {
+		return fmt.Errorf("failed to get server defaults: %w", err)
+	}
+
+	// Stop the container
+	logging.LogInfo("Stopping container %s for port change...", containerID)
+	if err := s.dockerService.StopContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	// Get the configured server info to recreate the container
+	configuredServers := s.configManager.GetConfiguredServers()
+	var configuredServer *config.ConfiguredServer
+	for _, server := range configuredServers {
+		if server.ContainerID == containerID || strings.HasPrefix(server.ContainerID, containerID) {
+			configuredServer = &server
+			break
+		}
+	}
+
+	if configuredServer == nil {
+		return fmt.Errorf("configured server info not found for container %s", containerID)
+	}
+
+	// Remove the old container
+	logging.LogInfo("Removing old container %s...", containerID)
+	if err := s.dockerService.RemoveContainer(ctx, containerID, true); err != nil {
+		return fmt.Errorf("failed to remove old container: %w", err)
+	}
+
+	// Create new container with updated port
+	newConfig := docker.ContainerCreateConfig{
+		Name:          containerInfo.Name,
+		Image:         containerInfo.Image,
+		Port:          newPort,                        // Use the new port
+		ContainerPort: configuredServer.ContainerPort, // Use stored container port
+		Environment:   configuredServer.Environment,
+		Volumes:       convertVolumesToMap(containerInfo.Volumes),
+		Labels:        containerInfo.Labels,
+		DockerCommand: "", // We don't store the original docker command
+		MemoryLimitMB: serverDefaults.MaxMemoryMB,
+		OpenStdin:     configuredServer.Transport == config.TransportStdio,
+		RestartPolicy: func() string {
+			if serverDefaults.RestartOnFailure {
+				return "on-failure"
+			}
+			return "no"
+		}(),
+	}
+
+	logging.LogInfo("Creating new container with host port %d and container port %d", newPort, newConfig.ContainerPort)
+
+	newContainerID, err := s.dockerService.CreateContainer(ctx, newConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create new container with new port: %w", err)
+	}
+
+	// Update the configured server with the new container ID
+	configuredServer.ContainerID = newContainerID
+	if err := s.configManager.AddOrUpdateConfiguredServer(*configuredServer); err != nil {
+		logging.LogWarning("Failed to update configured server with new container ID: %v", err)
+	}
+
+	// Start the new container
+	logging.LogInfo("Starting new container %s with port %d...", newContainerID, newPort)
+	if err := s.dockerService.StartContainer(ctx, newContainerID); err != nil {
+		return fmt.Errorf("failed to start new container: %w", err)
+	}
+
+	logging.LogInfo("Successfully recreated container with new port: %s -> %s (port %d -> %d)",
+		containerID, newContainerID, oldPort, newPort)
+	return nil
+}
+
+// applySettingsToExistingContainers applies new server defaults to existing containers
+func (s *Service) applySettingsToExistingContainers(ctx context.Context, serverDefaults config.ServerDefaultsConfig) error {
+	if s.configManager == nil || s.dockerService == nil {
+		return fmt.Errorf("configuration manager or docker service not available")
+	}
+
+	configuredServers := s.configManager.GetConfiguredServers()
+	if len(configuredServers) == 0 {
+		return nil // No servers to update
+	}
+
+	for _, server := range configuredServers {
+		if server.ContainerID == "" {
+			continue // Skip servers without container IDs
+		}
+
+		// Try to update container settings
+		if err := s.updateContainerSettings(ctx, server.ContainerID, serverDefaults); err != nil {
+			logging.LogWarning("Failed to update settings for container %s (%s): %v",
+				server.ContainerID, server.Name, err)
+			// Continue with other containers
+		} else {
+			logging.LogInfo("Updated settings for container %s (%s)", server.ContainerID, server.Name)
+		}
+	}
+
+	return nil
+}
+
+// updateContainerSettings updates memory and restart policy for an existing container by recreating it
+func (s *Service) updateContainerSettings(ctx context.Context, containerID string, serverDefaults config.ServerDefaultsConfig) error {
+	if s.dockerService == nil {
+		return fmt.Errorf("docker service not available")
+	}
+
+	// Get container information first
+	containers, err := s.dockerService.GetManagedContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container info: %w", err)
+	}
+
+	var containerInfo *docker.ContainerInfo
+	for _, container := range containers {
+		if container.ID == containerID || strings.HasPrefix(container.ID, containerID) || strings.HasPrefix(containerID, container.ID) {
+			containerInfo = &container
+			break
+		}
+	}
+
+	if containerInfo == nil {
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	// Check if container is running - only recreate if it's running
+	if containerInfo.State != "running" {
+		logging.LogInfo("Container %s is not running (%s), skipping recreation", containerID, containerInfo.State)
+		return nil
+	}
+
+	logging.LogInfo("Recreating container %s to apply new settings...", containerID)
+
+	// Stop the container
+	logging.LogInfo("Stopping container %s...", containerID)
+	if err := s.dockerService.StopContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	// Get the configured server info to recreate the container
+	configuredServers := s.configManager.GetConfiguredServers()
+	var configuredServer *config.ConfiguredServer
+	for _, server := range configuredServers {
+		if server.ContainerID == containerID || strings.HasPrefix(server.ContainerID, containerID) {
+			configuredServer = &server
+			break
+		}
+	}
+
+	if configuredServer == nil {
+		return fmt.Errorf("configured server info not found for container %s", containerID)
+	}
+
+	// Remove the old container
+	logging.LogInfo("Removing old container %s...", containerID)
+	if err := s.dockerService.RemoveContainer(ctx, containerID, true); err != nil {
+		return fmt.Errorf("failed to remove old container: %w", err)
+	}
+
+	// Create new container with updated settings
+	newConfig := docker.ContainerCreateConfig{
+		Name:          containerInfo.Name,
+		Image:         containerInfo.Image,
+		Port:          configuredServer.Port,          // Use the potentially updated port
+		ContainerPort: configuredServer.ContainerPort, // Use stored container port
+		Environment:   configuredServer.Environment,
+		Volumes:       convertVolumesToMap(containerInfo.Volumes),
+		Labels:        containerInfo.Labels,
+		DockerCommand: "", // We don't store the original docker command
+		MemoryLimitMB: serverDefaults.MaxMemoryMB,
+		OpenStdin:     configuredServer.Transport == config.TransportStdio,
+		RestartPolicy: func() string {
+			if serverDefaults.RestartOnFailure {
+				return "on-failure"
+			}
+			return "no"
+		}(),
+	}
+
+	logging.LogInfo("Creating new container with updated settings: Memory=%dMB, RestartPolicy=%s, HostPort=%d, ContainerPort=%d",
+		newConfig.MemoryLimitMB, newConfig.RestartPolicy, newConfig.Port, newConfig.ContainerPort)
+
+	newContainerID, err := s.dockerService.CreateContainer(ctx, newConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create new container: %w", err)
+	}
+
+	// Update the configured server with the new container ID
+	configuredServer.ContainerID = newContainerID
+	if err := s.configManager.AddOrUpdateConfiguredServer(*configuredServer); err != nil {
+		logging.LogWarning("Failed to update configured server with new container ID: %v", err)
+	}
+
+	// Start the new container (respecting auto-start setting would have been applied during original creation)
+	logging.LogInfo("Starting new container %s...", newContainerID)
+	if err := s.dockerService.StartContainer(ctx, newContainerID); err != nil {
+		return fmt.Errorf("failed to start new container: %w", err)
+	}
+
+	logging.LogInfo("Successfully recreated container: %s -> %s", containerID, newContainerID)
+	return nil
+}
+
+// convertVolumesToMap converts volume slice to map format expected by ContainerCreateConfig
+func convertVolumesToMap(volumes []string) map[string]string {
+	volumeMap := make(map[string]string)
+	for _, volume := range volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) >= 2 {
+			volumeMap[parts[0]] = parts[1]
+		}
+	}
+	return volumeMap
+}
+
+// GetAppConfig returns the current app configuration
+func (s *Service) GetAppConfig() (*config.AppConfig, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return nil, fmt.Errorf("no configuration available")
+	}
+
+	// Create a copy and update the autostart status with the actual OS-level status
+	appConfig := config.App
+	appConfig.AutoStart = s.isAutostartEnabled()
+
+	return &appConfig, nil
+}
+
+// UpdateAppConfig updates the app configuration
+func (s *Service) UpdateAppConfig(appConfig config.AppConfig) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("no configuration available")
+	}
+
+	// Check if autostart setting changed and handle it
+	oldConfig := config.App
+	if oldConfig.AutoStart != appConfig.AutoStart {
+		if err := s.handleAutostartChange(appConfig.AutoStart); err != nil {
+			logging.LogWarning("Failed to update autostart setting: %v", err)
+			// Don't fail the entire config update if autostart fails
+		}
+	}
+
+	// Check if debug mode changed and update logger
+	if oldConfig.DebugMode != appConfig.DebugMode {
+		logging.SetDebugMode(appConfig.DebugMode)
+	}
+
+	config.App = appConfig
+
+	// Save configuration
+	return s.configManager.Save()
+}
+
+// handleAutostartChange manages the OS-level autostart setting
+func (s *Service) handleAutostartChange(enable bool) error {
+	app := &autostart.App{
+		Name:        "Neobelt",
+		DisplayName: "Neobelt - MCP Server Manager",
+		Exec:        []string{getExecutablePath()},
+	}
+
+	if enable {
+		logging.LogInfo("Enabling autostart for Neobelt...")
+		return app.Enable()
+	} else {
+		logging.LogInfo("Disabling autostart for Neobelt...")
+		return app.Disable()
+	}
+}
+
+// getExecutablePath returns the path to the current executable
+func getExecutablePath() string {
+	exec, err := os.Executable()
+	if err != nil {
+		logging.LogWarning("Could not get executable path: %v", err)
+		return "neobelt" // fallback
+	}
+
+	// Get absolute path
+	absPath, err := filepath.Abs(exec)
+	if err != nil {
+		logging.LogWarning("Could not get absolute path: %v", err)
+		return exec
+	}
+
+	return absPath
+}
+
+// isAutostartEnabled checks if autostart is currently enabled at OS level
+func (s *Service) isAutostartEnabled() bool {
+	app := &autostart.App{
+		Name:        "Neobelt",
+		DisplayName: "Neobelt - MCP Server Manager",
+		Exec:        []string{getExecutablePath()},
+	}
+
+	return app.IsEnabled()
+}
+
+// GetRemoteAccess returns the current remote access configuration
+func (s *Service) GetRemoteAccess() (*config.RemoteAccessConfig, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	cfg := s.configManager.GetConfig()
+	if cfg == nil {
+		return &config.RemoteAccessConfig{
+			RemoteServer: "remote.neobelt.io",
+			Username:     "",
+			PrivateKey:   "",
+			PublicKey:    "",
+			KeyGenerated: false,
+		}, nil
+	}
+
+	return &cfg.RemoteAccess, nil
+}
+
+// UpdateRemoteAccess updates the remote access configuration
+func (s *Service) UpdateRemoteAccess(remoteAccess config.RemoteAccessConfig) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("no configuration loaded")
+	}
+
+	config.RemoteAccess = remoteAccess
+	return s.configManager.Save()
+}
+
+// GetClaudeIntegration returns the current Claude integration configuration
+func (s *Service) GetClaudeIntegration() (*config.ClaudeIntegrationConfig, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+	cfg := s.configManager.GetConfig()
+	if cfg == nil {
+		return &config.ClaudeIntegrationConfig{
+			Enabled:    false,
+			ConfigPath: "",
+		}, nil
+	}
+	return &cfg.ClaudeIntegration, nil
+}
+
+// UpdateClaudeIntegration updates the Claude integration configuration
+func (s *Service) UpdateClaudeIntegration(claudeIntegration config.ClaudeIntegrationConfig) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("no configuration loaded")
+	}
+
+	// Check if integration is being disabled
+	wasEnabled := config.ClaudeIntegration.Enabled
+	isBeingDisabled := wasEnabled && !claudeIntegration.Enabled
+
+	// Update the configuration
+	config.ClaudeIntegration = claudeIntegration
+
+	// If integration is being disabled, clean up all Neobelt-managed MCP servers
+	if isBeingDisabled {
+		logging.LogInfo("Claude integration is being disabled, cleaning up all Neobelt-managed MCP servers from Claude configuration")
+		s.RemoveAllNeobeltMCPServersFromClaude()
+	}
+
+	return s.configManager.Save()
+}
+
+// GenerateSSHKeys generates a new SSH key pair for remote access
+func (s *Service) GenerateSSHKeys() (*crypto.SSHKeyPair, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	// Generate new key pair
+	keyPair, err := crypto.GenerateSSHKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate SSH key pair: %w", err)
+	}
+
+	// Update configuration
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return nil, fmt.Errorf("no configuration loaded")
+	}
+
+	config.RemoteAccess.PrivateKey = keyPair.PrivateKey
+	config.RemoteAccess.PublicKey = keyPair.PublicKey
+	config.RemoteAccess.KeyGenerated = true
+
+	// Save configuration
+	if err := s.configManager.Save(); err != nil {
+		return nil, fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	return keyPair, nil
+}
+
+// GetSSHPublicKey returns the current SSH public key
+func (s *Service) GetSSHPublicKey() (string, error) {
+	if s.configManager == nil {
+		return "", fmt.Errorf("configuration manager not available")
+	}
+
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return "", fmt.Errorf("no configuration loaded")
+	}
+
+	// If we have a private key but no public key, derive it
+	if config.RemoteAccess.PrivateKey != "" && config.RemoteAccess.PublicKey == "" {
+		publicKey, err := crypto.GetPublicKeyFromPrivate(config.RemoteAccess.PrivateKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to derive public key from private key: %w", err)
+		}
+
+		// Update configuration with derived public key
+		config.RemoteAccess.PublicKey = publicKey
+		if err := s.configManager.Save(); err != nil {
+			return "", fmt.Errorf("failed to save derived public key: %w", err)
+		}
+
+		return publicKey, nil
+	}
+
+	return config.RemoteAccess.PublicKey, nil
+}
+
+// ListSecrets returns the names of the secrets in the encrypted secret store
+func (s *Service) ListSecrets() ([]string, error) {
+	store, err := crypto.NewSecretStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secret store: %w", err)
+	}
+	return store.List()
+}
+
+// SetSecret stores a secret that MCP proxy headers can reference as neobelt-secret:<name>
+func (s *Service) SetSecret(name, value string) error {
+	store, err := crypto.NewSecretStore()
+	if err != nil {
+		return fmt.Errorf("failed to open secret store: %w", err)
+	}
+
+	if err := store.Set(name, value); err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	logging.LogInfo("Stored secret '%s'", name)
+	return nil
+}
+
+// DeleteSecret removes a secret from the encrypted secret store
+func (s *Service) DeleteSecret(name string) error {
+	store, err := crypto.NewSecretStore()
+	if err != nil {
+		return fmt.Errorf("failed to open secret store: %w", err)
+	}
+
+	if err := store.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	logging.LogInfo("Deleted secret '%s'", name)
+	return nil
+}
+
+// ClearAllLogs removes all log files from the logs directory
+func (s *Service) ClearAllLogs() error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	logDir := s.configManager.GetLogDir()
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// Only remove .log files
+		if strings.HasSuffix(entry.Name(), ".log") {
+			logPath := filepath.Join(logDir, entry.Name())
+			if err := os.Remove(logPath); err != nil {
+				logging.LogWarning("Failed to remove log file %s: %v", logPath, err)
+			} else {
+				logging.LogInfo("Removed log file: %s", entry.Name())
+			}
+		}
+	}
+
+	return nil
+}
+
+// EncryptConfiguration returns the current configuration encrypted with a password, as
+// written by ExportConfiguration
+func (s *Service) EncryptConfiguration(password string) ([]byte, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+
+	// Get current configuration
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return nil, fmt.Errorf("no configuration available")
+	}
+
+	// Encrypt the configuration
+	encryptedData, err := crypto.EncryptConfiguration(config, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt configuration: %w", err)
+	}
+	return encryptedData, nil
+}
+
+// ImportConfiguration imports configuration from encrypted data
+func (s *Service) ImportConfiguration(encryptedData, password string) error {
+	if s.configManager == nil {
+		return fmt.Errorf("configuration manager not available")
+	}
+
+	if encryptedData == "" {
+		return fmt.Errorf("encrypted data cannot be empty")
+	}
+
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	// Decrypt configuration
+	config, err := crypto.DecryptConfiguration([]byte(encryptedData), password)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt configuration: %w", err)
+	}
+
+	// Validate configuration structure
+	if config == nil {
+		return fmt.Errorf("invalid configuration data")
+	}
+
+	// Backup current configuration
+	currentConfig := s.configManager.GetConfig()
+	if currentConfig != nil {
+		backupPath := s.configManager.GetConfigPath() + ".backup"
+		if backupData, err := json.MarshalIndent(currentConfig, "", "  "); err == nil {
+			os.WriteFile(backupPath, backupData, 0600)
+			logging.LogInfo("Current configuration backed up to: %s", backupPath)
+		}
+	}
+
+	// Update configuration manager with imported config
+	s.configManager.SetConfig(config)
+
+	// Save the imported configuration
+	if err := s.configManager.Save(); err != nil {
+		return fmt.Errorf("failed to save imported configuration: %w", err)
+	}
+
+	// Update logger settings if debug mode changed
+	if config.App.DebugMode != logging.GetDebugMode() {
+		logging.SetDebugMode(config.App.DebugMode)
+	}
+
+	logging.LogInfo("Configuration imported successfully")
+	return nil
+}
+
+// GetRecentLogMessages returns the most recent log messages for the dashboard
+func (s *Service) GetRecentLogMessages(count int) ([]logging.LogMessage, error) {
+	if count <= 0 {
+		count = 10 // Default to last 10 messages
+	}
+	if count > 100 {
+		count = 100 // Cap at 100 messages
+	}
+
+	return logging.GetRecentLogMessages(count), nil
+}
+
+// GetAuditRecords returns the audited tool calls matching the query, newest first
+func (s *Service) GetAuditRecords(query audit.Query) ([]audit.Record, error) {
+	if s.configManager == nil {
+		return nil, fmt.Errorf("configuration manager not available")
+	}
+
+	records, err := audit.Read(audit.Path(s.configManager.GetLogDir()), query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// ExportAuditRecords writes the audited tool calls matching the query as JSON lines or CSV and
+// returns how many were written
+func (s *Service) ExportAuditRecords(w io.Writer, query audit.Query, format string) (int, error) {
+	if s.configManager == nil {
+		return 0, fmt.Errorf("configuration manager not available")
+	}
+
+	records, err := audit.Read(audit.Path(s.configManager.GetLogDir()), query)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := audit.Export(w, records, format); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
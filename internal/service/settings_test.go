@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"neobelt/internal/config"
+	"neobelt/internal/docker"
+)
+
+// fakeConfigStore keeps the configuration in memory and counts how often it was saved
+type fakeConfigStore struct {
+	config  *config.Configuration
+	saves   int
+	saveErr error
+}
+
+func newFakeConfigStore() *fakeConfigStore {
+	return &fakeConfigStore{config: &config.Configuration{}}
+}
+
+func (f *fakeConfigStore) GetConfig() *config.Configuration    { return f.config }
+func (f *fakeConfigStore) SetConfig(cfg *config.Configuration) { f.config = cfg }
+func (f *fakeConfigStore) GetConfigPath() string               { return "/nonexistent/config.json" }
+func (f *fakeConfigStore) GetLogDir() string                   { return "/nonexistent/logs" }
+
+func (f *fakeConfigStore) Save() error {
+	f.saves++
+	return f.saveErr
+}
+
+func (f *fakeConfigStore) GetRegistries() []config.Registry { return f.config.Registries }
+
+func (f *fakeConfigStore) AddRegistry(registry config.Registry) error {
+	f.config.Registries = append(f.config.Registries, registry)
+	return f.Save()
+}
+
+func (f *fakeConfigStore) UpdateRegistry(oldURL string, registry config.Registry) error {
+	for i := range f.config.Registries {
+		if f.config.Registries[i].URL == oldURL {
+			f.config.Registries[i] = registry
+			return f.Save()
+		}
+	}
+	return fmt.Errorf("registry with URL %s not found", oldURL)
+}
+
+func (f *fakeConfigStore) RemoveRegistry(url string) error {
+	for i := range f.config.Registries {
+		if f.config.Registries[i].URL == url {
+			f.config.Registries = append(f.config.Registries[:i], f.config.Registries[i+1:]...)
+			return f.Save()
+		}
+	}
+	return fmt.Errorf("registry with URL %s not found", url)
+}
+
+func (f *fakeConfigStore) GetInstalledServers() []config.InstalledServer {
+	return f.config.InstalledServers
+}
+
+func (f *fakeConfigStore) AddOrUpdateInstalledServer(server config.InstalledServer) error {
+	for i := range f.config.InstalledServers {
+		if f.config.InstalledServers[i].ID == server.ID {
+			f.config.InstalledServers[i] = server
+			return f.Save()
+		}
+	}
+	f.config.InstalledServers = append(f.config.InstalledServers, server)
+	return f.Save()
+}
+
+func (f *fakeConfigStore) RemoveInstalledServer(serverID string) error {
+	for i := range f.config.InstalledServers {
+		if f.config.InstalledServers[i].ID == serverID {
+			f.config.InstalledServers = append(f.config.InstalledServers[:i], f.config.InstalledServers[i+1:]...)
+			return f.Save()
+		}
+	}
+	return fmt.Errorf("installed server with ID %s not found", serverID)
+}
+
+func (f *fakeConfigStore) GetConfiguredServers() []config.ConfiguredServer {
+	return f.config.ConfiguredServers
+}
+
+func (f *fakeConfigStore) FindConfiguredServer(idOrContainerName string) *config.ConfiguredServer {
+	for _, server := range f.config.ConfiguredServers {
+		if server.ID == idOrContainerName || server.ContainerName == idOrContainerName {
+			return &server
+		}
+	}
+	return nil
+}
+
+func (f *fakeConfigStore) AddOrUpdateConfiguredServer(server config.ConfiguredServer) error {
+	for i := range f.config.ConfiguredServers {
+		if f.config.ConfiguredServers[i].ID == server.ID {
+			f.config.ConfiguredServers[i] = server
+			return f.Save()
+		}
+	}
+	f.config.ConfiguredServers = append(f.config.ConfiguredServers, server)
+	return f.Save()
+}
+
+func (f *fakeConfigStore) RemoveConfiguredServer(serverID string) error {
+	for i := range f.config.ConfiguredServers {
+		if f.config.ConfiguredServers[i].ID == serverID {
+			f.config.ConfiguredServers = append(f.config.ConfiguredServers[:i], f.config.ConfiguredServers[i+1:]...)
+			return f.Save()
+		}
+	}
+	return fmt.Errorf("configured server with ID %s not found", serverID)
+}
+
+func TestServerDefaultsRoundTrip(t *testing.T) {
+	store := newFakeConfigStore()
+	store.config.ServerDefaults = config.ServerDefaultsConfig{DefaultPort: 9000, MaxMemoryMB: 512}
+	service := &Service{configManager: store}
+
+	// Only AutoStart changes, which the containers don't care about
+	defaults := config.ServerDefaultsConfig{AutoStart: true, DefaultPort: 9000, MaxMemoryMB: 512, HealthCheckFailures: 5}
+	recreated, err := service.UpdateServerDefaults(context.Background(), defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated {
+		t.Error("expected no containers to be recreated")
+	}
+	if store.saves != 1 {
+		t.Errorf("expected the configuration to be saved once, got %d", store.saves)
+	}
+
+	got, err := service.GetServerDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != defaults {
+		t.Errorf("expected the defaults %+v, got %+v", defaults, *got)
+	}
+}
+
+func TestRemoteAccessAndClaudeIntegrationRoundTrip(t *testing.T) {
+	store := newFakeConfigStore()
+	service := &Service{configManager: store}
+
+	remoteAccess := config.RemoteAccessConfig{RemoteServer: "remote.example.com", Username: "me", KeyGenerated: true}
+	if err := service.UpdateRemoteAccess(remoteAccess); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := service.GetRemoteAccess(); err != nil || *got != remoteAccess {
+		t.Errorf("expected the remote access %+v, got %+v (%v)", remoteAccess, got, err)
+	}
+
+	claudeIntegration := config.ClaudeIntegrationConfig{Enabled: true, ConfigPath: "/nonexistent/claude_desktop_config.json"}
+	if err := service.UpdateClaudeIntegration(claudeIntegration); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := service.GetClaudeIntegration(); err != nil || *got != claudeIntegration {
+		t.Errorf("expected the Claude integration %+v, got %+v (%v)", claudeIntegration, got, err)
+	}
+	if store.saves != 2 {
+		t.Errorf("expected every update to be saved, got %d saves", store.saves)
+	}
+
+	// A failed save is reported
+	store.saveErr = errors.New("disk full")
+	if err := service.UpdateRemoteAccess(remoteAccess); !errors.Is(err, store.saveErr) {
+		t.Errorf("expected the save error, got %v", err)
+	}
+}
+
+func TestSettingsWithoutConfiguration(t *testing.T) {
+	service := New(nil)
+
+	if _, err := service.GetServerDefaults(); err == nil {
+		t.Error("expected GetServerDefaults to fail without a configuration manager")
+	}
+	if err := service.UpdateRemoteAccess(config.RemoteAccessConfig{}); err == nil {
+		t.Error("expected UpdateRemoteAccess to fail without a configuration manager")
+	}
+	if registries := service.GetRegistries(); len(registries) != 1 || registries[0].Name != "Official Registry" {
+		t.Errorf("expected only the official registry, got %+v", registries)
+	}
+}
+
+func TestGetRegistriesPutsOfficialRegistryFirst(t *testing.T) {
+	store := newFakeConfigStore()
+	store.config.Registries = []config.Registry{
+		{Name: "Team", URL: "https://team.example.com/registry.json"},
+		{Name: "Lab", URL: "https://lab.example.com/registry.json"},
+	}
+	service := &Service{configManager: store}
+
+	registries := service.GetRegistries()
+	var names []string
+	for _, registry := range registries {
+		names = append(names, registry.Name)
+	}
+	if len(names) != 3 || names[0] != "Official Registry" || names[1] != "Team" || names[2] != "Lab" {
+		t.Errorf("expected the official registry before the custom ones, got %v", names)
+	}
+}
+
+func TestEnrichContainersMergesConfiguredServers(t *testing.T) {
+	store := newFakeConfigStore()
+	store.config.ConfiguredServers = []config.ConfiguredServer{
+		{ID: "jira-1", Name: "Jira", Version: "1.2.0", ContainerID: "0123456789abcdef0123456789abcdef"},
+		{ID: "notes-1", Name: "Notes", Version: "0.3.1", ContainerID: "fedcba987654"},
+		{ID: "stopped-1", Name: "Stopped", Version: "2.0.0"},
+	}
+	service := &Service{configManager: store}
+
+	// Docker lists short IDs for some containers and full ones for others
+	containers := []docker.ContainerInfo{
+		{ID: "0123456789ab", Name: "neobelt-jira"},
+		{ID: "fedcba9876543210fedcba9876543210", Name: "neobelt-notes"},
+		{ID: "aaaaaaaaaaaa", Name: "neobelt-manual"},
+	}
+	service.enrichContainers(containers)
+
+	want := []struct{ displayName, version string }{
+		{"Jira", "1.2.0"},
+		{"Notes", "0.3.1"},
+		{"neobelt-manual", "unknown"},
+	}
+	for i, container := range containers {
+		if container.DisplayName != want[i].displayName || container.Version != want[i].version {
+			t.Errorf("container %s: expected %s %s, got %s %s", container.Name, want[i].displayName, want[i].version, container.DisplayName, container.Version)
+		}
+	}
+}
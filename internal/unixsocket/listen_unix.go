@@ -0,0 +1,24 @@
+//go:build !windows
+
+package unixsocket
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// umaskMutex keeps concurrent calls from restoring each other's umask
+var umaskMutex sync.Mutex
+
+// Listen listens on a Unix socket that only the current user can connect to. The socket is
+// created with these permissions, there is no moment in which others could connect to it.
+func Listen(path string) (net.Listener, error) {
+	umaskMutex.Lock()
+	defer umaskMutex.Unlock()
+
+	previous := syscall.Umask(0177)
+	defer syscall.Umask(previous)
+
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,9 @@
+package unixsocket
+
+import "net"
+
+// Listen listens on a Unix socket. Windows has no file modes for sockets, the socket file
+// inherits the ACL of the user's config directory.
+func Listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
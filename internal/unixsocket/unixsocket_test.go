@@ -0,0 +1,26 @@
+//go:build !windows
+
+package unixsocket
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenRestrictsSocketToUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("socket was created with mode %o, want 600", mode)
+	}
+}
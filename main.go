@@ -1,17 +1,15 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"embed"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/wailsapp/wails/v2"
@@ -19,50 +17,18 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 	"github.com/wailsapp/wails/v2/pkg/options/mac"
 	"neobelt/internal/app"
+	"neobelt/internal/approval"
+	"neobelt/internal/audit"
+	"neobelt/internal/config"
+	"neobelt/internal/crypto"
+	"neobelt/internal/docker"
+	"neobelt/internal/logging"
+	"neobelt/internal/mcp"
 )
 
 //go:embed all:frontend/dist
 var assets embed.FS
 
-// JSON-RPC 2.0 message structures
-type JSONRPCMessage struct {
-	JSONRPC string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id,omitempty"`
-	Method  string          `json:"method,omitempty"`
-	Params  json.RawMessage `json:"params,omitempty"`
-	Result  json.RawMessage `json:"result,omitempty"`
-	Error   *JSONRPCError   `json:"error,omitempty"`
-}
-
-type JSONRPCError struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-// MCPProxy handles the stdio <-> HTTP bridging
-type MCPProxy struct {
-	targetURL   string
-	headers     map[string]string
-	httpClient  *http.Client
-}
-
-func NewMCPProxy(targetURL string, headersList []string) *MCPProxy {
-	headers := make(map[string]string)
-	for _, header := range headersList {
-		parts := strings.SplitN(header, ":", 2)
-		if len(parts) == 2 {
-			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-		}
-	}
-	
-	return &MCPProxy{
-		targetURL:  targetURL,
-		headers:    headers,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
-	}
-}
-
 func main() {
 	// Check if CLI arguments are provided
 	if len(os.Args) > 1 {
@@ -100,20 +66,82 @@ func main() {
 	}
 }
 
-type headerFlag []string
+// stringsFlag collects the values of a flag given multiple times
+type stringsFlag []string
 
-func (h *headerFlag) String() string {
-	return strings.Join(*h, ",")
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
 }
 
-func (h *headerFlag) Set(value string) error {
-	*h = append(*h, value)
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// timeoutFlag collects request timeouts given as "duration" (all methods) or "method=duration"
+type timeoutFlag map[string]time.Duration
+
+func (t timeoutFlag) String() string {
+	var parts []string
+	for method, timeout := range t {
+		parts = append(parts, method+"="+timeout.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (t timeoutFlag) Set(value string) error {
+	method, durationValue, found := strings.Cut(value, "=")
+	if !found {
+		method, durationValue = "*", value
+	}
+
+	timeout, err := time.ParseDuration(durationValue)
+	if err != nil {
+		return fmt.Errorf("invalid timeout %q: %w", value, err)
+	}
+	t[method] = timeout
 	return nil
 }
 
 func runCLI() {
-	var headers headerFlag
+	if os.Args[1] == "secret" {
+		runSecretCommand(os.Args[2:])
+		return
+	}
+	if os.Args[1] == "approve" {
+		runApproveCommand()
+		return
+	}
+	if os.Args[1] == "audit" {
+		runAuditCommand(os.Args[2:])
+		return
+	}
+	if runServerCommand(os.Args[1], os.Args[2:]) {
+		return
+	}
+
+	var headers stringsFlag
 	var mcpProxy bool
+	var maxConcurrency int
+	var transport string
+	var mcpServe bool
+	var port int
+	var host string
+	var sessionTimeout time.Duration
+	var oauth bool
+	var oauthClientID string
+	var maxMessageSize int
+	var recordFile string
+	var mcpReplay bool
+	var mcpGateway bool
+	var mcpAttach bool
+	var serveHTTP bool
+	var serverName string
+	var allowTools stringsFlag
+	var denyTools stringsFlag
+	var approveTools stringsFlag
+	var approvalTimeout time.Duration
+	timeouts := timeoutFlag{}
 	
 	// Create a new flag set for CLI commands
 	cliFlags := flag.NewFlagSet("neobelt", flag.ExitOnError)
@@ -121,6 +149,26 @@ func runCLI() {
 	cliFlags.BoolVar(&mcpProxy, "mcp-proxy", false, "Start MCP proxy server")
 	cliFlags.Var(&headers, "h", "Add HTTP header (can be used multiple times)")
 	cliFlags.Var(&headers, "header", "Add HTTP header (can be used multiple times)")
+	cliFlags.IntVar(&maxConcurrency, "max-concurrency", mcp.DefaultMaxConcurrency, "Maximum number of concurrent MCP proxy requests")
+	cliFlags.StringVar(&transport, "transport", mcp.TransportAuto, "MCP transport: auto, streamable-http or sse")
+	cliFlags.IntVar(&maxMessageSize, "max-message-size", 0, "Maximum size of a message from the MCP client in bytes (0 for no limit)")
+	cliFlags.Var(timeouts, "timeout", "Request inactivity timeout as DURATION or METHOD=DURATION, 0 for none (can be used multiple times, default 30s and no limit for tools/call)")
+	cliFlags.StringVar(&recordFile, "record", "", "Record all MCP proxy traffic as JSON lines to this file")
+	cliFlags.StringVar(&serverName, "server", "", "Apply the tool policy of this configured server (ID or container name)")
+	cliFlags.Var(&allowTools, "allow-tool", "Only allow tools matching this glob pattern (can be used multiple times)")
+	cliFlags.Var(&denyTools, "deny-tool", "Deny tools matching this glob pattern (can be used multiple times)")
+	cliFlags.Var(&approveTools, "require-approval", "Ask before running tools matching this glob pattern (can be used multiple times)")
+	cliFlags.DurationVar(&approvalTimeout, "approval-timeout", approval.DefaultTimeout, "How long a tool call waits for approval before it is denied")
+	cliFlags.BoolVar(&mcpReplay, "mcp-replay", false, "Act as a fake MCP server replaying a recording on stdio")
+	cliFlags.BoolVar(&oauth, "oauth", false, "Authorize against the MCP server with OAuth")
+	cliFlags.StringVar(&oauthClientID, "oauth-client-id", "", "Pre-registered OAuth client ID (default: dynamic client registration)")
+	cliFlags.BoolVar(&mcpServe, "mcp-serve", false, "Serve a stdio MCP server as a Streamable HTTP endpoint")
+	cliFlags.BoolVar(&mcpGateway, "mcp-gateway", false, "Serve all running MCP servers as a single MCP server on stdio")
+	cliFlags.BoolVar(&mcpAttach, "mcp-attach", false, "Connect stdio to a session of a stdio MCP server container")
+	cliFlags.BoolVar(&serveHTTP, "http", false, "Serve the MCP gateway as a Streamable HTTP endpoint instead of stdio")
+	cliFlags.IntVar(&port, "port", 8080, "Port to serve the MCP endpoint on")
+	cliFlags.StringVar(&host, "host", "127.0.0.1", "Interface to serve the MCP endpoint on")
+	cliFlags.DurationVar(&sessionTimeout, "session-timeout", mcp.DefaultServeSessionTimeout, "Idle time after which a session's server process is stopped")
 	
 	// Parse CLI arguments (skip program name)
 	cliFlags.Parse(os.Args[1:])
@@ -133,171 +181,410 @@ func runCLI() {
 			os.Exit(1)
 		}
 		
+		switch transport {
+		case mcp.TransportAuto, mcp.TransportStreamableHTTP, mcp.TransportSSE:
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown transport %q (expected auto, streamable-http or sse)\n", transport)
+			os.Exit(1)
+		}
+
+		toolPolicy, err := loadToolPolicy(serverName, allowTools, denyTools, approveTools)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		targetURL := args[0]
-		startMCPProxy(targetURL, headers)
+		startMCPProxy(targetURL, headers, mcp.ProxyOptions{
+			MaxConcurrency:  maxConcurrency,
+			Transport:       transport,
+			OAuth:           oauth || oauthClientID != "",
+			OAuthClientID:   oauthClientID,
+			MaxMessageSize:  maxMessageSize,
+			RequestTimeouts: timeouts,
+			RecordFile:      recordFile,
+			ToolPolicy:      toolPolicy,
+			ServerName:      serverName,
+			ApprovalTimeout: approvalTimeout,
+			AuditLog:        openAuditLog(),
+		})
+		return
+	}
+
+	if mcpReplay {
+		args := cliFlags.Args()
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: MCP replay requires a recording file")
+			fmt.Fprintln(os.Stderr, "Usage: neobelt --mcp-replay traffic.jsonl")
+			os.Exit(1)
+		}
+
+		startMCPReplay(args[0])
+		return
+	}
+	
+	if mcpGateway {
+		if serveHTTP {
+			// Every HTTP session gets its own stdio gateway process
+			executable, err := os.Executable()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			command := []string{executable, "--mcp-gateway", "--approval-timeout", approvalTimeout.String()}
+			startMCPServe(command, mcp.ServeOptions{
+				Host:           host,
+				Port:           port,
+				SessionTimeout: sessionTimeout,
+			})
+			return
+		}
+
+		startMCPGateway(mcp.GatewayOptions{
+			ApprovalTimeout: approvalTimeout,
+			AuditLog:        openAuditLog(),
+		})
+		return
+	}
+
+	if mcpAttach {
+		args := cliFlags.Args()
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: MCP attach requires a container name")
+			fmt.Fprintln(os.Stderr, "Usage: neobelt --mcp-attach <container>")
+			os.Exit(1)
+		}
+
+		// The container is a configured server, whose tool policy applies like in the proxy
+		toolPolicy, err := loadToolPolicy(args[0], allowTools, denyTools, approveTools)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		startMCPAttach(args[0], mcp.ToolFilterOptions{
+			ToolPolicy:      toolPolicy,
+			ServerName:      args[0],
+			ApprovalTimeout: approvalTimeout,
+			AuditLog:        openAuditLog(),
+		})
+		return
+	}
+
+	if mcpServe {
+		command := cliFlags.Args()
+		if len(command) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: MCP serve requires a command to run")
+			fmt.Fprintln(os.Stderr, "Usage: neobelt --mcp-serve --port 8080 -- npx -y @modelcontextprotocol/server-everything")
+			os.Exit(1)
+		}
+
+		startMCPServe(command, mcp.ServeOptions{
+			Host:           host,
+			Port:           port,
+			SessionTimeout: sessionTimeout,
+		})
 		return
 	}
 	
 	// Show help if no recognized command
 	fmt.Fprintln(os.Stderr, "Neobelt CLI")
 	fmt.Fprintln(os.Stderr, "Usage:")
-	fmt.Fprintln(os.Stderr, "  neobelt --mcp-proxy -h \"Header: Value\" <target-url>")
+	fmt.Fprintln(os.Stderr, "  neobelt --mcp-proxy [--transport auto|streamable-http|sse] [--max-concurrency N] [--oauth [--oauth-client-id ID]] [--timeout [METHOD=]DURATION] [--max-message-size BYTES] [--record FILE] [--server NAME] [--allow-tool PATTERN] [--deny-tool PATTERN] [--require-approval PATTERN] [--approval-timeout DURATION] -h \"Header: Value\" <target-url>")
+	fmt.Fprintln(os.Stderr, "  neobelt --mcp-replay <recording-file>")
+	fmt.Fprintln(os.Stderr, "  neobelt --mcp-gateway [--approval-timeout DURATION] [--http [--host ADDR] [--port N] [--session-timeout DURATION]]")
+	fmt.Fprintln(os.Stderr, "  neobelt --mcp-attach [--allow-tool PATTERN] [--deny-tool PATTERN] [--require-approval PATTERN] [--approval-timeout DURATION] <container>")
+	fmt.Fprintln(os.Stderr, "  neobelt approve")
+	fmt.Fprintln(os.Stderr, "  neobelt audit [--since 24h|TIME] [--until TIME] [--server NAME] [--tool NAME] [--client NAME] [--status STATUS] [--limit N] [--format jsonl|csv] [--output FILE]")
+	fmt.Fprintln(os.Stderr, "  neobelt secret set|list|rm [name]")
+	fmt.Fprintln(os.Stderr, "  neobelt --mcp-serve [--host ADDR] [--port N] [--session-timeout DURATION] -- <command> [args...]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Header values can reference env:VAR, file:/path or neobelt-secret:<name>,")
+	fmt.Fprintln(os.Stderr, "either as the whole value or after Bearer, Basic or Token (\"Bearer neobelt-secret:token\").")
+	fmt.Fprintln(os.Stderr, "Secrets are only obfuscated at rest: their key is stored next to them, so anyone")
+	fmt.Fprintln(os.Stderr, "who can read your config directory can read them too.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Server management (all commands accept --json):")
+	fmt.Fprintln(os.Stderr, "  neobelt list")
+	fmt.Fprintln(os.Stderr, "  neobelt status [server...]")
+	fmt.Fprintln(os.Stderr, "  neobelt install [--registry NAME] <registry-server>")
+	fmt.Fprintln(os.Stderr, "  neobelt create [--name NAME] [--env KEY=VALUE] [--volume HOST:CONTAINER] [--session-containers] [--claude] <installed-server>")
+	fmt.Fprintln(os.Stderr, "  neobelt start|stop|restart <server...>")
+	fmt.Fprintln(os.Stderr, "  neobelt rm [--force] <server...>")
+	fmt.Fprintln(os.Stderr, "  neobelt logs [--lines N] <server>")
+	fmt.Fprintln(os.Stderr, "  neobelt registry add [--description TEXT] [--auth none|basic|header] [--username USER] <name> <https-url>")
+	fmt.Fprintln(os.Stderr, "  neobelt registry list")
+	fmt.Fprintln(os.Stderr, "  neobelt registry search [--registry NAME] [query]")
+	fmt.Fprintln(os.Stderr, "  neobelt export [--output FILE]")
+	fmt.Fprintln(os.Stderr, "  neobelt import <file|->")
+	fmt.Fprintln(os.Stderr, "  neobelt plan|apply [--file neobelt.yaml]")
+	fmt.Fprintln(os.Stderr, "Export and import read the password from NEOBELT_CONFIG_PASSWORD or stdin.")
+	fmt.Fprintln(os.Stderr, "Registry add reads the password or \"Name: Value\" header from NEOBELT_REGISTRY_SECRET or stdin.")
+	fmt.Fprintln(os.Stderr, "Exit codes: 1 error, 2 usage, 3 not found, 4 Docker not running, 5 server or daemon not running, or unhealthy.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Background daemon (server management commands use it when it is running):")
+	fmt.Fprintln(os.Stderr, "  neobelt daemon [--listen 127.0.0.1:PORT]")
+	fmt.Fprintln(os.Stderr, "  neobelt daemon start|status|stop [--json]")
 }
 
-// Start the MCP proxy server
-func (p *MCPProxy) Start(ctx context.Context) error {
-	scanner := bufio.NewScanner(os.Stdin)
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		
-		// Parse JSON-RPC message from stdin
-		var message JSONRPCMessage
-		if err := json.Unmarshal([]byte(line), &message); err != nil {
-			// Send error response to stdout
-			errorResponse := JSONRPCMessage{
-				JSONRPC: "2.0",
-				ID:      nil,
-				Error: &JSONRPCError{
-					Code:    -32700,
-					Message: "Parse error",
-					Data:    err.Error(),
-				},
-			}
-			p.sendResponse(errorResponse)
-			continue
-		}
-		
-		// Forward message to HTTP endpoint
-		response, err := p.forwardToHTTP(ctx, message)
+// loadToolPolicy combines the tool policy of a configured server with patterns from the command line
+func loadToolPolicy(serverName string, allowTools, denyTools, approveTools []string) (config.ToolPolicy, error) {
+	var policy config.ToolPolicy
+	if serverName != "" {
+		configManager, err := config.NewConfigManager()
 		if err != nil {
-			// Send error response to stdout
-			errorResponse := JSONRPCMessage{
-				JSONRPC: "2.0",
-				ID:      message.ID,
-				Error: &JSONRPCError{
-					Code:    -32603,
-					Message: "Internal error",
-					Data:    err.Error(),
-				},
-			}
-			p.sendResponse(errorResponse)
-			continue
+			return policy, err
 		}
-		
-		// Send response back to stdout
-		p.sendResponse(response)
+		server := configManager.FindConfiguredServer(serverName)
+		if server == nil {
+			return policy, fmt.Errorf("configured server %q not found", serverName)
+		}
+		policy = server.ToolPolicy
 	}
+
+	policy.Allow = append(policy.Allow, allowTools...)
+	policy.Deny = append(policy.Deny, denyTools...)
+	policy.RequireApproval = append(policy.RequireApproval, approveTools...)
+	return policy, policy.Validate()
+}
+
+func startMCPProxy(targetURL string, headers []string, options mcp.ProxyOptions) {
+	proxy := mcp.NewMCPProxy(targetURL, headers, options)
+	ctx := context.Background()
+	
+	// Log to stderr that proxy is starting (for debugging)
+	fmt.Fprintf(os.Stderr, "MCP Proxy starting, target URL: %s\n", targetURL)
 	
-	return scanner.Err()
+	if err := proxy.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Proxy error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func startMCPServe(command []string, options mcp.ServeOptions) {
+	server := mcp.NewStdioServer(command, options)
+
+	// Stop the child processes cleanly on Ctrl+C or termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Serve error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
-// Forward JSON-RPC message to HTTP endpoint
-func (p *MCPProxy) forwardToHTTP(ctx context.Context, message JSONRPCMessage) (JSONRPCMessage, error) {
-	// Serialize message
-	messageBytes, err := json.Marshal(message)
+func startMCPGateway(options mcp.GatewayOptions) {
+	// stdout carries the MCP protocol
+	logging.SetConsoleOutput(os.Stderr)
+
+	gateway, err := mcp.NewGateway(options)
 	if err != nil {
-		return JSONRPCMessage{}, fmt.Errorf("failed to marshal message: %w", err)
+		fmt.Fprintf(os.Stderr, "Gateway error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", p.targetURL, bytes.NewReader(messageBytes))
+
+	fmt.Fprintf(os.Stderr, "MCP Gateway starting\n")
+
+	if err := gateway.Start(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Gateway error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// startMCPAttach connects stdio to a session of a stdio server container, in the container or
+// in a session container of its own. Tool calls go through the server's tool policy.
+func startMCPAttach(containerName string, options mcp.ToolFilterOptions) {
+	// stdout carries the MCP protocol
+	logging.SetConsoleOutput(os.Stderr)
+
+	dockerService, err := docker.NewDockerService()
 	if err != nil {
-		return JSONRPCMessage{}, fmt.Errorf("failed to create request: %w", err)
+		fmt.Fprintf(os.Stderr, "Attach error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json, text/event-stream")
-	for key, value := range p.headers {
-		req.Header.Set(key, value)
+
+	// Ending the session on Ctrl+C or termination removes a session container
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	filter := mcp.NewToolFilter(options)
+	err = filter.Run(ctx, os.Stdin, os.Stdout, func(stdin io.Reader, stdout io.Writer) error {
+		return dockerService.AttachStdio(ctx, containerName, stdin, stdout, os.Stderr)
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Attach error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// Send request
-	resp, err := p.httpClient.Do(req)
+}
+
+func startMCPReplay(recordingFile string) {
+	replayer, err := mcp.NewReplayer(recordingFile)
 	if err != nil {
-		return JSONRPCMessage{}, fmt.Errorf("failed to send request: %w", err)
+		fmt.Fprintf(os.Stderr, "Replay error: %v\n", err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+
+	if err := replayer.Start(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Replay error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// openAuditLog opens the audit log in the log directory. Proxying works without it, so
+// failures are only reported.
+func openAuditLog() *audit.Log {
+	configManager, err := config.NewConfigManager()
 	if err != nil {
-		return JSONRPCMessage{}, fmt.Errorf("failed to read response: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: tool calls are not audited: %v\n", err)
+		return nil
 	}
-	
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "HTTP error %d: %s\n", resp.StatusCode, string(body))
-		return JSONRPCMessage{}, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, string(body))
+
+	auditLog, err := audit.Open(configManager.GetLogDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tool calls are not audited: %v\n", err)
+		return nil
 	}
-	
-	// Log response for debugging
-	fmt.Fprintf(os.Stderr, "Raw HTTP response: %s\n", string(body))
-	
-	// Parse JSON-RPC response
-	var response JSONRPCMessage
-	if err := json.Unmarshal(body, &response); err != nil {
-		// Try parsing as SSE format
-		fmt.Fprintf(os.Stderr, "Direct JSON parse failed, trying SSE format...\n")
-		jsonData, sseErr := parseSSEResponse(body)
-		if sseErr != nil {
-			fmt.Fprintf(os.Stderr, "SSE parse error: %v, Raw response: %s\n", sseErr, string(body))
-			return JSONRPCMessage{}, fmt.Errorf("failed to parse response as JSON or SSE: %w", err)
-		}
-		
-		// Try parsing the extracted JSON data
-		if err := json.Unmarshal(jsonData, &response); err != nil {
-			fmt.Fprintf(os.Stderr, "JSON parse error from SSE data: %v, Extracted JSON: %s\n", err, string(jsonData))
-			return JSONRPCMessage{}, fmt.Errorf("failed to parse extracted JSON from SSE: %w", err)
+	return auditLog
+}
+
+// runAuditCommand queries the audit log and prints or exports the matching records
+func runAuditCommand(args []string) {
+	var since, until, format, output string
+	var query audit.Query
+
+	auditFlags := flag.NewFlagSet("neobelt audit", flag.ExitOnError)
+	auditFlags.StringVar(&since, "since", "", "Only records since this time (RFC 3339) or duration ago (e.g. 24h)")
+	auditFlags.StringVar(&until, "until", "", "Only records until this time (RFC 3339) or duration ago")
+	auditFlags.StringVar(&query.Server, "server", "", "Only records of this server")
+	auditFlags.StringVar(&query.Tool, "tool", "", "Only records of this tool")
+	auditFlags.StringVar(&query.Client, "client", "", "Only records of this MCP client")
+	auditFlags.StringVar(&query.Status, "status", "", "Only records with this status (success, tool_error, error, rejected, denied, cancelled)")
+	auditFlags.IntVar(&query.Limit, "limit", 0, "Only the newest N records (0 for all)")
+	auditFlags.StringVar(&format, "format", audit.FormatJSONL, "Output format: jsonl or csv")
+	auditFlags.StringVar(&output, "output", "", "Write the records to this file instead of stdout")
+	auditFlags.Parse(args)
+
+	var err error
+	if query.Since, err = parseAuditTime(since); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --since: %v\n", err)
+		os.Exit(1)
+	}
+	if query.Until, err = parseAuditTime(until); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --until: %v\n", err)
+		os.Exit(1)
+	}
+
+	configManager, err := config.NewConfigManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	records, err := audit.Read(audit.Path(configManager.GetLogDir()), query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var writer io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create %s: %v\n", output, err)
+			os.Exit(1)
 		}
-		
-		fmt.Fprintf(os.Stderr, "Successfully parsed SSE response\n")
+		defer file.Close()
+		writer = file
+	}
+
+	if err := audit.Export(writer, records, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d audit records to %s\n", len(records), output)
 	}
-	
-	return response, nil
 }
 
-// parseSSEResponse extracts JSON data from Server-Sent Events format
-func parseSSEResponse(body []byte) ([]byte, error) {
-	bodyStr := string(body)
-	lines := strings.Split(bodyStr, "\n")
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "data: ") {
-			// Extract JSON from "data: {json...}" line
-			jsonData := strings.TrimPrefix(line, "data: ")
-			return []byte(jsonData), nil
-		}
+// parseAuditTime parses an RFC 3339 time or a duration before now, empty for no limit
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
 	}
-	
-	return nil, fmt.Errorf("no data field found in SSE response")
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
-// Send JSON-RPC response to stdout
-func (p *MCPProxy) sendResponse(message JSONRPCMessage) {
-	responseBytes, err := json.Marshal(message)
+// runApproveCommand answers approval requests of MCP proxies on this terminal, for running
+// without the Neobelt app
+func runApproveCommand() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, "Waiting for tool approval requests, press Ctrl+C to stop")
+
+	err := approval.Listen(ctx, func(ctx context.Context, request approval.Request) approval.Decision {
+		decision, err := approval.PromptTerminal(ctx, request)
+		if err != nil {
+			return approval.Decision{Approved: false, Reason: err.Error()}
+		}
+		return decision
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error marshaling response: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	
-	fmt.Println(string(responseBytes))
 }
 
-func startMCPProxy(targetURL string, headers []string) {
-	proxy := NewMCPProxy(targetURL, headers)
-	ctx := context.Background()
-	
-	// Log to stderr that proxy is starting (for debugging)
-	fmt.Fprintf(os.Stderr, "MCP Proxy starting, target URL: %s\n", targetURL)
-	
-	if err := proxy.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "Proxy error: %v\n", err)
+// runSecretCommand manages the encrypted secrets that proxy headers can reference
+func runSecretCommand(args []string) {
+	usage := "Usage: neobelt secret set <name> (value read from stdin) | list | rm <name>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	store, err := crypto.NewSecretStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case args[0] == "list":
+		names, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+
+	case args[0] == "set" && len(args) == 2:
+		// Read the value from stdin so it never shows up in the shell history or process list
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read secret: %v\n", err)
+			os.Exit(1)
+		}
+		if err := store.Set(args[1], strings.TrimRight(string(value), "\r\n")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Stored secret %s, reference it as neobelt-secret:%s\n", args[1], args[1])
+
+	case args[0] == "rm" && len(args) == 2:
+		if err := store.Delete(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
 }